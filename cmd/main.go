@@ -1,276 +1,1395 @@
 package main
 
 import (
-	"encoding/csv"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net/http"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
+	_ "time/tzdata"
 
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	_ "modernc.org/sqlite"
 
 	pkgcsv "reconciliation/pkg/csv"
 	"reconciliation/pkg/reconcile"
+	"reconciliation/pkg/s3"
+	"reconciliation/pkg/sqldb"
 	"reconciliation/pkg/types"
 )
 
+// logger is the logger for diagnostics, it writes to stderr to keep stdout for the result
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
 // rootCmd is the root command for the reconciliation tool
+// It runs the reconcile command when no subcommand is given
 var rootCmd = &cobra.Command{
-	Short: "A tool to reconcile system transactions with bank statements",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		systemFile, _ := cmd.Flags().GetString("system")
-		bankFile, _ := cmd.Flags().GetString("bank")
-		startDate, _ := cmd.Flags().GetString("start")
-		endDate, _ := cmd.Flags().GetString("end")
-		print, _ := cmd.Flags().GetBool("print")
+	Use:           "reconciliation",
+	Short:         "A tool to reconcile system transactions with bank statements",
+	RunE:          runReconcile,
+	SilenceErrors: true,
+}
 
-		// Validate required flags
-		if systemFile == "" {
-			return fmt.Errorf("system transaction file path is required")
-		}
-		if bankFile == "" {
-			return fmt.Errorf("at least one bank statement file path is required")
-		}
-		if startDate == "" || endDate == "" {
-			return fmt.Errorf("start and end dates are required")
+// reconcileCmd reconciles the system transactions with the bank statements
+var reconcileCmd = &cobra.Command{
+	Use:           "reconcile",
+	Short:         "Reconcile system transactions with bank statements",
+	RunE:          runReconcile,
+	SilenceErrors: true,
+}
+
+// validateCmd reads the input files and reports the files that cannot be read without reconciling them
+var validateCmd = &cobra.Command{
+	Use:           "validate",
+	Short:         "Check that the system transaction and bank statement files can be read",
+	RunE:          runValidate,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+// inputFiles are the input files and reading options shared by the reconcile and validate commands
+type inputFiles struct {
+	systemFiles []string
+	bankFiles   []string
+	start       time.Time
+	end         time.Time
+	opts        []reconcile.Option
+}
+
+// parseInputFlags applies the config file and reads the input flags of the command
+func parseInputFlags(cmd *cobra.Command) (inputFiles, error) {
+	// Fill the flags not given on the command line from the config file
+	configFile, _ := cmd.Flags().GetString("config")
+	if configFile != "" {
+		if err := applyConfig(cmd.Flags(), configFile); err != nil {
+			return inputFiles{}, err
 		}
+	}
+
+	systemFile, _ := cmd.Flags().GetString("system")
+	bankFile, _ := cmd.Flags().GetString("bank")
+	startDate, _ := cmd.Flags().GetString("start")
+	endDate, _ := cmd.Flags().GetString("end")
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	bankNameFromDir, _ := cmd.Flags().GetBool("bank-name-from-dir")
+	logFormat, _ := cmd.Flags().GetString("log-format")
+	directionColumn, _ := cmd.Flags().GetBool("bank-direction-column")
+	autoHeader, _ := cmd.Flags().GetBool("auto-header")
+	bankNameColumn, _ := cmd.Flags().GetInt("bank-name-column")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	rejectZeroAmounts, _ := cmd.Flags().GetBool("reject-zero-amounts")
+	flagFutureDates, _ := cmd.Flags().GetBool("flag-future-dates")
+	timezone, _ := cmd.Flags().GetString("timezone")
+	dateFormats, _ := cmd.Flags().GetStringSlice("date-formats")
+	epochDates, _ := cmd.Flags().GetBool("epoch-dates")
+	bankAmountScale, _ := cmd.Flags().GetFloat64("bank-amount-scale")
+	delimiter, _ := cmd.Flags().GetString("delimiter")
+	maxRows, _ := cmd.Flags().GetInt("max-rows")
+	sample, _ := cmd.Flags().GetInt("sample")
+	readRetries, _ := cmd.Flags().GetInt("read-retries")
+	mmap, _ := cmd.Flags().GetBool("mmap")
+	debitCreditColumns, _ := cmd.Flags().GetIntSlice("debit-credit-columns")
+	useSignedAmount, _ := cmd.Flags().GetBool("use-signed-amount")
+	rejectNegativeAmounts, _ := cmd.Flags().GetBool("reject-negative-amounts")
+	systemColumns, _ := cmd.Flags().GetString("system-columns")
+	trimFields, _ := cmd.Flags().GetBool("trim-fields")
+	bankColumns, _ := cmd.Flags().GetString("bank-columns")
+	systemDB, _ := cmd.Flags().GetString("system-db")
+	systemQuery, _ := cmd.Flags().GetString("system-query")
+	bankDB, _ := cmd.Flags().GetString("bank-db")
+	bankQuery, _ := cmd.Flags().GetString("bank-query")
+	dbDriver, _ := cmd.Flags().GetString("db-driver")
+
+	// Configure the logger
+	l, err := newLogger(logFormat, os.Stderr)
+	if err != nil {
+		return inputFiles{}, err
+	}
+	logger = l
+
+	// Validate required flags
+	if systemFile == "" && systemDB == "" {
+		return inputFiles{}, fmt.Errorf("system transaction file path is required")
+	}
+	if bankFile == "" && bankDB == "" {
+		return inputFiles{}, fmt.Errorf("at least one bank statement file path is required")
+	}
+	if startDate == "" || endDate == "" {
+		return inputFiles{}, fmt.Errorf("start and end dates are required")
+	}
 
-		// Parse dates
-		start, err := time.Parse("2006-01-02", startDate)
+	// Load the timezone the dates are interpreted in
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return inputFiles{}, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+
+	// Parse dates
+	start, _, err := parseDateTime(startDate, location)
+	if err != nil {
+		return inputFiles{}, fmt.Errorf("invalid start date format. Use YYYY-MM-DD or YYYY-MM-DD HH:MM:SS")
+	}
+	end, endTimeOfDay, err := parseDateTime(endDate, location)
+	if err != nil {
+		return inputFiles{}, fmt.Errorf("invalid end date format. Use YYYY-MM-DD or YYYY-MM-DD HH:MM:SS")
+	}
+
+	// Validate date range
+	if end.Before(start) {
+		return inputFiles{}, fmt.Errorf("end date cannot be before start date")
+	}
+
+	// Validate the CSV delimiter
+	delimiterRunes := []rune(delimiter)
+	if len(delimiterRunes) != 1 {
+		return inputFiles{}, fmt.Errorf("invalid delimiter %q. Use a single character", delimiter)
+	}
+
+	// Validate the debit and credit columns
+	csvOptions := []pkgcsv.Option{
+		pkgcsv.WithBankNameFromDir(bankNameFromDir),
+		pkgcsv.WithDirectionColumn(directionColumn),
+		pkgcsv.WithAutoHeaderDetection(autoHeader),
+		pkgcsv.WithBankNameColumn(bankNameColumn),
+		pkgcsv.WithRejectZeroAmounts(rejectZeroAmounts),
+		pkgcsv.WithFlagFutureDates(flagFutureDates),
+		pkgcsv.WithRejectNegativeAmounts(rejectNegativeAmounts && !useSignedAmount),
+		pkgcsv.WithLocation(location),
+		pkgcsv.WithDateFormats(dateFormats...),
+		pkgcsv.WithEpochDates(epochDates),
+		pkgcsv.WithBankAmountScale(bankAmountScale),
+		pkgcsv.WithMaxRows(maxRows),
+		pkgcsv.WithSampleRows(sample),
+		pkgcsv.WithTrimFields(trimFields),
+		pkgcsv.WithEndTimeOfDay(endTimeOfDay),
+	}
+	switch len(debitCreditColumns) {
+	case 0:
+	case 2:
+		csvOptions = append(csvOptions, pkgcsv.WithDebitCreditColumns(debitCreditColumns[0], debitCreditColumns[1]))
+	default:
+		return inputFiles{}, fmt.Errorf("invalid debit and credit columns. Use the debit and credit column indexes, e.g. 1,2")
+	}
+
+	// Validate the column mappings
+	if systemColumns != "" {
+		columns, err := pkgcsv.ParseSystemColumns(systemColumns)
 		if err != nil {
-			return fmt.Errorf("invalid start date format. Use YYYY-MM-DD")
+			return inputFiles{}, fmt.Errorf("invalid system columns: %w", err)
 		}
-		end, err := time.Parse("2006-01-02", endDate)
+		csvOptions = append(csvOptions, pkgcsv.WithSystemColumns(columns))
+	}
+	if bankColumns != "" {
+		columns, err := pkgcsv.ParseBankColumns(bankColumns)
 		if err != nil {
-			return fmt.Errorf("invalid end date format. Use YYYY-MM-DD")
+			return inputFiles{}, fmt.Errorf("invalid bank columns: %w", err)
+		}
+		if bankNameColumn >= 0 || len(debitCreditColumns) > 0 {
+			return inputFiles{}, fmt.Errorf("--bank-columns cannot be combined with --bank-name-column or --debit-credit-columns")
 		}
+		csvOptions = append(csvOptions, pkgcsv.WithBankColumns(columns))
+	}
 
-		// Validate date range
-		if end.Before(start) {
-			return fmt.Errorf("end date cannot be before start date")
+	// Read s3:// inputs with an S3 client configured like the AWS CLI, other paths from the local file system
+	var fsys fs.FS = reconcile.OSFS{}
+	if strings.Contains(systemFile, "s3://") || strings.Contains(bankFile, "s3://") {
+		client, err := s3.NewClientFromEnv()
+		if err != nil {
+			return inputFiles{}, fmt.Errorf("failed to configure S3 client: %w", err)
 		}
+		fsys = s3.NewFS(client, fsys)
+	}
 
-		// Start timer for read CSV
-		startTimer := time.Now()
+	opts := []reconcile.Option{
+		reconcile.WithFileConcurrency(concurrency),
+		reconcile.WithDelimiter(delimiterRunes[0]),
+		reconcile.WithReadRetries(readRetries),
+		reconcile.WithMmap(mmap),
+		reconcile.WithFS(fsys),
+		reconcile.WithUseSignedAmount(useSignedAmount),
+		reconcile.WithCSVOptions(csvOptions...),
+	}
 
-		// Read system transactions
-		systemTransactions, err := readSystemTransactions(systemFile, start, end)
+	// Collect system files
+	var systemFiles []string
+	if systemFile != "" {
+		systemFiles, err = processSystemFiles(fsys, systemFile, recursive)
 		if err != nil {
-			return fmt.Errorf("failed to read system transactions: %w", err)
+			return inputFiles{}, fmt.Errorf("failed to process system files: %w", err)
+		}
+		if len(systemFiles) == 0 {
+			return inputFiles{}, fmt.Errorf("no system transaction files found in %s", systemFile)
 		}
+	}
+
+	// Collect bank files
+	var bankFiles []string
+	if bankFile != "" {
+		bankFiles, err = processBankFiles(fsys, bankFile, recursive)
+		if err != nil {
+			return inputFiles{}, fmt.Errorf("failed to process bank files: %w", err)
+		}
+	}
 
-		// Read bank statements
-		bankFiles, err := processBankFiles(bankFile)
+	// Query the system transactions and bank statements from databases, read with the files
+	if systemDB != "" {
+		db, err := openDB(dbDriver, systemDB)
 		if err != nil {
-			return fmt.Errorf("failed to process bank files: %w", err)
+			return inputFiles{}, fmt.Errorf("failed to open system database: %w", err)
 		}
-		bankStatements, err := readBankStatements(bankFiles, start, end)
+		opts = append(opts, reconcile.WithSystemSource(
+			sqldb.NewSource(db, sqldb.WithSystemQuery(systemQuery), sqldb.WithLocation(location), sqldb.WithEndTimeOfDay(endTimeOfDay))))
+	}
+	if bankDB != "" {
+		db, err := openDB(dbDriver, bankDB)
 		if err != nil {
-			return fmt.Errorf("failed to read bank statements: %w", err)
+			return inputFiles{}, fmt.Errorf("failed to open bank database: %w", err)
+		}
+		opts = append(opts, reconcile.WithBankSource(
+			sqldb.NewSource(db, sqldb.WithBankQuery(bankQuery), sqldb.WithLocation(location))))
+	}
+
+	return inputFiles{
+		systemFiles: systemFiles,
+		bankFiles:   bankFiles,
+		start:       start,
+		end:         end,
+		opts:        opts,
+	}, nil
+}
+
+// runReconcile reads and reconciles the input files and writes the result
+func runReconcile(cmd *cobra.Command, args []string) error {
+	input, err := parseInputFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	print, _ := cmd.Flags().GetBool("print")
+	skipInvalid, _ := cmd.Flags().GetBool("skip-invalid-bank-files")
+	idMatching, _ := cmd.Flags().GetBool("id-matching")
+	bestMatch, _ := cmd.Flags().GetBool("best-match")
+	optimalMatching, _ := cmd.Flags().GetBool("optimal-matching")
+	ignoreType, _ := cmd.Flags().GetBool("ignore-type")
+	consistentBankSign, _ := cmd.Flags().GetBool("consistent-bank-sign")
+	splitMatching, _ := cmd.Flags().GetBool("split-matching")
+	batchMatching, _ := cmd.Flags().GetBool("batch-matching")
+	referenceGrouping, _ := cmd.Flags().GetBool("reference-grouping")
+	traceID, _ := cmd.Flags().GetString("trace")
+	businessDayWindow, _ := cmd.Flags().GetInt("business-day-window")
+	holidaysFile, _ := cmd.Flags().GetString("holidays")
+	suggestionWindow, _ := cmd.Flags().GetInt("suggestion-window")
+	txType, _ := cmd.Flags().GetString("type")
+	invertBankSign, _ := cmd.Flags().GetBool("invert-bank-sign")
+	summaryJSON, _ := cmd.Flags().GetBool("summary-json")
+	clipboard, _ := cmd.Flags().GetBool("clipboard")
+	noColor, _ := cmd.Flags().GetBool("no-color")
+	dedupeReport, _ := cmd.Flags().GetBool("dedupe-report")
+	explain, _ := cmd.Flags().GetBool("explain")
+	reportFiltered, _ := cmd.Flags().GetBool("report-filtered")
+	appendOutput, _ := cmd.Flags().GetBool("append")
+	carryforward, _ := cmd.Flags().GetString("carryforward")
+	percentageTolerance, _ := cmd.Flags().GetFloat64("percentage-tolerance")
+	bankToleranceFlag, _ := cmd.Flags().GetString("bank-tolerance")
+	histogramEdgesFlag, _ := cmd.Flags().GetString("histogram-edges")
+	jsonKeyStyle, _ := cmd.Flags().GetString("json-key-style")
+	outputDateFormat, _ := cmd.Flags().GetString("output-date-format")
+	output, _ := cmd.Flags().GetString("output")
+	outputDir, _ := cmd.Flags().GetString("output-dir")
+	outputFormat, _ := cmd.Flags().GetString("output-format")
+	minMatchRate, _ := cmd.Flags().GetFloat64("min-match-rate")
+	summaryOnly, _ := cmd.Flags().GetBool("summary-only")
+	encryptKeyHex, _ := cmd.Flags().GetString("encrypt-key")
+	encryptKeyFile, _ := cmd.Flags().GetString("encrypt-key-file")
+	ndjsonFile, _ := cmd.Flags().GetString("ndjson-output")
+	webhookURL, _ := cmd.Flags().GetString("webhook")
+	webhookTimeout, _ := cmd.Flags().GetDuration("webhook-timeout")
+	webhookRetries, _ := cmd.Flags().GetInt("webhook-retries")
+	webhookRequired, _ := cmd.Flags().GetBool("webhook-required")
+	runID, _ := cmd.Flags().GetString("run-id")
+
+	// Generate the run ID unless given and add it to all log lines
+	if runID == "" {
+		if runID, err = newRunID(); err != nil {
+			return err
+		}
+	} else if err := validateRunID(runID); err != nil {
+		return err
+	}
+	logger = logger.With("run_id", runID)
+
+	// Validate transaction type filter
+	var typeFilter types.TransactionType
+	switch strings.ToUpper(txType) {
+	case "ALL":
+	case string(types.TransactionTypeDebit), string(types.TransactionTypeCredit):
+		typeFilter = types.TransactionType(strings.ToUpper(txType))
+	default:
+		return fmt.Errorf("invalid transaction type. Use DEBIT, CREDIT or ALL")
+	}
+
+	// The sign convention per bank is only checked when the type is not ignored
+	if ignoreType && consistentBankSign {
+		return fmt.Errorf("--consistent-bank-sign cannot be used with --ignore-type")
+	}
+
+	// Validate the histogram edges
+	histogramEdges, err := parseHistogramEdges(histogramEdgesFlag)
+	if err != nil {
+		return err
+	}
+
+	// Validate the bank tolerances
+	bankTolerances, err := parseBankTolerances(bankToleranceFlag)
+	if err != nil {
+		return err
+	}
+
+	// Read the holidays skipped by the business day window
+	var holidays []time.Time
+	if holidaysFile != "" {
+		if holidays, err = readHolidays(holidaysFile); err != nil {
+			return err
+		}
+	}
+
+	// Validate the JSON key style
+	var keyStyle reconcile.KeyStyle
+	switch strings.ToLower(jsonKeyStyle) {
+	case "snake":
+		keyStyle = reconcile.KeyStyleSnake
+	case "camel":
+		keyStyle = reconcile.KeyStyleCamel
+		if appendOutput {
+			return fmt.Errorf("--append only supports the snake JSON key style")
+		}
+	default:
+		return fmt.Errorf("invalid JSON key style. Use snake or camel")
+	}
+
+	// Validate the output date format, results with custom dates cannot be merged
+	if outputDateFormat != "" {
+		if err := reconcile.ValidateDateFormat(outputDateFormat); err != nil {
+			return err
+		}
+		if appendOutput {
+			return fmt.Errorf("--output-date-format cannot be used with --append")
 		}
+	}
+	jsonOpts := []reconcile.JSONOption{reconcile.WithKeyStyle(keyStyle), reconcile.WithJSONDateFormat(outputDateFormat)}
+
+	// Validate the minimum match rate
+	if minMatchRate < 0 || minMatchRate > 100 {
+		return fmt.Errorf("invalid minimum match rate. Use a percentage from 0 to 100")
+	}
+
+	// Validate the output format
+	outputFormat = strings.ToLower(outputFormat)
+	switch outputFormat {
+	case "json":
+	case "md":
+		if appendOutput {
+			return fmt.Errorf("--append only supports the json output format")
+		}
+	default:
+		return fmt.Errorf("invalid output format. Use json or md")
+	}
+
+	// Every --output-dir run writes a new file, so there is nothing to merge into
+	if appendOutput && outputDir != "" {
+		return fmt.Errorf("--append requires --output and cannot be used with --output-dir")
+	}
+
+	// Validate the summary only output, the other outputs list the unmatched details
+	if summaryOnly && (outputFormat != "json" || appendOutput || ndjsonFile != "") {
+		return fmt.Errorf("--summary-only cannot be used with --append, --ndjson-output or the md output format")
+	}
 
-		// Stop timer for read CSV
-		endTimer := time.Now()
-		fmt.Printf("Read CSV time: %s\n", endTimer.Sub(startTimer))
+	// Validate the encryption key, only a new JSON output file is encrypted
+	var encryptKey []byte
+	outputExt := outputFormat
+	encryptKeyHex, err = resolveEncryptKey(encryptKeyHex, encryptKeyFile)
+	if err != nil {
+		return err
+	}
+	if encryptKeyHex != "" {
+		encryptKey, err = reconcile.ParseEncryptionKey(encryptKeyHex)
+		if err != nil {
+			return err
+		}
+		if outputFormat != "json" || appendOutput || ndjsonFile != "" {
+			return fmt.Errorf("encryption cannot be used with --append, --ndjson-output or the md output format")
+		}
+		if output == "" && outputDir == "" {
+			return fmt.Errorf("encryption requires --output or --output-dir")
+		}
+		outputExt = "json.enc"
+	}
 
-		// Start timer for reconcile
-		startTimer = time.Now()
+	// Resolve the output file, an output directory gets an automatically named file
+	outputFile, err := resolveOutputFile(output, outputDir, outputExt, runID, input.start, input.end, time.Now())
+	if err != nil {
+		return err
+	}
+	if encryptKey != nil && !strings.HasSuffix(outputFile, ".enc") {
+		outputFile += ".enc"
+	}
 
-		// Reconcile transactions
-		result := reconcile.Reconcile(systemTransactions, bankStatements)
+	// Add the unmatched items of the previous run to the matching pool
+	if carryforward != "" {
+		previous, err := reconcile.LoadJSON(carryforward)
 		if err != nil {
-			return fmt.Errorf("failed to reconcile transactions: %w", err)
+			return fmt.Errorf("failed to load carryforward: %w", err)
 		}
+		input.opts = append(input.opts, reconcile.WithCarryforward(previous))
+	}
 
-		// Stop timer for reconcile
-		endTimer = time.Now()
-		fmt.Printf("Reconcile time: %s\n", endTimer.Sub(startTimer))
+	// Start timer for read CSV and reconcile
+	startTimer := time.Now()
 
-		// Start timer for generate result
-		startTimer = time.Now()
+	// Read and reconcile transactions
+	result, err := reconcile.RunFiles(input.systemFiles, input.bankFiles, input.start, input.end,
+		append(input.opts,
+			reconcile.WithWorkers(runtime.NumCPU()),
+			reconcile.WithSkipInvalidFiles(skipInvalid),
+			reconcile.WithIDMatching(idMatching),
+			reconcile.WithBestMatch(bestMatch),
+			reconcile.WithOptimalMatching(optimalMatching),
+			reconcile.WithSplitMatching(splitMatching),
+			reconcile.WithBatchMatching(batchMatching),
+			reconcile.WithReferenceGrouping(referenceGrouping),
+			reconcile.WithIgnoreType(ignoreType),
+			reconcile.WithConsistentBankSign(consistentBankSign),
+			reconcile.WithSuggestionWindow(suggestionWindow),
+			reconcile.WithTypeFilter(typeFilter),
+			reconcile.WithInvertBankSign(invertBankSign),
+			reconcile.WithReportFiltered(reportFiltered),
+			reconcile.WithPercentageTolerance(percentageTolerance/100),
+			reconcile.WithBankTolerance(bankTolerances),
+			reconcile.WithHistogramEdges(histogramEdges...),
+			reconcile.WithBusinessDayWindow(businessDayWindow, holidays),
+			reconcile.WithTrace(traceID, os.Stderr),
+			reconcile.WithRunID(runID),
+		)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile transactions: %w", err)
+	}
+
+	// Log the skipped files
+	for _, file := range result.SkippedFiles {
+		logger.Warn("skipping bank file", "file", file.Filename, "error", file.Reason)
+	}
+
+	// Log the rows filtered out by the date range
+	logFilteredRows(input.systemFiles, result.FilteredRows)
+
+	// Warn when nothing was reconciled, an empty result would otherwise look fully matched
+	logEmptyResult(result, input.start, input.end)
+
+	// Log the duplicate bank statement IDs, only the first statement of each can be matched
+	for _, duplicate := range result.DuplicateBankIDs {
+		logger.Warn("duplicate bank statement ID",
+			"bank", duplicate.BankName,
+			"id", duplicate.UniqueID,
+			"occurrences", duplicate.Occurrences,
+		)
+	}
+
+	// Log the rows excluded for a zero amount
+	for _, file := range sortedKeys(result.ZeroAmountRows) {
+		logger.Warn("zero amount rows excluded", "file", file, "count", result.ZeroAmountRows[file])
+	}
 
-		if print {
-			// Print reconciled transactions
-			fmt.Println(result.String())
+	// Log the warnings about the rows read
+	for _, warning := range result.Warnings {
+		logger.Warn("row warning", "warning", warning)
+	}
+
+	// Stop timer for read CSV and reconcile
+	endTimer := time.Now()
+	logger.Info("read CSV and reconcile",
+		"duration", endTimer.Sub(startTimer),
+		"bank_files", len(input.bankFiles),
+	)
+	if perf := result.Performance; perf != nil {
+		logger.Info("throughput",
+			"rows_read", perf.RowsRead,
+			"rows_per_second", math.Round(perf.RowsPerSecond()),
+			"matches", perf.Matches,
+			"matches_per_second", math.Round(perf.MatchesPerSecond()),
+		)
+	}
+
+	// Start timer for generate result
+	startTimer = time.Now()
+
+	// Collapse identical unmatched items, describe the configuration and format the dates in the reports when enabled
+	reportOpts := []reconcile.ReportOption{
+		reconcile.WithDedupe(dedupeReport),
+		reconcile.WithExplain(explain),
+		reconcile.WithDateFormat(outputDateFormat),
+	}
+
+	if print {
+		// Print reconciled transactions, colored on a terminal
+		writeSummary := result.WriteSummary
+		if useColor(os.Stdout, noColor) {
+			writeSummary = result.WriteSummaryColored
+		}
+		if err := writeSummary(os.Stdout, reportOpts...); err != nil {
+			return fmt.Errorf("failed to print result: %w", err)
+		}
+	}
+
+	// Copy the summary to the clipboard, a missing clipboard does not fail the run
+	if clipboard {
+		if err := copySummary(&result, reportOpts...); err != nil {
+			logger.Warn("failed to copy the summary to the clipboard", "error", err)
+		}
+	}
+
+	// Generate the Markdown report, it is printed to the console without output file
+	if outputFormat == "md" {
+		if err := generateMarkdown(&result, outputFile, reportOpts...); err != nil {
+			return fmt.Errorf("failed to generate Markdown report: %w", err)
 		}
+	}
 
-		// Generate JSON file
-		outputFile, _ := cmd.Flags().GetString("output")
-		if outputFile != "" {
-			if err := result.GenerateJSON(outputFile); err != nil {
-				return fmt.Errorf("failed to generate JSON file: %w", err)
+	// Generate NDJSON file
+	if ndjsonFile != "" {
+		if err := result.GenerateNDJSON(ndjsonFile, jsonOpts...); err != nil {
+			return fmt.Errorf("failed to generate NDJSON file: %w", err)
+		}
+	}
+
+	// Record the time spent generating the reports for the JSON output, the JSON is encoded once first
+	// so the duration covers the encoding and only the final file write is excluded
+	if result.Performance != nil {
+		if outputFile != "" && outputFormat != "md" {
+			if err := result.EncodeJSON(io.Discard, append(jsonOpts, reconcile.WithSummaryOnly(summaryOnly))...); err != nil {
+				return fmt.Errorf("failed to encode JSON result: %w", err)
 			}
 		}
+		result.Performance.GenerateDuration = time.Since(startTimer)
+	}
 
-		// Stop timer for generate result
-		endTimer = time.Now()
-		fmt.Printf("Generate result time: %s\n", endTimer.Sub(startTimer))
+	// Print the summary counts as a JSON line
+	if summaryJSON {
+		if err := result.WriteSummaryJSON(os.Stdout, jsonOpts...); err != nil {
+			return fmt.Errorf("failed to print JSON summary: %w", err)
+		}
+	}
 
+	// Generate the JSON output file with the recorded duration
+	jsonOutput := outputFile != "" && outputFormat != "md"
+	if jsonOutput && appendOutput {
+		// Merge the result into the existing JSON file
+		if err := result.AppendJSON(outputFile); err != nil {
+			return fmt.Errorf("failed to append JSON file: %w", err)
+		}
+	} else if jsonOutput && encryptKey != nil {
+		// Encrypt the JSON file
+		if err := result.GenerateEncryptedJSON(outputFile, encryptKey, append(jsonOpts, reconcile.WithSummaryOnly(summaryOnly))...); err != nil {
+			return fmt.Errorf("failed to generate encrypted JSON file: %w", err)
+		}
+	} else if jsonOutput {
+		if err := result.GenerateJSON(outputFile, append(jsonOpts, reconcile.WithSummaryOnly(summaryOnly))...); err != nil {
+			return fmt.Errorf("failed to generate JSON file: %w", err)
+		}
+	}
+
+	// Stop timer for generate result
+	endTimer = time.Now()
+	logger.Info("generate result", "duration", endTimer.Sub(startTimer))
+
+	// Notify the webhook with the JSON result, a failure only fails the run when required
+	if webhookURL != "" {
+		var body bytes.Buffer
+		if err := result.EncodeJSON(&body, append(jsonOpts, reconcile.WithSummaryOnly(summaryOnly))...); err != nil {
+			return fmt.Errorf("failed to encode webhook body: %w", err)
+		}
+		if err := postWebhook(webhookURL, body.Bytes(), webhookTimeout, webhookRetries); err != nil {
+			if webhookRequired {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("failed to post webhook: %w", err)
+			}
+			logger.Warn("failed to post webhook", "error", err)
+		}
+	}
+
+	// Fail the run when the match rate is below the minimum, after the result is written
+	if err := checkMatchRate(&result, minMatchRate); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	return nil
+}
+
+// webhookBackoff is the wait before the first webhook retry, it doubles with every retry
+var webhookBackoff = time.Second
+
+// postWebhook posts the JSON body to the URL, retrying network errors, 429 and 5xx responses up to retries times
+// Other non-2xx responses are not retried
+func postWebhook(url string, body []byte, timeout time.Duration, retries int) error {
+	client := &http.Client{Timeout: timeout}
+	backoff := webhookBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := postWebhookOnce(client, url, body)
+		if err == nil || !retry || attempt >= retries {
+			return err
+		}
+
+		// Wait before retrying, longer after every failure
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postWebhookOnce posts the JSON body to the URL, it reports whether a failure can succeed when retried
+func postWebhookOnce(client *http.Client, url string, body []byte) (bool, error) {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return false, nil
+}
+
+// useColor reports whether the summary printed to the file is colored, only on a terminal without --no-color
+// or the NO_COLOR environment variable, see https://no-color.org
+func useColor(file *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// clipboardCommands are the commands tried in order to write to the system clipboard, by their availability
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"clip"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// copyToClipboard writes the text to the system clipboard, it is replaced in tests
+var copyToClipboard = writeClipboard
+
+// copySummary copies the text summary of the result to the clipboard
+func copySummary(result *reconcile.ReconcileResult, opts ...reconcile.ReportOption) error {
+	var summary strings.Builder
+	if err := result.WriteSummary(&summary, opts...); err != nil {
+		return err
+	}
+	return copyToClipboard(summary.String())
+}
+
+// writeClipboard writes the text to the system clipboard with the first available clipboard command
+// Headless systems have no clipboard command or fail to reach a display, both are reported as an error
+func writeClipboard(text string) error {
+	for _, command := range clipboardCommands {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %w: %s", command[0], err, strings.TrimSpace(string(output)))
+		}
 		return nil
+	}
+	return fmt.Errorf("no clipboard command found, install pbcopy, clip, wl-copy, xclip or xsel, or use --print on headless systems")
+}
+
+// checkMatchRate checks the match rate of the result is at least the minimum percentage
+// A run without processed transactions always passes
+func checkMatchRate(result *reconcile.ReconcileResult, minMatchRate float64) error {
+	rate := result.MatchRate() * 100
+	if rate < minMatchRate {
+		return fmt.Errorf("match rate %.2f%% (%d of %d transactions) is below the minimum of %.2f%%",
+			rate, result.TransactionMatched, result.TransactionProcessed, minMatchRate)
+	}
+	return nil
+}
+
+// runValidate reads the input files and writes the validation result
+// It fails when any input file cannot be read
+func runValidate(cmd *cobra.Command, args []string) error {
+	input, err := parseInputFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Read the input files without reconciling them
+	result := reconcile.ValidateFiles(input.systemFiles, input.bankFiles, input.start, input.end, input.opts...)
+	if err := result.WriteSummary(os.Stdout); err != nil {
+		return fmt.Errorf("failed to print validation result: %w", err)
+	}
+
+	if !result.Valid() {
+		return fmt.Errorf("%d input files cannot be read", len(result.InvalidFiles))
+	}
+	return nil
+}
+
+// decryptCmd decrypts an encrypted JSON result file
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <result.json.enc>",
+	Short: "Decrypt a JSON result file written with --encrypt-key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyHex, _ := cmd.Flags().GetString("encrypt-key")
+		keyFile, _ := cmd.Flags().GetString("encrypt-key-file")
+		output, _ := cmd.Flags().GetString("output")
+		keyHex, err := resolveEncryptKey(keyHex, keyFile)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			return decryptFile(args[0], keyHex, os.Stdout)
+		}
+
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		if err := decryptFile(args[0], keyHex, file); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
 	},
 	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
-func main() {
-	// Start timer
-	start := time.Now()
+// resolveEncryptKey returns the hex encoded AES key given with --encrypt-key or read from --encrypt-key-file
+// The file keeps the key out of the process list and shell history, surrounding whitespace is ignored
+func resolveEncryptKey(keyHex, keyFile string) (string, error) {
+	if keyFile == "" {
+		return keyHex, nil
+	}
+	if keyHex != "" {
+		return "", fmt.Errorf("--encrypt-key and --encrypt-key-file cannot be used together")
+	}
 
-	// Define command line flags
-	rootCmd.Flags().StringP("system", "s", "", "Path to system transaction CSV file (required)")
-	rootCmd.Flags().StringP("bank", "b", "", "Directory path contains bank statement CSV files or Comma-separated paths to bank statement CSV files (required)")
-	rootCmd.Flags().StringP("start", "t", "", "Start date for reconciliation in YYYY-MM-DD format (required)")
-	rootCmd.Flags().StringP("end", "e", "", "End date for reconciliation in YYYY-MM-DD format (required)")
-	rootCmd.Flags().StringP("output", "o", "", "Path to output JSON file")
-	rootCmd.Flags().BoolP("print", "p", false, "Print the result to the console")
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+	keyHex = strings.TrimSpace(string(content))
+	if keyHex == "" {
+		return "", fmt.Errorf("encryption key file %s is empty", keyFile)
+	}
+	return keyHex, nil
+}
 
-	// Mark required flags
-	err := rootCmd.MarkFlagRequired("system")
+// decryptFile decrypts the encrypted file with the hex encoded key and writes the plaintext
+func decryptFile(filename, keyHex string, w io.Writer) error {
+	if keyHex == "" {
+		return fmt.Errorf("--encrypt-key-file or --encrypt-key is required")
+	}
+	key, err := reconcile.ParseEncryptionKey(keyHex)
 	if err != nil {
-		fmt.Printf("Error: %s\n\n", err)
-		os.Exit(1)
+		return err
 	}
-	err = rootCmd.MarkFlagRequired("bank")
+
+	plaintext, err := reconcile.ReadEncryptedFile(filename, key)
 	if err != nil {
-		fmt.Printf("Error: %s\n\n", err)
-		os.Exit(1)
+		return err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return fmt.Errorf("failed to write decrypted file: %w", err)
 	}
-	err = rootCmd.MarkFlagRequired("start")
+	return nil
+}
+
+// diffCmd compares two JSON result files and prints the new, resolved and unchanged unmatched items
+var diffCmd = &cobra.Command{
+	Use:   "diff <previous.json> <current.json>",
+	Short: "Compare the unmatched items of two JSON result files",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return diffFiles(args[0], args[1], os.Stdout)
+	},
+	SilenceErrors: true,
+}
+
+// diffFiles loads the previous and current JSON result files and writes their diff
+func diffFiles(previousFile, currentFile string, w io.Writer) error {
+	// Load both results
+	previous, err := reconcile.LoadJSON(previousFile)
 	if err != nil {
-		fmt.Printf("Error: %s\n\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load previous result: %w", err)
 	}
-	err = rootCmd.MarkFlagRequired("end")
+	current, err := reconcile.LoadJSON(currentFile)
 	if err != nil {
-		fmt.Printf("Error: %s\n\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load current result: %w", err)
 	}
 
+	// Compare the results and write the diff
+	diff := reconcile.DiffResults(previous, current)
+	return diff.WriteSummary(w)
+}
+
+func main() {
+	// Start timer
+	start := time.Now()
+
+	// Define command line flags, the root command accepts the reconcile flags for backward compatibility
+	for _, cmd := range []*cobra.Command{rootCmd, reconcileCmd} {
+		addInputFlags(cmd.Flags())
+		addReconcileFlags(cmd.Flags())
+	}
+	addInputFlags(validateCmd.Flags())
+
+	// Define the decrypt flags
+	decryptCmd.Flags().String("encrypt-key-file", "", "File holding the hex encoded AES key the file was encrypted with (this or --encrypt-key is required)")
+	decryptCmd.Flags().String("encrypt-key", "", "Hex encoded AES key the file was encrypted with, visible in the process list, prefer --encrypt-key-file")
+	decryptCmd.Flags().StringP("output", "o", "", "Path to the decrypted JSON file, printed to the console when empty")
+
+	// Register the subcommands
+	rootCmd.AddCommand(reconcileCmd, validateCmd, diffCmd, decryptCmd)
+
 	// Execute the root command
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Printf("Error: %s\n\n", err)
+	err := rootCmd.Execute()
+	if err != nil {
+		logger.Error("reconciliation failed", "error", err)
 	}
 
 	// Stop timer
 	end := time.Now()
-	fmt.Printf("Total execution time: %s\n", end.Sub(start))
+	logger.Info("total execution", "duration", end.Sub(start))
+
+	// Exit with a non-zero status on failure, e.g. a match rate below the minimum
+	if err != nil {
+		os.Exit(1)
+	}
 }
 
-// processBankFiles reads the bank statements from the given files
-func processBankFiles(bankFileString string) ([]string, error) {
-	// Check if path is a directory
-	fileInfo, err := os.Stat(bankFileString)
-	if err == nil {
-		// If the bank file is a directory, read all CSV files in the directory
-		if fileInfo.IsDir() {
-			files, err := filepath.Glob(filepath.Join(bankFileString, "*.csv"))
-			if err != nil {
-				return nil, fmt.Errorf("failed to read bank files: %w", err)
-			}
-			return files, nil
+// resolveOutputFile returns the output file path
+// With an output directory, the directory is created and the file is named reconcile-<start>-<end>-<timestamp>-<run ID>.<ext>
+func resolveOutputFile(output, outputDir, ext, runID string, start, end, now time.Time) (string, error) {
+	if outputDir == "" {
+		return output, nil
+	}
+	if output != "" {
+		return "", fmt.Errorf("--output and --output-dir cannot be used together")
+	}
+
+	// Create the output directory
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("reconcile-%s-%s-%s-%s.%s",
+		start.Format("2006-01-02"),
+		end.Format("2006-01-02"),
+		now.Format("20060102T150405"),
+		runID,
+		ext)
+	return filepath.Join(outputDir, filename), nil
+}
+
+// newRunID returns a random version 4 UUID identifying a run
+func newRunID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// validateRunID checks that a given run ID only has letters, digits, '-', '_' and '.' to be usable in filenames
+func validateRunID(runID string) error {
+	for _, c := range runID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("invalid run ID %q. Use letters, digits, '-', '_' and '.'", runID)
 		}
 	}
+	return nil
+}
 
-	// Create separate paths from comma-separated string
-	bankFiles := strings.Split(bankFileString, ",")
-	for _, file := range bankFiles {
-		_, err := os.Stat(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read bank files: %w", err)
+// generateMarkdown writes the Markdown report to the file, or to stdout when no file is given
+func generateMarkdown(result *reconcile.ReconcileResult, filename string, opts ...reconcile.ReportOption) error {
+	if filename == "" {
+		return result.GenerateMarkdown(os.Stdout, opts...)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := result.GenerateMarkdown(file, opts...); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// logFilteredRows logs the number of rows outside the date range of each system file and of each bank file
+func logFilteredRows(systemFiles []string, filtered map[string]int) {
+	for _, systemFile := range systemFiles {
+		if count, ok := filtered[systemFile]; ok {
+			logger.Info(fmt.Sprintf("%d system rows outside date range skipped", count), "file", systemFile, "count", count)
+		}
+	}
+	for _, file := range sortedKeys(filtered) {
+		if slices.Contains(systemFiles, file) {
+			continue
+		}
+		logger.Info(fmt.Sprintf("%d bank rows outside date range skipped", filtered[file]), "file", file, "count", filtered[file])
+	}
+}
+
+// logEmptyResult warns when no rows were reconciled, telling a date range missing the data apart from empty input files
+func logEmptyResult(result reconcile.ReconcileResult, start, end time.Time) {
+	switch {
+	case result.AllRowsOutsideRange():
+		logger.Warn("no transactions in the date range, all rows are outside it",
+			"start", start.Format("2006-01-02 15:04:05"),
+			"end", end.Format("2006-01-02 15:04:05"),
+			"rows_outside_range", result.RowsOutsideRange)
+	case result.IsEmpty():
+		logger.Warn("no transactions read, the input files are empty")
+	}
+}
+
+// parseDateTime parses a range bound as a date or a date with a time of day in the location
+// It reports whether a time of day was given, so an explicit 00:00:00 is not mistaken for a date-only bound
+func parseDateTime(value string, location *time.Location) (time.Time, bool, error) {
+	if date, err := time.ParseInLocation("2006-01-02 15:04:05", value, location); err == nil {
+		return date, true, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, location)
+	return date, false, err
+}
+
+// parseHistogramEdges parses comma separated non-negative discrepancy amounts, e.g. 0,0.01,0.1
+func parseHistogramEdges(value string) ([]float64, error) {
+	var edges []float64
+	for _, field := range strings.Split(value, ",") {
+		edge, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || edge < 0 {
+			return nil, fmt.Errorf("invalid histogram edge %q. Use non-negative discrepancy amounts, e.g. 0,0.01,0.1", field)
 		}
+		edges = append(edges, edge)
 	}
+	return edges, nil
+}
 
-	return bankFiles, nil
+// parseBankTolerances parses comma separated bank=amount pairs of non-negative tolerances, e.g. BankA=0.25,BankB=0.00
+func parseBankTolerances(value string) (map[string]float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	tolerances := make(map[string]float64)
+	for _, field := range strings.Split(value, ",") {
+		bankName, amount, ok := strings.Cut(strings.TrimSpace(field), "=")
+		tolerance, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+		if !ok || strings.TrimSpace(bankName) == "" || err != nil || tolerance < 0 {
+			return nil, fmt.Errorf("invalid bank tolerance %q. Use bank=amount pairs, e.g. BankA=0.25,BankB=0.00", field)
+		}
+		tolerances[strings.TrimSpace(bankName)] = tolerance
+	}
+	return tolerances, nil
 }
 
-// readSystemTransactions reads the system transactions from the given file
-func readSystemTransactions(systemFile string, start, end time.Time) ([]types.Transaction, error) {
-	// Open the system file
-	systemFileHandle, err := os.Open(systemFile)
+// readHolidays reads one YYYY-MM-DD holiday per line, blank lines and lines starting with # are skipped
+func readHolidays(path string) ([]time.Time, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open system file: %w", err)
+		return nil, fmt.Errorf("failed to read holidays file: %w", err)
+	}
+	var holidays []time.Time
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		holiday, err := time.Parse("2006-01-02", line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q on line %d of %s. Use YYYY-MM-DD", line, i+1, path)
+		}
+		holidays = append(holidays, holiday)
 	}
-	defer systemFileHandle.Close()
+	return holidays, nil
+}
 
-	// Create a CSV reader with the system file
-	systemReader := pkgcsv.NewCSVReader(
-		csv.NewReader(systemFileHandle),
-		pkgcsv.WithSkipHeader(true),
-		pkgcsv.WithTimeRange(start, end),
-	)
+// openDB opens the database with the registered driver, the database stays open for the lifetime of the process
+func openDB(driverName, dataSource string) (*sql.DB, error) {
+	if !slices.Contains(sql.Drivers(), driverName) {
+		return nil, fmt.Errorf("database driver %q is not registered in this build, registered drivers: %v", driverName, sql.Drivers())
+	}
+	db, err := sql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
-	// Read the system transactions
-	systemTransactions, err := systemReader.ReadSystemTransactionsFromCSV()
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addInputFlags defines the flags selecting and reading the input files
+func addInputFlags(flags *pflag.FlagSet) {
+	flags.StringP("system", "s", "", "Directory path contains system transaction CSV or xlsx files or Comma-separated paths to system transaction CSV or xlsx files (required)")
+	flags.StringP("bank", "b", "", "Directory path contains bank statement CSV, xlsx or .tar.gz files or Comma-separated paths to bank statement CSV, xlsx or .tar.gz files (required)")
+	flags.StringP("start", "t", "", "Start date for reconciliation in YYYY-MM-DD format, or YYYY-MM-DD HH:MM:SS to bound system transactions by time (required)")
+	flags.StringP("end", "e", "", "End date for reconciliation in YYYY-MM-DD format, or YYYY-MM-DD HH:MM:SS to bound system transactions by time (required)")
+	flags.BoolP("recursive", "r", false, "Scan the system and bank directories recursively for CSV, xlsx and .tar.gz files")
+	flags.Bool("bank-name-from-dir", false, "Derive the bank name from the parent directory instead of the filename")
+	flags.Bool("bank-direction-column", false, "Bank statements have a 4th D/C direction column with always positive amounts")
+	flags.Bool("auto-header", false, "Detect whether CSV files have a header row instead of always skipping the first row")
+	flags.Int("bank-name-column", -1, "Index of an extra bank statement column holding the bank name, -1 derives it from the filename")
+	flags.Int("concurrency", runtime.NumCPU(), "Maximum number of bank files read at once")
+	flags.Bool("reject-zero-amounts", false, "Exclude rows with a zero amount and report their count per file")
+	flags.Bool("flag-future-dates", false, "Warn about rows dated after the time of the run, they are still read, and list them under warnings in the summary")
+	flags.String("timezone", "UTC", "Timezone the dates are interpreted in, e.g. Asia/Jakarta")
+	flags.Bool("epoch-dates", false, "Parse integer bank statement dates as Unix epoch seconds or milliseconds")
+	flags.Float64("bank-amount-scale", 1, "Factor the bank statement amounts are multiplied by, e.g. 0.01 for bank feeds reporting integer cents")
+	flags.StringSlice("date-formats", nil, "Comma-separated Go layouts tried in order to parse bank statement dates (default \"2006-01-02,2006-01-02 15:04:05\")")
+	flags.String("delimiter", ",", "Field delimiter of the CSV files")
+	flags.IntSlice("debit-credit-columns", nil, "Indexes of separate bank statement debit and credit amount columns replacing the signed amount, e.g. 1,2")
+	flags.String("system-db", "", "Path to a SQLite database the system transactions are queried from, with or instead of --system")
+	flags.String("system-query", sqldb.DefaultSystemQuery, "Query returning the TrxID, Amount, Type and TransactionTime columns of the system database")
+	flags.String("bank-db", "", "Path to a SQLite database the bank statements are queried from, with or instead of --bank")
+	flags.String("bank-query", sqldb.DefaultBankQuery, "Query returning the BankName, UniqueID, Amount and Date columns of the bank database")
+	flags.String("db-driver", "sqlite", "Name of the database/sql driver opening --system-db and --bank-db")
+	flags.Bool("trim-fields", true, "Trim leading and trailing whitespace from every field before parsing")
+	flags.String("system-columns", "", "Column index of each system field, e.g. trxid=0,amount=1,type=2,transactiontime=3 with an optional batchid, currency and reference, other columns are ignored")
+	flags.String("bank-columns", "", "Column index of each bank field, e.g. uniqueid=0,amount=1,date=2 with an optional direction, batchid, currency and reference, other columns are ignored")
+	flags.Bool("reject-negative-amounts", true, "Fail on negative system amounts, set to false for systems storing debits as negative amounts")
+	flags.Bool("use-signed-amount", false, "Take the direction from the system amount sign instead of the Type column, debits are negative amounts")
+	flags.Int("max-rows", 0, "Fail once an input file has more than this many data rows, 0 disables the limit")
+	flags.Int("sample", 0, "Read only the first N data rows of each input file for quick testing, 0 reads all rows")
+	flags.Int("read-retries", 0, "Times opening and reading an input file is retried with backoff on transient errors like EIO")
+	flags.Bool("mmap", false, "Map local input files into memory instead of reading them, faster for very large files, falls back to reading without mmap support")
+	flags.StringP("config", "c", "", "Path to a YAML or TOML (.toml) config file with flag defaults, flags given on the command line take precedence")
+	flags.String("log-format", "text", "Format of the diagnostic logs written to stderr (text or json)")
+}
+
+// addReconcileFlags defines the flags controlling the matching and the result output
+func addReconcileFlags(flags *pflag.FlagSet) {
+	flags.StringP("output", "o", "", "Path to output JSON file")
+	flags.String("output-dir", "", "Directory the output JSON file is written to as reconcile-<start>-<end>-<timestamp>-<run ID>.json, created if missing")
+	flags.BoolP("print", "p", false, "Print the result to the console")
+	flags.String("ndjson-output", "", "Path to output NDJSON file with one line per unmatched item")
+	flags.Bool("skip-invalid-bank-files", false, "Skip bank files that cannot be read instead of failing")
+	flags.Bool("id-matching", false, "Match system TrxID to bank UniqueID before matching by amount and date")
+	flags.Bool("best-match", false, "Match the closest bank statement by reference, amount and date instead of the first one within the tolerance")
+	flags.Bool("optimal-matching", false, "Maximize the number of matches instead of matching transactions in order, slower on large dates")
+	flags.Bool("ignore-type", false, "Match on the absolute amount and date only, ignoring the DEBIT/CREDIT type and the amount signs")
+	flags.Bool("consistent-bank-sign", false, "Infer the sign convention of each bank and only require its amount signs to be consistent with the type")
+	flags.Bool("split-matching", false, "Match an unmatched system transaction to up to 4 bank lines of the same bank and day summing to its amount")
+	flags.Bool("reference-grouping", false, "Match system transactions sharing a reference column to the bank line with that reference when their amounts sum to it, e.g. installments")
+	flags.Bool("batch-matching", false, "Reconcile settlement batches by comparing the system total of each batch ID to its bank settlement lines")
+	flags.String("trace", "", "Print to stderr every bank statement the system transaction with this TrxID was compared against and why it failed to match")
+	flags.Int("business-day-window", 0, "Match bank statements posted up to this many business days after the system transaction, skipping weekends and holidays")
+	flags.String("holidays", "", "Path to a file with one YYYY-MM-DD holiday per line skipped by --business-day-window")
+	flags.Int("suggestion-window", 3, "Days searched for the closest bank statement of unmatched transactions, 0 disables suggestions")
+	flags.String("type", "ALL", "Only reconcile transactions of this type (DEBIT, CREDIT or ALL)")
+	flags.Bool("invert-bank-sign", false, "Expect banks to report CREDIT as negative and DEBIT as positive amounts")
+	flags.Bool("summary-json", false, "Print the summary counts as a single JSON line to stdout")
+	flags.Bool("clipboard", false, "Copy the text summary to the system clipboard for sharing")
+	flags.Bool("no-color", false, "Disable the colors of the printed summary, also disabled by NO_COLOR or when not printing to a terminal")
+	flags.Bool("dedupe-report", false, "Collapse identical unmatched rows of the printed summary and the Markdown report into one line with their count")
+	flags.Bool("explain", false, "Write the effective matching configuration at the end of the printed summary, the JSON output always records it")
+	flags.Bool("report-filtered", false, "Report the number of rows outside the date range per file")
+	flags.Bool("append", false, "Merge the result into the existing --output JSON file instead of overwriting it, dropping unmatched items matched since")
+	flags.String("carryforward", "", "Path to the output JSON file of the previous run whose unmatched items are matched again, e.g. for settlement lag")
+	flags.Float64("percentage-tolerance", 0, "Allowed discrepancy as a percentage of the system amount, used when larger than the absolute tolerance of 0.01, e.g. 0.5 for 0.5%")
+	flags.String("bank-tolerance", "", "Absolute discrepancy allowed per bank replacing the default of 0.01, e.g. BankA=0.25,BankB=0.00 for a bank taking a fee")
+	flags.String("histogram-edges", "0,0.01,0.1", "Upper edges of the discrepancy histogram buckets of the matched pairs, a last bucket holds larger discrepancies")
+	flags.String("json-key-style", "snake", "Naming style of the JSON output keys (snake or camel)")
+	flags.String("output-date-format", "", "Go layout of the dates in the text, Markdown and JSON output, e.g. 02/01/2006, JSON files with custom dates cannot be appended to or carried forward")
+	flags.Float64("min-match-rate", 0, "Fail the run when less than this percentage of the processed transactions is matched, e.g. 95")
+	flags.Bool("summary-only", false, "Write only the summary to the output JSON file, without the unmatched details")
+	flags.String("encrypt-key-file", "", "File holding the hex encoded 16, 24 or 32 byte AES key encrypting the output JSON file with AES-GCM as .json.enc")
+	flags.String("encrypt-key", "", "Hex encoded AES key like --encrypt-key-file, visible in the process list and shell history, prefer --encrypt-key-file")
+	flags.String("webhook", "", "URL the JSON result is posted to after the run, e.g. to notify a service")
+	flags.Duration("webhook-timeout", 10*time.Second, "Timeout of each webhook request")
+	flags.Int("webhook-retries", 2, "Number of times a webhook request failing with a network error, 429 or 5xx is retried")
+	flags.Bool("webhook-required", false, "Fail the run when the webhook cannot be posted instead of logging a warning")
+	flags.String("run-id", "", "Identifier of the run in the JSON summary, log lines and output file name, a random UUID by default")
+	flags.String("output-format", "json", "Format of the output file (json or md), md without output file prints the report to the console")
+}
+
+// applyConfig sets the flags not given on the command line from the config file, TOML for the .toml extension
+// and YAML otherwise. Keys are flag names, e.g. system or date-formats, and lists are joined for slice flags
+func applyConfig(flags *pflag.FlagSet, configFile string) error {
+	// Read the config file
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	readConfig := readYAMLConfig
+	if strings.EqualFold(filepath.Ext(configFile), ".toml") {
+		readConfig = readTOMLConfig
+	}
+	config, err := readConfig(content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read system transactions: %w", err)
+		return fmt.Errorf("invalid config file %s: %w", configFile, err)
+	}
+
+	// Apply the values in key order for deterministic errors
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !isConfigKey(key) {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		if key == "encrypt-key" {
+			return fmt.Errorf("the encryption key cannot be stored in the config file, use encrypt-key-file")
+		}
+
+		// Keys of flags the command does not have are shared with other commands
+		flag := flags.Lookup(key)
+		if flag == nil {
+			continue
+		}
+
+		// Command line flags take precedence over the config file
+		if flag.Changed {
+			continue
+		}
+
+		if err := flags.Set(key, config[key]); err != nil {
+			return fmt.Errorf("invalid config value for %q: %w", key, err)
+		}
 	}
 
-	return systemTransactions, nil
+	return nil
 }
 
-// readBankStatements reads the bank statements from the given files
-func readBankStatements(bankFiles []string, start, end time.Time) ([]types.BankStatement, error) {
-	bankStatements := []types.BankStatement{}
+// readYAMLConfig decodes a YAML config file to the flag value of each key
+// The scalars are kept as written, so values like dates are not reformatted
+func readYAMLConfig(content []byte) (map[string]string, error) {
+	nodes := map[string]yaml.Node{}
+	if err := yaml.Unmarshal(content, &nodes); err != nil {
+		return nil, err
+	}
 
-	// Process files concurrently using worker pool
-	type result struct {
-		statements []types.BankStatement
-		err        error
+	config := make(map[string]string, len(nodes))
+	for key, node := range nodes {
+		config[key] = node.Value
+		if node.Kind == yaml.SequenceNode {
+			items := make([]string, len(node.Content))
+			for i, item := range node.Content {
+				items[i] = item.Value
+			}
+			config[key] = strings.Join(items, ",")
+		}
 	}
+	return config, nil
+}
 
-	// Create a channel to receive results
-	resultCh := make(chan result, len(bankFiles))
+// readTOMLConfig decodes a TOML config file to the flag value of each key
+// The typed values are written back the way TOML writes them, local date-times with a space like the start flag
+func readTOMLConfig(content []byte) (map[string]string, error) {
+	values := map[string]any{}
+	if err := toml.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
 
-	// Create a wait group to wait for all goroutines to complete
-	var wg sync.WaitGroup
+	config := make(map[string]string, len(values))
+	for _, key := range sortedKeys(values) {
+		value, err := tomlValue(values[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+		config[key] = value
+	}
+	return config, nil
+}
+
+// tomlValue converts a decoded TOML value to its flag representation, arrays are joined for slice flags
+func tomlValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case toml.LocalDate:
+		return v.String(), nil
+	case toml.LocalDateTime:
+		return v.LocalDate.String() + " " + v.LocalTime.String(), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = text
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported TOML value %v", value)
+	}
+}
+
+// isConfigKey checks if the key is a flag of the reconcile or validate command that can be set in the config file
+func isConfigKey(key string) bool {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	addInputFlags(flags)
+	addReconcileFlags(flags)
+	return key != "config" && flags.Lookup(key) != nil
+}
+
+// newLogger creates a logger writing to w in the given format
+func newLogger(format string, w io.Writer) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q. Use text or json", format)
+	}
+}
 
-	// Process each bank file concurrently
-	for _, bankFile := range bankFiles {
-		wg.Add(1)
-		go func(filename string) {
-			defer wg.Done()
+// processBankFiles collects the bank statement files from the given files of the file system
+// A gzipped tar bundle is passed on as is, its members are extracted when reading
+// If recursive is set, a directory is walked to collect bank files in all subdirectories
+func processBankFiles(fsys fs.FS, bankFileString string, recursive bool) ([]string, error) {
+	return collectFiles(fsys, bankFileString, recursive, isBankFile)
+}
 
-			bankFileHandle, err := os.Open(filename)
+// processSystemFiles collects the system transaction files from the given files of the file system,
+// like processBankFiles, e.g. a directory of hourly exports
+func processSystemFiles(fsys fs.FS, systemFileString string, recursive bool) ([]string, error) {
+	return collectFiles(fsys, systemFileString, recursive, isSystemFile)
+}
+
+// collectFiles returns the matching files of a directory, an S3 prefix or the comma-separated paths
+// If recursive is set, a directory is walked to collect the files in all subdirectories
+func collectFiles(fsys fs.FS, fileString string, recursive bool, match func(path string) bool) ([]string, error) {
+	// Check if path is a directory
+	fileInfo, err := fs.Stat(fsys, fileString)
+	if err == nil {
+		// If the path is an S3 prefix, list the files under it
+		if s3fs, ok := fsys.(*s3.FS); ok && fileInfo.IsDir() && s3.IsURI(fileString) {
+			uris, err := s3fs.List(fileString, recursive)
 			if err != nil {
-				resultCh <- result{nil, fmt.Errorf("failed to open bank file: %w", err)}
-				return
+				return nil, fmt.Errorf("failed to read files: %w", err)
 			}
-			defer bankFileHandle.Close()
-
-			// Create a CSV reader with the bank file
-			bankReader := pkgcsv.NewCSVReader(
-				csv.NewReader(bankFileHandle),
-				pkgcsv.WithSkipHeader(true),
-				pkgcsv.WithTimeRange(start, end),
-				pkgcsv.WithFilename(filename),
-			)
-
-			// Read the bank statements
-			statements, err := bankReader.ReadBankStatementsFromCSV()
+			files := []string{}
+			for _, uri := range uris {
+				if match(uri) {
+					files = append(files, uri)
+				}
+			}
+			return files, nil
+		}
+
+		// If the path is a directory, read all files in the directory tree
+		if fileInfo.IsDir() && recursive {
+			return walkFiles(fsys, fileString, match)
+		}
+
+		// If the path is a directory, read all files in the directory
+		if fileInfo.IsDir() {
+			entries, err := fs.ReadDir(fsys, fileString)
 			if err != nil {
-				resultCh <- result{nil, fmt.Errorf("failed to read bank statements: %w", err)}
-				return
+				return nil, fmt.Errorf("failed to read files: %w", err)
+			}
+			files := []string{}
+			for _, entry := range entries {
+				if !entry.IsDir() && match(entry.Name()) {
+					files = append(files, path.Join(fileString, entry.Name()))
+				}
 			}
+			return files, nil
+		}
+	}
 
-			// Send the statements to the result channel
-			resultCh <- result{statements, nil}
-		}(bankFile)
+	// Create separate paths from comma-separated string
+	files := strings.Split(fileString, ",")
+	for _, file := range files {
+		_, err := fs.Stat(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read files: %w", err)
+		}
 	}
 
-	// Close result channel once all goroutines complete
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
+	return files, nil
+}
+
+// isBankFile checks if the file is a CSV or xlsx file or a gzipped tar bundle of them
+func isBankFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".csv" || ext == ".xlsx" || ext == ".tgz" || strings.HasSuffix(path, ".tar.gz")
+}
+
+// isSystemFile checks if the file is a CSV or xlsx file
+func isSystemFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".csv" || ext == ".xlsx"
+}
 
-	// Collect results
-	for res := range resultCh {
-		if res.err != nil {
-			return nil, res.err
+// walkFiles collects all matching files in the directory tree of the file system
+func walkFiles(fsys fs.FS, dir string, match func(path string) bool) ([]string, error) {
+	files := []string{}
+	err := fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && match(path) {
+			files = append(files, path)
 		}
-		bankStatements = append(bankStatements, res.statements...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files: %w", err)
 	}
-
-	return bankStatements, nil
+	return files, nil
 }
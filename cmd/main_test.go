@@ -1,12 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+
+	"reconciliation/pkg/reconcile"
+	"reconciliation/pkg/s3"
 )
 
 // TestProcessBankFiles tests the processBankFiles function
@@ -24,12 +38,24 @@ func TestProcessBankFiles(t *testing.T) {
 		f.Close()
 	}
 
+	// Create a nested fixture directory with per-bank subfolders
+	nestedDir := filepath.Join(tmpDir, "nested")
+	nestedFiles := []string{"bri/2024-01.csv", "bri/2024-02.csv", "bni/2024/01.csv", "bni/readme.txt", "bca/statements.tar.gz", "mandiri.csv"}
+	for _, file := range nestedFiles {
+		path := filepath.Join(nestedDir, file)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		f, err := os.Create(path)
+		assert.NoError(t, err)
+		f.Close()
+	}
+
 	// Define test cases
 	tests := []struct {
-		name    string
-		input   string
-		want    int
-		wantErr bool
+		name      string
+		input     string
+		recursive bool
+		want      int
+		wantErr   bool
 	}{
 		{
 			name:    "Directory with multiple CSV files",
@@ -61,6 +87,26 @@ func TestProcessBankFiles(t *testing.T) {
 			want:    0,
 			wantErr: true,
 		},
+		{
+			name:      "Nested directory recursive",
+			input:     nestedDir,
+			recursive: true,
+			want:      5,
+			wantErr:   false,
+		},
+		{
+			name:    "Nested directory not recursive",
+			input:   nestedDir,
+			want:    1,
+			wantErr: false,
+		},
+		{
+			name:      "Directory with multiple CSV files recursive",
+			input:     tmpDir,
+			recursive: true,
+			want:      8,
+			wantErr:   false,
+		},
 		{
 			name:    "Directory without CSV files",
 			input:   os.TempDir(),
@@ -73,7 +119,7 @@ func TestProcessBankFiles(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Call the processBankFiles function
-			got, err := processBankFiles(tt.input)
+			got, err := processBankFiles(reconcile.OSFS{}, tt.input, tt.recursive)
 
 			// Check if the result matches the expected result
 			if tt.wantErr {
@@ -86,220 +132,653 @@ func TestProcessBankFiles(t *testing.T) {
 	}
 }
 
-// TestReadSystemTransactions tests the readSystemTransactions function
-func TestReadSystemTransactions(t *testing.T) {
-	// Create a temporary test file
-	tmpFile, err := os.CreateTemp("", "test-system-*.csv")
-	assert.NoError(t, err)
-	defer os.Remove(tmpFile.Name())
-
-	// Write test data
-	testData := `TrxID,Amount,Type,TransactionTime
-TX001,100.0,DEBIT,2024-01-01 10:00:00
-TX002,200.0,CREDIT,2024-01-02 10:00:00`
-	_, err = tmpFile.WriteString(testData)
-	assert.NoError(t, err)
-	tmpFile.Close()
-
-	// Create an empty file for testing
-	emptyFile, err := os.CreateTemp("", "empty-*.csv")
-	assert.NoError(t, err)
-	defer os.Remove(emptyFile.Name())
-
-	// Create invalid CSV file
-	invalidFile, err := os.CreateTemp("", "invalid-*.csv")
-	assert.NoError(t, err)
-	defer os.Remove(invalidFile.Name())
-	_, err = invalidFile.WriteString("invalid,csv,format\nwithout,proper,headers")
-	assert.NoError(t, err)
-	invalidFile.Close()
-
+// TestNewLogger tests the newLogger function
+func TestNewLogger(t *testing.T) {
 	// Define test cases
 	tests := []struct {
-		name      string
-		file      string
-		startDate string
-		endDate   string
-		wantCount int
-		wantErr   bool
+		name     string
+		format   string
+		contains string
+		wantErr  bool
 	}{
 		{
-			name:      "Valid date range",
-			file:      tmpFile.Name(),
-			startDate: "2024-01-01",
-			endDate:   "2024-01-03",
-			wantCount: 2,
-			wantErr:   false,
-		},
-		{
-			name:      "Partial date range",
-			file:      tmpFile.Name(),
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 2,
-			wantErr:   false,
-		},
-		{
-			name:      "Invalid date range",
-			file:      tmpFile.Name(),
-			startDate: "2024-01-03",
-			endDate:   "2024-01-01",
-			wantCount: 0,
-			wantErr:   false,
+			name:     "Text format",
+			format:   "text",
+			contains: `msg=reconcile duration=1s`,
 		},
 		{
-			name:      "Non-existent file",
-			file:      "nonexistent.csv",
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   true,
+			name:     "JSON format",
+			format:   "json",
+			contains: `"msg":"reconcile","duration":1000000000`,
 		},
 		{
-			name:      "Empty file",
-			file:      emptyFile.Name(),
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   false,
-		},
-		{
-			name:      "Invalid CSV format",
-			file:      invalidFile.Name(),
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   true,
+			name:    "Invalid format",
+			format:  "xml",
+			wantErr: true,
 		},
 	}
 
 	// Run each test case
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Parse the start and end dates
-			start, err := time.Parse("2006-01-02", tt.startDate)
-			assert.NoError(t, err)
-
-			// Parse the end date
-			end, err := time.Parse("2006-01-02", tt.endDate)
-			assert.NoError(t, err)
-
-			// Call the readSystemTransactions function
-			transactions, err := readSystemTransactions(tt.file, start, end)
+			// Call the newLogger function
+			var buf bytes.Buffer
+			l, err := newLogger(tt.format, &buf)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
 			}
-
-			// Check if the result matches the expected result
 			assert.NoError(t, err)
-			assert.Equal(t, tt.wantCount, len(transactions))
+
+			// Check the log line is written in the format
+			l.Info("reconcile", "duration", time.Second)
+			assert.Contains(t, buf.String(), tt.contains)
 		})
 	}
 }
 
-// TestReadBankStatements tests the readBankStatements function
-func TestReadBankStatements(t *testing.T) {
-	// Create temporary test files
-	tmpDir, err := os.MkdirTemp("", "test-bank-statements")
+// TestDiffFiles tests the diffFiles function
+func TestDiffFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Write two result files
+	previous := filepath.Join(tmpDir, "previous.json")
+	current := filepath.Join(tmpDir, "current.json")
+	assert.NoError(t, os.WriteFile(previous, []byte(`{"summary":{},"unmatched_details":{"system_transactions":[{"TrxID":"TRX1"}]}}`), 0o644))
+	assert.NoError(t, os.WriteFile(current, []byte(`{"summary":{},"unmatched_details":{"system_transactions":[{"TrxID":"TRX2"}]}}`), 0o644))
+
+	// Diff the files
+	var buf bytes.Buffer
+	assert.NoError(t, diffFiles(previous, current, &buf))
+	assert.Contains(t, buf.String(), "New unmatched items: 1\n")
+	assert.Contains(t, buf.String(), "Resolved unmatched items: 1\n")
+	assert.Contains(t, buf.String(), "- TrxID: TRX2")
+
+	// A missing file fails
+	assert.Error(t, diffFiles(filepath.Join(tmpDir, "missing.json"), current, &buf))
+}
+
+// TestApplyConfig tests filling flags from a config file with command line flags taking precedence
+func TestApplyConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Write a config file
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("system: sample/system.csv\n"+
+		"bank: sample/banks\n"+
+		"start: 2024-01-01\n"+
+		"percentage-tolerance: 0.5\n"+
+		"date-formats:\n  - 02/01/2006\n  - 2006-01-02\n"+
+		"delimiter: \";\"\n"+
+		"output: config.json\n"), 0o644))
+
+	// Define the flags and give the output on the command line
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.StringP("system", "s", "", "")
+		flags.StringP("bank", "b", "", "")
+		flags.StringP("start", "t", "", "")
+		flags.Float64("percentage-tolerance", 0, "")
+		flags.StringSlice("date-formats", nil, "")
+		flags.String("delimiter", ",", "")
+		flags.StringP("output", "o", "", "")
+		flags.String("config", "", "")
+		return flags
+	}
+	flags := newFlags()
+	assert.NoError(t, flags.Parse([]string{"-o", "cli.json"}))
+
+	// Apply the config file
+	assert.NoError(t, applyConfig(flags, configFile))
+
+	// Check the values from the config file
+	system, _ := flags.GetString("system")
+	assert.Equal(t, "sample/system.csv", system)
+	bank, _ := flags.GetString("bank")
+	assert.Equal(t, "sample/banks", bank)
+	start, _ := flags.GetString("start")
+	assert.Equal(t, "2024-01-01", start)
+	tolerance, _ := flags.GetFloat64("percentage-tolerance")
+	assert.Equal(t, 0.5, tolerance)
+	dateFormats, _ := flags.GetStringSlice("date-formats")
+	assert.Equal(t, []string{"02/01/2006", "2006-01-02"}, dateFormats)
+	delimiter, _ := flags.GetString("delimiter")
+	assert.Equal(t, ";", delimiter)
+
+	// Check the command line flag overrides the config file
+	output, _ := flags.GetString("output")
+	assert.Equal(t, "cli.json", output)
+
+	// Check unknown keys and invalid values are rejected
+	assert.NoError(t, os.WriteFile(configFile, []byte("unknown: true\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), configFile), `unknown config key "unknown"`)
+	assert.NoError(t, os.WriteFile(configFile, []byte("tolerance: 0.01\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), configFile), `unknown config key "tolerance"`)
+	assert.NoError(t, os.WriteFile(configFile, []byte("percentage-tolerance: high\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), configFile), `invalid config value for "percentage-tolerance"`)
+	assert.Error(t, applyConfig(newFlags(), filepath.Join(tmpDir, "missing.yaml")))
+
+	// Check the encryption key is not accepted in the config file
+	assert.NoError(t, os.WriteFile(configFile, []byte("encrypt-key: 00112233445566778899aabbccddeeff\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), configFile), "the encryption key cannot be stored in the config file")
+
+	// Check keys of flags the command does not have are ignored
+	assert.NoError(t, os.WriteFile(configFile, []byte("system: sample/system.csv\nid-matching: true\n"), 0o644))
+	flags = newFlags()
+	assert.NoError(t, applyConfig(flags, configFile))
+	system, _ = flags.GetString("system")
+	assert.Equal(t, "sample/system.csv", system)
+
+	// Write a TOML config file, dates and numbers reach the flags as written
+	tomlFile := filepath.Join(tmpDir, "config.toml")
+	assert.NoError(t, os.WriteFile(tomlFile, []byte("system = \"sample/system.csv\"\n"+
+		"start = 2024-01-01 09:00:00\n"+
+		"percentage-tolerance = 0.5\n"+
+		"date-formats = [\"02/01/2006\", \"2006-01-02\"]\n"+
+		"delimiter = \";\"\n"), 0o644))
+	flags = newFlags()
+	assert.NoError(t, applyConfig(flags, tomlFile))
+	system, _ = flags.GetString("system")
+	assert.Equal(t, "sample/system.csv", system)
+	start, _ = flags.GetString("start")
+	assert.Equal(t, "2024-01-01 09:00:00", start)
+	tolerance, _ = flags.GetFloat64("percentage-tolerance")
+	assert.Equal(t, 0.5, tolerance)
+	dateFormats, _ = flags.GetStringSlice("date-formats")
+	assert.Equal(t, []string{"02/01/2006", "2006-01-02"}, dateFormats)
+	delimiter, _ = flags.GetString("delimiter")
+	assert.Equal(t, ";", delimiter)
+
+	// Check a local date, unknown keys and unsupported TOML values
+	assert.NoError(t, os.WriteFile(tomlFile, []byte("start = 2024-01-01\n"), 0o644))
+	flags = newFlags()
+	assert.NoError(t, applyConfig(flags, tomlFile))
+	start, _ = flags.GetString("start")
+	assert.Equal(t, "2024-01-01", start)
+	assert.NoError(t, os.WriteFile(tomlFile, []byte("unknown = true\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), tomlFile), `unknown config key "unknown"`)
+	assert.NoError(t, os.WriteFile(tomlFile, []byte("[bank]\npath = \"sample/banks\"\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), tomlFile), `invalid value for "bank": unsupported TOML value`)
+	assert.NoError(t, os.WriteFile(tomlFile, []byte("system: sample/system.csv\n"), 0o644))
+	assert.ErrorContains(t, applyConfig(newFlags(), tomlFile), "invalid config file")
+}
+
+// TestRunReconcileTimings tests the JSON output records the time spent generating the result
+func TestRunReconcileTimings(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "result.json")
+
+	// Discard the logs, the run ID is added to the logger
+	previous := logger
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	defer func() { logger = previous }()
+
+	// Run the reconciliation with an output file
+	cmd := &cobra.Command{RunE: runReconcile}
+	addInputFlags(cmd.Flags())
+	addReconcileFlags(cmd.Flags())
+	cmd.SetArgs([]string{
+		"-s", "../sample/matched/system.csv",
+		"-b", "../sample/matched/mandiri.csv",
+		"-t", "2024-01-01",
+		"-e", "2024-01-31",
+		"-o", outputFile,
+	})
+	assert.NoError(t, cmd.Execute())
+
+	// Check the generate timing covers generating the result
+	data, err := os.ReadFile(outputFile)
 	assert.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
+	var result struct {
+		Summary struct {
+			Timings struct {
+				GenerateMs float64 `json:"generate_ms"`
+			} `json:"timings"`
+		} `json:"summary"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &result))
+	assert.Greater(t, result.Summary.Timings.GenerateMs, 0.0)
+}
 
-	// Create and write to test files
-	testFiles := []string{"bank1.csv", "bank2.csv"}
-	testData := `UniqueID,Amount,Date
-BS001,-100.0,2024-01-01
-BS002,200.0,2024-01-02`
+// TestLogFilteredRows tests logging the rows outside the date range of the system and bank files
+func TestLogFilteredRows(t *testing.T) {
+	// Capture the logs
+	var buf bytes.Buffer
+	previous := logger
+	logger = slog.New(slog.NewTextHandler(&buf, nil))
+	defer func() { logger = previous }()
 
-	// Create and write to test files
-	for _, file := range testFiles {
-		f, err := os.Create(filepath.Join(tmpDir, file))
-		assert.NoError(t, err)
-		_, err = f.WriteString(testData)
-		assert.NoError(t, err)
-		f.Close()
+	// Log the counts of a system file and two bank files
+	logFilteredRows([]string{"system.csv"}, map[string]int{"bri.csv": 0, "system.csv": 4, "bni.csv": 2})
+
+	// Check the system file comes first followed by the bank files in order
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `msg="4 system rows outside date range skipped" file=system.csv count=4`)
+	assert.Contains(t, lines[1], `msg="2 bank rows outside date range skipped" file=bni.csv count=2`)
+	assert.Contains(t, lines[2], `msg="0 bank rows outside date range skipped" file=bri.csv count=0`)
+}
+
+// TestLogEmptyResult tests warning about a date range missing the data apart from empty input files
+func TestLogEmptyResult(t *testing.T) {
+	// Capture the logs
+	var buf bytes.Buffer
+	previous := logger
+	logger = slog.New(slog.NewTextHandler(&buf, nil))
+	defer func() { logger = previous }()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Every row was outside the range
+	logEmptyResult(reconcile.ReconcileResult{RowsOutsideRange: 5}, start, end)
+	assert.Contains(t, buf.String(), `level=WARN msg="no transactions in the date range, all rows are outside it" start="2025-01-01 00:00:00" end="2025-01-31 00:00:00" rows_outside_range=5`)
+
+	// The input files were empty
+	buf.Reset()
+	logEmptyResult(reconcile.ReconcileResult{}, start, end)
+	assert.Contains(t, buf.String(), `level=WARN msg="no transactions read, the input files are empty"`)
+
+	// Nothing is logged when transactions were reconciled
+	buf.Reset()
+	logEmptyResult(reconcile.ReconcileResult{TransactionProcessed: 1, RowsOutsideRange: 5}, start, end)
+	assert.Empty(t, buf.String())
+}
+
+// TestResolveOutputFile tests naming the output file in the output directory
+func TestResolveOutputFile(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 2, 1, 9, 30, 15, 0, time.UTC)
+
+	// Without an output directory the output path is kept
+	filename, err := resolveOutputFile("result.json", "", "json", "run-1", start, end, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "result.json", filename)
+
+	// The missing output directory is created and the file is named after the range, time and run ID
+	outputDir := filepath.Join(t.TempDir(), "reports", "daily")
+	filename, err = resolveOutputFile("", outputDir, "json", "run-1", start, end, now)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(outputDir, "reconcile-2024-01-01-2024-01-31-20240201T093015-run-1.json"), filename)
+	assert.DirExists(t, outputDir)
+
+	// A result written to the path produces a file with the expected name pattern
+	result := reconcile.ReconcileResult{}
+	assert.NoError(t, result.GenerateJSON(filename))
+	matches, err := filepath.Glob(filepath.Join(outputDir, "reconcile-*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Regexp(t, `reconcile-\d{4}-\d{2}-\d{2}-\d{4}-\d{2}-\d{2}-\d{8}T\d{6}-run-1\.json$`, matches[0])
+
+	// The Markdown report gets its own extension
+	filename, err = resolveOutputFile("", outputDir, "md", "run-1", start, end, now)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(outputDir, "reconcile-2024-01-01-2024-01-31-20240201T093015-run-1.md"), filename)
+
+	// The output and output directory cannot be combined
+	_, err = resolveOutputFile("result.json", outputDir, "json", "run-1", start, end, now)
+	assert.Error(t, err)
+}
+
+// TestNewRunID tests generating and validating run IDs
+func TestNewRunID(t *testing.T) {
+	// A generated run ID is a version 4 UUID, unique per run
+	runID, err := newRunID()
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, runID)
+	other, err := newRunID()
+	assert.NoError(t, err)
+	assert.NotEqual(t, runID, other)
+	assert.NoError(t, validateRunID(runID))
+
+	// A given run ID must be usable in a file name
+	assert.NoError(t, validateRunID("nightly_2024.01.31"))
+	assert.Error(t, validateRunID("../run"))
+	assert.Error(t, validateRunID("run 1"))
+}
+
+// TestProcessBankFiles_FS tests collecting bank files from an in-memory file system
+func TestProcessBankFiles_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"banks/bri.csv":             {},
+		"banks/bni.xlsx":            {},
+		"banks/readme.txt":          {},
+		"banks/archive/2024.tgz":    {},
+		"banks/archive/old/bca.csv": {},
 	}
 
-	// Create invalid CSV file
-	invalidFile := filepath.Join(tmpDir, "invalid.csv")
-	f, err := os.Create(invalidFile)
+	// Read the directory
+	files, err := processBankFiles(fsys, "banks", false)
 	assert.NoError(t, err)
-	_, err = f.WriteString("invalid,csv\nformat,data")
+	assert.Equal(t, []string{"banks/bni.xlsx", "banks/bri.csv"}, files)
+
+	// Walk the directory tree
+	files, err = processBankFiles(fsys, "banks", true)
 	assert.NoError(t, err)
-	f.Close()
+	assert.Equal(t, []string{"banks/archive/2024.tgz", "banks/archive/old/bca.csv", "banks/bni.xlsx", "banks/bri.csv"}, files)
 
-	// Define test cases
-	tests := []struct {
-		name      string
-		files     []string
-		startDate string
-		endDate   string
-		wantCount int
-		wantErr   bool
-	}{
-		{
-			name:      "Multiple valid files",
-			files:     []string{filepath.Join(tmpDir, "bank1.csv"), filepath.Join(tmpDir, "bank2.csv")},
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 4, // 2 transactions per file
-			wantErr:   false,
-		},
-		{
-			name:      "Non-existent file",
-			files:     []string{filepath.Join(tmpDir, "nonexistent.csv")},
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   true,
-		},
-		{
-			name:      "Empty file list",
-			files:     []string{},
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   false,
-		},
-		{
-			name:      "Invalid CSV format",
-			files:     []string{invalidFile},
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   true,
-		},
-		{
-			name:      "Mix of valid and invalid files",
-			files:     []string{filepath.Join(tmpDir, "bank1.csv"), invalidFile},
-			startDate: "2024-01-01",
-			endDate:   "2024-01-02",
-			wantCount: 0,
-			wantErr:   true,
-		},
+	// Comma-separated files must exist
+	files, err = processBankFiles(fsys, "banks/bri.csv,banks/archive/old/bca.csv", false)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	_, err = processBankFiles(fsys, "banks/bri.csv,banks/missing.csv", false)
+	assert.Error(t, err)
+}
+
+// mockS3Client is an S3 client listing objects from memory
+type mockS3Client struct {
+	keys []string
+}
+
+// ListObjects returns the keys with the prefix
+func (m *mockS3Client) ListObjects(ctx context.Context, bucket, prefix string) ([]s3.Object, error) {
+	objects := []s3.Object{}
+	for _, key := range m.keys {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, s3.Object{Key: key})
+		}
 	}
+	return objects, nil
+}
 
-	// Run each test case
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Parse the start and end dates
-			start, err := time.Parse("2006-01-02", tt.startDate)
-			assert.NoError(t, err)
+// GetObject returns an empty object
+func (m *mockS3Client) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
 
-			// Parse the end date
-			end, err := time.Parse("2006-01-02", tt.endDate)
-			assert.NoError(t, err)
+// TestProcessBankFiles_S3 tests collecting bank files under an S3 prefix
+func TestProcessBankFiles_S3(t *testing.T) {
+	fsys := s3.NewFS(&mockS3Client{keys: []string{
+		"banks/bni.xlsx",
+		"banks/bri.csv",
+		"banks/readme.txt",
+		"banks/archive/2024.tgz",
+	}}, fstest.MapFS{"local/bca.csv": {}})
 
-			// Call the readBankStatements function
-			statements, err := readBankStatements(tt.files, start, end)
-			if tt.wantErr {
-				assert.Error(t, err)
-				return
-			}
+	// List the prefix
+	files, err := processBankFiles(fsys, "s3://bucket/banks/", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/banks/bni.xlsx", "s3://bucket/banks/bri.csv"}, files)
 
-			// Check if the result matches the expected result
-			assert.NoError(t, err)
-			assert.Equal(t, tt.wantCount, len(statements))
-		})
+	// List the sub-prefixes too
+	files, err = processBankFiles(fsys, "s3://bucket/banks/", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/banks/bni.xlsx", "s3://bucket/banks/bri.csv", "s3://bucket/banks/archive/2024.tgz"}, files)
+
+	// Mix S3 and local files
+	files, err = processBankFiles(fsys, "s3://bucket/banks/bri.csv,local/bca.csv", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/banks/bri.csv", "local/bca.csv"}, files)
+	_, err = processBankFiles(fsys, "s3://bucket/banks/missing.csv", false)
+	assert.Error(t, err)
+}
+
+// TestCheckMatchRate tests failing the run below the minimum match rate
+func TestCheckMatchRate(t *testing.T) {
+	result := reconcile.ReconcileResult{TransactionProcessed: 20, TransactionMatched: 19}
+
+	// The rate of 95% passes a minimum up to 95%
+	assert.NoError(t, checkMatchRate(&result, 0))
+	assert.NoError(t, checkMatchRate(&result, 95))
+
+	// A higher minimum fails with the rate and counts
+	err := checkMatchRate(&result, 99.5)
+	assert.EqualError(t, err, "match rate 95.00% (19 of 20 transactions) is below the minimum of 99.50%")
+
+	// A run without processed transactions passes
+	assert.NoError(t, checkMatchRate(&reconcile.ReconcileResult{}, 100))
+}
+
+// TestPostWebhook tests posting the JSON result and retrying failed requests
+func TestPostWebhook(t *testing.T) {
+	original := webhookBackoff
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = original }()
+
+	// Fail the first request with a 503, then accept
+	var requests int
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	// The body is the JSON result
+	result := reconcile.ReconcileResult{TransactionProcessed: 2, TransactionMatched: 1}
+	var body bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&body))
+	assert.NoError(t, postWebhook(server.URL, body.Bytes(), time.Second, 2))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, body.String(), string(received))
+
+	// A client error is not retried
+	requests = 0
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	err := postWebhook(rejecting.URL, body.Bytes(), time.Second, 2)
+	assert.EqualError(t, err, "webhook responded with 400 Bad Request")
+	assert.Equal(t, 1, requests)
+
+	// Server errors are retried up to the number of retries
+	requests = 0
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.Error(t, postWebhook(failing.URL, body.Bytes(), time.Second, 2))
+	assert.Equal(t, 3, requests)
+}
+
+// TestUseColor tests coloring the printed summary only on a terminal without --no-color or NO_COLOR
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+
+	// A regular file is not a terminal
+	file, err := os.Create(filepath.Join(t.TempDir(), "summary.txt"))
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.False(t, useColor(file, false))
+
+	// The null device is a character device like a terminal
+	null, err := os.Open(os.DevNull)
+	assert.NoError(t, err)
+	defer null.Close()
+	assert.True(t, useColor(null, false))
+
+	// --no-color and NO_COLOR disable the colors
+	assert.False(t, useColor(null, true))
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, useColor(null, false))
+}
+
+// TestCopySummary tests copying the text summary with a stubbed clipboard writer
+func TestCopySummary(t *testing.T) {
+	var copied string
+	original := copyToClipboard
+	copyToClipboard = func(text string) error {
+		copied = text
+		return nil
+	}
+	defer func() { copyToClipboard = original }()
+
+	// The copied text is the text summary
+	result := reconcile.ReconcileResult{TransactionProcessed: 20, TransactionMatched: 19}
+	var summary strings.Builder
+	assert.NoError(t, result.WriteSummary(&summary))
+	assert.NoError(t, copySummary(&result))
+	assert.Equal(t, summary.String(), copied)
+}
+
+// TestWriteClipboard tests the error on a system without a clipboard command
+func TestWriteClipboard(t *testing.T) {
+	original := clipboardCommands
+	clipboardCommands = [][]string{{"reconciliation-missing-clipboard"}}
+	defer func() { clipboardCommands = original }()
+
+	err := writeClipboard("summary")
+	assert.ErrorContains(t, err, "no clipboard command found")
+}
+
+// TestProcessSystemFiles tests collecting system files from a directory and a comma-separated list
+func TestProcessSystemFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system/10.csv":          {},
+		"system/11.xlsx":         {},
+		"system/bundle.tgz":      {},
+		"system/archive/09.csv":  {},
+		"system/readme.txt":      {},
+		"exports/system-12.csv":  {},
+		"exports/system-13.xlsx": {},
 	}
+
+	// Read the directory, bundles are not system files
+	files, err := processSystemFiles(fsys, "system", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"system/10.csv", "system/11.xlsx"}, files)
+
+	// Walk the directory tree
+	files, err = processSystemFiles(fsys, "system", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"system/10.csv", "system/11.xlsx", "system/archive/09.csv"}, files)
+
+	// Comma-separated files must exist
+	files, err = processSystemFiles(fsys, "exports/system-12.csv,exports/system-13.xlsx", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"exports/system-12.csv", "exports/system-13.xlsx"}, files)
+	_, err = processSystemFiles(fsys, "exports/system-12.csv,exports/missing.csv", false)
+	assert.Error(t, err)
+}
+
+// TestDecryptFile tests decrypting an encrypted JSON result file with a hex key
+func TestDecryptFile(t *testing.T) {
+	keyHex := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	key, err := reconcile.ParseEncryptionKey(keyHex)
+	assert.NoError(t, err)
+
+	// Write an encrypted result
+	result := reconcile.ReconcileResult{TransactionProcessed: 3, TransactionMatched: 3}
+	filename := filepath.Join(t.TempDir(), "result.json.enc")
+	assert.NoError(t, result.GenerateEncryptedJSON(filename, key))
+
+	// Decrypting yields the original JSON
+	var expected, decrypted bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&expected))
+	assert.NoError(t, decryptFile(filename, keyHex, &decrypted))
+	assert.Equal(t, expected.String(), decrypted.String())
+
+	// The key is required
+	assert.EqualError(t, decryptFile(filename, "", &decrypted), "--encrypt-key-file or --encrypt-key is required")
+}
+
+// TestResolveEncryptKey tests reading the encryption key from the key file or the flag
+func TestResolveEncryptKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("00112233445566778899aabbccddeeff\n"), 0o600))
+
+	// The flag is used without key file
+	keyHex, err := resolveEncryptKey("aabb", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "aabb", keyHex)
+
+	// The key file is read without the trailing newline
+	keyHex, err = resolveEncryptKey("", keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "00112233445566778899aabbccddeeff", keyHex)
+
+	// Both cannot be given, a missing or empty file fails
+	_, err = resolveEncryptKey("aabb", keyFile)
+	assert.EqualError(t, err, "--encrypt-key and --encrypt-key-file cannot be used together")
+	_, err = resolveEncryptKey("", filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "failed to read encryption key file")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("\n"), 0o600))
+	_, err = resolveEncryptKey("", keyFile)
+	assert.EqualError(t, err, "encryption key file "+keyFile+" is empty")
+}
+
+// TestOpenDB tests opening a database with the built-in SQLite driver and a driver missing from the build
+func TestOpenDB(t *testing.T) {
+	db, err := openDB("sqlite", filepath.Join(t.TempDir(), "transactions.db"))
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	_, err = openDB("missing-driver", "transactions.db")
+	assert.ErrorContains(t, err, `database driver "missing-driver" is not registered in this build`)
+}
+
+// TestParseDateTime tests parsing range bounds with and without a time of day
+func TestParseDateTime(t *testing.T) {
+	date, timeOfDay, err := parseDateTime("2024-01-02", time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), date)
+	assert.False(t, timeOfDay)
+
+	date, timeOfDay, err = parseDateTime("2024-01-02 17:30:00", time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 17, 30, 0, 0, time.UTC), date)
+	assert.True(t, timeOfDay)
+
+	// An explicit midnight is a time of day, not a date-only bound
+	date, timeOfDay, err = parseDateTime("2024-01-02 00:00:00", time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), date)
+	assert.True(t, timeOfDay)
+
+	_, _, err = parseDateTime("2024-01-02T17:30", time.UTC)
+	assert.Error(t, err)
+}
+
+// TestParseBankTolerances tests parsing the tolerances per bank
+func TestParseBankTolerances(t *testing.T) {
+	tolerances, err := parseBankTolerances("BankA=0.25, BankB = 0.00")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"BankA": 0.25, "BankB": 0}, tolerances)
+
+	tolerances, err = parseBankTolerances("")
+	assert.NoError(t, err)
+	assert.Nil(t, tolerances)
+
+	for _, value := range []string{"BankA", "BankA=fee", "=0.25", "BankA=-0.25"} {
+		_, err = parseBankTolerances(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// TestReadHolidays tests reading the holidays file of the business day window
+func TestReadHolidays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# 2024\n2024-03-29\n\n 2024-04-10 \n"), 0o644))
+	holidays, err := readHolidays(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 3, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC),
+	}, holidays)
+
+	// An invalid date reports its line
+	assert.NoError(t, os.WriteFile(path, []byte("2024-03-29\n29/03/2024\n"), 0o644))
+	_, err = readHolidays(path)
+	assert.ErrorContains(t, err, `invalid holiday "29/03/2024" on line 2`)
+}
+
+// TestParseHistogramEdges tests parsing the discrepancy histogram edges
+func TestParseHistogramEdges(t *testing.T) {
+	edges, err := parseHistogramEdges("0, 0.01,0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 0.01, 0.1}, edges)
+
+	_, err = parseHistogramEdges("0,-1")
+	assert.Error(t, err)
+
+	_, err = parseHistogramEdges("0,,1")
+	assert.Error(t, err)
 }
@@ -0,0 +1,150 @@
+package csv
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// systemFields are the fields of a system transaction row in the default column order
+var systemFields = []string{"trxid", "amount", "type", "transactiontime"}
+
+// bankFields are the fields of a bank statement row in the default column order, the direction is optional
+var bankFields = []string{"uniqueid", "amount", "date", "direction"}
+
+// batchField is the optional settlement batch ID field of system and bank rows, it is not part of the default columns
+const batchField = "batchid"
+
+// currencyField is the optional currency code field of system and bank rows, it is not part of the default columns
+const currencyField = "currency"
+
+// referenceField is the optional payment reference field of system and bank rows, it is not part of the default columns
+const referenceField = "reference"
+
+// Columns maps the fields of a row to the column indexes they are read from, other columns are ignored
+type Columns struct {
+	// Column index of each mapped field in the default column order
+	indexes []int
+
+	// Column index of the settlement batch ID, -1 when not mapped
+	batch int
+
+	// Column index of the currency code, -1 when not mapped
+	currency int
+
+	// Column index of the payment reference, -1 when not mapped
+	reference int
+}
+
+// ParseSystemColumns parses a system column spec like trxid=0,amount=1,type=2,transactiontime=3
+// All fields are required, an optional batchid field reads the settlement batch ID, a currency field the currency code
+// and a reference field the payment reference
+func ParseSystemColumns(spec string) (*Columns, error) {
+	return parseColumns(spec, systemFields, len(systemFields))
+}
+
+// ParseBankColumns parses a bank column spec like uniqueid=0,amount=1,date=2
+// The direction field is optional and reads a D/C direction column when given, so are the batchid, currency and reference fields
+func ParseBankColumns(spec string) (*Columns, error) {
+	return parseColumns(spec, bankFields, 3)
+}
+
+// parseColumns parses a comma-separated list of field=index pairs, the first required fields must be given
+func parseColumns(spec string, fields []string, required int) (*Columns, error) {
+	// Parse the pairs
+	indexes := make(map[string]int, len(fields))
+	used := make(map[int]string, len(fields))
+	for _, pair := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || (!slices.Contains(fields, name) && name != batchField && name != currencyField && name != referenceField) {
+			return nil, fmt.Errorf("invalid column %q, use field=index with the fields %s, %s, %s, %s",
+				pair, strings.Join(fields, ", "), batchField, currencyField, referenceField)
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid column index %q for %s", value, name)
+		}
+		if _, ok := indexes[name]; ok {
+			return nil, fmt.Errorf("duplicate column field %s", name)
+		}
+		if other, ok := used[index]; ok {
+			return nil, fmt.Errorf("column %d is mapped to both %s and %s", index, other, name)
+		}
+		indexes[name] = index
+		used[index] = name
+	}
+
+	// Order the indexes by field, the optional fields are at the end
+	columns := &Columns{batch: -1, currency: -1, reference: -1}
+	if index, ok := indexes[batchField]; ok {
+		columns.batch = index
+	}
+	if index, ok := indexes[currencyField]; ok {
+		columns.currency = index
+	}
+	if index, ok := indexes[referenceField]; ok {
+		columns.reference = index
+	}
+	for i, name := range fields {
+		index, ok := indexes[name]
+		if !ok {
+			if i < required {
+				return nil, fmt.Errorf("missing column for %s", name)
+			}
+			continue
+		}
+		columns.indexes = append(columns.indexes, index)
+	}
+	return columns, nil
+}
+
+// field returns the column index of the field at the position in the default column order, -1 when not mapped
+func (c *Columns) field(position int) int {
+	if position >= len(c.indexes) {
+		return -1
+	}
+	return c.indexes[position]
+}
+
+// project returns the mapped columns of the record in the default column order
+func (c *Columns) project(record []string) ([]string, error) {
+	for _, index := range []int{c.batch, c.currency, c.reference} {
+		if index >= len(record) {
+			return nil, fmt.Errorf("expected at least %d columns but got %d", index+1, len(record))
+		}
+	}
+	projected := make([]string, len(c.indexes))
+	for i, index := range c.indexes {
+		if index >= len(record) {
+			return nil, fmt.Errorf("expected at least %d columns but got %d", index+1, len(record))
+		}
+		projected[i] = record[index]
+	}
+	return projected, nil
+}
+
+// batchID returns the settlement batch ID of the record, empty when the batch column is not mapped
+func (c *Columns) batchID(record []string) string {
+	if c.batch < 0 || c.batch >= len(record) {
+		return ""
+	}
+	return record[c.batch]
+}
+
+// currencyCode returns the upper case currency code of the record, empty when the currency column is not mapped
+func (c *Columns) currencyCode(record []string) string {
+	if c.currency < 0 || c.currency >= len(record) {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSpace(record[c.currency]))
+}
+
+// referenceOf returns the payment reference of the record, empty when the reference column is not mapped
+func (c *Columns) referenceOf(record []string) string {
+	if c.reference < 0 || c.reference >= len(record) {
+		return ""
+	}
+	return record[c.reference]
+}
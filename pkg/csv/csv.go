@@ -3,18 +3,29 @@ package csv
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
+	"math"
 	"path/filepath"
 	"reconciliation/pkg/types"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
 // NewCSVReader creates a new CSVReader
+// The number of fields is checked per row against the expected columns, so rows of any length are read
+// and jagged rows are reported as an invalid format with their row instead of failing the whole read
 func NewCSVReader(reader *csv.Reader, opts ...Option) *CSVReaderImpl {
+	reader.FieldsPerRecord = -1
+
 	// Initialize the CSVReaderImpl
 	r := &CSVReaderImpl{
-		reader: reader,
+		reader:                reader,
+		dateFormats:           defaultDateFormats,
+		bankNameColumn:        -1,
+		rejectNegativeAmounts: true,
+		trimFields:            true,
 	}
 
 	// Apply options
@@ -26,12 +37,28 @@ func NewCSVReader(reader *csv.Reader, opts ...Option) *CSVReaderImpl {
 	return r
 }
 
+// Filtered returns the number of rows skipped by the time range filter in the last read
+func (r *CSVReaderImpl) Filtered() int {
+	return r.filtered
+}
+
+// ZeroAmounts returns the number of rows with a zero amount excluded in the last read
+func (r *CSVReaderImpl) ZeroAmounts() int {
+	return r.zeroAmounts
+}
+
+// Warnings returns the warnings about the rows of the last read, e.g. future dates with WithFlagFutureDates
+func (r *CSVReaderImpl) Warnings() []string {
+	return r.warnings
+}
+
 // ReadSystemTransactionsFromCSV reads a CSV file and parses it into a slice of Transaction
 func (r *CSVReaderImpl) ReadSystemTransactionsFromCSV() ([]types.Transaction, error) {
-	// Read all records from the CSV file
-	records, err := r.reader.ReadAll()
+	// Read all records from the CSV file, xlsx date cells in the transaction time format
+	r.setXLSXDateLayout("2006-01-02 15:04:05")
+	records, err := r.readRecords()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+		return nil, fmt.Errorf("failed to read CSV %s: %w", r.fileLabel(), err)
 	}
 
 	// If there are no records, return an empty slice
@@ -44,51 +71,100 @@ func (r *CSVReaderImpl) ReadSystemTransactionsFromCSV() ([]types.Transaction, er
 
 	// Check time range once
 	hasTimeRange := !r.start.IsZero() && !r.end.IsZero()
+	r.filtered = 0
+	r.zeroAmounts = 0
+	r.warnings = nil
+	now := time.Now()
 
-	// Determine starting index based on skipHeader flag
-	startIdx := 0
-	if r.skipHeader {
-		startIdx = 1
+	// Determine the amount column, it is mapped with custom columns
+	amountIdx := 1
+	if r.systemColumns != nil {
+		amountIdx = r.systemColumns.field(1)
+	}
+
+	// Determine starting index based on skipHeader flag or header detection
+	startIdx := r.startIndex(records, func(record []string) bool {
+		return r.isAmountColumn(record, amountIdx)
+	})
+	records = r.sampleRecords(records, startIdx)
+	if err := r.checkMaxRows(records, startIdx); err != nil {
+		return nil, err
 	}
 
 	// Iterate over the records
 	for i, record := range records[startIdx:] {
-		// Check if the record has the correct number of columns
+		// Skip empty records, e.g. a blank last line or a row of empty fields
+		if isEmptyRecord(record) {
+			continue
+		}
+
+		// Take the mapped columns in the default order, extra columns are ignored
+		var batchID, currency, reference string
+		if r.systemColumns != nil {
+			batchID = r.systemColumns.batchID(record)
+			currency = r.systemColumns.currencyCode(record)
+			reference = r.systemColumns.referenceOf(record)
+			record, err = r.systemColumns.project(record)
+			if err != nil {
+				return nil, fmt.Errorf("invalid format [%s] in %s: %w", formatRecord(records[i+startIdx]), r.location(i+startIdx+1), err)
+			}
+		}
+
+		// Check if the record has the correct number of columns, a bank file has 3 columns
 		if len(record) != 4 {
-			return nil, fmt.Errorf("invalid format [%s] in row %d of file", strings.Join(record, ","), i+startIdx+1)
+			hint := ""
+			if len(record) == 3 {
+				hint = ", did you pass a bank file?"
+			}
+			return nil, fmt.Errorf("invalid format [%s] in %s: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got %d%s",
+				formatRecord(record), r.location(i+startIdx+1), len(record), hint)
 		}
 
 		// Parse the amount
-		amount, err := strconv.ParseFloat(record[1], 64)
+		amount, err := r.parseAmount(record[1])
 		if err != nil {
-			return nil, fmt.Errorf("invalid amount [%s] in row %d of file", record[1], i+startIdx+1)
+			return nil, fmt.Errorf("invalid amount [%s] in %s", record[1], r.location(i+startIdx+1))
 		}
 
-		// Check negative amount
-		if amount < 0 {
-			return nil, fmt.Errorf("negative amount [%s] in row %d of file", record[1], i+startIdx+1)
+		// Check negative amount when rejected
+		if amount < 0 && r.rejectNegativeAmounts {
+			return nil, fmt.Errorf("negative amount [%s] in %s", record[1], r.location(i+startIdx+1))
 		}
 
 		// Parse date in YYYY-MM-DD HH:MM:SS format
-		date, err := time.Parse("2006-01-02 15:04:05", record[3])
+		date, err := time.ParseInLocation("2006-01-02 15:04:05", record[3], r.dateLocation())
 		if err != nil {
-			return nil, fmt.Errorf("invalid date [%s] in row %d of file", record[3], i+startIdx+1)
+			return nil, fmt.Errorf("invalid date [%s] in %s", record[3], r.location(i+startIdx+1))
+		}
+
+		// Warn about a transaction time after now when flagged
+		if r.flagFutureDates && date.After(now) {
+			r.warnings = append(r.warnings, fmt.Sprintf("future date [%s] in %s", record[3], r.location(i+startIdx+1)))
 		}
 
 		// Skip if outside time range when range is set
 		if hasTimeRange {
-			dateForComparison := date.Truncate(24 * time.Hour)
-			if dateForComparison.Before(r.start) || dateForComparison.After(r.end) {
+			if !inTimeRange(date, r.start, r.end, r.endTimeOfDay) {
+				r.filtered++
 				continue
 			}
 		}
 
+		// Skip zero amounts when rejected
+		if r.rejectZeroAmounts && amount == 0 {
+			r.zeroAmounts++
+			continue
+		}
+
 		// Append the transaction to the slice
 		transactions = append(transactions, types.Transaction{
 			TrxID:           record[0],
 			Amount:          amount,
 			Type:            types.TransactionType(record[2]),
 			TransactionTime: date,
+			BatchID:         batchID,
+			Currency:        currency,
+			Reference:       reference,
 		})
 	}
 
@@ -98,10 +174,11 @@ func (r *CSVReaderImpl) ReadSystemTransactionsFromCSV() ([]types.Transaction, er
 
 // ReadBankStatementsFromCSV reads a CSV file and parses it into a slice of BankStatement
 func (r *CSVReaderImpl) ReadBankStatementsFromCSV() ([]types.BankStatement, error) {
-	// Read all records from the CSV file
-	records, err := r.reader.ReadAll()
+	// Read all records from the CSV file, xlsx date cells in the first date format
+	r.setXLSXDateLayout(r.dateFormats[0])
+	records, err := r.readRecords()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+		return nil, fmt.Errorf("failed to read CSV %s: %w", r.fileLabel(), err)
 	}
 
 	// If there are no records, return an empty slice
@@ -114,53 +191,457 @@ func (r *CSVReaderImpl) ReadBankStatementsFromCSV() ([]types.BankStatement, erro
 
 	// Check time range once
 	hasTimeRange := !r.start.IsZero() && !r.end.IsZero()
+	r.filtered = 0
+	r.zeroAmounts = 0
+	r.warnings = nil
+	now := time.Now()
 
-	// Determine starting index based on skipHeader flag
-	startIdx := 0
-	if r.skipHeader {
-		startIdx = 1
+	// Determine the expected number of columns, custom columns map the direction column themselves
+	directionColumn := r.directionColumn
+	if r.bankColumns != nil {
+		if r.bankNameColumn >= 0 || r.debitCreditColumns {
+			return nil, fmt.Errorf("bank columns cannot be combined with a bank name column or debit and credit columns")
+		}
+		directionColumn = r.bankColumns.field(3) >= 0
+	}
+	columns := 3
+	if directionColumn {
+		columns++
+	}
+	if r.bankNameColumn >= 0 {
+		columns++
+		if r.bankNameColumn >= columns {
+			return nil, fmt.Errorf("invalid bank name column %d for %d columns", r.bankNameColumn, columns)
+		}
+	}
+	if r.debitCreditColumns {
+		columns++
+		if err := r.checkDebitCreditColumns(columns); err != nil {
+			return nil, err
+		}
 	}
 
-	// Get bank name from filename
+	// Determine the amount column, it shifts when the bank name column comes before it
+	amountIdx := 1
+	if r.bankNameColumn >= 0 && r.bankNameColumn <= amountIdx {
+		amountIdx++
+	}
+	if r.bankColumns != nil {
+		amountIdx = r.bankColumns.field(1)
+	}
+
+	// Determine starting index based on skipHeader flag or header detection
+	startIdx := r.startIndex(records, func(record []string) bool {
+		if r.debitCreditColumns {
+			// Records too short to have the columns are data records, so the column count check reports them
+			if len(record) <= max(r.debitColumn, r.creditColumn) {
+				return true
+			}
+			_, err := r.parseDebitCredit(record)
+			return err == nil
+		}
+		return r.isAmountColumn(record, amountIdx)
+	})
+	records = r.sampleRecords(records, startIdx)
+	if err := r.checkMaxRows(records, startIdx); err != nil {
+		return nil, err
+	}
+
+	// Get bank name from filename or parent directory
 	bankName := filepath.Base(r.filename)
+	if r.bankNameFromDir {
+		bankName = filepath.Base(filepath.Dir(r.filename))
+	}
 	bankName = strings.TrimSuffix(bankName, filepath.Ext(bankName))
 	bankName = strings.ToUpper(bankName)
 
 	// Iterate over the records
 	for i, record := range records[startIdx:] {
+		// Skip empty records, e.g. a blank last line or a row of empty fields
+		if isEmptyRecord(record) {
+			continue
+		}
+
+		// Take the mapped columns in the default order, extra columns are ignored
+		var batchID, currency, reference string
+		if r.bankColumns != nil {
+			batchID = r.bankColumns.batchID(record)
+			currency = r.bankColumns.currencyCode(record)
+			reference = r.bankColumns.referenceOf(record)
+			record, err = r.bankColumns.project(record)
+			if err != nil {
+				return nil, fmt.Errorf("invalid format [%s] in %s: %w", formatRecord(records[i+startIdx]), r.location(i+startIdx+1), err)
+			}
+		}
+
 		// Check if the record has the correct number of columns
-		if len(record) != 3 {
-			return nil, fmt.Errorf("invalid format [%s] in row %d of file", strings.Join(record, ","), i+startIdx+1)
+		if len(record) != columns {
+			return nil, fmt.Errorf("invalid format [%s] in %s", formatRecord(record), r.location(i+startIdx+1))
 		}
 
-		// Parse the amount
-		amount, err := strconv.ParseFloat(record[1], 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid amount [%s] in row %d of file", record[1], i+startIdx+1)
+		// Take the bank name from its column when set, falling back to the filename
+		statementBankName := bankName
+		if r.bankNameColumn >= 0 {
+			if name := strings.TrimSpace(record[r.bankNameColumn]); name != "" {
+				statementBankName = strings.ToUpper(name)
+			}
+		}
+
+		// Parse the amount from the debit and credit columns or the signed amount column
+		var amount float64
+		if r.debitCreditColumns {
+			amount, err = r.parseDebitCredit(record)
+			if err != nil {
+				return nil, fmt.Errorf("%w in %s", err, r.location(i+startIdx+1))
+			}
+
+			// Replace the debit and credit columns with an empty amount column to keep the column order
+			record = removeColumns(record, r.bankNameColumn, r.debitColumn, r.creditColumn)
+			record = append(record[:1], append([]string{""}, record[1:]...)...)
+		} else {
+			record = removeColumns(record, r.bankNameColumn)
+			amount, err = r.parseAmount(record[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid amount [%s] in %s", record[1], r.location(i+startIdx+1))
+			}
 		}
+		amount = r.scaleBankAmount(amount)
 
-		// Parse date in YYYY-MM-DD format
-		date, err := time.Parse("2006-01-02", record[2])
+		// Parse date in YYYY-MM-DD format or any of the configured layouts
+		date, err := r.parseDate(record[2])
 		if err != nil {
-			return nil, fmt.Errorf("invalid date [%s] in row %d of file", record[2], i+startIdx+1)
+			return nil, fmt.Errorf("invalid date [%s] in %s", record[2], r.location(i+startIdx+1))
+		}
+
+		// Warn about a date after today when flagged
+		if r.flagFutureDates && date.After(truncateToDay(now.In(r.dateLocation()))) {
+			r.warnings = append(r.warnings, fmt.Sprintf("future date [%s] in %s", record[2], r.location(i+startIdx+1)))
 		}
 
 		// Skip if outside time range when range is set
 		if hasTimeRange {
-			if date.Before(r.start) || date.After(r.end) {
+			if !inTimeRange(date, truncateToDay(r.start), truncateToDay(r.end), false) {
+				r.filtered++
 				continue
 			}
 		}
 
+		// Skip zero amounts when rejected
+		if r.rejectZeroAmounts && amount == 0 {
+			r.zeroAmounts++
+			continue
+		}
+
+		// Parse the direction when the column is present
+		var direction types.TransactionType
+		if directionColumn {
+			direction, err = parseDirection(record[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid direction [%s] in %s", record[3], r.location(i+startIdx+1))
+			}
+		}
+
 		// Append the statement to the slice
 		statements = append(statements, types.BankStatement{
-			BankName: bankName,
-			UniqueID: record[0],
-			Amount:   amount,
-			Date:     date,
+			BankName:  statementBankName,
+			UniqueID:  record[0],
+			Amount:    amount,
+			Date:      date,
+			Direction: direction,
+			BatchID:   batchID,
+			Currency:  currency,
+			Reference: reference,
 		})
 	}
 
 	// Return the statements
 	return statements, nil
 }
+
+// scaleBankAmount converts the bank statement amount with the configured scale
+// Dividing by the inverse keeps 12345 * 0.01 at 123.45 instead of 123.45000000000002
+func (r *CSVReaderImpl) scaleBankAmount(amount float64) float64 {
+	if r.bankAmountScale == 0 || r.bankAmountScale == 1 {
+		return amount
+	}
+	return amount / (1 / r.bankAmountScale)
+}
+
+// parseAmount parses the amount using the configured amount format
+func (r *CSVReaderImpl) parseAmount(value string) (float64, error) {
+	format := r.amountFormat
+
+	// Parse plain amounts directly
+	if format.GroupSeparator == "" && format.DecimalSeparator == "" && len(format.CurrencyPrefixes) == 0 {
+		return strconv.ParseFloat(value, 64)
+	}
+
+	// Strip the sign, it can be written before or after the currency prefix
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	// Strip the currency prefix
+	for _, prefix := range format.CurrencyPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			value = strings.TrimSpace(strings.TrimPrefix(value, prefix))
+			break
+		}
+	}
+	if !negative && strings.HasPrefix(value, "-") {
+		negative = true
+		value = strings.TrimPrefix(value, "-")
+	}
+
+	// Remove the grouping separators and normalize the decimal separator
+	if format.GroupSeparator != "" {
+		value = strings.ReplaceAll(value, format.GroupSeparator, "")
+	}
+	if format.DecimalSeparator != "" && format.DecimalSeparator != "." {
+		value = strings.Replace(value, format.DecimalSeparator, ".", 1)
+	}
+
+	// Restore the sign
+	if negative {
+		value = "-" + value
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseDate parses the bank statement date with the configured layouts and truncates it to the day
+func (r *CSVReaderImpl) parseDate(value string) (time.Time, error) {
+	// Integer dates are epoch timestamps when enabled
+	if r.epochDates {
+		if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+			date := time.Unix(epoch, 0)
+			if epoch >= epochMillisThreshold || epoch <= -epochMillisThreshold {
+				date = time.UnixMilli(epoch)
+			}
+			return truncateToDay(date.In(r.dateLocation())), nil
+		}
+	}
+
+	var err error
+	for _, layout := range r.dateFormats {
+		var date time.Time
+		date, err = time.ParseInLocation(layout, value, r.dateLocation())
+		if err == nil {
+			return truncateToDay(date), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// dateLocation returns the location the dates are interpreted in
+func (r *CSVReaderImpl) dateLocation() *time.Location {
+	if r.timezone == nil {
+		return time.UTC
+	}
+	return r.timezone
+}
+
+// inTimeRange checks if the timestamp is within the range, the start is inclusive
+// An end with a time of day is compared with the full timestamp, a date-only end includes its whole day
+func inTimeRange(date, start, end time.Time, endTimeOfDay bool) bool {
+	if date.Before(start) {
+		return false
+	}
+	if endTimeOfDay {
+		return !date.After(end)
+	}
+	return !truncateToDay(date).After(truncateToDay(end))
+}
+
+// truncateToDay returns midnight of the day of the date in its own location
+func truncateToDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
+
+// parseDirection parses a D/C or DEBIT/CREDIT direction indicator
+func parseDirection(value string) (types.TransactionType, error) {
+	switch strings.ToUpper(value) {
+	case "D", "DEBIT":
+		return types.TransactionTypeDebit, nil
+	case "C", "CREDIT":
+		return types.TransactionTypeCredit, nil
+	default:
+		return "", fmt.Errorf("invalid direction: %s", value)
+	}
+}
+
+// formatRecord reconstructs a record for error messages
+// Fields are joined with | so that commas inside quoted fields are not mistaken for separators
+func formatRecord(record []string) string {
+	return strings.Join(record, "|")
+}
+
+// isEmptyRecord checks if the record has no fields or only blank fields
+func isEmptyRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readRecords reads the records of the file and trims the fields when enabled
+func (r *CSVReaderImpl) readRecords() ([][]string, error) {
+	records, err := r.readRawRecords()
+	if err != nil || !r.trimFields {
+		return records, err
+	}
+	for _, record := range records {
+		for i, field := range record {
+			record[i] = strings.TrimSpace(field)
+		}
+	}
+	return records, nil
+}
+
+// readRawRecords reads the records of the file
+// A reader reporting positions records the line each record starts on, as quoted fields can span lines
+// With a row limit, it stops once enough records are read to exceed the limit, with a sample once the sample is read
+func (r *CSVReaderImpl) readRawRecords() ([][]string, error) {
+	r.lines = nil
+	rowReader, ok := r.reader.(lineReader)
+	if !ok {
+		return r.reader.ReadAll()
+	}
+
+	// Read one record more than the limit or the sample size, plus a possible header row
+	limit := 0
+	if r.maxRows > 0 {
+		limit = r.maxRows + 2
+	}
+	if r.sampleRows > 0 && (limit == 0 || r.sampleRows+1 < limit) {
+		limit = r.sampleRows + 1
+	}
+	records := [][]string{}
+	for limit == 0 || len(records) < limit {
+		record, err := rowReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		line, _ := rowReader.FieldPos(0)
+		r.lines = append(r.lines, line)
+	}
+	return records, nil
+}
+
+// sampleRecords keeps the header and the first data rows of the sample, all records without a sample
+func (r *CSVReaderImpl) sampleRecords(records [][]string, startIdx int) [][]string {
+	if r.sampleRows > 0 && len(records)-startIdx > r.sampleRows {
+		return records[:startIdx+r.sampleRows]
+	}
+	return records
+}
+
+// checkMaxRows checks the number of data rows after the header does not exceed the row limit
+func (r *CSVReaderImpl) checkMaxRows(records [][]string, startIdx int) error {
+	if r.maxRows > 0 && len(records)-startIdx > r.maxRows {
+		return fmt.Errorf("%s exceeds the limit of %d data rows", r.fileLabel(), r.maxRows)
+	}
+	return nil
+}
+
+// startIndex returns the index of the first data record
+// With header auto-detection, the first record is a header if it is not a data record
+func (r *CSVReaderImpl) startIndex(records [][]string, isData func(record []string) bool) int {
+	if r.autoHeaderDetection {
+		if isData(records[0]) {
+			return 0
+		}
+		return 1
+	}
+
+	if r.skipHeader {
+		return 1
+	}
+	return 0
+}
+
+// isAmountColumn checks if the record has a valid amount at the index
+// Records too short to have the column are data records, so the column count check reports them
+func (r *CSVReaderImpl) isAmountColumn(record []string, amountIdx int) bool {
+	if len(record) <= amountIdx {
+		return true
+	}
+	_, err := r.parseAmount(record[amountIdx])
+	return err == nil
+}
+
+// checkDebitCreditColumns checks the debit and credit columns are distinct columns of the row
+func (r *CSVReaderImpl) checkDebitCreditColumns(columns int) error {
+	for _, idx := range []int{r.debitColumn, r.creditColumn} {
+		if idx < 0 || idx >= columns || idx == r.bankNameColumn {
+			return fmt.Errorf("invalid debit and credit columns %d and %d for %d columns", r.debitColumn, r.creditColumn, columns)
+		}
+	}
+	if r.debitColumn == r.creditColumn {
+		return fmt.Errorf("invalid debit and credit columns %d and %d for %d columns", r.debitColumn, r.creditColumn, columns)
+	}
+	return nil
+}
+
+// parseDebitCredit parses the signed amount from the debit and credit columns of the record
+// Exactly one column must be filled, credits are positive and debits negative
+func (r *CSVReaderImpl) parseDebitCredit(record []string) (float64, error) {
+	if len(record) <= r.debitColumn || len(record) <= r.creditColumn {
+		return 0, fmt.Errorf("missing debit and credit columns")
+	}
+	debit := strings.TrimSpace(record[r.debitColumn])
+	credit := strings.TrimSpace(record[r.creditColumn])
+
+	switch {
+	case debit != "" && credit != "":
+		return 0, fmt.Errorf("both debit [%s] and credit [%s] amounts", debit, credit)
+	case debit != "":
+		amount, err := r.parseAmount(debit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid debit amount [%s]", debit)
+		}
+		return -math.Abs(amount), nil
+	case credit != "":
+		amount, err := r.parseAmount(credit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid credit amount [%s]", credit)
+		}
+		return math.Abs(amount), nil
+	default:
+		return 0, fmt.Errorf("missing debit and credit amounts")
+	}
+}
+
+// fileLabel returns "file" followed by the filename when it is set
+func (r *CSVReaderImpl) fileLabel() string {
+	if r.filename == "" {
+		return "file"
+	}
+	return "file " + r.filename
+}
+
+// location describes the row of the file for error messages
+// The line is added when an earlier record spans several lines, so the row is not on the line of the same number
+func (r *CSVReaderImpl) location(row int) string {
+	if row-1 < len(r.lines) && r.lines[row-1] != row {
+		return fmt.Sprintf("row %d (line %d) of %s", row, r.lines[row-1], r.fileLabel())
+	}
+	return fmt.Sprintf("row %d of %s", row, r.fileLabel())
+}
+
+// removeColumns returns a copy of the record without the columns at the indexes, negative indexes are ignored
+func removeColumns(record []string, indexes ...int) []string {
+	result := make([]string, 0, len(record))
+	for i, field := range record {
+		if !slices.Contains(indexes, i) {
+			result = append(result, field)
+		}
+	}
+	return result
+}
@@ -1,9 +1,12 @@
 package csv
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/csv"
+	"os"
 	"reconciliation/pkg/types"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,6 +32,7 @@ func (s *CSVReaderTestSuite) TestReadSystemTransactionsFromCSV() {
 		csvContent    string
 		timeRange     *struct{ start, end time.Time }
 		skipHeader    bool
+		allowNegative bool
 		expected      []types.Transaction
 		expectedError string
 	}{
@@ -61,6 +65,28 @@ TX002,-200.0,CREDIT,2024-01-02 10:00:00`,
 			skipHeader:    true,
 			expectedError: "negative amount [-100.0] in row 2 of file",
 		},
+		{
+			name: "system transactions with negative amounts allowed",
+			csvContent: `TrxID,Amount,Type,TransactionTime
+TX001,-100.0,DEBIT,2024-01-01 10:00:00
+TX002,-200.0,CREDIT,2024-01-02 10:00:00`,
+			skipHeader:    true,
+			allowNegative: true,
+			expected: []types.Transaction{
+				{
+					TrxID:           "TX001",
+					Amount:          -100.0,
+					Type:            types.TransactionTypeDebit,
+					TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				},
+				{
+					TrxID:           "TX002",
+					Amount:          -200.0,
+					Type:            types.TransactionTypeCredit,
+					TransactionTime: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		},
 		{
 			name: "invalid amount format",
 			csvContent: `TrxID,Amount,Type,TransactionTime
@@ -113,14 +139,28 @@ TX003,300.0,DEBIT,2024-01-03 10:00:00`,
 			csvContent: `TrxID,Amount,Type
 TX001,100.0,DEBIT`,
 			skipHeader:    true,
-			expectedError: "invalid format [TX001,100.0,DEBIT] in row 2 of file",
+			expectedError: "invalid format [TX001|100.0|DEBIT] in row 2 of file: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 3, did you pass a bank file?",
+		},
+		{
+			name: "bank file passed as system file",
+			csvContent: `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01`,
+			skipHeader:    true,
+			expectedError: "invalid format [BS001|-100.0|2024-01-01] in row 2 of file: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 3, did you pass a bank file?",
+		},
+		{
+			name: "single column",
+			csvContent: `TrxID,Amount,Type,TransactionTime
+TX001`,
+			skipHeader:    true,
+			expectedError: "invalid format [TX001] in row 2 of file: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 1",
 		},
 		{
 			name: "too many columns",
 			csvContent: `TrxID,Amount,Type,TransactionTime
 TX001,100.0,DEBIT,2024-01-01 10:00:00,extra`,
 			skipHeader:    true,
-			expectedError: "failed to read CSV file: record on line 2: wrong number of fields",
+			expectedError: "invalid format [TX001|100.0|DEBIT|2024-01-01 10:00:00|extra] in row 2 of file: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 5",
 		},
 		{
 			name:       "completely empty file",
@@ -154,6 +194,9 @@ TX001,100.0,DEBIT,2024-01-01 10:00:00`,
 			if tc.skipHeader {
 				opts = append(opts, WithSkipHeader(true))
 			}
+			if tc.allowNegative {
+				opts = append(opts, WithRejectNegativeAmounts(false))
+			}
 			csvReader := NewCSVReader(reader, opts...)
 
 			// Read the system transactions
@@ -204,13 +247,28 @@ BS002,200.0,2024-01-02`,
 				},
 			},
 		},
+		{
+			name: "date with time component",
+			csvContent: `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01 14:30:00`,
+			filename:   "bri.csv",
+			skipHeader: true,
+			expected: []types.BankStatement{
+				{
+					BankName: "BRI",
+					UniqueID: "BS001",
+					Amount:   -100.0,
+					Date:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
 		{
 			name: "invalid amount format",
 			csvContent: `UniqueID,Amount,Date
 BS001,invalid,2024-01-01`,
 			filename:      "bri.csv",
 			skipHeader:    true,
-			expectedError: "invalid amount [invalid] in row 2 of file",
+			expectedError: "invalid amount [invalid] in row 2 of file bri.csv",
 		},
 		{
 			name: "invalid date format",
@@ -218,7 +276,7 @@ BS001,invalid,2024-01-01`,
 BS001,100.0,invalid-date`,
 			filename:      "bri.csv",
 			skipHeader:    true,
-			expectedError: "invalid date [invalid-date] in row 2 of file",
+			expectedError: "invalid date [invalid-date] in row 2 of file bri.csv",
 		},
 		{
 			name: "with time range filter",
@@ -261,7 +319,15 @@ BS003,-300.0,2024-01-03`,
 BS001,100.0`,
 			filename:      "bri.csv",
 			skipHeader:    true,
-			expectedError: "invalid format [BS001,100.0] in row 2 of file",
+			expectedError: "invalid format [BS001|100.0] in row 2 of file bri.csv",
+		},
+		{
+			name: "quoted field with embedded comma",
+			csvContent: `UniqueID,Amount
+"PAYMENT, THANK YOU",100.0`,
+			filename:      "bri.csv",
+			skipHeader:    true,
+			expectedError: "invalid format [PAYMENT, THANK YOU|100.0] in row 2 of file bri.csv",
 		},
 		{
 			name: "too many columns",
@@ -269,7 +335,7 @@ BS001,100.0`,
 BS001,100.0,2024-01-01,extra`,
 			filename:      "bri.csv",
 			skipHeader:    true,
-			expectedError: "failed to read CSV file: record on line 2: wrong number of fields",
+			expectedError: "invalid format [BS001|100.0|2024-01-01|extra] in row 2 of file bri.csv",
 		},
 		{
 			name:       "completely empty file",
@@ -323,3 +389,1221 @@ BS001,100.0,2024-01-01`,
 		})
 	}
 }
+
+// TestReadWithAmountFormat tests reading grouped amounts with the WithAmountFormat option
+func (s *CSVReaderTestSuite) TestReadWithAmountFormat() {
+	// Define the amount formats
+	usFormat := AmountFormat{GroupSeparator: ",", DecimalSeparator: ".", CurrencyPrefixes: []string{"$"}}
+	euFormat := AmountFormat{GroupSeparator: ".", DecimalSeparator: ",", CurrencyPrefixes: []string{"Rp", "IDR"}}
+
+	// Define test cases
+	testCases := []struct {
+		name          string
+		format        AmountFormat
+		amount        string
+		expected      float64
+		expectedError bool
+	}{
+		{name: "plain amount with default format", amount: "1234.56", expected: 1234.56},
+		{name: "grouped amount with default format", amount: "1,234.56", expectedError: true},
+		{name: "US-style grouped amount", format: usFormat, amount: "1,234,567.89", expected: 1234567.89},
+		{name: "US-style with currency prefix", format: usFormat, amount: "$1,234.56", expected: 1234.56},
+		{name: "US-style negative before prefix", format: usFormat, amount: "-$1,234.56", expected: -1234.56},
+		{name: "European-style grouped amount", format: euFormat, amount: "1.234.567,89", expected: 1234567.89},
+		{name: "European-style with currency prefix", format: euFormat, amount: "Rp 1.234,56", expected: 1234.56},
+		{name: "European-style negative after prefix", format: euFormat, amount: "Rp -1.234,56", expected: -1234.56},
+		{name: "European-style with alternative prefix", format: euFormat, amount: "IDR 500", expected: 500},
+		{name: "invalid amount with format", format: euFormat, amount: "Rp abc", expectedError: true},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// Create a CSV reader with the amount format
+			csvReader := NewCSVReader(csv.NewReader(bytes.NewBufferString("")), WithAmountFormat(tc.format))
+
+			// Parse the amount
+			amount, err := csvReader.parseAmount(tc.amount)
+
+			// Check if there was an error
+			if tc.expectedError {
+				assert.Error(s.T(), err)
+			} else {
+				assert.NoError(s.T(), err)
+				assert.Equal(s.T(), tc.expected, amount)
+			}
+		})
+	}
+
+	// Read a bank statement file with quoted European-style amounts
+	reader := csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,"Rp -1.234,56",2024-01-01
+BS002,"Rp 200,00",2024-01-02`))
+	statements, err := NewCSVReader(reader,
+		WithSkipHeader(true),
+		WithFilename("bca.csv"),
+		WithAmountFormat(euFormat),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 2)
+	assert.Equal(s.T(), -1234.56, statements[0].Amount)
+	assert.Equal(s.T(), 200.0, statements[1].Amount)
+}
+
+// TestReadBankStatementsWithDateFormats tests reading bank statements with custom date layouts
+func (s *CSVReaderTestSuite) TestReadBankStatementsWithDateFormats() {
+	// Read a bank statement file with custom date layouts
+	reader := csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,-100.0,01/02/2024
+BS002,200.0,02/01/2024 23:59`))
+	statements, err := NewCSVReader(reader,
+		WithSkipHeader(true),
+		WithDateFormats("02/01/2006", "02/01/2006 15:04"),
+	).ReadBankStatementsFromCSV()
+
+	// Check the dates are parsed and truncated to the day
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 2)
+	assert.Equal(s.T(), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), statements[0].Date)
+	assert.Equal(s.T(), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), statements[1].Date)
+
+	// The default layout is no longer accepted
+	reader = csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,-100.0,2024-01-01`))
+	_, err = NewCSVReader(reader,
+		WithSkipHeader(true),
+		WithDateFormats("02/01/2006"),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid date [2024-01-01] in row 2 of file")
+}
+
+// TestReadBankStatementsWithEpochDates tests reading bank statement dates given as Unix epoch seconds and milliseconds
+func (s *CSVReaderTestSuite) TestReadBankStatementsWithEpochDates() {
+	// Read epoch seconds, epoch milliseconds and a regular date
+	reader := csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,-100.0,1704239999
+BS002,200.0,1704153600000
+BS003,300.0,2024-01-03`))
+	statements, err := NewCSVReader(reader,
+		WithSkipHeader(true),
+		WithEpochDates(true),
+	).ReadBankStatementsFromCSV()
+
+	// Check the dates are converted and truncated to the day
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), statements, 3) {
+		assert.Equal(s.T(), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), statements[0].Date)
+		assert.Equal(s.T(), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), statements[1].Date)
+		assert.Equal(s.T(), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), statements[2].Date)
+	}
+
+	// The day is taken in the location, 23:00 UTC is the next day in Jakarta
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	reader = csv.NewReader(bytes.NewBufferString(`BS001,-100.0,1704150000`))
+	statements, err = NewCSVReader(reader, WithEpochDates(true), WithLocation(jakarta)).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), statements, 1) {
+		assert.Equal(s.T(), time.Date(2024, 1, 2, 0, 0, 0, 0, jakarta), statements[0].Date)
+	}
+
+	// Integer dates are rejected without the option
+	reader = csv.NewReader(bytes.NewBufferString(`BS001,-100.0,1704150000`))
+	_, err = NewCSVReader(reader).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid date [1704150000] in row 1 of file")
+}
+
+// TestReadBankStatementsWithBankNameFromDir tests deriving the bank name from the parent directory
+func (s *CSVReaderTestSuite) TestReadBankStatementsWithBankNameFromDir() {
+	// Read a bank statement file organized in a per-bank subfolder
+	reader := csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,-100.0,2024-01-01`))
+	statements, err := NewCSVReader(reader,
+		WithSkipHeader(true),
+		WithFilename("banks/bri/2024-01.csv"),
+		WithBankNameFromDir(true),
+	).ReadBankStatementsFromCSV()
+
+	// Check the bank name is derived from the directory
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 1)
+	assert.Equal(s.T(), "BRI", statements[0].BankName)
+}
+
+// TestReadBankStatementsWithDirectionColumn tests reading bank statements with a D/C direction column
+func (s *CSVReaderTestSuite) TestReadBankStatementsWithDirectionColumn() {
+	// Define test cases
+	testCases := []struct {
+		name            string
+		csvContent      string
+		directionColumn bool
+		expected        []types.TransactionType
+		expectedError   string
+	}{
+		{
+			name: "direction column",
+			csvContent: `UniqueID,Amount,Date,Direction
+BS001,100.0,2024-01-01,D
+BS002,200.0,2024-01-02,c
+BS003,300.0,2024-01-03,CREDIT`,
+			directionColumn: true,
+			expected:        []types.TransactionType{types.TransactionTypeDebit, types.TransactionTypeCredit, types.TransactionTypeCredit},
+		},
+		{
+			name: "invalid direction",
+			csvContent: `UniqueID,Amount,Date,Direction
+BS001,100.0,2024-01-01,X`,
+			directionColumn: true,
+			expectedError:   "invalid direction [X] in row 2 of file",
+		},
+		{
+			name: "3-column file with direction column enabled",
+			csvContent: `UniqueID,Amount,Date
+BS001,100.0,2024-01-01`,
+			directionColumn: true,
+			expectedError:   "invalid format [BS001|100.0|2024-01-01] in row 2 of file",
+		},
+		{
+			name: "3-column file without direction column",
+			csvContent: `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01`,
+			expected: []types.TransactionType{""},
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// Create a CSV reader with the direction column option
+			reader := csv.NewReader(bytes.NewBufferString(tc.csvContent))
+			csvReader := NewCSVReader(reader, WithSkipHeader(true), WithDirectionColumn(tc.directionColumn))
+
+			// Read the bank statements
+			statements, err := csvReader.ReadBankStatementsFromCSV()
+
+			// Check if there was an error
+			if tc.expectedError != "" {
+				assert.EqualError(s.T(), err, tc.expectedError)
+				return
+			}
+			assert.NoError(s.T(), err)
+			directions := make([]types.TransactionType, len(statements))
+			for i, stmt := range statements {
+				directions[i] = stmt.Direction
+			}
+			assert.Equal(s.T(), tc.expected, directions)
+		})
+	}
+}
+
+// TestReadWithAutoHeaderDetection tests reading files with and without headers when auto-detection is on
+func (s *CSVReaderTestSuite) TestReadWithAutoHeaderDetection() {
+	// Define test cases
+	testCases := []struct {
+		name          string
+		systemContent string
+		bankContent   string
+		skipHeader    bool
+		expected      int
+	}{
+		{
+			name: "with headers",
+			systemContent: `TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00`,
+			bankContent: `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01`,
+			expected: 1,
+		},
+		{
+			name:          "without headers",
+			systemContent: `TX001,100.0,DEBIT,2024-01-01 10:00:00`,
+			bankContent:   `BS001,-100.0,2024-01-01`,
+			expected:      1,
+		},
+		{
+			name: "without headers and skip header set",
+			systemContent: `TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00`,
+			bankContent: `BS001,-100.0,2024-01-01
+BS002,200.0,2024-01-02`,
+			skipHeader: true,
+			expected:   2,
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// Read the system transactions with auto-detection
+			transactions, err := NewCSVReader(
+				csv.NewReader(bytes.NewBufferString(tc.systemContent)),
+				WithSkipHeader(tc.skipHeader),
+				WithAutoHeaderDetection(true),
+			).ReadSystemTransactionsFromCSV()
+			assert.NoError(s.T(), err)
+			assert.Len(s.T(), transactions, tc.expected)
+
+			// Read the bank statements with auto-detection
+			statements, err := NewCSVReader(
+				csv.NewReader(bytes.NewBufferString(tc.bankContent)),
+				WithSkipHeader(tc.skipHeader),
+				WithAutoHeaderDetection(true),
+			).ReadBankStatementsFromCSV()
+			assert.NoError(s.T(), err)
+			assert.Len(s.T(), statements, tc.expected)
+		})
+	}
+
+	// Without auto-detection the header row fails to parse
+	_, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(`TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00`))).ReadSystemTransactionsFromCSV()
+	assert.EqualError(s.T(), err, "invalid amount [Amount] in row 1 of file")
+}
+
+// TestParseErrorsWithFilename tests parse errors include the filename when it is set
+func (s *CSVReaderTestSuite) TestParseErrorsWithFilename() {
+	// Read a system transaction file with an invalid amount
+	_, err := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`TrxID,Amount,Type,TransactionTime
+TX001,invalid,DEBIT,2024-01-01 10:00:00`)),
+		WithSkipHeader(true),
+		WithFilename("data/system.csv"),
+	).ReadSystemTransactionsFromCSV()
+	assert.EqualError(s.T(), err, "invalid amount [invalid] in row 2 of file data/system.csv")
+
+	// Read a bank statement file in a directory with an invalid date
+	_, err = NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,100.0,2024-01-01
+BS002,100.0,invalid-date`)),
+		WithSkipHeader(true),
+		WithFilename("data/banks/bca.csv"),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid date [invalid-date] in row 3 of file data/banks/bca.csv")
+}
+
+// TestParseErrorsWithMultilineFields tests error locations after a quoted field spanning several lines
+func (s *CSVReaderTestSuite) TestParseErrorsWithMultilineFields() {
+	// Read a system transaction file with a multiline TrxID followed by an invalid amount
+	_, err := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`TrxID,Amount,Type,TransactionTime
+"TX001
+refund
+note",100.0,DEBIT,2024-01-01 10:00:00
+TX002,invalid,DEBIT,2024-01-01 10:00:00`)),
+		WithSkipHeader(true),
+		WithFilename("system.csv"),
+	).ReadSystemTransactionsFromCSV()
+	assert.EqualError(s.T(), err, "invalid amount [invalid] in row 3 (line 5) of file system.csv")
+
+	// Read a bank statement file with a multiline ID followed by an invalid date
+	_, err = NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+"BS001
+note",100.0,2024-01-01
+BS002,100.0,invalid-date`)),
+		WithSkipHeader(true),
+		WithFilename("bca.csv"),
+		WithMaxRows(10),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid date [invalid-date] in row 3 (line 4) of file bca.csv")
+
+	// Rows before the multiline field keep the plain row number
+	_, err = NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,invalid,2024-01-01
+"BS002
+note",100.0,2024-01-01`)),
+		WithSkipHeader(true),
+		WithFilename("bca.csv"),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid amount [invalid] in row 2 of file bca.csv")
+}
+
+// TestReadBankStatementsWithBankNameColumn tests reading a combined multi-bank file
+func (s *CSVReaderTestSuite) TestReadBankStatementsWithBankNameColumn() {
+	// Define test cases
+	testCases := []struct {
+		name          string
+		csvContent    string
+		column        int
+		expected      []string
+		expectedError string
+	}{
+		{
+			name: "bank name as last column",
+			csvContent: `UniqueID,Amount,Date,Bank
+BS001,-100.0,2024-01-01,bri
+BS002,200.0,2024-01-02,Mandiri
+BS003,300.0,2024-01-03,`,
+			column:   3,
+			expected: []string{"BRI", "MANDIRI", "COMBINED"},
+		},
+		{
+			name: "bank name as first column",
+			csvContent: `Bank,UniqueID,Amount,Date
+BCA,BS001,-100.0,2024-01-01
+BNI,BS002,200.0,2024-01-02`,
+			column:   0,
+			expected: []string{"BCA", "BNI"},
+		},
+		{
+			name: "missing bank name column",
+			csvContent: `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01`,
+			column:        3,
+			expectedError: "invalid format [BS001|-100.0|2024-01-01] in row 2 of file combined.csv",
+		},
+		{
+			name: "bank name column out of range",
+			csvContent: `UniqueID,Amount,Date,Bank
+BS001,-100.0,2024-01-01,BRI`,
+			column:        5,
+			expectedError: "invalid bank name column 5 for 4 columns",
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// Create a CSV reader with the bank name column
+			reader := csv.NewReader(bytes.NewBufferString(tc.csvContent))
+			csvReader := NewCSVReader(reader,
+				WithSkipHeader(true),
+				WithFilename("combined.csv"),
+				WithBankNameColumn(tc.column),
+			)
+
+			// Read the bank statements
+			statements, err := csvReader.ReadBankStatementsFromCSV()
+
+			// Check if there was an error
+			if tc.expectedError != "" {
+				assert.EqualError(s.T(), err, tc.expectedError)
+				return
+			}
+			assert.NoError(s.T(), err)
+			bankNames := make([]string, len(statements))
+			for i, stmt := range statements {
+				bankNames[i] = stmt.BankName
+			}
+			assert.Equal(s.T(), tc.expected, bankNames)
+			assert.Equal(s.T(), "BS001", statements[0].UniqueID)
+			assert.Equal(s.T(), -100.0, statements[0].Amount)
+		})
+	}
+}
+
+// TestFiltered tests counting the rows skipped by the time range
+func (s *CSVReaderTestSuite) TestFiltered() {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	// Read system transactions with one row in range
+	systemReader := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00
+TX003,300.0,CREDIT,2024-01-03 10:00:00`)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+	)
+	transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), transactions, 1)
+	assert.Equal(s.T(), 2, systemReader.Filtered())
+
+	// Read bank statements with all rows in range
+	bankReader := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,100.0,2024-01-02`)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+	)
+	statements, err := bankReader.ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 1)
+	assert.Equal(s.T(), 0, bankReader.Filtered())
+}
+
+// TestFilteredByTime tests bounding system transactions within a single day by hour
+func (s *CSVReaderTestSuite) TestFilteredByTime() {
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 2, 17, 0, 0, 0, time.UTC)
+
+	// Read system transactions with the rows from 09:00 to 17:00 inclusive in range
+	systemReader := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-02 08:59:59
+TX002,200.0,CREDIT,2024-01-02 09:00:00
+TX003,300.0,CREDIT,2024-01-02 12:30:00
+TX004,400.0,CREDIT,2024-01-02 17:00:00
+TX005,500.0,CREDIT,2024-01-02 17:00:01`)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+		WithEndTimeOfDay(true),
+	)
+	transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	var ids []string
+	for _, tx := range transactions {
+		ids = append(ids, tx.TrxID)
+	}
+	assert.Equal(s.T(), []string{"TX002", "TX003", "TX004"}, ids)
+	assert.Equal(s.T(), 2, systemReader.Filtered())
+
+	// Bank statements of the day are in range
+	bankReader := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,100.0,2024-01-02
+BS002,100.0,2024-01-03`)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+		WithEndTimeOfDay(true),
+	)
+	statements, err := bankReader.ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 1)
+	assert.Equal(s.T(), 1, bankReader.Filtered())
+}
+
+// TestFilteredByMidnightEnd tests an explicit 00:00:00 end excludes the rest of its day unlike a date-only end
+func (s *CSVReaderTestSuite) TestFilteredByMidnightEnd() {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	content := `TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 00:00:00
+TX003,300.0,CREDIT,2024-01-02 10:00:00`
+
+	for _, tc := range []struct {
+		name         string
+		endTimeOfDay bool
+		expected     []string
+	}{
+		{name: "explicit midnight", endTimeOfDay: true, expected: []string{"TX001", "TX002"}},
+		{name: "date only", endTimeOfDay: false, expected: []string{"TX001", "TX002", "TX003"}},
+	} {
+		s.Run(tc.name, func() {
+			systemReader := NewCSVReader(
+				csv.NewReader(bytes.NewBufferString(content)),
+				WithSkipHeader(true),
+				WithTimeRange(start, end),
+				WithEndTimeOfDay(tc.endTimeOfDay),
+			)
+			transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+			assert.NoError(s.T(), err)
+			var ids []string
+			for _, tx := range transactions {
+				ids = append(ids, tx.TrxID)
+			}
+			assert.Equal(s.T(), tc.expected, ids)
+		})
+	}
+}
+
+// TestReadXLSX tests reading xlsx files with the same parsing as CSV files
+func (s *CSVReaderTestSuite) TestReadXLSX() {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Read system transactions with date cells and text dates
+	data, err := os.ReadFile("testdata/system.xlsx")
+	assert.NoError(s.T(), err)
+	systemReader := NewXLSXReader(bytes.NewReader(data), int64(len(data)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+	)
+	transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.Transaction{
+		{TrxID: "TX001", Amount: 100.0, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{TrxID: "TX002", Amount: 200.5, Type: types.TransactionTypeCredit, TransactionTime: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+	}, transactions)
+	assert.Equal(s.T(), 1, systemReader.Filtered())
+
+	// Read bank statements, the bank name comes from the filename
+	data, err = os.ReadFile("testdata/bank.xlsx")
+	assert.NoError(s.T(), err)
+	statements, err := NewXLSXReader(bytes.NewReader(data), int64(len(data)),
+		WithSkipHeader(true),
+		WithFilename("testdata/bank.xlsx"),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BANK", UniqueID: "BS001", Amount: -100.0, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{BankName: "BANK", UniqueID: "BS002", Amount: 200.5, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+
+	// A system file read as bank statements fails the same validation as CSV
+	data, err = os.ReadFile("testdata/system.xlsx")
+	assert.NoError(s.T(), err)
+	_, err = NewXLSXReader(bytes.NewReader(data), int64(len(data)),
+		WithSkipHeader(true),
+		WithFilename("testdata/system.xlsx"),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid format [TX001|100|DEBIT|2024-01-01] in row 2 of file testdata/system.xlsx")
+
+	// An invalid archive fails to read
+	_, err = NewXLSXReader(bytes.NewReader([]byte("not xlsx")), 8).ReadBankStatementsFromCSV()
+	assert.ErrorContains(s.T(), err, "invalid xlsx archive")
+}
+
+// newXLSX builds an xlsx file with the given sheet data, style 1 is a date format
+func newXLSX(sheetData string) []byte {
+	return newXLSXWorkbook("", sheetData)
+}
+
+// newXLSXWorkbook creates an xlsx file with the workbook properties, e.g. the 1904 date system
+func newXLSXWorkbook(workbookPr, sheetData string) []byte {
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` + workbookPr + `<sheets><sheet r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/styles.xml":              `<styleSheet><cellXfs><xf numFmtId="0"/><xf numFmtId="14"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, _ := archive.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = archive.Close()
+	return buf.Bytes()
+}
+
+// TestReadXLSXDateFormats tests that date cells are converted to the configured bank date format
+func (s *CSVReaderTestSuite) TestReadXLSXDateFormats() {
+	data := newXLSX(`<row><c r="A1" t="inlineStr"><is><t>BS001</t></is></c><c r="B1"><v>100</v></c><c r="C1" s="1"><v>45292</v></c></row>`)
+
+	statements, err := NewXLSXReader(bytes.NewReader(data), int64(len(data)),
+		WithDateFormats("02/01/2006"),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{UniqueID: "BS001", Amount: 100.0, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+
+	// Date cells explicitly typed as numbers are converted as well
+	data = newXLSX(`<row><c r="A1" t="inlineStr"><is><t>BS001</t></is></c><c r="B1" t="n"><v>100</v></c><c r="C1" s="1" t="n"><v>45292</v></c></row>`)
+	statements, err = NewXLSXReader(bytes.NewReader(data), int64(len(data))).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{UniqueID: "BS001", Amount: 100.0, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+
+	// Workbooks using the 1904 date system count the serial numbers from 1904-01-01
+	data = newXLSXWorkbook(`<workbookPr date1904="1"/>`, `<row><c r="A1" t="inlineStr"><is><t>BS001</t></is></c><c r="B1"><v>100</v></c><c r="C1" s="1"><v>43830</v></c></row>`)
+	statements, err = NewXLSXReader(bytes.NewReader(data), int64(len(data))).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{UniqueID: "BS001", Amount: 100.0, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+}
+
+// TestReadXLSXLimits tests that oversized columns and parts fail the file instead of exhausting the memory
+func (s *CSVReaderTestSuite) TestReadXLSXLimits() {
+	// The last column is read
+	data := newXLSX(`<row><c r="XFD1"><v>1</v></c></row>`)
+	records, err := (&xlsxReader{reader: bytes.NewReader(data), size: int64(len(data))}).ReadAll()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), records[0], 16384)
+
+	// Columns after XFD are rejected, including references overflowing the index
+	for _, ref := range []string{"XFE1", "XFDXFDXFD1", "ZZZZZZZZZZZZZZ1"} {
+		data = newXLSX(`<row><c r="` + ref + `"><v>1</v></c></row>`)
+		_, err = NewXLSXReader(bytes.NewReader(data), int64(len(data)), WithFilename("bank.xlsx")).ReadBankStatementsFromCSV()
+		assert.EqualError(s.T(), err, "failed to read CSV file bank.xlsx: invalid cell reference "+ref+" in row 1: column after XFD", ref)
+	}
+
+	// A part larger than the limit fails the file
+	defer func(limit int64) { maxXLSXPartSize = limit }(maxXLSXPartSize)
+	maxXLSXPartSize = 256
+	data = newXLSX(strings.Repeat(`<row><c r="A1"><v>1</v></c></row>`, 10))
+	_, err = NewXLSXReader(bytes.NewReader(data), int64(len(data)), WithFilename("bank.xlsx")).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "failed to read CSV file bank.xlsx: xlsx part xl/worksheets/sheet1.xml exceeds 256 bytes")
+}
+
+// TestReadWithRejectZeroAmounts tests excluding rows with a zero amount
+func (s *CSVReaderTestSuite) TestReadWithRejectZeroAmounts() {
+	systemContent := `TrxID,Amount,Type,TransactionTime
+TX001,0.00,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00`
+	bankContent := `UniqueID,Amount,Date
+BS001,-0.00,2024-01-01
+BS002,0,2024-01-01
+BS003,200.0,2024-01-02`
+
+	// Define test cases
+	testCases := []struct {
+		name                 string
+		reject               bool
+		expectedTransactions int
+		expectedStatements   int
+		expectedSystemZero   int
+		expectedBankZero     int
+	}{
+		{
+			name:                 "zero amounts kept by default",
+			expectedTransactions: 2,
+			expectedStatements:   3,
+		},
+		{
+			name:                 "zero amounts rejected",
+			reject:               true,
+			expectedTransactions: 1,
+			expectedStatements:   1,
+			expectedSystemZero:   1,
+			expectedBankZero:     2,
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// Read the system transactions
+			systemReader := NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)),
+				WithSkipHeader(true),
+				WithRejectZeroAmounts(tc.reject),
+			)
+			transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+			assert.NoError(s.T(), err)
+			assert.Len(s.T(), transactions, tc.expectedTransactions)
+			assert.Equal(s.T(), tc.expectedSystemZero, systemReader.ZeroAmounts())
+
+			// Read the bank statements
+			bankReader := NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)),
+				WithSkipHeader(true),
+				WithRejectZeroAmounts(tc.reject),
+			)
+			statements, err := bankReader.ReadBankStatementsFromCSV()
+			assert.NoError(s.T(), err)
+			assert.Len(s.T(), statements, tc.expectedStatements)
+			assert.Equal(s.T(), tc.expectedBankZero, bankReader.ZeroAmounts())
+		})
+	}
+}
+
+// TestReadWithLocation tests interpreting dates in a timezone around midnight
+func (s *CSVReaderTestSuite) TestReadWithLocation() {
+	wib := time.FixedZone("WIB", 7*60*60)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, wib)
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, wib)
+
+	// Read system transactions around local midnight, 06:30 local is the previous day in UTC
+	systemReader := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 06:30:00
+TX002,200.0,CREDIT,2024-01-01 23:30:00
+TX003,300.0,CREDIT,2024-01-02 00:30:00`)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+		WithLocation(wib),
+	)
+	transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.Transaction{
+		{TrxID: "TX001", Amount: 100.0, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 1, 6, 30, 0, 0, wib)},
+		{TrxID: "TX002", Amount: 200.0, Type: types.TransactionTypeCredit, TransactionTime: time.Date(2024, 1, 1, 23, 30, 0, 0, wib)},
+	}, transactions)
+
+	// Read bank statements with and without a time component
+	statements, err := NewCSVReader(
+		csv.NewReader(bytes.NewBufferString(`UniqueID,Amount,Date
+BS001,-100.0,2024-01-01
+BS002,200.0,2024-01-01 23:30:00
+BS003,300.0,2024-01-02`)),
+		WithSkipHeader(true),
+		WithTimeRange(start, end),
+		WithLocation(wib),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 2)
+	assert.Equal(s.T(), time.Date(2024, 1, 1, 0, 0, 0, 0, wib), statements[1].Date)
+}
+
+// TestReadWithMaxRows tests the data row limit at its boundary
+func (s *CSVReaderTestSuite) TestReadWithMaxRows() {
+	systemContent := `TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00`
+	bankContent := `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01
+BS002,200.0,2024-01-02`
+
+	// Define test cases
+	testCases := []struct {
+		name        string
+		maxRows     int
+		expectedErr string
+	}{
+		{
+			name: "no limit",
+		},
+		{
+			name:    "rows equal to the limit",
+			maxRows: 2,
+		},
+		{
+			name:        "rows over the limit",
+			maxRows:     1,
+			expectedErr: "file test.csv exceeds the limit of 1 data rows",
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// Read the system transactions
+			systemReader := NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)),
+				WithSkipHeader(true),
+				WithFilename("test.csv"),
+				WithMaxRows(tc.maxRows),
+			)
+			transactions, err := systemReader.ReadSystemTransactionsFromCSV()
+			if tc.expectedErr != "" {
+				assert.EqualError(s.T(), err, tc.expectedErr)
+			} else {
+				assert.NoError(s.T(), err)
+				assert.Len(s.T(), transactions, 2)
+			}
+
+			// Read the bank statements with header detection
+			bankReader := NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)),
+				WithAutoHeaderDetection(true),
+				WithFilename("test.csv"),
+				WithMaxRows(tc.maxRows),
+			)
+			statements, err := bankReader.ReadBankStatementsFromCSV()
+			if tc.expectedErr != "" {
+				assert.EqualError(s.T(), err, tc.expectedErr)
+			} else {
+				assert.NoError(s.T(), err)
+				assert.Len(s.T(), statements, 2)
+			}
+		})
+	}
+
+	// Reading stops at the limit, a malformed row after it is never reached
+	content := systemContent + "\nTX003,300.0,CREDIT,2024-01-03 10:00:00\n\"unterminated"
+	reader := NewCSVReader(csv.NewReader(bytes.NewBufferString(content)), WithSkipHeader(true), WithMaxRows(2))
+	_, err := reader.ReadSystemTransactionsFromCSV()
+	assert.EqualError(s.T(), err, "file exceeds the limit of 2 data rows")
+}
+
+// TestReadWithSampleRows tests reading only the first data rows of a file
+func (s *CSVReaderTestSuite) TestReadWithSampleRows() {
+	// A malformed row after the sample is never reached
+	systemContent := `TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00
+TX003,300.0,CREDIT,2024-01-03 10:00:00
+"unterminated`
+	reader := NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)), WithSkipHeader(true), WithSampleRows(2))
+	transactions, err := reader.ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), transactions, 2) {
+		assert.Equal(s.T(), "TX002", transactions[1].TrxID)
+	}
+
+	// A sample within the row limit does not fail, the header is detected before sampling
+	bankContent := `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01
+BS002,200.0,2024-01-02
+BS003,300.0,2024-01-03`
+	reader = NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)), WithAutoHeaderDetection(true), WithSampleRows(1), WithMaxRows(2))
+	statements, err := reader.ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), statements, 1) {
+		assert.Equal(s.T(), "BS001", statements[0].UniqueID)
+	}
+
+	// A sample larger than the file reads all rows
+	reader = NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)), WithSkipHeader(true), WithSampleRows(10))
+	statements, err = reader.ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 3)
+}
+
+// TestReadBankStatementsWithDebitCreditColumns tests reading the amount from separate debit and credit columns
+func (s *CSVReaderTestSuite) TestReadBankStatementsWithDebitCreditColumns() {
+	// Define test cases
+	testCases := []struct {
+		name               string
+		content            string
+		opts               []Option
+		expectedStatements []types.BankStatement
+		expectedErr        string
+	}{
+		{
+			name: "debit and credit rows",
+			content: `UniqueID,Debit,Credit,Date
+BS001,150.50,,2024-01-01
+BS002,,200.00,2024-01-02`,
+			opts: []Option{WithSkipHeader(true), WithDebitCreditColumns(1, 2)},
+			expectedStatements: []types.BankStatement{
+				{BankName: "BANK", UniqueID: "BS001", Amount: -150.50, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{BankName: "BANK", UniqueID: "BS002", Amount: 200.00, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "columns after the date with a bank name column and header detection",
+			content: `Bank,UniqueID,Date,Credit,Debit
+bri,BS001,2024-01-01,,"1,000.00"
+bni,BS002,2024-01-02,300.00,`,
+			opts: []Option{
+				WithAutoHeaderDetection(true),
+				WithBankNameColumn(0),
+				WithDebitCreditColumns(4, 3),
+				WithAmountFormat(AmountFormat{GroupSeparator: ","}),
+			},
+			expectedStatements: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BS001", Amount: -1000.00, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{BankName: "BNI", UniqueID: "BS002", Amount: 300.00, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "both debit and credit populated",
+			content: `UniqueID,Debit,Credit,Date
+BS001,150.50,10.00,2024-01-01`,
+			opts:        []Option{WithSkipHeader(true), WithDebitCreditColumns(1, 2)},
+			expectedErr: "both debit [150.50] and credit [10.00] amounts in row 2 of file bank.csv",
+		},
+		{
+			name: "neither debit nor credit populated",
+			content: `UniqueID,Debit,Credit,Date
+BS001,,,2024-01-01`,
+			opts:        []Option{WithSkipHeader(true), WithDebitCreditColumns(1, 2)},
+			expectedErr: "missing debit and credit amounts in row 2 of file bank.csv",
+		},
+		{
+			name: "invalid debit amount",
+			content: `UniqueID,Debit,Credit,Date
+BS001,abc,,2024-01-01`,
+			opts:        []Option{WithSkipHeader(true), WithDebitCreditColumns(1, 2)},
+			expectedErr: "invalid debit amount [abc] in row 2 of file bank.csv",
+		},
+		{
+			name: "signed amount column count",
+			content: `UniqueID,Amount,Date
+BS001,150.50,2024-01-01`,
+			opts:        []Option{WithSkipHeader(true), WithDebitCreditColumns(1, 2)},
+			expectedErr: "invalid format [BS001|150.50|2024-01-01] in row 2 of file bank.csv",
+		},
+		{
+			name: "same debit and credit column",
+			content: `UniqueID,Debit,Credit,Date
+BS001,150.50,,2024-01-01`,
+			opts:        []Option{WithSkipHeader(true), WithDebitCreditColumns(1, 1)},
+			expectedErr: "invalid debit and credit columns 1 and 1 for 4 columns",
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			reader := NewCSVReader(csv.NewReader(bytes.NewBufferString(tc.content)),
+				append([]Option{WithFilename("bank.csv")}, tc.opts...)...)
+			statements, err := reader.ReadBankStatementsFromCSV()
+			if tc.expectedErr != "" {
+				assert.EqualError(s.T(), err, tc.expectedErr)
+				return
+			}
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), tc.expectedStatements, statements)
+		})
+	}
+}
+
+// TestReadWithColumns tests reading rows with mapped columns and extra ignored columns
+func (s *CSVReaderTestSuite) TestReadWithColumns() {
+	mustColumns := func(columns *Columns, err error) *Columns {
+		s.Require().NoError(err)
+		return columns
+	}
+
+	// A trailing empty column fails without a mapping
+	content := `TrxID,Amount,Type,TransactionTime,
+TX001,100.00,DEBIT,2024-01-01 10:00:00,`
+	_, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(content)),
+		WithSkipHeader(true), WithFilename("system.csv")).ReadSystemTransactionsFromCSV()
+	assert.EqualError(s.T(), err, "invalid format [TX001|100.00|DEBIT|2024-01-01 10:00:00|] in row 2 of file system.csv: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 5")
+
+	// The mapped columns are read and the trailing empty column ignored
+	transactions, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(content)),
+		WithSkipHeader(true),
+		WithSystemColumns(mustColumns(ParseSystemColumns("trxid=0,amount=1,type=2,transactiontime=3"))),
+	).ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.Transaction{
+		{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+	}, transactions)
+
+	// Bank columns can be reordered, with a direction column and header detection
+	statements, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(`Date,Memo,UniqueID,DC,Amount,
+2024-01-02,rent,BS001,D,150.50,
+2024-01-03,,BS002,C,200.00,`)),
+		WithAutoHeaderDetection(true),
+		WithFilename("bca.csv"),
+		WithBankColumns(mustColumns(ParseBankColumns("uniqueid=2, amount=4, date=0, direction=3"))),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: 150.50, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Direction: types.TransactionTypeDebit},
+		{BankName: "BCA", UniqueID: "BS002", Amount: 200.00, Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Direction: types.TransactionTypeCredit},
+	}, statements)
+
+	// The settlement batch ID is read from its mapped column
+	transactions, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`TX001,100.00,DEBIT,2024-01-01 10:00:00,B1`)),
+		WithSystemColumns(mustColumns(ParseSystemColumns("trxid=0,amount=1,type=2,transactiontime=3,batchid=4"))),
+	).ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), transactions, 1) {
+		assert.Equal(s.T(), "B1", transactions[0].BatchID)
+	}
+	statements, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`B1,SETTLE1,-100.00,2024-01-01`)),
+		WithFilename("bca.csv"),
+		WithBankColumns(mustColumns(ParseBankColumns("batchid=0,uniqueid=1,amount=2,date=3"))),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BCA", UniqueID: "SETTLE1", Amount: -100.00, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), BatchID: "B1"},
+	}, statements)
+
+	// The currency code is read from its mapped column in upper case
+	transactions, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`TX001,100.00,DEBIT,2024-01-01 10:00:00, usd`)),
+		WithSystemColumns(mustColumns(ParseSystemColumns("trxid=0,amount=1,type=2,transactiontime=3,currency=4"))),
+	).ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), transactions, 1) {
+		assert.Equal(s.T(), "USD", transactions[0].Currency)
+	}
+	statements, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`IDR,BS001,-100.00,2024-01-01`)),
+		WithFilename("bca.csv"),
+		WithBankColumns(mustColumns(ParseBankColumns("currency=0,uniqueid=1,amount=2,date=3"))),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: -100.00, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Currency: "IDR"},
+	}, statements)
+
+	// The payment reference is read from its mapped column
+	transactions, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`TX001,100.00,CREDIT,2024-01-01 10:00:00,PAY1`)),
+		WithSystemColumns(mustColumns(ParseSystemColumns("trxid=0,amount=1,type=2,transactiontime=3,reference=4"))),
+	).ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), transactions, 1) {
+		assert.Equal(s.T(), "PAY1", transactions[0].Reference)
+	}
+	statements, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`PAY1,BS001,300.00,2024-01-01`)),
+		WithFilename("bca.csv"),
+		WithBankColumns(mustColumns(ParseBankColumns("reference=0,uniqueid=1,amount=2,date=3"))),
+	).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: 300.00, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Reference: "PAY1"},
+	}, statements)
+
+	// Rows missing the batch column are reported
+	_, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`SETTLE1,-100.00,2024-01-01`)),
+		WithFilename("bca.csv"),
+		WithBankColumns(mustColumns(ParseBankColumns("uniqueid=0,amount=1,date=2,batchid=3"))),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid format [SETTLE1|-100.00|2024-01-01] in row 1 of file bca.csv: expected at least 4 columns but got 3")
+
+	// Rows missing a mapped column are reported
+	_, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`BS001,150.50`)),
+		WithFilename("bca.csv"),
+		WithBankColumns(mustColumns(ParseBankColumns("uniqueid=0,amount=1,date=2"))),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid format [BS001|150.50] in row 1 of file bca.csv: expected at least 3 columns but got 2")
+
+	// Bank columns cannot be combined with a bank name column
+	_, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(`BS001,150.50,2024-01-01,BCA`)),
+		WithBankNameColumn(3),
+		WithBankColumns(mustColumns(ParseBankColumns("uniqueid=0,amount=1,date=2"))),
+	).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "bank columns cannot be combined with a bank name column or debit and credit columns")
+}
+
+// TestParseColumns tests parsing column specs
+func (s *CSVReaderTestSuite) TestParseColumns() {
+	testCases := []struct {
+		name        string
+		spec        string
+		parse       func(string) (*Columns, error)
+		expected    []int
+		expectedErr string
+	}{
+		{name: "system columns", spec: "TrxID=1,Amount=0,Type=3,TransactionTime=2", parse: ParseSystemColumns, expected: []int{1, 0, 3, 2}},
+		{name: "bank columns without direction", spec: "uniqueid=0,amount=1,date=2", parse: ParseBankColumns, expected: []int{0, 1, 2}},
+		{name: "batch column is not part of the default columns", spec: "batchid=4,uniqueid=0,amount=1,date=2", parse: ParseBankColumns, expected: []int{0, 1, 2}},
+		{name: "missing field", spec: "trxid=0,amount=1,type=2", parse: ParseSystemColumns, expectedErr: "missing column for transactiontime"},
+		{name: "unknown field", spec: "id=0", parse: ParseBankColumns, expectedErr: `invalid column "id=0", use field=index with the fields uniqueid, amount, date, direction, batchid, currency, reference`},
+		{name: "invalid index", spec: "uniqueid=-1", parse: ParseBankColumns, expectedErr: `invalid column index "-1" for uniqueid`},
+		{name: "duplicate field", spec: "uniqueid=0,uniqueid=1", parse: ParseBankColumns, expectedErr: "duplicate column field uniqueid"},
+		{name: "shared index", spec: "uniqueid=0,amount=0", parse: ParseBankColumns, expectedErr: "column 0 is mapped to both uniqueid and amount"},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			columns, err := tc.parse(tc.spec)
+			if tc.expectedErr != "" {
+				assert.EqualError(s.T(), err, tc.expectedErr)
+				return
+			}
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), tc.expected, columns.indexes)
+		})
+	}
+}
+
+// TestReadWithTrimFields tests reading fields padded with whitespace
+func (s *CSVReaderTestSuite) TestReadWithTrimFields() {
+	systemContent := `TrxID,Amount,Type,TransactionTime
+ TX001 , 100.0,DEBIT ,2024-01-01 10:00:00 `
+	bankContent := `UniqueID,Amount,Date
+	BS001, -100.0 , 2024-01-01`
+
+	// Padded fields are trimmed by default
+	transactions, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)), WithSkipHeader(true)).ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.Transaction{
+		{TrxID: "TX001", Amount: 100.0, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+	}, transactions)
+
+	statements, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)), WithSkipHeader(true), WithFilename("bca.csv")).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: -100.0, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+
+	// Without trimming the padded amount fails to parse
+	_, err = NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)),
+		WithSkipHeader(true), WithFilename("system.csv"), WithTrimFields(false)).ReadSystemTransactionsFromCSV()
+	assert.EqualError(s.T(), err, "invalid amount [ 100.0] in row 2 of file system.csv")
+}
+
+// TestReadJaggedRows tests that rows with fewer or more fields than expected are reported instead of panicking
+func (s *CSVReaderTestSuite) TestReadJaggedRows() {
+	systemColumns, err := ParseSystemColumns("trxid=0,amount=1,type=2,transactiontime=3")
+	s.Require().NoError(err)
+
+	// Define test cases
+	testCases := []struct {
+		name        string
+		content     string
+		bank        bool
+		opts        []Option
+		expectedErr string
+	}{
+		{
+			name:        "short system row after a valid row",
+			content:     "TX001,100.0,DEBIT,2024-01-01 10:00:00\nTX002,200.0\n",
+			expectedErr: "invalid format [TX002|200.0] in row 2 of file jagged.csv: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 2",
+		},
+		{
+			name:        "short first system row with header detection",
+			content:     "TX001\nTX002,200.0,DEBIT,2024-01-01 10:00:00\n",
+			opts:        []Option{WithAutoHeaderDetection(true)},
+			expectedErr: "invalid format [TX001] in row 1 of file jagged.csv: expected 4 columns (TrxID,Amount,Type,TransactionTime) but got 1",
+		},
+		{
+			name:        "short system row with mapped columns",
+			content:     "TX001,100.0,DEBIT,2024-01-01 10:00:00,note\nTX002,200.0,DEBIT\n",
+			opts:        []Option{WithSystemColumns(systemColumns)},
+			expectedErr: "invalid format [TX002|200.0|DEBIT] in row 2 of file jagged.csv: expected at least 4 columns but got 3",
+		},
+		{
+			name:        "short bank row",
+			content:     "BS001,100.0,2024-01-01\nBS002\n",
+			bank:        true,
+			expectedErr: "invalid format [BS002] in row 2 of file jagged.csv",
+		},
+		{
+			name:        "short bank row with a direction column",
+			content:     "BS001,100.0,2024-01-01,D\nBS002,100.0,2024-01-01\n",
+			bank:        true,
+			opts:        []Option{WithDirectionColumn(true)},
+			expectedErr: "invalid format [BS002|100.0|2024-01-01] in row 2 of file jagged.csv",
+		},
+		{
+			name:        "short bank row with a bank name column",
+			content:     "BS001,100.0,2024-01-01,BCA\nBS002,100.0\n",
+			bank:        true,
+			opts:        []Option{WithBankNameColumn(3)},
+			expectedErr: "invalid format [BS002|100.0] in row 2 of file jagged.csv",
+		},
+		{
+			name:        "short first bank row with debit and credit columns and header detection",
+			content:     "BS001\nBS002,,10.0,2024-01-01\n",
+			bank:        true,
+			opts:        []Option{WithAutoHeaderDetection(true), WithDebitCreditColumns(1, 2)},
+			expectedErr: "invalid format [BS001] in row 1 of file jagged.csv",
+		},
+	}
+
+	// Run each test case
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			reader := NewCSVReader(csv.NewReader(bytes.NewBufferString(tc.content)),
+				append([]Option{WithFilename("jagged.csv")}, tc.opts...)...)
+			if tc.bank {
+				_, err = reader.ReadBankStatementsFromCSV()
+			} else {
+				_, err = reader.ReadSystemTransactionsFromCSV()
+			}
+			assert.EqualError(s.T(), err, tc.expectedErr)
+		})
+	}
+}
+
+// TestReadEmptyRecords tests that blank lines and rows of empty fields are skipped in both readers
+func (s *CSVReaderTestSuite) TestReadEmptyRecords() {
+	systemContent := "TrxID,Amount,Type,TransactionTime\nTX001,100.0,DEBIT,2024-01-01 10:00:00\n,,,\n   \n\n"
+	bankContent := "UniqueID,Amount,Date\nBS001,-100.0,2024-01-01\n\n,,\n"
+
+	// The trailing blank lines and empty rows are skipped
+	transactions, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)), WithSkipHeader(true)).ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.Transaction{
+		{TrxID: "TX001", Amount: 100.0, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+	}, transactions)
+
+	statements, err := NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)), WithSkipHeader(true), WithFilename("bca.csv")).ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: -100.0, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+
+	// A row with some empty fields is still reported with its row
+	_, err = NewCSVReader(csv.NewReader(bytes.NewBufferString("BS001,-100.0,2024-01-01\n,,\nBS002,\n")),
+		WithFilename("bca.csv")).ReadBankStatementsFromCSV()
+	assert.EqualError(s.T(), err, "invalid format [BS002|] in row 3 of file bca.csv")
+}
+
+// TestReadFutureDates tests warning about rows dated after the time of reading while still reading them
+func (s *CSVReaderTestSuite) TestReadFutureDates() {
+	today := time.Now().UTC().Format("2006-01-02")
+	systemContent := "TrxID,Amount,Type,TransactionTime\nTX001,100.0,DEBIT,2024-01-01 10:00:00\nTX002,200.0,CREDIT,2099-01-01 10:00:00\n"
+	bankContent := "UniqueID,Amount,Date\nBS001,-100.0," + today + "\nBS002,200.0,2099-01-01\n"
+
+	// The future-dated transaction is read and warned about
+	reader := NewCSVReader(csv.NewReader(bytes.NewBufferString(systemContent)), WithSkipHeader(true), WithFilename("system.csv"), WithFlagFutureDates(true))
+	transactions, err := reader.ReadSystemTransactionsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), transactions, 2)
+	assert.Equal(s.T(), []string{"future date [2099-01-01 10:00:00] in row 3 of file system.csv"}, reader.Warnings())
+
+	// A bank statement of today is not in the future
+	reader = NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)), WithSkipHeader(true), WithFilename("bca.csv"), WithFlagFutureDates(true))
+	statements, err := reader.ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), statements, 2)
+	assert.Equal(s.T(), []string{"future date [2099-01-01] in row 3 of file bca.csv"}, reader.Warnings())
+
+	// Without the option no warnings are collected
+	reader = NewCSVReader(csv.NewReader(bytes.NewBufferString(bankContent)), WithSkipHeader(true), WithFilename("bca.csv"))
+	_, err = reader.ReadBankStatementsFromCSV()
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), reader.Warnings())
+}
@@ -0,0 +1,117 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+// fuzzOptions returns reader options selected by the bits of the mode, to cover the column layouts
+func fuzzOptions(mode uint8) []Option {
+	opts := []Option{WithFilename("fuzz.csv")}
+	if mode&1 != 0 {
+		opts = append(opts, WithAutoHeaderDetection(true))
+	} else {
+		opts = append(opts, WithSkipHeader(true))
+	}
+	if mode&2 != 0 {
+		opts = append(opts, WithTimeRange(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)))
+	}
+	if mode&4 != 0 {
+		opts = append(opts, WithAmountFormat(AmountFormat{GroupSeparator: ".", DecimalSeparator: ",", CurrencyPrefixes: []string{"Rp"}}))
+	}
+	switch mode >> 3 & 7 {
+	case 1:
+		opts = append(opts, WithDirectionColumn(true))
+	case 2:
+		opts = append(opts, WithBankNameColumn(0))
+	case 3:
+		opts = append(opts, WithDebitCreditColumns(1, 2))
+	case 4:
+		columns, _ := ParseBankColumns("uniqueid=3,amount=0,date=1,direction=2")
+		opts = append(opts, WithBankColumns(columns))
+	case 5:
+		columns, _ := ParseSystemColumns("trxid=4,amount=0,type=1,transactiontime=2")
+		opts = append(opts, WithSystemColumns(columns))
+	case 6:
+		opts = append(opts, WithMaxRows(2))
+	}
+	return opts
+}
+
+// newFuzzReader creates a CSV reader for the data, the high bit of the mode allows lazy quotes
+// like readers configured by library users
+func newFuzzReader(data []byte, mode uint8) *CSVReaderImpl {
+	reader := csv.NewReader(bytes.NewReader(data))
+	if mode&128 != 0 {
+		reader.LazyQuotes = true
+	}
+	return NewCSVReader(reader, fuzzOptions(mode)...)
+}
+
+// maxFuzzInput is the largest input fed to the readers, larger inputs only slow the fuzzer down
+const maxFuzzInput = 64 << 10
+
+// FuzzReadSystemTransactionsFromCSV checks that arbitrary system files never panic
+// and never return more transactions than the file has records
+func FuzzReadSystemTransactionsFromCSV(f *testing.F) {
+	f.Add([]byte("TrxID,Amount,Type,TransactionTime\nTX001,100.0,DEBIT,2024-01-01 10:00:00\n"), uint8(0))
+	f.Add([]byte("TX001,100.0,DEBIT,2024-01-01 10:00:00\nTX002,\"1.000,50\",CREDIT,2024-01-02 10:00:00\n"), uint8(7))
+	f.Add([]byte("\"TX\n001\",-1e308,DEBIT,2024-01-01 10:00:00,\n"), uint8(1<<3*5|1))
+	f.Add([]byte(",\n\n\"\n"), uint8(0))
+	f.Add([]byte("TX001,100.0\nTX002,100.0,DEBIT,2024-01-01 10:00:00\n"), uint8(1))
+
+	f.Fuzz(func(t *testing.T, data []byte, mode uint8) {
+		if len(data) > maxFuzzInput {
+			t.Skip()
+		}
+		reader := newFuzzReader(data, mode)
+		transactions, err := reader.ReadSystemTransactionsFromCSV()
+		if err == nil && len(transactions) > bytes.Count(data, []byte("\n"))+1 {
+			t.Fatalf("read %d transactions from %d lines", len(transactions), bytes.Count(data, []byte("\n"))+1)
+		}
+	})
+}
+
+// FuzzReadBankStatementsFromCSV checks that arbitrary bank files never panic
+// and never return more statements than the file has records
+func FuzzReadBankStatementsFromCSV(f *testing.F) {
+	f.Add([]byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\n"), uint8(0))
+	f.Add([]byte("BS001,150.0,2024-01-01,D\nBS002,Rp 1.000,00,2024-01-02,C\n"), uint8(1<<3|5))
+	f.Add([]byte("bca,BS001,100.0,2024-01-01\n"), uint8(2<<3|1))
+	f.Add([]byte("UniqueID,Debit,Credit,Date\nBS001,,10,2024-01-01\nBS002\n"), uint8(3<<3))
+	f.Add([]byte("100.0,2024-01-01,D,BS001\n"), uint8(4<<3|1))
+	f.Add([]byte("BS001\nBS002,100.0,2024-01-01\n"), uint8(3<<3|1))
+
+	f.Fuzz(func(t *testing.T, data []byte, mode uint8) {
+		if len(data) > maxFuzzInput {
+			t.Skip()
+		}
+		reader := newFuzzReader(data, mode)
+		statements, err := reader.ReadBankStatementsFromCSV()
+		if err == nil && len(statements) > bytes.Count(data, []byte("\n"))+1 {
+			t.Fatalf("read %d statements from %d lines", len(statements), bytes.Count(data, []byte("\n"))+1)
+		}
+	})
+}
+
+// FuzzReadXLSX checks that arbitrary worksheets never panic and never return more statements than the sheet has rows
+// The fuzzed data is the sheet data of a workbook, as arbitrary bytes are rarely a valid archive
+func FuzzReadXLSX(f *testing.F) {
+	f.Add([]byte(`<row><c r="A1" t="inlineStr"><is><t>BS001</t></is></c><c r="B1"><v>100</v></c><c r="C1" s="1"><v>45292</v></c></row>`), uint8(0))
+	f.Add([]byte(`<row><c r="C1"><v>1</v></c><c><v>2</v></c></row><row></row><row><c r="XFD2" s="1"><v>-1e308</v></c></row>`), uint8(1))
+	f.Add([]byte(`<row><c r="ZZZZZZZZZZZZZZ1"><v>1</v></c></row>`), uint8(0))
+	f.Add([]byte(`<row><c r="A1" t="s"><v>0</v></c><c r="1"><v>1</v></c></row>`), uint8(1<<3|1))
+
+	f.Fuzz(func(t *testing.T, sheetData []byte, mode uint8) {
+		if len(sheetData) > maxFuzzInput {
+			t.Skip()
+		}
+		data := newXLSX(string(sheetData))
+		statements, err := NewXLSXReader(bytes.NewReader(data), int64(len(data)), fuzzOptions(mode)...).ReadBankStatementsFromCSV()
+		if err == nil && len(statements) > bytes.Count(sheetData, []byte("<row")) {
+			t.Fatalf("read %d statements from %d rows", len(statements), bytes.Count(sheetData, []byte("<row")))
+		}
+	})
+}
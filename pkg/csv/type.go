@@ -1,7 +1,6 @@
 package csv
 
 import (
-	"encoding/csv"
 	"reconciliation/pkg/types"
 	"time"
 )
@@ -12,9 +11,20 @@ type CSVReader interface {
 	ReadBankStatementsFromCSV() ([]types.BankStatement, error)
 }
 
+// recordReader reads all records of an input file, it is implemented by csv.Reader
+type recordReader interface {
+	ReadAll() ([][]string, error)
+}
+
+// lineReader reads single records and reports their position in the file, it is implemented by csv.Reader
+type lineReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+}
+
 // CSVReaderImpl is the implementation of the CSVReader interface
 type CSVReaderImpl struct {
-	reader *csv.Reader
+	reader recordReader
 
 	// Filename of the CSV file
 	filename string
@@ -23,14 +33,105 @@ type CSVReaderImpl struct {
 	start time.Time
 	end   time.Time
 
+	// End has a time of day and bounds system transactions by timestamp, otherwise its whole day is included
+	endTimeOfDay bool
+
 	// Skip Header
 	skipHeader bool
+
+	// Format of the amount column
+	amountFormat AmountFormat
+
+	// Layouts tried in order to parse the bank statement date column
+	dateFormats []string
+
+	// Parse integer bank statement dates as Unix epoch seconds or milliseconds before trying the layouts
+	epochDates bool
+
+	// Factor the bank statement amounts are multiplied by, e.g. 0.01 for amounts in cents, 0 keeps them
+	bankAmountScale float64
+
+	// Derive the bank name from the parent directory instead of the filename
+	bankNameFromDir bool
+
+	// Bank statements have a 4th D/C direction column
+	directionColumn bool
+
+	// Detect the header from the first row instead of using skipHeader
+	autoHeaderDetection bool
+
+	// Index of the bank name column, -1 derives the bank name from the filename
+	bankNameColumn int
+
+	// Exclude rows with a zero amount
+	rejectZeroAmounts bool
+
+	// Warn about rows dated after the time of reading, they are kept
+	flagFutureDates bool
+
+	// Location the dates are interpreted in, UTC when nil
+	timezone *time.Location
+
+	// Maximum number of data rows read, 0 reads any number of rows
+	maxRows int
+
+	// Number of data rows read before stopping without an error, 0 reads all rows
+	sampleRows int
+
+	// Reject negative system transaction amounts, systems storing debits as negative amounts disable it
+	rejectNegativeAmounts bool
+
+	// Bank statements have separate debit and credit amount columns instead of a signed amount
+	debitCreditColumns bool
+	debitColumn        int
+	creditColumn       int
+
+	// Trim leading and trailing whitespace from every field before parsing
+	trimFields bool
+
+	// Column mapping of system transaction and bank statement rows, nil expects the default columns only
+	systemColumns *Columns
+	bankColumns   *Columns
+
+	// File line number of each record in the last read, empty when the reader does not report lines
+	lines []int
+
+	// Number of rows skipped by the time range filter in the last read
+	filtered int
+
+	// Number of rows with a zero amount excluded in the last read
+	zeroAmounts int
+
+	// Warnings about the rows of the last read, e.g. future dates
+	warnings []string
+}
+
+// defaultDateFormats are the layouts tried to parse the bank statement date column
+// A date with a time component is truncated to the day
+var defaultDateFormats = []string{"2006-01-02", "2006-01-02 15:04:05"}
+
+// epochMillisThreshold is the smallest epoch timestamp read as milliseconds, as seconds it would be after the year 5000
+const epochMillisThreshold = 100000000000
+
+// AmountFormat describes how amounts are written in the CSV file
+// The zero value expects the plain 1234.56 format
+type AmountFormat struct {
+	// GroupSeparator is the thousands separator, e.g. "," in 1,234.56
+	GroupSeparator string
+
+	// DecimalSeparator is the decimal separator, e.g. "," in 1.234,56
+	// Defaults to "." when empty
+	DecimalSeparator string
+
+	// CurrencyPrefixes are currency symbols stripped from the start of the amount, e.g. "Rp" or "$"
+	CurrencyPrefixes []string
 }
 
 // Option is a functional option for the CSVReader
 type Option func(*CSVReaderImpl)
 
 // WithTimeRange sets the time range for filtering
+// Bounds with a time of day limit system transactions by timestamp, e.g. 09:00 to 17:00, bank statements by day
 func WithTimeRange(start, end time.Time) Option {
 	return func(r *CSVReaderImpl) {
 		r.start = start
@@ -38,6 +139,14 @@ func WithTimeRange(start, end time.Time) Option {
 	}
 }
 
+// WithEndTimeOfDay compares system transactions with the full end timestamp instead of including its whole day
+// Set it when the end was given with a time of day, an explicit 00:00:00 then excludes the rest of the day
+func WithEndTimeOfDay(endTimeOfDay bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.endTimeOfDay = endTimeOfDay
+	}
+}
+
 // WithSkipHeader skips the header row
 func WithSkipHeader(skipHeader bool) Option {
 	return func(r *CSVReaderImpl) {
@@ -51,3 +160,160 @@ func WithFilename(filename string) Option {
 		r.filename = filename
 	}
 }
+
+// WithAmountFormat sets the format of the amount column
+func WithAmountFormat(format AmountFormat) Option {
+	return func(r *CSVReaderImpl) {
+		r.amountFormat = format
+	}
+}
+
+// WithDateFormats sets the layouts tried in order to parse the bank statement date column
+// The default layouts are kept when no layout is given
+func WithDateFormats(layouts ...string) Option {
+	return func(r *CSVReaderImpl) {
+		if len(layouts) > 0 {
+			r.dateFormats = layouts
+		}
+	}
+}
+
+// WithEpochDates parses integer bank statement dates as Unix epoch timestamps before trying the date layouts
+// Values of 100000000000 or more are milliseconds, smaller values seconds, the date is truncated to the day in the location
+func WithEpochDates(epochDates bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.epochDates = epochDates
+	}
+}
+
+// WithBankAmountScale multiplies the bank statement amounts by the factor before storing them, e.g. 0.01 for bank feeds
+// reporting integer cents like 12345 for 123.45, a factor of 0 or 1 keeps the amounts
+func WithBankAmountScale(factor float64) Option {
+	return func(r *CSVReaderImpl) {
+		r.bankAmountScale = factor
+	}
+}
+
+// WithBankNameFromDir derives the bank name from the parent directory of the file instead of the filename
+func WithBankNameFromDir(bankNameFromDir bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.bankNameFromDir = bankNameFromDir
+	}
+}
+
+// WithDirectionColumn expects bank statements to have a 4th D/C direction column
+// The direction is matched against the system transaction type instead of the amount sign
+func WithDirectionColumn(directionColumn bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.directionColumn = directionColumn
+	}
+}
+
+// WithAutoHeaderDetection treats the first row as a header when its amount column is not a valid amount
+// It takes precedence over WithSkipHeader
+func WithAutoHeaderDetection(autoHeaderDetection bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.autoHeaderDetection = autoHeaderDetection
+	}
+}
+
+// WithBankNameColumn reads the bank name from the column at the index instead of the filename
+// The column is in addition to the regular columns, a negative index derives the bank name from the filename
+func WithBankNameColumn(index int) Option {
+	return func(r *CSVReaderImpl) {
+		r.bankNameColumn = index
+	}
+}
+
+// WithRejectZeroAmounts excludes rows with a zero amount, they are often placeholders or data errors
+// The number of excluded rows is available from ZeroAmounts after reading
+func WithRejectZeroAmounts(rejectZeroAmounts bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.rejectZeroAmounts = rejectZeroAmounts
+	}
+}
+
+// WithFlagFutureDates warns about rows dated after the time of reading, they are often data-entry errors
+// The rows are still read and filtered by the time range, the warnings are available from Warnings after reading
+// Bank statement dates are days and only warned about from the next day on
+func WithFlagFutureDates(flagFutureDates bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.flagFutureDates = flagFutureDates
+	}
+}
+
+// WithLocation interprets the dates in the given location instead of UTC before truncating them to the day
+// The time range should be given in the same location
+func WithLocation(location *time.Location) Option {
+	return func(r *CSVReaderImpl) {
+		r.timezone = location
+	}
+}
+
+// WithMaxRows fails reading once a file has more than n data rows, stopping before the rest of the file is read
+// Values less than 1 disable the limit
+func WithMaxRows(n int) Option {
+	return func(r *CSVReaderImpl) {
+		r.maxRows = n
+	}
+}
+
+// WithSampleRows reads only the first n data rows of a file and ignores the rest, e.g. to check a new file format quickly
+// Rows filtered out by the time range count towards the sample, values less than 1 read all rows
+func WithSampleRows(n int) Option {
+	return func(r *CSVReaderImpl) {
+		r.sampleRows = n
+	}
+}
+
+// WithRejectNegativeAmounts sets whether negative system transaction amounts fail the read, it defaults to true
+// Disable it for systems storing debits as negative amounts, the amounts are then matched by their absolute value
+func WithRejectNegativeAmounts(rejectNegativeAmounts bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.rejectNegativeAmounts = rejectNegativeAmounts
+	}
+}
+
+// WithDebitCreditColumns reads the bank statement amount from separate debit and credit columns
+// at the given indexes of the row instead of a signed amount column, exactly one of them must be filled
+// Credits become positive and debits negative amounts, the other columns keep the UniqueID, Date order
+func WithDebitCreditColumns(debitIdx, creditIdx int) Option {
+	return func(r *CSVReaderImpl) {
+		r.debitCreditColumns = true
+		r.debitColumn = debitIdx
+		r.creditColumn = creditIdx
+	}
+}
+
+// WithTrimFields sets whether leading and trailing whitespace is trimmed from every field before parsing,
+// e.g. " 100.0" or "DEBIT ", it defaults to true
+func WithTrimFields(trimFields bool) Option {
+	return func(r *CSVReaderImpl) {
+		r.trimFields = trimFields
+	}
+}
+
+// WithSystemColumns reads the system transaction fields from the mapped columns, other columns are ignored,
+// e.g. a trailing empty column exported by Excel, see ParseSystemColumns
+func WithSystemColumns(columns *Columns) Option {
+	return func(r *CSVReaderImpl) {
+		r.systemColumns = columns
+	}
+}
+
+// WithBankColumns reads the bank statement fields from the mapped columns, other columns are ignored, see ParseBankColumns
+// A mapped direction column replaces WithDirectionColumn, it cannot be combined with a bank name column or debit and credit columns
+func WithBankColumns(columns *Columns) Option {
+	return func(r *CSVReaderImpl) {
+		r.bankColumns = columns
+	}
+}
+
+// EffectiveDateFormats returns the layouts tried to parse the bank statement dates with the given options
+func EffectiveDateFormats(opts ...Option) []string {
+	r := &CSVReaderImpl{dateFormats: defaultDateFormats}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r.dateFormats
+}
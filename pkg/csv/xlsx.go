@@ -0,0 +1,344 @@
+package csv
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewXLSXReader creates a reader for the first worksheet of an xlsx file
+// The rows are parsed with the same column mapping, time filtering and validation as CSV files
+// Cells formatted as dates are converted to the YYYY-MM-DD HH:MM:SS format for system transactions
+// and to the first date format for bank statements
+func NewXLSXReader(reader io.ReaderAt, size int64, opts ...Option) *CSVReaderImpl {
+	// Initialize the CSVReaderImpl
+	r := &CSVReaderImpl{
+		reader:                &xlsxReader{reader: reader, size: size},
+		dateFormats:           defaultDateFormats,
+		bankNameColumn:        -1,
+		rejectNegativeAmounts: true,
+		trimFields:            true,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// Return the CSVReaderImpl
+	return r
+}
+
+// excelEpoch is the day Excel date serial numbers count from
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// excel1904Epoch is the day serial numbers count from in workbooks using the 1904 date system, 1462 days later
+var excel1904Epoch = excelEpoch.AddDate(0, 0, 1462)
+
+// xlsxMaxColumns is the number of columns of a worksheet, the last column is XFD
+const xlsxMaxColumns = 16384
+
+// maxXLSXPartSize is the largest decompressed size of an xlsx part, a larger part fails the file
+// It keeps a small compressed workbook from exhausting the memory before any row limit applies
+var maxXLSXPartSize int64 = 256 << 20
+
+// xlsxReader reads the records of the first worksheet of an xlsx file
+type xlsxReader struct {
+	reader io.ReaderAt
+	size   int64
+
+	// Layout date cells are converted to, YYYY-MM-DD HH:MM:SS when empty
+	dateLayout string
+}
+
+// xlsxWorkbook is the sheet list and date system of xl/workbook.xml
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships is the relationship list of xl/_rels/workbook.xml.rels
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxSharedStrings is the string table of xl/sharedStrings.xml
+type xlsxSharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+// xlsxStyles is the number format part of xl/styles.xml
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+// xlsxWorksheet is the cell data of a worksheet
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  int    `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadAll reads all rows of the first worksheet, rows are padded to the widest row like CSV fields
+func (x *xlsxReader) ReadAll() ([][]string, error) {
+	// Open the xlsx archive
+	archive, err := zip.NewReader(x.reader, x.size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx archive: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	// Locate the first worksheet
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	sheetPath, err := firstSheetPath(files, workbook)
+	if err != nil {
+		return nil, err
+	}
+	var sheet xlsxWorksheet
+	if err := decodeXLSXPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	// Read the optional shared strings and styles
+	var sharedStrings xlsxSharedStrings
+	if err := decodeXLSXPart(files, "xl/sharedStrings.xml", &sharedStrings); err != nil && files["xl/sharedStrings.xml"] != nil {
+		return nil, err
+	}
+	var styles xlsxStyles
+	if err := decodeXLSXPart(files, "xl/styles.xml", &styles); err != nil && files["xl/styles.xml"] != nil {
+		return nil, err
+	}
+	dateStyles := xlsxDateStyles(styles)
+	epoch := excelEpoch
+	if workbook.Properties.Date1904 {
+		epoch = excel1904Epoch
+	}
+
+	// Convert the cells to records
+	records := make([][]string, 0, len(sheet.Rows))
+	width := 0
+	for _, row := range sheet.Rows {
+		// Skip empty rows like blank CSV lines
+		if len(row.Cells) == 0 {
+			continue
+		}
+
+		record := []string{}
+		for i, cell := range row.Cells {
+			// Place the cell in its column, cells without a reference follow the previous cell
+			column := len(record)
+			if cell.Ref != "" {
+				column, err = xlsxColumn(cell.Ref)
+				if err != nil {
+					return nil, fmt.Errorf("invalid cell reference %s in row %d: %w", cell.Ref, len(records)+1, err)
+				}
+			}
+			for len(record) <= column {
+				record = append(record, "")
+			}
+
+			// Resolve the cell value
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, fmt.Errorf("invalid shared string %s in cell %d of row %d", cell.Value, i+1, len(records)+1)
+				}
+				item := sharedStrings.Items[index]
+				text := item.Text
+				for _, run := range item.Runs {
+					text += run.Text
+				}
+				record[column] = text
+			case "inlineStr":
+				record[column] = cell.Inline
+			default:
+				record[column] = cell.Value
+				// Numbers are typed n or untyped, other types like booleans and errors are not dates
+				if cell.Value != "" && (cell.Type == "" || cell.Type == "n") && dateStyles[cell.Style] {
+					record[column] = xlsxDate(cell.Value, x.dateLayout, epoch)
+				}
+			}
+		}
+
+		if len(record) > width {
+			width = len(record)
+		}
+		records = append(records, record)
+	}
+
+	// Pad the records to the same width
+	for i, record := range records {
+		for len(record) < width {
+			record = append(record, "")
+		}
+		records[i] = record
+	}
+
+	return records, nil
+}
+
+// firstSheetPath returns the path of the first worksheet listed in the workbook
+func firstSheetPath(files map[string]*zip.File, workbook xlsxWorkbook) (string, error) {
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("xlsx workbook has no sheets")
+	}
+
+	var rels xlsxRelationships
+	if err := decodeXLSXPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].ID {
+			continue
+		}
+
+		// Targets are relative to the xl directory unless they are absolute
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("xlsx worksheet %s not found", workbook.Sheets[0].ID)
+}
+
+// decodeXLSXPart decodes the XML part of the archive with the given name
+func decodeXLSXPart(files map[string]*zip.File, name string, v any) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx part %s not found", name)
+	}
+	if file.UncompressedSize64 > uint64(maxXLSXPartSize) {
+		return fmt.Errorf("xlsx part %s exceeds %d bytes", name, maxXLSXPartSize)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open xlsx part %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	// The zip reader fails a part larger than its declared size, the limit only guards the decoder
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXLSXPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("invalid xlsx part %s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxDateStyles returns the indexes of the cell styles with a date number format
+func xlsxDateStyles(styles xlsxStyles) map[int]bool {
+	// Collect the custom date formats
+	customDates := make(map[int]bool, len(styles.NumFmts))
+	for _, numFmt := range styles.NumFmts {
+		customDates[numFmt.ID] = isDateFormatCode(numFmt.Code)
+	}
+
+	dateStyles := make(map[int]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		// Built-in formats 14 to 22 and 45 to 47 are dates and times
+		builtin := (xf.NumFmtID >= 14 && xf.NumFmtID <= 22) || (xf.NumFmtID >= 45 && xf.NumFmtID <= 47)
+		dateStyles[i] = builtin || customDates[xf.NumFmtID]
+	}
+	return dateStyles
+}
+
+// isDateFormatCode checks if a custom number format displays a date, ignoring colors and quoted text
+func isDateFormatCode(code string) bool {
+	var plain strings.Builder
+	inQuote, inBracket := false, false
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '[':
+			inBracket = true
+		case c == ']':
+			inBracket = false
+		case !inBracket:
+			plain.WriteRune(c)
+		}
+	}
+	return strings.ContainsAny(plain.String(), "yd")
+}
+
+// xlsxColumn converts the column letters of a cell reference like C12 to a zero-based index
+// Columns after XFD are rejected, as worksheets have no more columns
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	letters := 0
+	for _, c := range strings.ToUpper(ref) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		column = column*26 + int(c-'A'+1)
+		letters++
+
+		// Stop before the index can overflow on long references
+		if column > xlsxMaxColumns {
+			return 0, fmt.Errorf("column after XFD")
+		}
+	}
+	if letters == 0 {
+		return 0, fmt.Errorf("missing column")
+	}
+	return column - 1, nil
+}
+
+// setXLSXDateLayout sets the layout date cells of an xlsx file are converted to, other readers are left as is
+func (r *CSVReaderImpl) setXLSXDateLayout(layout string) {
+	if x, ok := r.reader.(*xlsxReader); ok {
+		x.dateLayout = layout
+	}
+}
+
+// xlsxDate converts an Excel date serial number counted from the epoch to the layout, YYYY-MM-DD HH:MM:SS when empty
+// Values that are not numbers are returned as is
+func xlsxDate(value, layout string, epoch time.Time) string {
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	// Round to the second to avoid floating point artifacts in the time of day
+	seconds := math.Round(serial * 24 * 60 * 60)
+	if layout == "" {
+		layout = "2006-01-02 15:04:05"
+	}
+	return epoch.Add(time.Duration(seconds) * time.Second).Format(layout)
+}
@@ -0,0 +1,103 @@
+package reconcile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// maxArchiveMemberSize is the largest decompressed size of a bank archive member, a larger member fails the archive
+// It keeps a small compressed bundle from exhausting the memory before any row limit applies
+var maxArchiveMemberSize int64 = 256 << 20
+
+// bankSource is a bank file to read, data holds the content of an archive member
+type bankSource struct {
+	// Path of the file, or name of the archive member
+	filename string
+
+	// Content of the archive member, nil for regular files
+	data []byte
+
+	// Error extracting the archive, the source is reported as failed
+	err error
+}
+
+// isTarGz checks if the path is a gzipped tar bundle
+func isTarGz(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// expandBankFiles replaces the gzipped tar bundles with their CSV and xlsx members
+// A bundle that cannot be extracted is kept as a single failed source
+func (r *reconciler) expandBankFiles(bankFiles []string) []bankSource {
+	sources := make([]bankSource, 0, len(bankFiles))
+	for _, bankFile := range bankFiles {
+		if !isTarGz(bankFile) {
+			sources = append(sources, bankSource{filename: bankFile})
+			continue
+		}
+
+		members, err := r.readTarGz(bankFile)
+		if err != nil {
+			sources = append(sources, bankSource{filename: bankFile, err: err})
+			continue
+		}
+		sources = append(sources, members...)
+	}
+	return sources
+}
+
+// readTarGz extracts the CSV and xlsx members of a gzipped tar bundle in memory
+// The member name is used as the filename, so the bank name is derived from it
+func (r *reconciler) readTarGz(path string) ([]bankSource, error) {
+	var members []bankSource
+
+	// Open and extract the bundle
+	err := r.readWithRetries(path, "bank archive", func(file fs.File) error {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read bank archive %s: %w", path, err)
+		}
+		defer gz.Close()
+
+		// Read the regular CSV and xlsx members
+		members = nil
+		archive := tar.NewReader(gz)
+		for {
+			header, err := archive.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read bank archive %s: %w", path, err)
+			}
+
+			ext := strings.ToLower(filepath.Ext(header.Name))
+			if header.Typeflag != tar.TypeReg || (ext != ".csv" && ext != ".xlsx") {
+				continue
+			}
+
+			// Read one byte past the limit to detect a larger member
+			data, err := io.ReadAll(io.LimitReader(archive, maxArchiveMemberSize+1))
+			if err != nil {
+				return fmt.Errorf("failed to read %s from bank archive %s: %w", header.Name, path, err)
+			}
+			if int64(len(data)) > maxArchiveMemberSize {
+				return fmt.Errorf("failed to read %s from bank archive %s: member exceeds %d bytes", header.Name, path, maxArchiveMemberSize)
+			}
+			members = append(members, bankSource{filename: header.Name, data: data})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
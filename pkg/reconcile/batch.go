@@ -0,0 +1,129 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+)
+
+// BatchResult is the reconciliation of a settlement batch, the system transactions of the batch against its bank settlement lines
+// The totals count CREDIT as positive and DEBIT as negative like the control totals
+type BatchResult struct {
+	// BatchID is the settlement batch ID
+	BatchID string `json:"batch_id"`
+
+	// SystemTotal is the sum of the system amounts of the batch
+	SystemTotal float64 `json:"system_total"`
+
+	// SystemCount is the number of system transactions of the batch
+	SystemCount int `json:"system_count"`
+
+	// BankTotal is the sum of the sign-adjusted bank amounts of the batch
+	BankTotal float64 `json:"bank_total"`
+
+	// BankCount is the number of bank settlement lines of the batch
+	BankCount int `json:"bank_count"`
+
+	// Difference is BankTotal minus SystemTotal
+	Difference float64 `json:"difference"`
+
+	// Matched is whether the batch has both sides and the totals agree within the tolerance of the system total
+	Matched bool `json:"matched"`
+}
+
+// batchMatches are the items of the matched batches
+type batchMatches struct {
+	// Whether the system transaction or bank statement at each index is part of a matched batch
+	system []bool
+	bank   []bool
+
+	// Sum of the absolute and signed differences of the matched batches in integer units
+	totalUnits int64
+	netUnits   int64
+
+	// Number of system transactions of the matched batches without a difference
+	exact int
+}
+
+// matchBatches reconciles the settlement batches, comparing the sum of the system amounts of each batch to the sum
+// of its bank settlement lines, items without a batch ID are not part of any batch
+// The batches are returned in order of first occurrence in the system transactions, then the bank statements
+func (r *reconciler) matchBatches(system []types.Transaction, bank []types.BankStatement) ([]BatchResult, batchMatches) {
+	// Group the items by batch ID
+	type batch struct {
+		system  []types.Transaction
+		bank    []types.BankStatement
+		sysIdx  []int
+		bankIdx []int
+	}
+	var batchIDs []string
+	batches := make(map[string]*batch)
+	group := func(batchID string) *batch {
+		b, ok := batches[batchID]
+		if !ok {
+			b = &batch{}
+			batches[batchID] = b
+			batchIDs = append(batchIDs, batchID)
+		}
+		return b
+	}
+	for i, sysTx := range system {
+		if sysTx.BatchID != "" {
+			b := group(sysTx.BatchID)
+			b.system = append(b.system, sysTx)
+			b.sysIdx = append(b.sysIdx, i)
+		}
+	}
+	for j, bankTx := range bank {
+		if bankTx.BatchID != "" {
+			b := group(bankTx.BatchID)
+			b.bank = append(b.bank, bankTx)
+			b.bankIdx = append(b.bankIdx, j)
+		}
+	}
+
+	// Compare the totals of each batch
+	results := make([]BatchResult, 0, len(batchIDs))
+	matches := batchMatches{system: make([]bool, len(system)), bank: make([]bool, len(bank))}
+	for _, batchID := range batchIDs {
+		b := batches[batchID]
+		systemUnits, bankUnits := r.controlTotals(b.system, b.bank)
+		diff := bankUnits - systemUnits
+		matched := len(b.system) > 0 && len(b.bank) > 0 && abs(diff) <= r.tolerance(systemUnits, "")
+		results = append(results, BatchResult{
+			BatchID:     batchID,
+			SystemTotal: r.fromUnits(systemUnits),
+			SystemCount: len(b.system),
+			BankTotal:   r.fromUnits(bankUnits),
+			BankCount:   len(b.bank),
+			Difference:  r.fromUnits(diff),
+			Matched:     matched,
+		})
+		if !matched {
+			continue
+		}
+
+		// Mark the items of the matched batch
+		for _, i := range b.sysIdx {
+			matches.system[i] = true
+		}
+		for _, j := range b.bankIdx {
+			matches.bank[j] = true
+		}
+		matches.totalUnits += abs(diff)
+		matches.netUnits += diff
+		if diff == 0 {
+			matches.exact += len(b.sysIdx)
+		}
+	}
+	return results, matches
+}
+
+// count returns the number of system transactions of the matched batches
+func (m batchMatches) count() int {
+	count := 0
+	for _, matched := range m.system {
+		if matched {
+			count++
+		}
+	}
+	return count
+}
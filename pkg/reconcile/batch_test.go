@@ -0,0 +1,61 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcile_WithBatchMatching tests reconciling settlement batches by their totals
+func TestReconcile_WithBatchMatching(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Batch B1 balances, batch B2 is short by 5.00 and TRX5 has no batch
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date, BatchID: "B1"},
+		{TrxID: "TRX2", Amount: 250.00, Type: types.TransactionTypeCredit, TransactionTime: date, BatchID: "B1"},
+		{TrxID: "TRX3", Amount: 50.00, Type: types.TransactionTypeDebit, TransactionTime: date, BatchID: "B1"},
+		{TrxID: "TRX4", Amount: 80.00, Type: types.TransactionTypeCredit, TransactionTime: date, BatchID: "B2"},
+		{TrxID: "TRX5", Amount: 10.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "bca", UniqueID: "SETTLE1", Amount: 300.00, Date: date, BatchID: "B1"},
+		{BankName: "bca", UniqueID: "SETTLE2", Amount: 75.00, Date: date.AddDate(0, 0, 1), BatchID: "B2"},
+		{BankName: "bca", UniqueID: "SETTLE3", Amount: 20.00, Date: date, BatchID: "B3"},
+	}
+
+	result := Reconcile(systemTxs, bankTxs, WithBatchMatching(true))
+	assert.Equal(t, []BatchResult{
+		{BatchID: "B1", SystemTotal: 300.00, SystemCount: 3, BankTotal: 300.00, BankCount: 1, Difference: 0, Matched: true},
+		{BatchID: "B2", SystemTotal: 80.00, SystemCount: 1, BankTotal: 75.00, BankCount: 1, Difference: -5.00, Matched: false},
+		{BatchID: "B3", SystemTotal: 0, SystemCount: 0, BankTotal: 20.00, BankCount: 1, Difference: 20.00, Matched: false},
+	}, result.Batches)
+
+	// The items of the balanced batch are matched, the others unmatched
+	assert.Equal(t, 5, result.TransactionProcessed)
+	assert.Equal(t, 3, result.TransactionMatched)
+	assert.Equal(t, 0, result.MatchedByHeuristic)
+	assert.Equal(t, []types.Transaction{systemTxs[3], systemTxs[4]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[1], bankTxs[2]}, result.TransactionUnmatched.BankUnmatched)
+	assert.Equal(t, 0.0, result.TotalDiscrepancies)
+
+	// The batches are part of the summary
+	assert.Contains(t, result.String(), "Settlement batches:\n"+
+		"- Batch: B1, System: 300.00 (3), Bank: 300.00 (1), Difference: 0.00, matched\n"+
+		"- Batch: B2, System: 80.00 (1), Bank: 75.00 (1), Difference: -5.00, unmatched\n")
+
+	// A percentage tolerance balances the short batch, its difference is a discrepancy
+	result = Reconcile(systemTxs, bankTxs, WithBatchMatching(true), WithPercentageTolerance(0.1))
+	assert.True(t, result.Batches[1].Matched)
+	assert.Equal(t, 4, result.TransactionMatched)
+	assert.InDelta(t, 5.00, result.TotalDiscrepancies, 1e-9)
+	assert.InDelta(t, -5.00, result.NetDiscrepancy, 1e-9)
+
+	// Without the option single transactions are matched
+	result = Reconcile(systemTxs, bankTxs)
+	assert.Nil(t, result.Batches)
+	assert.False(t, strings.Contains(result.String(), "Settlement batches"))
+}
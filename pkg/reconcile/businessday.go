@@ -0,0 +1,47 @@
+package reconcile
+
+import (
+	"time"
+)
+
+// dateMatches checks if a bank statement date matches a system transaction date
+// Without a business day window the dates must share the date key, with a window the bank statement
+// is posted on the same day or up to the window of business days later
+func (r *reconciler) dateMatches(sysDate, bankDate time.Time) bool {
+	if r.businessDayWindow <= 0 {
+		return r.dateKey(sysDate) == r.dateKey(bankDate)
+	}
+	days := r.businessDaysBetween(sysDate, bankDate)
+	return days >= 0 && days <= r.businessDayWindow
+}
+
+// businessDaysBetween counts the business days after the from date up to and including the to date,
+// it returns -1 when the to date is before the from date and stops counting past the window
+func (r *reconciler) businessDaysBetween(from, to time.Time) int {
+	day, last := civilDate(from), civilDate(to)
+	if last.Before(day) {
+		return -1
+	}
+	count := 0
+	for day.Before(last) && count <= r.businessDayWindow {
+		day = day.AddDate(0, 0, 1)
+		if r.isBusinessDay(day) {
+			count++
+		}
+	}
+	return count
+}
+
+// isBusinessDay checks if the date is a weekday and not a holiday
+func (r *reconciler) isBusinessDay(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !r.holidays[date.Format("2006-01-02")]
+}
+
+// civilDate returns the calendar date of the time in its location as midnight UTC, to step through days
+func civilDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,43 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcile_BusinessDayWindow tests matching bank statements posted business days after the system transaction
+func TestReconcile_BusinessDayWindow(t *testing.T) {
+	friday := time.Date(2024, 3, 22, 15, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: friday},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: friday},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: monday},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: monday},
+		{UniqueID: "BANK2", Amount: 200.00, Date: tuesday},
+		{UniqueID: "BANK3", Amount: 300.00, Date: friday},
+	}
+
+	// Without a window the dates must be equal
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 0, result.TransactionMatched)
+
+	// The weekend is skipped, Monday is 1 business day after Friday and Tuesday is 2
+	// A bank statement posted before the system transaction never matches
+	result = Reconcile(systemTxs, bankTxs, WithBusinessDayWindow(1, nil), WithWorkers(4))
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[1], systemTxs[2]}, result.TransactionUnmatched.SystemUnmatched)
+
+	result = Reconcile(systemTxs, bankTxs, WithBusinessDayWindow(2, nil))
+	assert.Equal(t, 2, result.TransactionMatched)
+
+	// A Monday holiday makes Tuesday the next business day
+	result = Reconcile(systemTxs, bankTxs, WithBusinessDayWindow(1, []time.Time{monday}))
+	assert.Equal(t, 2, result.TransactionMatched)
+}
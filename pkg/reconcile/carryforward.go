@@ -0,0 +1,80 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+)
+
+// withCarryforward appends the carried forward items to the fresh transactions and statements
+// Carried items also present in the fresh input are dropped, e.g. when the previous period overlaps
+// The carried items follow the fresh ones, so an index at or past the fresh length is a carried item
+func (r *reconciler) withCarryforward(system []types.Transaction, bank []types.BankStatement) ([]types.Transaction, []types.BankStatement) {
+	carriedSystem, carriedBank := r.carriedSystem, r.carriedBank
+	if r.typeFilter != "" {
+		carriedSystem, carriedBank = r.filterByType(carriedSystem, carriedBank)
+	}
+
+	freshIDs := make(map[string]bool, len(system))
+	for _, sysTx := range system {
+		freshIDs[sysTx.TrxID] = true
+	}
+	freshKeys := make(map[string]bool, len(bank))
+	for _, bankTx := range bank {
+		freshKeys[bankKey(bankTx)] = true
+	}
+
+	// Copy the fresh items, the caller's slices are not modified
+	pooledSystem := append(make([]types.Transaction, 0, len(system)+len(carriedSystem)), system...)
+	for _, sysTx := range carriedSystem {
+		if !freshIDs[sysTx.TrxID] {
+			pooledSystem = append(pooledSystem, sysTx)
+		}
+	}
+	pooledBank := append(make([]types.BankStatement, 0, len(bank)+len(carriedBank)), bank...)
+	for _, bankTx := range carriedBank {
+		if !freshKeys[bankKey(bankTx)] {
+			pooledBank = append(pooledBank, bankTx)
+		}
+	}
+	return pooledSystem, pooledBank
+}
+
+// matchCarryforward matches the remaining system transactions to bank statements posted on the same or a later date,
+// where the transaction or the statement is carried forward, to allow for the settlement lag between periods
+// A custom matcher decides on its own, fresh pairs are left to the regular matching
+func (r *reconciler) matchCarryforward(system []types.Transaction, bank []types.BankStatement, freshSystem, freshBank int, matches []int) {
+	claimed := claimedBank(bank, matches)
+	for i, sysTx := range system {
+		// Skip already matched system transactions
+		if matches[i] >= 0 {
+			continue
+		}
+
+		// Fresh transactions are only compared against carried statements
+		start := 0
+		if i < freshSystem {
+			start = freshBank
+		}
+		for j := start; j < len(bank); j++ {
+			bankTx := bank[j]
+			if claimed[bankKey(bankTx)] || !r.isCarryforwardMatch(sysTx, bankTx) {
+				continue
+			}
+			matches[i] = j
+			claimed[bankKey(bankTx)] = true
+			break
+		}
+	}
+}
+
+// isCarryforwardMatch checks if a system transaction matches a bank statement posted on the same or a later date
+func (r *reconciler) isCarryforwardMatch(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	if !currencyMatches(sysTx, bankTx) {
+		return false
+	}
+	if r.matcher != nil {
+		return r.matcher.Match(sysTx, bankTx)
+	}
+
+	// The date keys sort chronologically at every granularity
+	return r.amountMatches(sysTx, bankTx) && r.dateKey(bankTx.Date) >= r.dateKey(sysTx.TransactionTime)
+}
@@ -0,0 +1,47 @@
+package reconcile
+
+import (
+	"io"
+	"strings"
+)
+
+// ANSI escape codes of the summary colors
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// StringColored returns the summary like String, with the matched counts in green, the unmatched in red
+// and the discrepancies in yellow using ANSI colors, for printing to a terminal
+func (r *ReconcileResult) StringColored() string {
+	var result strings.Builder
+
+	// Writing to a strings.Builder never fails
+	_ = r.WriteSummaryColored(&result)
+	return result.String()
+}
+
+// WriteSummaryColored writes the summary like WriteSummary, highlighted with ANSI colors
+func (r *ReconcileResult) WriteSummaryColored(w io.Writer, opts ...ReportOption) error {
+	o := newReportOptions(opts...)
+	o.colored = true
+	return r.writeSummary(w, o)
+}
+
+// paint wraps the text in the color when the summary is colored
+func (sw *summaryWriter) paint(color, text string) string {
+	if !sw.colored {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// paintIf paints the text only when the condition holds, e.g. to leave a zero count of a clean result uncolored
+func (sw *summaryWriter) paintIf(color string, condition bool, text string) string {
+	if !condition {
+		return text
+	}
+	return sw.paint(color, text)
+}
@@ -0,0 +1,34 @@
+package reconcile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStringColored tests highlighting the summary counts and discrepancies with ANSI colors
+func TestStringColored(t *testing.T) {
+	result := ReconcileResult{
+		TransactionProcessed: 3,
+		TransactionMatched:   2,
+		TransactionUnmatched: ReconcileUnmatched{TransactionUnmatched: 1},
+		TotalDiscrepancies:   0.5,
+	}
+
+	// Color on highlights the counts and the nonzero discrepancy, the zero net discrepancy stays plain
+	colored := result.StringColored()
+	assert.Contains(t, colored, "Total matched transactions: \033[32m2\033[0m\n")
+	assert.Contains(t, colored, "Total unmatched transactions: \033[31m1\033[0m\n")
+	assert.Contains(t, colored, "Total amount discrepancies: \033[33m0.50\033[0m\n")
+	assert.Contains(t, colored, "Net amount discrepancies: 0.00\n")
+
+	// Color off has no escape codes and matches the colored summary once they are removed
+	plain := result.String()
+	assert.NotContains(t, plain, "\033[")
+	assert.Contains(t, plain, "Total unmatched transactions: 1\n")
+	for _, code := range []string{colorGreen, colorRed, colorYellow, colorReset} {
+		colored = strings.ReplaceAll(colored, code, "")
+	}
+	assert.Equal(t, plain, colored)
+}
@@ -0,0 +1,175 @@
+package reconcile
+
+import (
+	"sort"
+	"strings"
+)
+
+// Config is the effective configuration of a reconciliation run, recorded in the result to make reports self-describing
+// when defaults change
+type Config struct {
+	// Tolerance is the absolute amount discrepancy allowed, one unit of the last decimal place
+	Tolerance float64 `json:"tolerance"`
+
+	// PercentageTolerance is the fraction of the system amount allowed as discrepancy, see WithPercentageTolerance
+	PercentageTolerance float64 `json:"percentage_tolerance"`
+
+	// BankTolerances are the absolute amount discrepancies allowed per bank, see WithBankTolerance
+	BankTolerances map[string]float64 `json:"bank_tolerances,omitempty"`
+
+	// DecimalPlaces is the number of decimal places amounts are rounded to
+	DecimalPlaces int `json:"decimal_places"`
+
+	// DateGranularity is the precision the dates must agree on, day, month or year
+	DateGranularity string `json:"date_granularity"`
+
+	// BusinessDayWindow is the number of business days a bank statement may be posted later, see WithBusinessDayWindow
+	BusinessDayWindow int `json:"business_day_window,omitempty"`
+
+	// Holidays are the holidays skipped by the business day window in YYYY-MM-DD format
+	Holidays []string `json:"holidays,omitempty"`
+
+	// TypeSignRules are the expected bank amount signs per transaction type, positive, negative or either
+	TypeSignRules map[string]string `json:"type_sign_rules"`
+
+	// TypeFilter is the only transaction type reconciled, empty for all types
+	TypeFilter string `json:"type_filter,omitempty"`
+
+	// InvertBankSign, UseSignedAmount and IgnoreType change how the bank direction is checked against the type
+	InvertBankSign  bool `json:"invert_bank_sign"`
+	UseSignedAmount bool `json:"use_signed_amount"`
+	IgnoreType      bool `json:"ignore_type"`
+
+	// ConsistentBankSign infers the sign convention per bank, InvertedBanks are the banks found to be inverted
+	ConsistentBankSign bool     `json:"consistent_bank_sign,omitempty"`
+	InvertedBanks      []string `json:"inverted_banks,omitempty"`
+
+	// The matching modes enabled for the run
+	IDMatching      bool `json:"id_matching"`
+	BestMatch       bool `json:"best_match"`
+	OptimalMatching bool `json:"optimal_matching"`
+	BatchMatching   bool `json:"batch_matching"`
+	SplitMatching   bool `json:"split_matching"`
+	CustomMatcher   bool `json:"custom_matcher"`
+
+	// ReferenceGrouping matches system transactions sharing a reference to one bank statement
+	ReferenceGrouping bool `json:"reference_grouping,omitempty"`
+
+	// SuggestionWindow is the number of days searched for suggestions of unmatched transactions
+	SuggestionWindow int `json:"suggestion_window"`
+
+	// DateFormats are the layouts tried to parse the bank statement dates, only set for runs reading files
+	DateFormats []string `json:"date_formats,omitempty"`
+}
+
+// config returns the effective configuration of the reconciler
+func (r *reconciler) config() *Config {
+	c := &Config{
+		Tolerance:           r.fromUnits(r.toleranceUnits),
+		PercentageTolerance: r.percentageTolerance,
+		DecimalPlaces:       r.decimalPlaces,
+		DateGranularity:     r.dateGranularity.String(),
+		BusinessDayWindow:   r.businessDayWindow,
+		TypeSignRules:       make(map[string]string, len(r.typeSignRules)),
+		TypeFilter:          string(r.typeFilter),
+		InvertBankSign:      r.invertBankSign,
+		UseSignedAmount:     r.useSignedAmount,
+		IgnoreType:          r.ignoreType,
+		IDMatching:          r.idMatching,
+		BestMatch:           r.bestMatch,
+		OptimalMatching:     r.optimalMatching,
+		BatchMatching:       r.batchMatching,
+		SplitMatching:       r.splitMatching,
+		CustomMatcher:       r.matcher != nil,
+		ReferenceGrouping:   r.referenceGrouping && !r.batchMatching,
+		SuggestionWindow:    r.suggestionWindow,
+	}
+	for txType, sign := range r.typeSignRules {
+		c.TypeSignRules[string(txType)] = sign.String()
+	}
+	if len(r.bankTolerances) > 0 {
+		c.BankTolerances = r.bankTolerances
+	}
+	if r.consistentBankSign && !r.ignoreType {
+		c.ConsistentBankSign = true
+		for bankName := range r.invertedBanks {
+			c.InvertedBanks = append(c.InvertedBanks, bankName)
+		}
+		sort.Strings(c.InvertedBanks)
+	}
+	if r.businessDayWindow > 0 {
+		for holiday := range r.holidays {
+			c.Holidays = append(c.Holidays, holiday)
+		}
+		sort.Strings(c.Holidays)
+	}
+	return c
+}
+
+// String returns the name of the sign, positive, negative or either
+func (s Sign) String() string {
+	switch s {
+	case SignPositive:
+		return "positive"
+	case SignNegative:
+		return "negative"
+	default:
+		return "either"
+	}
+}
+
+// String returns the name of the date granularity, day, month or year
+func (g DateGranularity) String() string {
+	switch g {
+	case DateGranularityMonth:
+		return "month"
+	case DateGranularityYear:
+		return "year"
+	default:
+		return "day"
+	}
+}
+
+// writeConfig writes the configuration section of the text summary
+func (c *Config) writeConfig(result *summaryWriter) {
+	result.printf("\nConfiguration:\n")
+	result.printf("- Tolerance: %g, Percentage tolerance: %g, Decimal places: %d\n", c.Tolerance, c.PercentageTolerance, c.DecimalPlaces)
+	result.printf("- Date granularity: %s", c.DateGranularity)
+	if c.BusinessDayWindow > 0 {
+		result.printf(", Business day window: %d, Holidays: %d", c.BusinessDayWindow, len(c.Holidays))
+	}
+	result.printf("\n")
+
+	// Write the sign rules in type order
+	txTypes := make([]string, 0, len(c.TypeSignRules))
+	for txType := range c.TypeSignRules {
+		txTypes = append(txTypes, txType)
+	}
+	sort.Strings(txTypes)
+	rules := make([]string, len(txTypes))
+	for i, txType := range txTypes {
+		rules[i] = txType + " " + c.TypeSignRules[txType]
+	}
+	result.printf("- Type sign rules: %s\n", strings.Join(rules, ", "))
+
+	if c.TypeFilter != "" {
+		result.printf("- Type filter: %s\n", c.TypeFilter)
+	}
+	result.printf("- Invert bank sign: %t, Use signed amount: %t, Ignore type: %t\n", c.InvertBankSign, c.UseSignedAmount, c.IgnoreType)
+	if c.ConsistentBankSign {
+		inverted := "none"
+		if len(c.InvertedBanks) > 0 {
+			inverted = strings.Join(c.InvertedBanks, ", ")
+		}
+		result.printf("- Consistent bank sign, inverted banks: %s\n", inverted)
+	}
+	result.printf("- ID matching: %t, Best match: %t, Optimal matching: %t, Batch matching: %t, Split matching: %t, Custom matcher: %t\n",
+		c.IDMatching, c.BestMatch, c.OptimalMatching, c.BatchMatching, c.SplitMatching, c.CustomMatcher)
+	if c.ReferenceGrouping {
+		result.printf("- Reference grouping: %t\n", c.ReferenceGrouping)
+	}
+	result.printf("- Suggestion window: %d days\n", c.SuggestionWindow)
+	if len(c.DateFormats) > 0 {
+		result.printf("- Date formats: %s\n", strings.Join(c.DateFormats, ", "))
+	}
+}
@@ -0,0 +1,51 @@
+package reconcile
+
+import (
+	"bytes"
+	"path/filepath"
+	pkgcsv "reconciliation/pkg/csv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcile_Config tests recording the effective configuration in the result and the JSON output
+func TestReconcile_Config(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system.csv":  {Data: []byte("TrxID,Amount,Type,TransactionTime\nTX001,100.0,CREDIT,2024-01-01 10:00:00\n")},
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,100.0,01/01/2024\n")},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	result, err := RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys),
+		WithPercentageTolerance(0.005), WithDateGranularity(DateGranularityMonth), WithCSVOptions(pkgcsv.WithDateFormats("01/02/2006")))
+	assert.NoError(t, err)
+
+	// The config reflects the non-default tolerance, the defaults and the date formats
+	assert.Equal(t, 0.005, result.Config.PercentageTolerance)
+	assert.Equal(t, 0.01, result.Config.Tolerance)
+	assert.Equal(t, "month", result.Config.DateGranularity)
+	assert.Equal(t, map[string]string{"DEBIT": "negative", "CREDIT": "positive"}, result.Config.TypeSignRules)
+	assert.Equal(t, []string{"01/02/2006"}, result.Config.DateFormats)
+
+	// The JSON output has a config block, loaded back with the result
+	var buf bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&buf))
+	assert.Contains(t, buf.String(), `"percentage_tolerance": 0.005`)
+	filename := filepath.Join(t.TempDir(), "result.json")
+	assert.NoError(t, result.GenerateJSON(filename))
+	loaded, err := LoadJSON(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, result.Config, loaded.Config)
+
+	// The summary describes the configuration only when explained
+	assert.NotContains(t, result.String(), "Configuration:")
+	var summary strings.Builder
+	assert.NoError(t, result.WriteSummary(&summary, WithExplain(true)))
+	assert.Contains(t, summary.String(), "\nConfiguration:\n- Tolerance: 0.01, Percentage tolerance: 0.005, Decimal places: 2\n"+
+		"- Date granularity: month\n- Type sign rules: CREDIT positive, DEBIT negative\n")
+	assert.Contains(t, summary.String(), "- Date formats: 01/02/2006\n")
+}
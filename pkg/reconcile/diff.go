@@ -0,0 +1,126 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"reconciliation/pkg/types"
+)
+
+// ResultDiff is the comparison of the unmatched items of two reconciliation results
+// System transactions are matched across results by TrxID, bank statements by bank name and UniqueID
+type ResultDiff struct {
+	// NewSystem are the unmatched system transactions that only appear in the later result
+	NewSystem []types.Transaction
+
+	// ResolvedSystem are the unmatched system transactions that only appear in the earlier result
+	ResolvedSystem []types.Transaction
+
+	// UnchangedSystem are the unmatched system transactions that appear in both results
+	UnchangedSystem []types.Transaction
+
+	// NewBank are the unmatched bank statements that only appear in the later result
+	NewBank []types.BankStatement
+
+	// ResolvedBank are the unmatched bank statements that only appear in the earlier result
+	ResolvedBank []types.BankStatement
+
+	// UnchangedBank are the unmatched bank statements that appear in both results
+	UnchangedBank []types.BankStatement
+}
+
+// DiffResults compares the unmatched items of the earlier result a with the later result b
+func DiffResults(a, b ReconcileResult) ResultDiff {
+	diff := ResultDiff{}
+
+	// Compare the unmatched system transactions by TrxID
+	diff.NewSystem, diff.ResolvedSystem, diff.UnchangedSystem = diffItems(
+		a.TransactionUnmatched.SystemUnmatched, b.TransactionUnmatched.SystemUnmatched,
+		func(tx types.Transaction) string { return tx.TrxID })
+
+	// Compare the unmatched bank statements by bank name and UniqueID
+	diff.NewBank, diff.ResolvedBank, diff.UnchangedBank = diffItems(
+		a.TransactionUnmatched.BankUnmatched, b.TransactionUnmatched.BankUnmatched,
+		func(stmt types.BankStatement) string { return stmt.BankName + "|" + stmt.UniqueID })
+
+	return diff
+}
+
+// diffItems splits the items into the ones only in next, only in prev and in both, keeping their order
+// Items in both are taken from next
+func diffItems[T any](prev, next []T, key func(T) string) (added, resolved, unchanged []T) {
+	// Index the keys of both sides
+	inPrev := make(map[string]bool, len(prev))
+	for _, item := range prev {
+		inPrev[key(item)] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, item := range next {
+		inNext[key(item)] = true
+	}
+
+	// Split the later items into new and unchanged
+	for _, item := range next {
+		if inPrev[key(item)] {
+			unchanged = append(unchanged, item)
+		} else {
+			added = append(added, item)
+		}
+	}
+
+	// Collect the earlier items that are gone
+	for _, item := range prev {
+		if !inNext[key(item)] {
+			resolved = append(resolved, item)
+		}
+	}
+
+	return added, resolved, unchanged
+}
+
+// WriteSummary writes a human readable summary of the diff to the given writer
+func (d *ResultDiff) WriteSummary(w io.Writer) error {
+	// Wrap the writer to keep the first write error
+	result := &summaryWriter{w: w}
+
+	// Write the diff header and counts
+	result.printf("Reconciliation Diff:\n--------------------\n")
+	result.printf("New unmatched items: %d\n", len(d.NewSystem)+len(d.NewBank))
+	result.printf("Resolved unmatched items: %d\n", len(d.ResolvedSystem)+len(d.ResolvedBank))
+	result.printf("Unchanged unmatched items: %d\n", len(d.UnchangedSystem)+len(d.UnchangedBank))
+
+	// Write each group of items
+	writeDiffGroup(result, "New", d.NewSystem, d.NewBank)
+	writeDiffGroup(result, "Resolved", d.ResolvedSystem, d.ResolvedBank)
+	writeDiffGroup(result, "Unchanged", d.UnchangedSystem, d.UnchangedBank)
+
+	// Return the first write error, if any
+	if result.err != nil {
+		return fmt.Errorf("failed to write diff: %w", result.err)
+	}
+	return nil
+}
+
+// writeDiffGroup writes the system transactions and bank statements of one diff group
+func writeDiffGroup(result *summaryWriter, label string, system []types.Transaction, bank []types.BankStatement) {
+	if len(system) > 0 {
+		result.printf("\n%s system transactions:\n", label)
+		for _, tx := range system {
+			result.printf("- TrxID: %s, Amount: %.2f, Type: %s, Date: %s\n",
+				tx.TrxID,
+				tx.Amount,
+				tx.Type,
+				tx.TransactionTime.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	if len(bank) > 0 {
+		result.printf("\n%s bank statements:\n", label)
+		for _, stmt := range bank {
+			result.printf("- Bank: %s, ID: %s, Amount: %.2f, Date: %s\n",
+				stmt.BankName,
+				stmt.UniqueID,
+				stmt.Amount,
+				stmt.Date.Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package reconcile
+
+import (
+	"bytes"
+	"path/filepath"
+	"reconciliation/pkg/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDiffResults tests comparing the unmatched items of two results
+func TestDiffResults(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the earlier result
+	previous := ReconcileResult{
+		TransactionUnmatched: ReconcileUnmatched{
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+				{TrxID: "TRX2", Amount: 200.00, Type: "CREDIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BANK1", Amount: 300.00, Date: date},
+				{BankName: "BCA", UniqueID: "BANK1", Amount: 400.00, Date: date},
+			},
+		},
+	}
+
+	// Define the later result, TRX1 and BRI BANK1 are resolved, TRX3 and BNI BANK2 are new
+	current := ReconcileResult{
+		TransactionUnmatched: ReconcileUnmatched{
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX2", Amount: 200.00, Type: "CREDIT", TransactionTime: date},
+				{TrxID: "TRX3", Amount: 500.00, Type: "DEBIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BCA", UniqueID: "BANK1", Amount: 400.00, Date: date},
+				{BankName: "BNI", UniqueID: "BANK2", Amount: 600.00, Date: date},
+			},
+		},
+	}
+
+	// Compare the results
+	diff := DiffResults(previous, current)
+
+	// Check the items are split by TrxID and by bank name and UniqueID
+	assert.Equal(t, []types.Transaction{current.TransactionUnmatched.SystemUnmatched[1]}, diff.NewSystem)
+	assert.Equal(t, []types.Transaction{previous.TransactionUnmatched.SystemUnmatched[0]}, diff.ResolvedSystem)
+	assert.Equal(t, []types.Transaction{current.TransactionUnmatched.SystemUnmatched[0]}, diff.UnchangedSystem)
+	assert.Equal(t, []types.BankStatement{current.TransactionUnmatched.BankUnmatched[1]}, diff.NewBank)
+	assert.Equal(t, []types.BankStatement{previous.TransactionUnmatched.BankUnmatched[0]}, diff.ResolvedBank)
+	assert.Equal(t, []types.BankStatement{current.TransactionUnmatched.BankUnmatched[0]}, diff.UnchangedBank)
+
+	// Check the summary
+	var buf bytes.Buffer
+	assert.NoError(t, diff.WriteSummary(&buf))
+	assert.Equal(t, "Reconciliation Diff:\n"+
+		"--------------------\n"+
+		"New unmatched items: 2\n"+
+		"Resolved unmatched items: 2\n"+
+		"Unchanged unmatched items: 2\n"+
+		"\nNew system transactions:\n"+
+		"- TrxID: TRX3, Amount: 500.00, Type: DEBIT, Date: 2024-03-20 00:00:00\n"+
+		"\nNew bank statements:\n"+
+		"- Bank: BNI, ID: BANK2, Amount: 600.00, Date: 2024-03-20\n"+
+		"\nResolved system transactions:\n"+
+		"- TrxID: TRX1, Amount: 100.00, Type: CREDIT, Date: 2024-03-20 00:00:00\n"+
+		"\nResolved bank statements:\n"+
+		"- Bank: BRI, ID: BANK1, Amount: 300.00, Date: 2024-03-20\n"+
+		"\nUnchanged system transactions:\n"+
+		"- TrxID: TRX2, Amount: 200.00, Type: CREDIT, Date: 2024-03-20 00:00:00\n"+
+		"\nUnchanged bank statements:\n"+
+		"- Bank: BCA, ID: BANK1, Amount: 400.00, Date: 2024-03-20\n",
+		buf.String())
+}
+
+// TestLoadJSON tests reading back a result written by GenerateJSON
+func TestLoadJSON(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the result
+	result := ReconcileResult{
+		TransactionProcessed: 3,
+		TransactionMatched:   1,
+		MatchedByHeuristic:   1,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 3,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BANK1", Amount: 300.00, Date: date},
+				{BankName: "BCA", UniqueID: "BANK2", Amount: 400.00, Date: date},
+			},
+		},
+		TotalDiscrepancies: 0.5,
+		NetDiscrepancy:     -0.5,
+	}
+
+	// Write and read back the result
+	filename := filepath.Join(t.TempDir(), "result.json")
+	assert.NoError(t, result.GenerateJSON(filename))
+	loaded, err := LoadJSON(filename)
+	assert.NoError(t, err)
+
+	// Bank statements come back ordered by bank name
+	expected := result
+	expected.TransactionUnmatched.BankUnmatched = []types.BankStatement{
+		result.TransactionUnmatched.BankUnmatched[1],
+		result.TransactionUnmatched.BankUnmatched[0],
+	}
+	assert.Equal(t, expected, loaded)
+
+	// A missing file fails
+	_, err = LoadJSON(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
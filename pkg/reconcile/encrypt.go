@@ -0,0 +1,109 @@
+package reconcile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// encryptedMagic starts every encrypted file, it identifies the format and its version
+const encryptedMagic = "RECONENC1"
+
+// ParseEncryptionKey decodes a hex encoded AES key of 16, 24 or 32 bytes, e.g. generated with openssl rand -hex 32
+func ParseEncryptionKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: not hex encoded")
+	}
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, fmt.Errorf("invalid encryption key: got %d bytes, use 16, 24 or 32 bytes", len(key))
+	}
+	return key, nil
+}
+
+// Encrypt seals the plaintext with AES-GCM under the key
+// The result is the format magic followed by a random nonce and the sealed plaintext
+func Encrypt(plaintext, key []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use a random nonce per file, the magic is authenticated as additional data
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := append([]byte(encryptedMagic), nonce...)
+	return aead.Seal(sealed, nonce, plaintext, []byte(encryptedMagic)), nil
+}
+
+// Decrypt opens data sealed by Encrypt, it fails for a wrong key or modified data
+func Decrypt(data, key []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the format and split the nonce from the sealed plaintext
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return nil, errors.New("not an encrypted reconciliation file")
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(encryptedMagic))
+	if err != nil {
+		return nil, errors.New("failed to decrypt: wrong key or modified data")
+	}
+	return plaintext, nil
+}
+
+// WriteEncryptedFile encrypts the plaintext with the key and writes it to the file, readable by the owner only
+func WriteEncryptedFile(filename string, plaintext, key []byte) error {
+	data, err := Encrypt(plaintext, key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	return nil
+}
+
+// ReadEncryptedFile reads the file written by WriteEncryptedFile and decrypts it with the key
+func ReadEncryptedFile(filename string, key []byte) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted file: %w", err)
+	}
+	return Decrypt(data, key)
+}
+
+// GenerateEncryptedJSON generates a JSON file containing reconciliation results encrypted with the key,
+// e.g. result.json.enc, ReadEncryptedFile returns the JSON
+func (r *ReconcileResult) GenerateEncryptedJSON(filename string, key []byte, opts ...JSONOption) error {
+	var buf bytes.Buffer
+	if err := r.EncodeJSON(&buf, opts...); err != nil {
+		return err
+	}
+	return WriteEncryptedFile(filename, buf.Bytes(), key)
+}
+
+// newGCM creates the AES-GCM cipher for the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,85 @@
+package reconcile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reconciliation/pkg/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGenerateEncryptedJSON tests that decrypting the encrypted JSON file yields the original JSON
+func TestGenerateEncryptedJSON(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	key, err := ParseEncryptionKey("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	assert.NoError(t, err)
+
+	// Define the result
+	result := ReconcileResult{
+		TransactionProcessed: 1,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 1,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+			},
+		},
+	}
+	var expected bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&expected))
+
+	// Generate the encrypted file, the content does not reveal the transactions
+	filename := filepath.Join(t.TempDir(), "result.json.enc")
+	assert.NoError(t, result.GenerateEncryptedJSON(filename, key))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "TRX1")
+
+	// Decrypt the file
+	plaintext, err := ReadEncryptedFile(filename, key)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), string(plaintext))
+
+	// A wrong key fails
+	otherKey := bytes.Repeat([]byte{1}, 32)
+	_, err = ReadEncryptedFile(filename, otherKey)
+	assert.EqualError(t, err, "failed to decrypt: wrong key or modified data")
+
+	// Modified data fails
+	data[len(data)-1] ^= 1
+	_, err = Decrypt(data, key)
+	assert.EqualError(t, err, "failed to decrypt: wrong key or modified data")
+
+	// A plain JSON file is not decrypted
+	_, err = Decrypt(expected.Bytes(), key)
+	assert.EqualError(t, err, "not an encrypted reconciliation file")
+}
+
+// TestParseEncryptionKey tests decoding hex encoded AES keys
+func TestParseEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantLen int
+		wantErr string
+	}{
+		{name: "AES-128", key: "000102030405060708090a0b0c0d0e0f", wantLen: 16},
+		{name: "AES-256", key: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", wantLen: 32},
+		{name: "Not hex", key: "secret", wantErr: "invalid encryption key: not hex encoded"},
+		{name: "Wrong length", key: "0001020304", wantErr: "invalid encryption key: got 5 bytes, use 16, 24 or 32 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseEncryptionKey(tt.key)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, key, tt.wantLen)
+		})
+	}
+}
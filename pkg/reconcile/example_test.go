@@ -0,0 +1,76 @@
+package reconcile_test
+
+import (
+	"fmt"
+	"reconciliation/pkg/reconcile"
+	"reconciliation/pkg/types"
+	"strings"
+	"time"
+)
+
+// exampleData returns a system transaction matching a bank statement, one off by a fee and one missing from the bank
+func exampleData() ([]types.Transaction, []types.BankStatement) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	system := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date.Add(9 * time.Hour)},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date.Add(10 * time.Hour)},
+		{TrxID: "TRX3", Amount: 50.00, Type: types.TransactionTypeDebit, TransactionTime: date.Add(11 * time.Hour)},
+	}
+	bank := []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS1", Amount: 100.00, Date: date},
+		{BankName: "BCA", UniqueID: "BS2", Amount: 199.50, Date: date},
+	}
+	return system, bank
+}
+
+// ExampleReconcile reconciles transactions parsed from any source
+func ExampleReconcile() {
+	system, bank := exampleData()
+
+	result := reconcile.Reconcile(system, bank)
+	fmt.Println("matched:", result.TransactionMatched)
+	for _, tx := range result.TransactionUnmatched.SystemUnmatched {
+		fmt.Println("missing from bank:", tx.TrxID)
+	}
+	for _, stmt := range result.TransactionUnmatched.BankUnmatched {
+		fmt.Println("missing from system:", stmt.UniqueID)
+	}
+	// Output:
+	// matched: 1
+	// missing from bank: TRX2
+	// missing from bank: TRX3
+	// missing from system: BS2
+}
+
+// ExampleReconcile_options configures the matching with options
+func ExampleReconcile_options() {
+	system, bank := exampleData()
+
+	// Allow a discrepancy of 0.5% of the system amount
+	result := reconcile.Reconcile(system, bank,
+		reconcile.WithPercentageTolerance(0.005),
+		reconcile.WithSuggestionWindow(0),
+	)
+	fmt.Println("matched:", result.TransactionMatched)
+	fmt.Printf("total discrepancies: %.2f\n", result.TotalDiscrepancies)
+	// Output:
+	// matched: 2
+	// total discrepancies: 0.50
+}
+
+// ExampleWithMatcher adds a rule on top of the built-in matcher
+func ExampleWithMatcher() {
+	system, bank := exampleData()
+
+	// Only match bank statements whose ID ends with the number of the transaction
+	defaultMatcher := reconcile.DefaultMatcher(reconcile.WithPercentageTolerance(0.005))
+	matcher := reconcile.MatcherFunc(func(sysTx types.Transaction, bankTx types.BankStatement) bool {
+		return strings.TrimPrefix(sysTx.TrxID, "TRX") == strings.TrimPrefix(bankTx.UniqueID, "BS") &&
+			defaultMatcher.Match(sysTx, bankTx)
+	})
+
+	result := reconcile.Reconcile(system, bank, reconcile.WithMatcher(matcher))
+	fmt.Println("matched:", result.TransactionMatched)
+	// Output:
+	// matched: 2
+}
@@ -0,0 +1,326 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	pkgcsv "reconciliation/pkg/csv"
+	"reconciliation/pkg/types"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunFiles reads the system transactions and bank statements from the given CSV or xlsx files
+// within the date range and reconciles them
+// The transactions of all system files are combined, e.g. hourly exports, a TrxID in several files is processed once per file
+// Sources set with WithSystemSource and WithBankSource are read in addition to the files
+func RunFiles(systemPaths []string, bankPaths []string, start, end time.Time, opts ...Option) (ReconcileResult, error) {
+	// Create the reconciler with the given options
+	r := newReconciler(opts...)
+	readStart := time.Now()
+
+	// Read system transactions
+	systemTransactions, systemStats, err := r.readSystemFiles(systemPaths, start, end)
+	if err != nil {
+		return ReconcileResult{}, fmt.Errorf("failed to read system transactions: %w", err)
+	}
+	if r.systemSource != nil {
+		transactions, err := r.systemSource.ReadSystemTransactions(start, end)
+		if err != nil {
+			return ReconcileResult{}, fmt.Errorf("failed to read system transactions: %w", err)
+		}
+		systemTransactions = append(systemTransactions, transactions...)
+	}
+
+	// Read bank statements
+	bankStatements, skippedFiles, bankStats, err := r.readBankStatements(bankPaths, start, end)
+	if err != nil {
+		return ReconcileResult{}, fmt.Errorf("failed to read bank statements: %w", err)
+	}
+	if r.bankSource != nil {
+		statements, err := r.bankSource.ReadBankStatements(start, end)
+		if err != nil {
+			return ReconcileResult{}, fmt.Errorf("failed to read bank statements: %w", err)
+		}
+		bankStatements = append(bankStatements, statements...)
+	}
+
+	// Reconcile transactions
+	reconcileStart := time.Now()
+	result := r.reconcile(systemTransactions, bankStatements)
+	result.SkippedFiles = skippedFiles
+	result.Config.DateFormats = pkgcsv.EffectiveDateFormats(r.csvOptions...)
+
+	// Record the throughput, rows filtered out or excluded while reading count as read
+	rowsRead := len(systemTransactions) + len(bankStatements)
+	for _, stats := range systemStats {
+		rowsRead += stats.filtered + stats.zeroAmounts
+	}
+	for _, stats := range bankStats {
+		rowsRead += stats.filtered + stats.zeroAmounts
+	}
+	result.Performance = &Performance{
+		RowsRead:          rowsRead,
+		Matches:           result.TransactionMatched,
+		ReadDuration:      reconcileStart.Sub(readStart),
+		ReconcileDuration: time.Since(reconcileStart),
+	}
+
+	// Report the rows filtered out by the date range per file
+	for filename, stats := range systemStats {
+		bankStats[filename] = stats
+	}
+	for _, stats := range bankStats {
+		result.RowsOutsideRange += stats.filtered
+	}
+	if r.reportFiltered {
+		result.FilteredRows = make(map[string]int, len(bankStats))
+		for filename, stats := range bankStats {
+			result.FilteredRows[filename] = stats.filtered
+		}
+	}
+
+	// Report the rows excluded for a zero amount per file
+	for filename, stats := range bankStats {
+		if stats.zeroAmounts == 0 {
+			continue
+		}
+		if result.ZeroAmountRows == nil {
+			result.ZeroAmountRows = make(map[string]int)
+		}
+		result.ZeroAmountRows[filename] = stats.zeroAmounts
+	}
+
+	// Report the warnings about the rows read in order of the filenames
+	filenames := make([]string, 0, len(bankStats))
+	for filename := range bankStats {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		result.Warnings = append(result.Warnings, bankStats[filename].warnings...)
+	}
+
+	return result, nil
+}
+
+// fileStats are the counts of rows excluded while reading a file
+type fileStats struct {
+	// Number of rows filtered out by the date range
+	filtered int
+
+	// Number of rows excluded for a zero amount
+	zeroAmounts int
+
+	// Warnings about the rows read, e.g. future dates
+	warnings []string
+}
+
+// readSystemFiles reads and concatenates the system transactions of the given files in order
+// It also returns the counts of rows excluded while reading per file
+func (r *reconciler) readSystemFiles(systemFiles []string, start, end time.Time) ([]types.Transaction, map[string]fileStats, error) {
+	systemTransactions := []types.Transaction{}
+	stats := make(map[string]fileStats, len(systemFiles))
+	for _, systemFile := range systemFiles {
+		transactions, fileStats, err := r.readSystemTransactions(systemFile, start, end)
+		if err != nil {
+			return nil, nil, err
+		}
+		systemTransactions = append(systemTransactions, transactions...)
+		stats[systemFile] = fileStats
+	}
+	return systemTransactions, stats, nil
+}
+
+// readSystemTransactions reads the system transactions from the given file
+// It also returns the counts of rows excluded while reading
+func (r *reconciler) readSystemTransactions(systemFile string, start, end time.Time) ([]types.Transaction, fileStats, error) {
+	var systemTransactions []types.Transaction
+	var stats fileStats
+
+	// Open and read the system file
+	err := r.readWithRetries(systemFile, "system file", func(systemFileHandle fs.File) error {
+		content, size, release, err := r.fileContent(systemFileHandle)
+		if err != nil {
+			return fmt.Errorf("failed to stat system file: %w", err)
+		}
+		defer release()
+
+		// Create a reader for the system file
+		systemReader := r.newFileReader(content, size, systemFile, start, end)
+
+		// Read the system transactions
+		systemTransactions, err = systemReader.ReadSystemTransactionsFromCSV()
+		if err != nil {
+			return fmt.Errorf("failed to read system transactions: %w", err)
+		}
+
+		stats = fileStats{systemReader.Filtered(), systemReader.ZeroAmounts(), systemReader.Warnings()}
+		return nil
+	})
+	if err != nil {
+		return nil, fileStats{}, err
+	}
+
+	return systemTransactions, stats, nil
+}
+
+// readBankStatements reads the bank statements from the given files
+// If skipInvalidFiles is set, files that cannot be read are skipped and returned instead of failing
+// It also returns the counts of rows excluded while reading per file
+func (r *reconciler) readBankStatements(bankFiles []string, start, end time.Time) ([]types.BankStatement, []SkippedFile, map[string]fileStats, error) {
+	bankStatements := []types.BankStatement{}
+	var skippedFiles []SkippedFile
+
+	// Expand the archives into their members
+	sources := r.expandBankFiles(bankFiles)
+	stats := make(map[string]fileStats, len(sources))
+
+	// Create a channel to receive results, buffered so workers never block after an early return
+	resultCh := make(chan bankFileResult, len(sources))
+
+	// Queue the bank files for the workers
+	jobs := make(chan bankSource, len(sources))
+	for _, source := range sources {
+		jobs <- source
+	}
+	close(jobs)
+
+	// Bound the number of files read at once
+	workers := r.fileConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(sources) {
+		workers = len(sources)
+	}
+
+	// Create a wait group to wait for all workers to complete
+	var wg sync.WaitGroup
+
+	// Read the bank files with a bounded worker pool
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for source := range jobs {
+				resultCh <- r.readBankFile(source, start, end)
+			}
+		}()
+	}
+
+	// Close result channel once all goroutines complete
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	// Collect results
+	for res := range resultCh {
+		if res.err != nil {
+			if !r.skipInvalidFiles {
+				return nil, nil, nil, res.err
+			}
+
+			// Skip the invalid file
+			skippedFiles = append(skippedFiles, SkippedFile{Filename: res.filename, Reason: res.err.Error()})
+			continue
+		}
+		bankStatements = append(bankStatements, res.statements...)
+		stats[res.filename] = res.stats
+	}
+
+	// Sort skipped files for a stable report
+	sort.Slice(skippedFiles, func(i, j int) bool {
+		return skippedFiles[i].Filename < skippedFiles[j].Filename
+	})
+
+	return bankStatements, skippedFiles, stats, nil
+}
+
+// bankFileResult is the result of reading a single bank file
+type bankFileResult struct {
+	filename   string
+	statements []types.BankStatement
+	stats      fileStats
+	err        error
+}
+
+// readBankFile reads the bank statements from a single file or archive member
+func (r *reconciler) readBankFile(source bankSource, start, end time.Time) bankFileResult {
+	filename := source.filename
+	if source.err != nil {
+		return bankFileResult{filename, nil, fileStats{}, source.err}
+	}
+
+	// Read the archive member content
+	if source.data != nil {
+		return r.readBankContent(bytes.NewReader(source.data), int64(len(source.data)), filename, start, end)
+	}
+
+	// Open and read the bank file
+	var result bankFileResult
+	err := r.readWithRetries(filename, "bank file", func(bankFileHandle fs.File) error {
+		content, size, release, err := r.fileContent(bankFileHandle)
+		if err != nil {
+			return fmt.Errorf("failed to stat bank file: %w", err)
+		}
+		defer release()
+		result = r.readBankContent(content, size, filename, start, end)
+		return result.err
+	})
+	if err != nil {
+		return bankFileResult{filename, nil, fileStats{}, err}
+	}
+	return result
+}
+
+// fileContent returns the content of the opened file, mapped into memory when enabled
+// The returned function releases the content once it is read
+func (r *reconciler) fileContent(file fs.File) (io.ReaderAt, int64, func(), error) {
+	if r.mmap {
+		return mappedReaderAt(file)
+	}
+	content, size, err := readerAt(file)
+	return content, size, func() {}, err
+}
+
+// readBankContent reads the bank statements from the content of a bank file
+func (r *reconciler) readBankContent(content io.ReaderAt, size int64, filename string, start, end time.Time) bankFileResult {
+	// Create a reader for the bank file
+	bankReader := r.newFileReader(content, size, filename, start, end)
+
+	// Read the bank statements
+	statements, err := bankReader.ReadBankStatementsFromCSV()
+	if err != nil {
+		return bankFileResult{filename, nil, fileStats{}, fmt.Errorf("failed to read bank statements: %w", err)}
+	}
+
+	return bankFileResult{filename, statements, fileStats{bankReader.Filtered(), bankReader.ZeroAmounts(), bankReader.Warnings()}, nil}
+}
+
+// newFileReader creates a reader for the file content, selected by the filename extension
+// Files with the .xlsx extension are read as Excel workbooks, any other file is read as CSV
+func (r *reconciler) newFileReader(content io.ReaderAt, size int64, filename string, start, end time.Time) *pkgcsv.CSVReaderImpl {
+	opts := append([]pkgcsv.Option{
+		pkgcsv.WithSkipHeader(true),
+		pkgcsv.WithTimeRange(start, end),
+		pkgcsv.WithFilename(filename),
+		pkgcsv.WithRejectNegativeAmounts(!r.useSignedAmount),
+	}, r.csvOptions...)
+
+	if strings.EqualFold(filepath.Ext(filename), ".xlsx") {
+		return pkgcsv.NewXLSXReader(content, size, opts...)
+	}
+
+	reader := csv.NewReader(io.NewSectionReader(content, 0, size))
+	if r.delimiter != 0 {
+		reader.Comma = r.delimiter
+	}
+	return pkgcsv.NewCSVReader(reader, opts...)
+}
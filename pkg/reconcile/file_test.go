@@ -0,0 +1,826 @@
+package reconcile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	pkgcsv "reconciliation/pkg/csv"
+	"reconciliation/pkg/types"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadSystemTransactions tests the readSystemTransactions function
+func TestReadSystemTransactions(t *testing.T) {
+	// Create a temporary test file
+	tmpFile, err := os.CreateTemp("", "test-system-*.csv")
+	assert.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	// Write test data
+	testData := `TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00`
+	_, err = tmpFile.WriteString(testData)
+	assert.NoError(t, err)
+	tmpFile.Close()
+
+	// Create an empty file for testing
+	emptyFile, err := os.CreateTemp("", "empty-*.csv")
+	assert.NoError(t, err)
+	defer os.Remove(emptyFile.Name())
+
+	// Create invalid CSV file
+	invalidFile, err := os.CreateTemp("", "invalid-*.csv")
+	assert.NoError(t, err)
+	defer os.Remove(invalidFile.Name())
+	_, err = invalidFile.WriteString("invalid,csv,format\nwithout,proper,headers")
+	assert.NoError(t, err)
+	invalidFile.Close()
+
+	// Define test cases
+	tests := []struct {
+		name      string
+		file      string
+		startDate string
+		endDate   string
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "Valid date range",
+			file:      tmpFile.Name(),
+			startDate: "2024-01-01",
+			endDate:   "2024-01-03",
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name:      "Partial date range",
+			file:      tmpFile.Name(),
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name:      "Invalid date range",
+			file:      tmpFile.Name(),
+			startDate: "2024-01-03",
+			endDate:   "2024-01-01",
+			wantCount: 0,
+			wantErr:   false,
+		},
+		{
+			name:      "Non-existent file",
+			file:      "nonexistent.csv",
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "Empty file",
+			file:      emptyFile.Name(),
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   false,
+		},
+		{
+			name:      "Invalid CSV format",
+			file:      invalidFile.Name(),
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   true,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Parse the start and end dates
+			start, err := time.Parse("2006-01-02", tt.startDate)
+			assert.NoError(t, err)
+
+			// Parse the end date
+			end, err := time.Parse("2006-01-02", tt.endDate)
+			assert.NoError(t, err)
+
+			// Call the readSystemTransactions function
+			transactions, _, err := newReconciler().readSystemTransactions(tt.file, start, end)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			// Check if the result matches the expected result
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCount, len(transactions))
+		})
+	}
+}
+
+// TestReadBankStatements tests the readBankStatements function
+func TestReadBankStatements(t *testing.T) {
+	// Create temporary test files
+	tmpDir, err := os.MkdirTemp("", "test-bank-statements")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// Create and write to test files
+	testFiles := []string{"bank1.csv", "bank2.csv"}
+	testData := `UniqueID,Amount,Date
+BS001,-100.0,2024-01-01
+BS002,200.0,2024-01-02`
+
+	// Create and write to test files
+	for _, file := range testFiles {
+		f, err := os.Create(filepath.Join(tmpDir, file))
+		assert.NoError(t, err)
+		_, err = f.WriteString(testData)
+		assert.NoError(t, err)
+		f.Close()
+	}
+
+	// Create invalid CSV file
+	invalidFile := filepath.Join(tmpDir, "invalid.csv")
+	f, err := os.Create(invalidFile)
+	assert.NoError(t, err)
+	_, err = f.WriteString("invalid,csv\nformat,data")
+	assert.NoError(t, err)
+	f.Close()
+
+	// Define test cases
+	tests := []struct {
+		name        string
+		files       []string
+		startDate   string
+		endDate     string
+		skipInvalid bool
+		wantCount   int
+		wantSkipped int
+		wantErr     bool
+	}{
+		{
+			name:      "Multiple valid files",
+			files:     []string{filepath.Join(tmpDir, "bank1.csv"), filepath.Join(tmpDir, "bank2.csv")},
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 4, // 2 transactions per file
+			wantErr:   false,
+		},
+		{
+			name:      "Non-existent file",
+			files:     []string{filepath.Join(tmpDir, "nonexistent.csv")},
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "Empty file list",
+			files:     []string{},
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   false,
+		},
+		{
+			name:      "Invalid CSV format",
+			files:     []string{invalidFile},
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "Mix of valid and invalid files",
+			files:     []string{filepath.Join(tmpDir, "bank1.csv"), invalidFile},
+			startDate: "2024-01-01",
+			endDate:   "2024-01-02",
+			wantCount: 0,
+			wantErr:   true,
+		},
+		{
+			name:        "Mix of valid and invalid files with skip invalid",
+			files:       []string{filepath.Join(tmpDir, "bank1.csv"), invalidFile, filepath.Join(tmpDir, "nonexistent.csv")},
+			startDate:   "2024-01-01",
+			endDate:     "2024-01-02",
+			skipInvalid: true,
+			wantCount:   2,
+			wantSkipped: 2,
+			wantErr:     false,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Parse the start and end dates
+			start, err := time.Parse("2006-01-02", tt.startDate)
+			assert.NoError(t, err)
+
+			// Parse the end date
+			end, err := time.Parse("2006-01-02", tt.endDate)
+			assert.NoError(t, err)
+
+			// Call the readBankStatements function
+			statements, skipped, _, err := newReconciler(WithSkipInvalidFiles(tt.skipInvalid)).readBankStatements(tt.files, start, end)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			// Check if the result matches the expected result
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantCount, len(statements))
+			assert.Equal(t, tt.wantSkipped, len(skipped))
+		})
+	}
+}
+
+// TestReadBankStatements_FileConcurrency tests reading more bank files than workers
+func TestReadBankStatements_FileConcurrency(t *testing.T) {
+	tmpDir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	// Create more bank files than workers
+	var files []string
+	for i := 0; i < 10; i++ {
+		file := filepath.Join(tmpDir, fmt.Sprintf("bank%d.csv", i))
+		err := os.WriteFile(file, []byte(fmt.Sprintf("UniqueID,Amount,Date\nBS%03d,100.0,2024-01-01\n", i)), 0o644)
+		assert.NoError(t, err)
+		files = append(files, file)
+	}
+
+	// All files are read with two workers
+	statements, skipped, filtered, err := newReconciler(WithFileConcurrency(2)).readBankStatements(files, start, end)
+	assert.NoError(t, err)
+	assert.Len(t, statements, 10)
+	assert.Empty(t, skipped)
+	assert.Len(t, filtered, 10)
+
+	// A single worker still fails on an invalid file
+	invalidFile := filepath.Join(tmpDir, "invalid.csv")
+	assert.NoError(t, os.WriteFile(invalidFile, []byte("invalid,csv\nformat,data"), 0o644))
+	_, _, _, err = newReconciler(WithFileConcurrency(1)).readBankStatements(append(files, invalidFile), start, end)
+	assert.Error(t, err)
+}
+
+// TestReadBankStatements_TarGz tests reading the members of a gzipped tar bundle
+func TestReadBankStatements_TarGz(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Read the bundle, the bank name is derived from the member name and other members are ignored
+	statements, skipped, stats, err := newReconciler().readBankStatements([]string{"testdata/banks.tar.gz"}, start, end)
+	assert.NoError(t, err)
+	assert.Empty(t, skipped)
+	assert.Len(t, statements, 3)
+	bankNames := map[string]string{}
+	for _, stmt := range statements {
+		bankNames[stmt.UniqueID] = stmt.BankName
+	}
+	assert.Equal(t, map[string]string{"BS001": "BRI", "BS002": "BRI", "BS003": "BNI"}, bankNames)
+	assert.Len(t, stats, 2)
+	assert.Contains(t, stats, "statements/bni.csv")
+
+	// An invalid bundle fails, or is skipped when invalid files are skipped
+	invalidFile := filepath.Join(t.TempDir(), "invalid.tar.gz")
+	assert.NoError(t, os.WriteFile(invalidFile, []byte("not gzip"), 0o644))
+	_, _, _, err = newReconciler().readBankStatements([]string{invalidFile}, start, end)
+	assert.Error(t, err)
+	_, skipped, _, err = newReconciler(WithSkipInvalidFiles(true)).readBankStatements([]string{invalidFile, "testdata/banks.tar.gz"}, start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, invalidFile, skipped[0].Filename)
+}
+
+// TestRunFiles_DuplicateBankIDs tests reporting bank statement IDs colliding across files of the same bank
+func TestRunFiles_DuplicateBankIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Write a system file and two files of the same bank both containing BS001
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	assert.NoError(t, os.WriteFile(systemFile, []byte("TrxID,Amount,Type,TransactionTime\n"+
+		"TX001,100.0,CREDIT,2024-01-01 10:00:00\n"+
+		"TX002,200.0,CREDIT,2024-01-01 11:00:00\n"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "bri"), 0o755))
+	janFile := filepath.Join(tmpDir, "bri", "jan.csv")
+	assert.NoError(t, os.WriteFile(janFile, []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\n"), 0o644))
+	febFile := filepath.Join(tmpDir, "bri", "feb.csv")
+	assert.NoError(t, os.WriteFile(febFile, []byte("UniqueID,Amount,Date\nBS001,200.0,2024-01-01\nBS002,300.0,2024-01-01\n"), 0o644))
+
+	// Reconcile the files with the bank name taken from the directory
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := RunFiles([]string{systemFile}, []string{janFile, febFile}, start, start,
+		WithCSVOptions(pkgcsv.WithBankNameFromDir(true)))
+	assert.NoError(t, err)
+
+	// Check the colliding ID is reported
+	assert.Equal(t, []DuplicateBankID{{BankName: "BRI", UniqueID: "BS001", Occurrences: 2}}, result.DuplicateBankIDs)
+	assert.Contains(t, result.String(), "Duplicate bank statement IDs:\n- Bank: BRI, ID: BS001, Occurrences: 2\n")
+
+	// The same ID in different banks is not a duplicate
+	result, err = RunFiles([]string{systemFile}, []string{janFile, febFile}, start, start)
+	assert.NoError(t, err)
+	assert.Empty(t, result.DuplicateBankIDs)
+}
+
+// TestRunFiles tests the RunFiles function
+func TestRunFiles(t *testing.T) {
+	// Create temporary test files
+	tmpDir := t.TempDir()
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	err := os.WriteFile(systemFile, []byte(`TrxID,Amount,Type,TransactionTime
+TX001,100.0,DEBIT,2024-01-01 10:00:00
+TX002,200.0,CREDIT,2024-01-02 10:00:00
+TX003,300.0,CREDIT,2024-02-01 10:00:00`), 0o644)
+	assert.NoError(t, err)
+	bankFile := filepath.Join(tmpDir, "bri.csv")
+	err = os.WriteFile(bankFile, []byte(`UniqueID,Amount,Date
+BS001,-100.0,2024-01-01
+BS002,250.0,2024-01-02`), 0o644)
+	assert.NoError(t, err)
+	invalidFile := filepath.Join(tmpDir, "invalid.csv")
+	err = os.WriteFile(invalidFile, []byte("invalid,csv\nformat,data"), 0o644)
+	assert.NoError(t, err)
+
+	// Define the date range
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Run the reconciliation on the files
+	result, err := RunFiles([]string{systemFile}, []string{bankFile}, start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionProcessed)
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Equal(t, 2, result.TransactionUnmatched.TransactionUnmatched)
+	assert.Equal(t, "BRI", result.TransactionUnmatched.BankUnmatched[0].BankName)
+
+	assert.Equal(t, 1, result.RowsOutsideRange)
+	assert.False(t, result.AllRowsOutsideRange())
+
+	// A range missing the data filters out every row
+	result, err = RunFiles([]string{systemFile}, []string{bankFile}, start.AddDate(1, 0, 0), end.AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Equal(t, 5, result.RowsOutsideRange)
+	assert.True(t, result.AllRowsOutsideRange())
+
+	// Empty files are not reported as outside the range
+	emptyFile := filepath.Join(tmpDir, "empty.csv")
+	assert.NoError(t, os.WriteFile(emptyFile, []byte("TrxID,Amount,Type,TransactionTime\n"), 0o644))
+	result, err = RunFiles([]string{emptyFile}, nil, start, end)
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.False(t, result.AllRowsOutsideRange())
+
+	// Invalid bank files fail by default
+	_, err = RunFiles([]string{systemFile}, []string{bankFile, invalidFile}, start, end)
+	assert.Error(t, err)
+
+	// Invalid bank files are reported when skipped
+	result, err = RunFiles([]string{systemFile}, []string{bankFile, invalidFile}, start, end, WithSkipInvalidFiles(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Len(t, result.SkippedFiles, 1)
+	assert.Equal(t, invalidFile, result.SkippedFiles[0].Filename)
+
+	// Filtered rows are reported per file
+	result, err = RunFiles([]string{systemFile}, []string{bankFile}, start, end, WithReportFiltered(true))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{systemFile: 1, bankFile: 0}, result.FilteredRows)
+	assert.Contains(t, result.String(), fmt.Sprintf("Rows outside date range skipped:\n- %s: 0\n- %s: 1\n", bankFile, systemFile))
+
+	// Zero amount rows are only reported when rejected
+	assert.Nil(t, result.ZeroAmountRows)
+	zeroFile := filepath.Join(tmpDir, "zero.csv")
+	assert.NoError(t, os.WriteFile(zeroFile, []byte("UniqueID,Amount,Date\nBS009,0.00,2024-01-01\n"), 0o644))
+	result, err = RunFiles([]string{systemFile}, []string{bankFile, zeroFile}, start, end,
+		WithCSVOptions(pkgcsv.WithRejectZeroAmounts(true)))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{zeroFile: 1}, result.ZeroAmountRows)
+
+	// xlsx files are selected by extension
+	result, err = RunFiles([]string{"../csv/testdata/system.xlsx"}, []string{"../csv/testdata/bank.xlsx"},
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, 0, result.TransactionUnmatched.TransactionUnmatched)
+
+	// Missing system file fails
+	_, err = RunFiles([]string{filepath.Join(tmpDir, "missing.csv")}, []string{bankFile}, start, end)
+	assert.Error(t, err)
+}
+
+// TestValidateFiles tests that every input file that cannot be read is reported
+func TestValidateFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Write a valid system and bank file and an invalid bank file
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	assert.NoError(t, os.WriteFile(systemFile, []byte("TrxID,Amount,Type,TransactionTime\nTX001,100.0,CREDIT,2024-01-01 10:00:00\n"), 0o644))
+	briFile := filepath.Join(tmpDir, "bri.csv")
+	assert.NoError(t, os.WriteFile(briFile, []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\nBS002,50.0,2024-01-01\n"), 0o644))
+	bniFile := filepath.Join(tmpDir, "bni.csv")
+	assert.NoError(t, os.WriteFile(bniFile, []byte("UniqueID,Amount,Date\nBS001,abc,2024-01-01\n"), 0o644))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Validate the valid files
+	result := ValidateFiles([]string{systemFile}, []string{briFile}, start, start)
+	assert.True(t, result.Valid())
+	assert.Equal(t, 1, result.SystemTransactions)
+	assert.Equal(t, 2, result.BankStatements)
+
+	// Validate with a missing system file and an invalid bank file
+	missingFile := filepath.Join(tmpDir, "missing.csv")
+	result = ValidateFiles([]string{missingFile}, []string{briFile, bniFile}, start, start)
+	assert.False(t, result.Valid())
+	assert.Equal(t, 2, result.BankStatements)
+	assert.Len(t, result.InvalidFiles, 2)
+	assert.Equal(t, missingFile, result.InvalidFiles[0].Filename)
+	assert.Equal(t, bniFile, result.InvalidFiles[1].Filename)
+
+	// Check the summary lists the invalid files
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteSummary(&buf))
+	assert.Contains(t, buf.String(), "Invalid files: 2\n- File: "+missingFile)
+}
+
+// TestReadWithRetries tests retrying transient errors when opening input files
+func TestReadWithRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	assert.NoError(t, os.WriteFile(systemFile, []byte("TrxID,Amount,Type,TransactionTime\nTX001,100.0,CREDIT,2024-01-01 10:00:00\n"), 0o644))
+	bankFile := filepath.Join(tmpDir, "bri.csv")
+	assert.NoError(t, os.WriteFile(bankFile, []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\n"), 0o644))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// flakyOpener fails the given number of times per file with the error before opening the file
+	flakyOpener := func(failures int, failErr error) (openFunc, map[string]int) {
+		opens := map[string]int{}
+		var mu sync.Mutex
+		return func(name string) (fs.File, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			opens[name]++
+			if opens[name] <= failures {
+				return nil, &os.PathError{Op: "open", Path: name, Err: failErr}
+			}
+			return os.Open(name)
+		}, opens
+	}
+
+	// Transient errors are retried until the file opens
+	opener, opens := flakyOpener(2, syscall.EIO)
+	r := newReconciler(WithReadRetries(3), WithFS(opener))
+	r.retryBackoff = time.Millisecond
+	transactions, _, err := r.readSystemTransactions(systemFile, start, start)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	statements, _, _, err := r.readBankStatements([]string{bankFile}, start, start)
+	assert.NoError(t, err)
+	assert.Len(t, statements, 1)
+	assert.Equal(t, map[string]int{systemFile: 3, bankFile: 3}, opens)
+
+	// The error is returned once the retries are used up
+	opener, opens = flakyOpener(2, syscall.EIO)
+	r = newReconciler(WithReadRetries(1), WithFS(opener))
+	r.retryBackoff = time.Millisecond
+	_, _, err = r.readSystemTransactions(systemFile, start, start)
+	assert.ErrorIs(t, err, syscall.EIO)
+	assert.ErrorContains(t, err, "failed to open system file")
+	assert.Equal(t, 2, opens[systemFile])
+
+	// Missing files are not retried
+	opener, opens = flakyOpener(0, nil)
+	r = newReconciler(WithReadRetries(3), WithFS(opener))
+	r.retryBackoff = time.Millisecond
+	missingFile := filepath.Join(tmpDir, "missing.csv")
+	_, _, _, err = r.readBankStatements([]string{missingFile}, start, start)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, 1, opens[missingFile])
+
+	// Without retries a transient error fails at once
+	opener, opens = flakyOpener(1, syscall.EIO)
+	r = newReconciler(WithFS(opener))
+	_, _, err = r.readSystemTransactions(systemFile, start, start)
+	assert.ErrorIs(t, err, syscall.EIO)
+	assert.Equal(t, 1, opens[systemFile])
+}
+
+// openFunc is a file system opening files with the function
+type openFunc func(name string) (fs.File, error)
+
+// Open opens the named file
+func (f openFunc) Open(name string) (fs.File, error) {
+	return f(name)
+}
+
+// TestRunFiles_FS tests reconciling files read from an in-memory file system
+func TestRunFiles_FS(t *testing.T) {
+	archive, err := os.ReadFile("testdata/banks.tar.gz")
+	assert.NoError(t, err)
+	xlsx, err := os.ReadFile("../csv/testdata/bank.xlsx")
+	assert.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"system.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX001,100.0,CREDIT,2024-01-01 10:00:00\n" +
+			"TX002,50.0,DEBIT,2024-01-02 10:00:00\n")},
+		"banks/mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\nBS002,-50.0,2024-01-02\n")},
+		"banks/bundle.tgz":  {Data: archive},
+		"banks/bca.xlsx":    {Data: xlsx},
+	}
+
+	// Reconcile the files of the file system
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	result, err := RunFiles([]string{"system.csv"}, []string{"banks/mandiri.csv", "banks/bundle.tgz", "banks/bca.xlsx"}, start, end,
+		WithFS(fsys))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionProcessed)
+	assert.Equal(t, 2, result.TransactionMatched)
+
+	// Statements of the archive members and the xlsx file are read from the file system too
+	banks := map[string]bool{}
+	for _, stmt := range result.TransactionUnmatched.BankUnmatched {
+		banks[stmt.BankName] = true
+	}
+	assert.Equal(t, map[string]bool{"BRI": true, "BNI": true, "BCA": true}, banks)
+
+	// Files missing from the file system fail
+	_, err = RunFiles([]string{"missing.csv"}, []string{"banks/mandiri.csv"}, start, end, WithFS(fsys))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// TestReadTarGz_MemberLimit tests failing an archive with a member larger than the limit
+func TestReadTarGz_MemberLimit(t *testing.T) {
+	// Build a bundle with a member of 64 bytes
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	member := bytes.Repeat([]byte("A"), 64)
+	assert.NoError(t, archive.WriteHeader(&tar.Header{Name: "bri.csv", Mode: 0o644, Size: int64(len(member)), Typeflag: tar.TypeReg}))
+	_, err := archive.Write(member)
+	assert.NoError(t, err)
+	assert.NoError(t, archive.Close())
+	assert.NoError(t, gz.Close())
+	r := newReconciler(WithFS(fstest.MapFS{"bundle.tgz": {Data: buf.Bytes()}}))
+
+	// A member up to the limit is read
+	defer func(limit int64) { maxArchiveMemberSize = limit }(maxArchiveMemberSize)
+	maxArchiveMemberSize = 64
+	members, err := r.readTarGz("bundle.tgz")
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+
+	// A larger member fails the archive
+	maxArchiveMemberSize = 63
+	_, err = r.readTarGz("bundle.tgz")
+	assert.EqualError(t, err, "failed to read bri.csv from bank archive bundle.tgz: member exceeds 63 bytes")
+}
+
+// TestRunFiles_UseSignedAmount tests reading negative system amounts with signed amounts
+func TestRunFiles_UseSignedAmount(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX001,100.0,,2024-01-01 10:00:00\n" +
+			"TX002,-50.0,,2024-01-02 10:00:00\n")},
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\nBS002,-50.0,2024-01-02\n")},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Negative system amounts are rejected by default
+	_, err := RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys))
+	assert.ErrorContains(t, err, "negative amount [-50.0]")
+
+	// Negative system amounts are read and matched with signed amounts
+	result, err := RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys), WithUseSignedAmount(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionProcessed)
+	assert.Equal(t, 2, result.TransactionMatched)
+}
+
+// TestRunFiles_RejectNegativeAmounts tests matching negative system amounts by type when they are not rejected
+func TestRunFiles_RejectNegativeAmounts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX001,100.0,CREDIT,2024-01-01 10:00:00\n" +
+			"TX002,-50.0,DEBIT,2024-01-02 10:00:00\n")},
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\nBS002,-50.0,2024-01-02\n")},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// The negative DEBIT amount is matched by its absolute value
+	result, err := RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end,
+		WithFS(fsys), WithCSVOptions(pkgcsv.WithRejectNegativeAmounts(false)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, 0.0, result.TotalDifference)
+}
+
+// TestRunFiles_BankAmountScale tests matching a bank file with amounts in cents to a system file in major units
+func TestRunFiles_BankAmountScale(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX001,123.45,CREDIT,2024-01-01 10:00:00\n" +
+			"TX002,50.00,DEBIT,2024-01-02 10:00:00\n")},
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,12345,2024-01-01\nBS002,-5000,2024-01-02\n")},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// The amounts in cents do not match without a scale
+	result, err := RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.TransactionMatched)
+
+	// The scaled amounts match and are stored in major units
+	result, err = RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end,
+		WithFS(fsys), WithCSVOptions(pkgcsv.WithBankAmountScale(0.01)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, 73.45, result.BankTotal)
+}
+
+// TestRunFiles_MultipleSystemFiles tests combining the transactions of several system files
+func TestRunFiles_MultipleSystemFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system/10.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX001,100.0,CREDIT,2024-01-01 10:00:00\n" +
+			"TX002,50.0,DEBIT,2024-01-01 10:30:00\n" +
+			"TX000,10.0,DEBIT,2023-12-31 10:00:00\n")},
+		"system/11.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX002,50.0,DEBIT,2024-01-01 10:30:00\n" +
+			"TX003,75.0,CREDIT,2024-01-01 11:00:00\n")},
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\n" +
+			"BS001,100.0,2024-01-01\nBS002,-50.0,2024-01-01\nBS003,75.0,2024-01-01\n")},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Reconcile the transactions of both files
+	result, err := RunFiles([]string{"system/10.csv", "system/11.csv"}, []string{"mandiri.csv"}, start, end,
+		WithFS(fsys), WithReportFiltered(true), WithIDMatching(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.TransactionProcessed)
+
+	// The TrxID exported in both files is processed twice, only one of them matches the single bank statement
+	assert.Equal(t, 3, result.TransactionMatched)
+	assert.Len(t, result.TransactionUnmatched.SystemUnmatched, 1)
+	assert.Equal(t, "TX002", result.TransactionUnmatched.SystemUnmatched[0].TrxID)
+	assert.Empty(t, result.TransactionUnmatched.BankUnmatched)
+
+	// The filtered rows are reported per system file
+	assert.Equal(t, map[string]int{"system/10.csv": 1, "system/11.csv": 0, "mandiri.csv": 0}, result.FilteredRows)
+
+	// A system file that cannot be read fails the run
+	_, err = RunFiles([]string{"system/10.csv", "system/missing.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys))
+	assert.ErrorContains(t, err, "failed to read system transactions")
+
+	// Every system file that cannot be read is reported by the validation
+	validation := ValidateFiles([]string{"system/10.csv", "system/missing.csv", "mandiri.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys))
+	assert.Equal(t, 2, validation.SystemTransactions)
+	assert.Len(t, validation.InvalidFiles, 2)
+}
+
+// staticSource is a system and bank source returning fixed rows
+type staticSource struct {
+	transactions []types.Transaction
+	statements   []types.BankStatement
+	err          error
+}
+
+func (s staticSource) ReadSystemTransactions(start, end time.Time) ([]types.Transaction, error) {
+	return s.transactions, s.err
+}
+
+func (s staticSource) ReadBankStatements(start, end time.Time) ([]types.BankStatement, error) {
+	return s.statements, s.err
+}
+
+// TestRunFiles_Sources tests reading system transactions and bank statements from sources with the files
+func TestRunFiles_Sources(t *testing.T) {
+	fsys := fstest.MapFS{
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\n")},
+	}
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := staticSource{
+		transactions: []types.Transaction{
+			{TrxID: "TX001", Amount: 100.0, Type: types.TransactionTypeCredit, TransactionTime: date},
+			{TrxID: "TX002", Amount: 50.0, Type: types.TransactionTypeDebit, TransactionTime: date},
+		},
+		statements: []types.BankStatement{{BankName: "BCA", UniqueID: "BS002", Amount: -50.0, Date: date}},
+	}
+
+	// Reconcile the source transactions against the bank file and the source statements
+	result, err := RunFiles(nil, []string{"mandiri.csv"}, date, date, WithFS(fsys), WithSystemSource(source), WithBankSource(source))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionProcessed)
+	assert.Equal(t, 2, result.TransactionMatched)
+
+	// Source errors fail the run
+	_, err = RunFiles(nil, []string{"mandiri.csv"}, date, date, WithFS(fsys), WithSystemSource(staticSource{err: fmt.Errorf("connection refused")}))
+	assert.EqualError(t, err, "failed to read system transactions: connection refused")
+}
+
+// TestRunFiles_Mmap tests reading memory-mapped files gives the same result as reading them
+func TestRunFiles_Mmap(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	bankFile := filepath.Join(tmpDir, "bca.csv")
+	emptyFile := filepath.Join(tmpDir, "bri.csv")
+	assert.NoError(t, os.WriteFile(systemFile, []byte("TrxID,Amount,Type,TransactionTime\n"+
+		"TX001,100.00,CREDIT,2024-01-01 10:00:00\nTX002,50.00,DEBIT,2024-01-02 10:00:00\n"), 0o644))
+	assert.NoError(t, os.WriteFile(bankFile, []byte("UniqueID,Amount,Date\nBS001,100.00,2024-01-01\nBS002,-50.00,2024-01-03\n"), 0o644))
+	assert.NoError(t, os.WriteFile(emptyFile, nil, 0o644))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// The mapped files and the empty file falling back to reading give the same result
+	expected, err := RunFiles([]string{systemFile}, []string{bankFile, emptyFile}, start, end)
+	assert.NoError(t, err)
+	result, err := RunFiles([]string{systemFile}, []string{bankFile, emptyFile}, start, end, WithMmap(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Equal(t, expected.TransactionUnmatched, result.TransactionUnmatched)
+
+	// Files of other file systems are read as usual
+	fsys := fstest.MapFS{
+		"system.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\nTX001,100.00,CREDIT,2024-01-01 10:00:00\n")},
+		"bca.csv":    {Data: []byte("UniqueID,Amount,Date\nBS001,100.00,2024-01-01\n")},
+	}
+	result, err = RunFiles([]string{"system.csv"}, []string{"bca.csv"}, start, end, WithFS(fsys), WithMmap(true))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TransactionMatched)
+}
+
+// TestRunFiles_FlagFutureDates tests reporting future-dated rows as warnings in the result and the summary
+func TestRunFiles_FlagFutureDates(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	bankFile := filepath.Join(tmpDir, "bca.csv")
+	assert.NoError(t, os.WriteFile(systemFile, []byte("TrxID,Amount,Type,TransactionTime\n"+
+		"TX001,100.00,CREDIT,2024-01-01 10:00:00\nTX002,50.00,CREDIT,2099-01-02 10:00:00\n"), 0o644))
+	assert.NoError(t, os.WriteFile(bankFile, []byte("UniqueID,Amount,Date\nBS001,100.00,2024-01-01\n"), 0o644))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// The future-dated row is still reconciled and listed under warnings
+	result, err := RunFiles([]string{systemFile}, []string{bankFile}, start, end, WithCSVOptions(pkgcsv.WithFlagFutureDates(true)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.TransactionProcessed)
+	warning := "future date [2099-01-02 10:00:00] in row 3 of file " + systemFile
+	assert.Equal(t, []string{warning}, result.Warnings)
+	assert.Contains(t, result.String(), "\nWarnings:\n- "+warning+"\n")
+
+	// Without the option the row is read silently
+	result, err = RunFiles([]string{systemFile}, []string{bankFile}, start, end)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Warnings)
+}
+
+// BenchmarkRunFiles_Mmap benchmarks reading a large bank file with and without memory mapping
+func BenchmarkRunFiles_Mmap(b *testing.B) {
+	tmpDir := b.TempDir()
+	systemFile := filepath.Join(tmpDir, "system.csv")
+	bankFile := filepath.Join(tmpDir, "bca.csv")
+	var bank bytes.Buffer
+	bank.WriteString("UniqueID,Amount,Date\n")
+	for i := 0; i < 200000; i++ {
+		fmt.Fprintf(&bank, "BS%06d,%d.00,2024-01-%02d\n", i, i%1000+1, i%28+1)
+	}
+	if err := os.WriteFile(bankFile, bank.Bytes(), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(systemFile, []byte("TrxID,Amount,Type,TransactionTime\n"), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, mmap := range []bool{false, true} {
+		b.Run(fmt.Sprintf("mmap=%t", mmap), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := RunFiles([]string{systemFile}, []string{bankFile}, start, end, WithMmap(mmap), WithSuggestionWindow(0)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package reconcile
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// OSFS is a file system reading the files of the operating system with paths as given, relative or absolute
+// Unlike os.DirFS it is not rooted at a directory, so paths from the command line can be used as is
+type OSFS struct{}
+
+// Open opens the named file
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+// Stat returns the file info of the named file
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// ReadDir reads the named directory, sorted by filename
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// mappedReaderAt returns the content of an operating system file mapped into memory, reducing the read syscalls
+// of large files, the returned function releases the mapping once the content is read
+// Other files, empty files and platforms or files that cannot be mapped fall back to readerAt
+func mappedReaderAt(file fs.File) (io.ReaderAt, int64, func(), error) {
+	if osFile, ok := file.(*os.File); ok {
+		info, err := osFile.Stat()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if info.Mode().IsRegular() && info.Size() > 0 {
+			if data, unmap, err := mmapFile(osFile, info.Size()); err == nil {
+				return bytes.NewReader(data), info.Size(), func() { _ = unmap() }, nil
+			}
+		}
+	}
+
+	content, size, err := readerAt(file)
+	return content, size, func() {}, err
+}
+
+// readerAt returns the file as an io.ReaderAt, files without random access are read into memory
+func readerAt(file fs.File) (io.ReaderAt, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if ra, ok := file.(io.ReaderAt); ok {
+		return ra, info.Size(), nil
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
@@ -0,0 +1,77 @@
+package reconcile
+
+import (
+	"strconv"
+)
+
+// defaultHistogramEdges are the upper bucket edges of the discrepancy histogram by default
+var defaultHistogramEdges = []float64{0, 0.01, 0.1}
+
+// HistogramBucket is the number of matched pairs whose absolute amount discrepancy falls in a range
+type HistogramBucket struct {
+	// Label is the range of the bucket, e.g. "0", "0-0.01" or ">0.1"
+	Label string `json:"label"`
+
+	// Count is the number of matched pairs in the bucket
+	Count int `json:"count"`
+}
+
+// discrepancyHistogram counts the discrepancies of matched pairs in buckets bounded by the edges in integer units
+// A discrepancy falls in the first bucket whose edge it does not exceed, the last bucket holds the ones above every edge
+type discrepancyHistogram struct {
+	edges   []int64
+	buckets []HistogramBucket
+}
+
+// newHistogram creates an empty histogram with the configured bucket edges
+func (r *reconciler) newHistogram() *discrepancyHistogram {
+	h := &discrepancyHistogram{
+		edges:   make([]int64, len(r.histogramEdges)),
+		buckets: make([]HistogramBucket, len(r.histogramEdges)+1),
+	}
+	lower := ""
+	for i, edge := range r.histogramEdges {
+		h.edges[i] = r.toUnits(edge)
+		upper := strconv.FormatFloat(edge, 'f', -1, 64)
+		if lower == "" {
+			h.buckets[i].Label = upper
+		} else {
+			h.buckets[i].Label = lower + "-" + upper
+		}
+		lower = upper
+	}
+	h.buckets[len(h.edges)].Label = ">" + lower
+	return h
+}
+
+// add counts a discrepancy given in integer units
+func (h *discrepancyHistogram) add(units int64) {
+	for i, edge := range h.edges {
+		if units <= edge {
+			h.buckets[i].Count++
+			return
+		}
+	}
+	h.buckets[len(h.edges)].Count++
+}
+
+// mergeHistograms sums the counts of buckets with the same label, buckets only in next are appended
+func mergeHistograms(previous, next []HistogramBucket) []HistogramBucket {
+	if len(previous) == 0 && len(next) == 0 {
+		return nil
+	}
+	merged := append([]HistogramBucket(nil), previous...)
+	index := make(map[string]int, len(merged))
+	for i, bucket := range merged {
+		index[bucket.Label] = i
+	}
+	for _, bucket := range next {
+		if i, ok := index[bucket.Label]; ok {
+			merged[i].Count += bucket.Count
+			continue
+		}
+		index[bucket.Label] = len(merged)
+		merged = append(merged, bucket)
+	}
+	return merged
+}
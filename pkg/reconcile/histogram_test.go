@@ -0,0 +1,61 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcile_DiscrepancyHistogram tests assigning the discrepancies of matched pairs to the histogram buckets
+func TestReconcile_DiscrepancyHistogram(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Discrepancies of 0, 0.01, 0.05 and 0.5 with a 1% tolerance, TRX5 is unmatched
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX4", Amount: 400.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX5", Amount: 500.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{UniqueID: "BANK2", Amount: 200.01, Date: date},
+		{UniqueID: "BANK3", Amount: 299.95, Date: date},
+		{UniqueID: "BANK4", Amount: 400.50, Date: date},
+	}
+
+	// The default buckets include the edge in the lower bucket
+	result := Reconcile(systemTxs, bankTxs, WithPercentageTolerance(0.01))
+	assert.Equal(t, 4, result.TransactionMatched)
+	assert.Equal(t, []HistogramBucket{
+		{Label: "0", Count: 1},
+		{Label: "0-0.01", Count: 1},
+		{Label: "0.01-0.1", Count: 1},
+		{Label: ">0.1", Count: 1},
+	}, result.DiscrepancyHistogram)
+
+	// Custom edges are sorted
+	result = Reconcile(systemTxs, bankTxs, WithPercentageTolerance(0.01), WithHistogramEdges(1, 0.05))
+	assert.Equal(t, []HistogramBucket{
+		{Label: "0.05", Count: 3},
+		{Label: "0.05-1", Count: 1},
+		{Label: ">1", Count: 0},
+	}, result.DiscrepancyHistogram)
+
+	// The histogram is part of the summary
+	var summary strings.Builder
+	assert.NoError(t, result.WriteSummary(&summary))
+	assert.Contains(t, summary.String(), "Discrepancy histogram:\n- 0.05: 3\n- 0.05-1: 1\n- >1: 0\n")
+}
+
+// TestMergeHistograms tests summing the buckets of two results
+func TestMergeHistograms(t *testing.T) {
+	previous := []HistogramBucket{{Label: "0", Count: 1}, {Label: ">0", Count: 2}}
+	next := []HistogramBucket{{Label: "0", Count: 3}, {Label: "0-0.01", Count: 4}}
+	assert.Equal(t, []HistogramBucket{{Label: "0", Count: 4}, {Label: ">0", Count: 2}, {Label: "0-0.01", Count: 4}}, mergeHistograms(previous, next))
+	assert.Nil(t, mergeHistograms(nil, nil))
+}
@@ -0,0 +1,233 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// KeyStyle is the naming style of the keys in the JSON output
+type KeyStyle int
+
+const (
+	// Enum for JSON key style
+	KeyStyleSnake KeyStyle = iota
+	KeyStyleCamel
+)
+
+// JSONOption is a functional option for the JSON output
+type JSONOption func(*jsonOptions)
+
+// jsonOptions holds the configuration of the JSON output
+type jsonOptions struct {
+	// Naming style of the keys, snake_case by default
+	keyStyle KeyStyle
+
+	// Write only the summary object without the unmatched details
+	summaryOnly bool
+
+	// Layout of the dates and transaction times, empty for RFC 3339
+	dateFormat string
+}
+
+// WithKeyStyle sets the naming style of the JSON keys, e.g. KeyStyleCamel for systemTransactions
+// Only field names are renamed, keys holding data like bank names, filenames or TrxIDs are kept as is
+func WithKeyStyle(style KeyStyle) JSONOption {
+	return func(o *jsonOptions) {
+		o.keyStyle = style
+	}
+}
+
+// WithSummaryOnly writes only the summary object, omitting the unmatched details and every other section
+// listing transactions, keeping the output small and free of personal data
+func WithSummaryOnly(summaryOnly bool) JSONOption {
+	return func(o *jsonOptions) {
+		o.summaryOnly = summaryOnly
+	}
+}
+
+// WithJSONDateFormat writes the dates and transaction times in the Go layout, e.g. 02/01/2006, instead of RFC 3339
+// Results written with a custom layout cannot be loaded back, e.g. with LoadJSON, AppendJSON or as a carryforward
+func WithJSONDateFormat(layout string) JSONOption {
+	return func(o *jsonOptions) {
+		o.dateFormat = layout
+	}
+}
+
+// newJSONOptions creates the JSON output configuration with the given options
+func newJSONOptions(opts ...JSONOption) jsonOptions {
+	o := jsonOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// styled returns the value to encode with the configured key style and date layout
+// The snake style is the style of the struct tags, so without a date layout the value is encoded as is
+func (o jsonOptions) styled(v any) any {
+	if o.keyStyle == KeyStyleSnake && o.dateFormat == "" {
+		return v
+	}
+	return o.styleValue(reflect.ValueOf(v))
+}
+
+// jsonMarshalerType is the type of values that encode themselves, like time.Time
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// timeType is the type of the dates formatted with the date layout
+var timeType = reflect.TypeOf(time.Time{})
+
+// styleValue converts the value to a generic JSON value with the struct field names renamed in the key style
+// and the dates formatted in the date layout
+func (o jsonOptions) styleValue(v reflect.Value) any {
+	// Unwrap pointers and interfaces, nil encodes as null
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	// Dates are formatted in the date layout when set
+	if v.Type() == timeType && o.dateFormat != "" {
+		return v.Interface().(time.Time).Format(o.dateFormat)
+	}
+
+	// Values that encode themselves are kept as is
+	if v.Type().Implements(jsonMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return o.styleStruct(v)
+	case reflect.Map:
+		// Map keys are data, only the values are styled
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = o.styleValue(iter.Value())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = o.styleValue(v.Index(i))
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+// styleStruct converts the exported struct fields to an ordered JSON object, honoring the json tags
+func (o jsonOptions) styleStruct(v reflect.Value) jsonObject {
+	object := jsonObject{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		// Resolve the key from the tag, untagged fields use the field name
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(options, "omitempty") && isEmptyJSON(v.Field(i)) {
+			continue
+		}
+
+		object = append(object, jsonField{key: styleKey(name, o.keyStyle), value: o.styleValue(v.Field(i))})
+	}
+	return object
+}
+
+// isEmptyJSON checks if the value is omitted by omitempty, following the rules of encoding/json
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return v.IsZero()
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// styleKey renames a snake_case tag or Go field name in the given style
+func styleKey(name string, style KeyStyle) string {
+	if style != KeyStyleCamel {
+		return name
+	}
+
+	// Join the words of a snake_case name, capitalizing all but the first
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	name = strings.Join(parts, "")
+
+	// Lowercase the first letter of Go field names like TrxID
+	if name != "" {
+		runes := []rune(name)
+		runes[0] = unicode.ToLower(runes[0])
+		name = string(runes)
+	}
+	return name
+}
+
+// jsonObject is a JSON object that keeps the order of its fields
+type jsonObject []jsonField
+
+// jsonField is a key and value of a JSON object
+type jsonField struct {
+	key   string
+	value any
+}
+
+// MarshalJSON encodes the fields in order
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
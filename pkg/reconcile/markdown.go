@@ -0,0 +1,138 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GenerateMarkdown writes the reconciliation result as a Markdown report, e.g. for a pull request comment
+// The summary is a table, the unmatched items are tables in collapsible sections per bank
+func (r *ReconcileResult) GenerateMarkdown(w io.Writer, opts ...ReportOption) error {
+	o := newReportOptions(opts...)
+
+	// Wrap the writer to keep the first write error
+	result := &summaryWriter{w: w}
+
+	// Write the summary table
+	result.printf("## Reconciliation Summary\n\n")
+	result.printf("| Metric | Value |\n| --- | ---: |\n")
+	result.printf("| Total transactions processed | %d |\n", r.TransactionProcessed)
+	result.printf("| Total matched transactions | %d |\n", r.TransactionMatched)
+	if r.MatchedByID > 0 || r.MatchedByCarryforward > 0 {
+		result.printf("| Matched by ID | %d |\n", r.MatchedByID)
+		result.printf("| Matched by amount and date | %d |\n", r.MatchedByHeuristic)
+	}
+	if r.MatchedByCarryforward > 0 {
+		result.printf("| Matched from carryforward | %d |\n", r.MatchedByCarryforward)
+	}
+	if r.ExactMatches > 0 || r.ToleranceMatches > 0 {
+		result.printf("| Exact matches | %d |\n", r.ExactMatches)
+		result.printf("| Matched within tolerance | %d |\n", r.ToleranceMatches)
+	}
+	result.printf("| Total unmatched transactions | %d |\n", r.TransactionUnmatched.TransactionUnmatched)
+	result.printf("| System transactions missing from bank statements | %d |\n", r.TransactionUnmatched.SystemUnmatchedCount)
+	result.printf("| Bank statements missing from system transactions | %d |\n", r.TransactionUnmatched.BankUnmatchedCount)
+	result.printf("| Total amount discrepancies | %.2f |\n", r.TotalDiscrepancies)
+	result.printf("| Net amount discrepancies | %.2f |\n", r.NetDiscrepancy)
+	for _, bucket := range r.DiscrepancyHistogram {
+		result.printf("| Discrepancy %s | %d |\n", markdownCell(bucket.Label), bucket.Count)
+	}
+	result.printf("| System control total | %.2f |\n", r.SystemTotal)
+	result.printf("| Bank control total | %.2f |\n", r.BankTotal)
+	result.printf("| Control total difference | %.2f |\n", r.TotalDifference)
+
+	// Write the system transactions missing from bank statements
+	if len(r.TransactionUnmatched.SystemUnmatched) > 0 {
+		result.printf("\n<details>\n<summary>System transactions missing from bank statements (%d)</summary>\n\n",
+			len(r.TransactionUnmatched.SystemUnmatched))
+		result.printf("| TrxID | Amount | Type | Date |%s\n| --- | ---: | --- | --- |%s\n", countHeader(o.dedupe), countDivider(o.dedupe))
+		transactions, counts := unmatchedItems(r.TransactionUnmatched.SystemUnmatched, o.dedupe)
+		for k, tx := range transactions {
+			result.printf("| %s | %.2f | %s | %s |%s\n",
+				markdownCell(tx.TrxID),
+				tx.Amount,
+				tx.Type,
+				o.formatDate(tx.TransactionTime),
+				countCell(counts, k))
+		}
+		result.printf("\n</details>\n")
+	}
+
+	// Write the bank statements missing from system transactions, one section per bank in name order
+	bankGroups := r.groupBankUnmatched()
+	bankNames := make([]string, 0, len(bankGroups))
+	for bankName := range bankGroups {
+		bankNames = append(bankNames, bankName)
+	}
+	sort.Strings(bankNames)
+	for _, bankName := range bankNames {
+		statements := bankGroups[bankName]
+		result.printf("\n<details>\n<summary>Bank %s: statements missing from system transactions (%d)</summary>\n\n",
+			markdownCell(bankName), len(statements))
+		result.printf("| ID | Amount | Date |%s\n| --- | ---: | --- |%s\n", countHeader(o.dedupe), countDivider(o.dedupe))
+		statements, counts := unmatchedItems(statements, o.dedupe)
+		for k, stmt := range statements {
+			result.printf("| %s | %.2f | %s |%s\n", markdownCell(stmt.UniqueID), stmt.Amount, o.formatDate(stmt.Date), countCell(counts, k))
+		}
+		result.printf("\n</details>\n")
+	}
+
+	// Write the settlement batches
+	if len(r.Batches) > 0 {
+		result.printf("\n<details>\n<summary>Settlement batches (%d)</summary>\n\n", len(r.Batches))
+		result.printf("| Batch | System | Bank | Difference | Matched |\n| --- | ---: | ---: | ---: | --- |\n")
+		for _, batch := range r.Batches {
+			result.printf("| %s | %.2f | %.2f | %.2f | %t |\n",
+				markdownCell(batch.BatchID), batch.SystemTotal, batch.BankTotal, batch.Difference, batch.Matched)
+		}
+		result.printf("\n</details>\n")
+	}
+
+	// Write the skipped files
+	if len(r.SkippedFiles) > 0 {
+		result.printf("\n<details>\n<summary>Skipped files (%d)</summary>\n\n", len(r.SkippedFiles))
+		result.printf("| File | Reason |\n| --- | --- |\n")
+		for _, file := range r.SkippedFiles {
+			result.printf("| %s | %s |\n", markdownCell(file.Filename), markdownCell(file.Reason))
+		}
+		result.printf("\n</details>\n")
+	}
+
+	// Return the first write error, if any
+	if result.err != nil {
+		return fmt.Errorf("failed to write Markdown report: %w", result.err)
+	}
+	return nil
+}
+
+// countHeader returns the header cell of the count column of deduplicated unmatched items, empty without deduplication
+func countHeader(dedupe bool) string {
+	if !dedupe {
+		return ""
+	}
+	return " Count |"
+}
+
+// countDivider returns the divider cell of the count column of deduplicated unmatched items, empty without deduplication
+func countDivider(dedupe bool) string {
+	if !dedupe {
+		return ""
+	}
+	return " ---: |"
+}
+
+// countCell returns the count cell of the k-th reported unmatched item, empty without deduplication
+func countCell(counts []int, k int) string {
+	if counts == nil {
+		return ""
+	}
+	return fmt.Sprintf(" %d |", counts[k])
+}
+
+// markdownCell escapes the value for a Markdown table cell
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
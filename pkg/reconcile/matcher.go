@@ -0,0 +1,34 @@
+package reconcile
+
+import "reconciliation/pkg/types"
+
+// Matcher decides if a system transaction matches a bank statement
+// Implementations must be safe for concurrent use, bank statements are matched at most once in order of the system transactions
+type Matcher interface {
+	Match(sysTx types.Transaction, bankTx types.BankStatement) bool
+}
+
+// MatcherFunc adapts a function to a Matcher
+type MatcherFunc func(sysTx types.Transaction, bankTx types.BankStatement) bool
+
+// Match calls the function
+func (f MatcherFunc) Match(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	return f(sysTx, bankTx)
+}
+
+// DefaultMatcher returns the built-in matcher configured by the options, e.g. WithPercentageTolerance
+// It matches equal amounts within the tolerance, a bank direction or sign agreeing with the type and dates
+// at the same granularity, custom matchers can call it to add rules on top
+func DefaultMatcher(opts ...Option) Matcher {
+	return defaultMatcher{r: newReconciler(opts...)}
+}
+
+// defaultMatcher is the built-in matcher of a reconciler configuration
+type defaultMatcher struct {
+	r *reconciler
+}
+
+// Match checks the amount, direction and date
+func (m defaultMatcher) Match(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	return m.r.matchDefault(sysTx, bankTx)
+}
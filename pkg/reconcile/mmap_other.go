@@ -0,0 +1,13 @@
+//go:build !unix
+
+package reconcile
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not supported on this platform, files are read without mapping
+func mmapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
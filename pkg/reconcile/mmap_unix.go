@@ -0,0 +1,17 @@
+//go:build unix
+
+package reconcile
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the file read-only into memory, the returned function unmaps it
+func mmapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,146 @@
+package reconcile
+
+import "time"
+
+// Performance is the processing throughput of a run reading files, for capacity planning
+type Performance struct {
+	// RowsRead is the number of data rows read from the input files and sources, including rows filtered out
+	RowsRead int
+
+	// Matches is the number of matched transactions
+	Matches int
+
+	// ReadDuration is the time spent reading the input files and sources
+	ReadDuration time.Duration
+
+	// ReconcileDuration is the time spent matching the transactions
+	ReconcileDuration time.Duration
+
+	// GenerateDuration is the time spent generating the reports and encoding the result, set by the caller
+	// before the JSON is written, only the final file write is excluded. It is zero unless the caller sets it
+	GenerateDuration time.Duration
+}
+
+// RowsPerSecond returns the number of rows read per second
+func (p Performance) RowsPerSecond() float64 {
+	return throughput(p.RowsRead, p.ReadDuration)
+}
+
+// MatchesPerSecond returns the number of matches per second of reconciling
+func (p Performance) MatchesPerSecond() float64 {
+	return throughput(p.Matches, p.ReconcileDuration)
+}
+
+// throughput returns the count per second of the duration, 0 for a duration too short to measure
+func throughput(count int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(count) / d.Seconds()
+}
+
+// jsonPerformance is the JSON representation of the processing throughput
+type jsonPerformance struct {
+	RowsRead         int     `json:"rows_read"`
+	ReadSeconds      float64 `json:"read_seconds"`
+	ReconcileSeconds float64 `json:"reconcile_seconds"`
+	RowsPerSecond    float64 `json:"rows_per_second"`
+	MatchesPerSecond float64 `json:"matches_per_second"`
+}
+
+// toJSON converts the performance to its JSON representation, nil stays nil
+func (p *Performance) toJSON() *jsonPerformance {
+	if p == nil {
+		return nil
+	}
+	return &jsonPerformance{
+		RowsRead:         p.RowsRead,
+		ReadSeconds:      p.ReadDuration.Seconds(),
+		ReconcileSeconds: p.ReconcileDuration.Seconds(),
+		RowsPerSecond:    p.RowsPerSecond(),
+		MatchesPerSecond: p.MatchesPerSecond(),
+	}
+}
+
+// toPerformance converts the JSON representation back with the number of matches and the timings of the summary
+func (j *jsonPerformance) toPerformance(matches int, timings *jsonTimings) *Performance {
+	if j == nil {
+		return nil
+	}
+	p := &Performance{
+		RowsRead:          j.RowsRead,
+		Matches:           matches,
+		ReadDuration:      seconds(j.ReadSeconds),
+		ReconcileDuration: seconds(j.ReconcileSeconds),
+	}
+	if timings != nil {
+		p.GenerateDuration = milliseconds(timings.GenerateMs)
+	}
+	return p
+}
+
+// jsonTimings is the JSON representation of the elapsed time of each step of a run in milliseconds
+type jsonTimings struct {
+	ReadMs      float64 `json:"read_ms"`
+	ReconcileMs float64 `json:"reconcile_ms"`
+	GenerateMs  float64 `json:"generate_ms"`
+}
+
+// toTimings converts the durations of the performance to their JSON representation, nil stays nil
+func (p *Performance) toTimings() *jsonTimings {
+	if p == nil {
+		return nil
+	}
+	return &jsonTimings{
+		ReadMs:      toMilliseconds(p.ReadDuration),
+		ReconcileMs: toMilliseconds(p.ReconcileDuration),
+		GenerateMs:  toMilliseconds(p.GenerateDuration),
+	}
+}
+
+// mergeTimings sums the timings of two runs
+func mergeTimings(t, next *jsonTimings) *jsonTimings {
+	if t == nil {
+		return next
+	}
+	if next == nil {
+		return t
+	}
+	return &jsonTimings{
+		ReadMs:      t.ReadMs + next.ReadMs,
+		ReconcileMs: t.ReconcileMs + next.ReconcileMs,
+		GenerateMs:  t.GenerateMs + next.GenerateMs,
+	}
+}
+
+// mergePerformance sums the rows and durations of two runs, the throughput is recomputed over both runs
+func mergePerformance(p, next *jsonPerformance, matches int) *jsonPerformance {
+	if p == nil {
+		return next
+	}
+	if next == nil {
+		return p
+	}
+	merged := &Performance{
+		RowsRead:          p.RowsRead + next.RowsRead,
+		Matches:           matches,
+		ReadDuration:      seconds(p.ReadSeconds + next.ReadSeconds),
+		ReconcileDuration: seconds(p.ReconcileSeconds + next.ReconcileSeconds),
+	}
+	return merged.toJSON()
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// milliseconds converts a number of milliseconds to a duration
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// toMilliseconds converts a duration to a number of milliseconds
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
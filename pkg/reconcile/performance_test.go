@@ -0,0 +1,95 @@
+package reconcile
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestThroughput tests the count per second calculation
+func TestThroughput(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		duration time.Duration
+		expected float64
+	}{
+		{name: "One second", count: 500, duration: time.Second, expected: 500},
+		{name: "Half a second", count: 500, duration: 500 * time.Millisecond, expected: 1000},
+		{name: "No rows", count: 0, duration: time.Second, expected: 0},
+		{name: "Too short to measure", count: 500, duration: 0, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, throughput(tt.count, tt.duration))
+		})
+	}
+}
+
+// TestPerformance_JSON tests the performance block of the summary JSON and merging it
+func TestPerformance_JSON(t *testing.T) {
+	result := ReconcileResult{
+		TransactionProcessed: 4,
+		TransactionMatched:   4,
+		Performance: &Performance{
+			RowsRead:          10,
+			Matches:           4,
+			ReadDuration:      2 * time.Second,
+			ReconcileDuration: 500 * time.Millisecond,
+			GenerateDuration:  250 * time.Millisecond,
+		},
+	}
+	assert.Equal(t, 5.0, result.Performance.RowsPerSecond())
+	assert.Equal(t, 8.0, result.Performance.MatchesPerSecond())
+
+	// The summary JSON has a performance block
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteSummaryJSON(&buf))
+	assert.Contains(t, buf.String(), `"performance":{"rows_read":10,"read_seconds":2,"reconcile_seconds":0.5,`+
+		`"rows_per_second":5,"matches_per_second":8}`)
+
+	// The summary JSON has the timings of each step in milliseconds
+	assert.Contains(t, buf.String(), `"timings":{"read_ms":2000,"reconcile_ms":500,"generate_ms":250}`)
+
+	// Appending sums the rows and durations and recomputes the throughput
+	filename := filepath.Join(t.TempDir(), "result.json")
+	assert.NoError(t, result.AppendJSON(filename))
+	assert.NoError(t, result.AppendJSON(filename))
+	loaded, err := LoadJSON(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, &Performance{
+		RowsRead:          20,
+		Matches:           8,
+		ReadDuration:      4 * time.Second,
+		ReconcileDuration: time.Second,
+		GenerateDuration:  500 * time.Millisecond,
+	}, loaded.Performance)
+}
+
+// TestRunFiles_Performance tests that reading files records the throughput
+func TestRunFiles_Performance(t *testing.T) {
+	fsys := fstest.MapFS{
+		"system.csv": {Data: []byte("TrxID,Amount,Type,TransactionTime\n" +
+			"TX001,100.0,CREDIT,2024-01-01 10:00:00\n" +
+			"TX002,50.0,DEBIT,2024-02-01 10:00:00\n")},
+		"mandiri.csv": {Data: []byte("UniqueID,Amount,Date\nBS001,100.0,2024-01-01\n")},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// Filtered rows count as read
+	result, err := RunFiles([]string{"system.csv"}, []string{"mandiri.csv"}, start, end, WithFS(fsys))
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Performance)
+	assert.Equal(t, 3, result.Performance.RowsRead)
+	assert.Equal(t, 1, result.Performance.Matches)
+	assert.Positive(t, result.Performance.ReadDuration)
+
+	// Results of Reconcile have no performance
+	assert.Nil(t, Reconcile(nil, nil).Performance)
+}
@@ -1,71 +1,228 @@
+// Package reconcile matches system transactions against bank statements
+// Reconcile is the entrypoint for already parsed transactions, RunFiles reads them from files first
 package reconcile
 
 import (
 	"math"
 	"reconciliation/pkg/types"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// amountTolerance is the amount of discrepancy allowed
+// amountTolerance is the amount of discrepancy allowed with the default decimal places
 const amountTolerance = 0.01
 
-// Reconcile reconciles the system transactions against the bank statements
-func Reconcile(system []types.Transaction, bank []types.BankStatement) ReconcileResult {
+// defaultDecimalPlaces is the number of decimal places amounts are rounded to by default
+const defaultDecimalPlaces = 2
+
+// defaultSuggestionWindow is the number of days searched for a suggestion by default
+const defaultSuggestionWindow = 3
+
+// maxOptimalBucketSize is the largest number of transactions or statements of a date matched optimally
+const maxOptimalBucketSize = 500
+
+// Reconcile reconciles the system transactions against the bank statements, it is the entrypoint for library use
+// with transactions parsed from any source, the input slices are not modified
+// The options configure the matching, e.g. WithPercentageTolerance, WithSuggestionWindow, WithDateGranularity or WithMatcher
+// Options for reading files, e.g. WithCSVOptions, WithFS or WithSystemSource, only apply to RunFiles and are ignored
+func Reconcile(system []types.Transaction, bank []types.BankStatement, opts ...Option) ReconcileResult {
+	// Create the reconciler with the given options
+	return newReconciler(opts...).reconcile(system, bank)
+}
+
+// reconcile reconciles the system transactions against the bank statements with the configured options
+func (r *reconciler) reconcile(system []types.Transaction, bank []types.BankStatement) ReconcileResult {
+	// Filter the transactions by type when set
+	if r.typeFilter != "" {
+		system, bank = r.filterByType(system, bank)
+	}
+
+	// Detect duplicate bank statement IDs, matching assumes they are unique
+	duplicateIDs := findDuplicateBankIDs(bank)
+
+	// Add the carried forward items after the fresh ones
+	freshSystem, freshBank := len(system), len(bank)
+	if len(r.carriedSystem) > 0 || len(r.carriedBank) > 0 {
+		system, bank = r.withCarryforward(system, bank)
+	}
+
+	// Infer the sign convention of each bank when enabled
+	if r.consistentBankSign && !r.ignoreType {
+		r.invertedBanks = r.inferInvertedBanks(system, bank)
+	}
+
+	// Initialize the matches, -1 means unmatched
+	matches := make([]int, len(system))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// Reconcile settlement batches instead of single transactions when enabled
+	var batches []BatchResult
+	var batched batchMatches
+	var splits map[int][]int
+	var groups []referenceGroup
+	matchedByID := 0
+	claimedByID := map[string]bool{}
+	if r.batchMatching {
+		batches, batched = r.matchBatches(system, bank)
+	} else {
+		// Match the transactions sharing a reference first, ID matching would give the bank statement to one of them
+		if r.referenceGrouping {
+			groups = r.matchReferenceGroups(system, bank, matches)
+		}
+		matchedByID, claimedByID, splits = r.matchLines(system, bank, freshSystem, freshBank, matches)
+	}
+
+	// Trace the comparisons of the traced transaction when set
+	if r.traceID != "" && r.traceWriter != nil {
+		r.trace(system, bank, matches, splits, batched)
+	}
+
 	// Initialize the result
 	result := ReconcileResult{
+		RunID:                r.runID,
 		TransactionUnmatched: ReconcileUnmatched{},
+		MatchedByID:          matchedByID,
+		DuplicateBankIDs:     duplicateIDs,
+		Batches:              batches,
+		Config:               r.config(),
 	}
 
-	// Pre-allocate maps with expected capacity
-	matchedSystem := make(map[string]bool, len(system))
+	// Pre-allocate map with expected capacity
 	matchedBank := make(map[string]bool, len(bank))
 
+	// Accumulate discrepancies in integer units to avoid floating point drift
+	var totalUnits, netUnits int64
+	histogram := r.newHistogram()
+
 	// Set the total number of transactions processed
 	result.TransactionProcessed = len(system)
 
-	// Compare each system transaction against bank statements
-	for _, sysTx := range system {
-		matched := false
-
-		// Compare each system transaction against bank statements
-		for _, bankTx := range bank {
-			// Skip already matched bank transactions
-			if matchedBank[bankTx.UniqueID] {
-				continue
-			}
-
-			// Check if the system transaction matches the bank transaction
-			if isMatch(sysTx, bankTx) {
-				// Set the matched flag to true
-				matched = true
-
-				// Add the system transaction to the matched map
-				matchedSystem[sysTx.TrxID] = true
+	// Record the reference groups, the difference is counted per group
+	grouped := make(map[int]bool)
+	for _, group := range groups {
+		bankTx := bank[group.bank]
+		referenceGroup := ReferenceGroup{Reference: bankTx.Reference, Bank: bankTx}
+		var sysUnits int64
+		for _, i := range group.system {
+			grouped[i] = true
+			referenceGroup.System = append(referenceGroup.System, system[i])
+			sysUnits += r.systemUnits(system[i])
+		}
+		result.ReferenceGroups = append(result.ReferenceGroups, referenceGroup)
+		matchedBank[bankKey(bankTx)] = true
 
-				// Add the bank transaction to the matched map
-				matchedBank[bankTx.UniqueID] = true
+		bankUnits := abs(r.toUnits(bankTx.Amount))
+		totalUnits += abs(sysUnits - bankUnits)
+		netUnits += bankUnits - sysUnits
+		histogram.add(abs(sysUnits - bankUnits))
+		result.countMatches(len(group.system), sysUnits == bankUnits)
+	}
 
-				// Increment the matched transaction count
-				result.TransactionMatched++
+	// Collect matched and unmatched system transactions
+	for i, sysTx := range system {
+		// Transactions of a reference group are matched together
+		if grouped[i] {
+			result.TransactionMatched++
+			continue
+		}
 
-				// Add any amount discrepancy to total
-				result.TotalDiscrepancies += round(abs(sysTx.Amount - abs(bankTx.Amount)))
+		// Transactions of a matched batch are matched together, the difference is counted per batch
+		if r.batchMatching && batched.system[i] {
+			result.TransactionMatched++
+			continue
+		}
 
-				// Break out of the loop
-				break
+		// Record a split match like a single match with the summed bank amount
+		if group, ok := splits[i]; ok {
+			split := SplitMatch{System: sysTx}
+			var bankUnits int64
+			for _, j := range group {
+				matchedBank[bankKey(bank[j])] = true
+				split.Bank = append(split.Bank, bank[j])
+				bankUnits += abs(r.toUnits(bank[j].Amount))
 			}
+			result.SplitMatches = append(result.SplitMatches, split)
+			result.TransactionMatched++
+
+			sysUnits := r.systemUnits(sysTx)
+			totalUnits += abs(sysUnits - bankUnits)
+			netUnits += bankUnits - sysUnits
+			histogram.add(abs(sysUnits - bankUnits))
+			result.countMatches(1, sysUnits == bankUnits)
+			continue
 		}
 
 		// If no match is found, add the system transaction to the unmatched map
-		if !matched {
+		if matches[i] < 0 {
 			result.TransactionUnmatched.TransactionUnmatched++
 			result.TransactionUnmatched.SystemUnmatched = append(result.TransactionUnmatched.SystemUnmatched, sysTx)
+			continue
 		}
+		bankTx := bank[matches[i]]
+
+		// Add the bank transaction to the matched map
+		matchedBank[bankKey(bankTx)] = true
+
+		// Increment the matched transaction count
+		result.TransactionMatched++
+
+		// Report the pairs with a carried forward item apart from the fresh matches, ID matches are counted by ID
+		if (i >= freshSystem || matches[i] >= freshBank) && !claimedByID[bankKey(bankTx)] {
+			result.MatchedByCarryforward++
+			result.CarriedForwardMatches = append(result.CarriedForwardMatches, MatchedPair{System: sysTx, Bank: bankTx})
+		}
+
+		// Convert the amounts to integer units
+		sysUnits := r.systemUnits(sysTx)
+		bankUnits := abs(r.toUnits(bankTx.Amount))
+
+		// Add any amount discrepancy to total
+		totalUnits += abs(sysUnits - bankUnits)
+
+		// Add the signed difference (bank minus system) to the net discrepancy
+		netUnits += bankUnits - sysUnits
+
+		// Count the discrepancy in its histogram bucket
+		histogram.add(abs(sysUnits - bankUnits))
+
+		// Count the match as exact or within the tolerance
+		result.countMatches(1, sysUnits == bankUnits)
 	}
 
+	// The bank statements of the matched batches are matched
+	for j, matched := range batched.bank {
+		if matched {
+			matchedBank[bankKey(bank[j])] = true
+		}
+	}
+	totalUnits += batched.totalUnits
+	netUnits += batched.netUnits
+	result.countMatches(batched.exact, true)
+	result.countMatches(batched.count()-batched.exact, false)
+
+	// The remaining matches come from amount, date and type matching
+	result.MatchedByHeuristic = result.TransactionMatched - result.MatchedByID - result.MatchedByCarryforward - batched.count() - len(grouped)
+
+	// Convert the discrepancies back to amounts
+	result.TotalDiscrepancies = r.fromUnits(totalUnits)
+	result.NetDiscrepancy = r.fromUnits(netUnits)
+	result.DiscrepancyHistogram = histogram.buckets
+
+	// Compute the control totals independent of line matching
+	systemUnits, bankUnits := r.controlTotals(system[:freshSystem], bank[:freshBank])
+	result.SystemTotal = r.fromUnits(systemUnits)
+	result.BankTotal = r.fromUnits(bankUnits)
+	result.TotalDifference = r.fromUnits(bankUnits - systemUnits)
+
 	// Collect unmatched bank statements
 	for _, bankTx := range bank {
 		// Skip already matched bank transactions
-		if matchedBank[bankTx.UniqueID] {
+		if matchedBank[bankKey(bankTx)] {
 			continue
 		}
 
@@ -74,39 +231,871 @@ func Reconcile(system []types.Transaction, bank []types.BankStatement) Reconcile
 		result.TransactionUnmatched.BankUnmatched = append(result.TransactionUnmatched.BankUnmatched, bankTx)
 	}
 
+	// Count the unmatched items of each side
+	result.TransactionUnmatched.SystemUnmatchedCount = len(result.TransactionUnmatched.SystemUnmatched)
+	result.TransactionUnmatched.BankUnmatchedCount = len(result.TransactionUnmatched.BankUnmatched)
+
+	// Report the matches chosen among several candidates
+	result.Ambiguities = r.findAmbiguities(system, bank, matches, claimedByID)
+
+	// Flag unmatched pairs that only differ by sign
+	result.SignMismatches = r.findSignMismatches(result.TransactionUnmatched)
+
+	// Flag unmatched pairs that only differ by currency
+	result.CurrencyMismatches = r.findCurrencyMismatches(result.TransactionUnmatched)
+
+	// Suggest the closest bank statement for unmatched system transactions
+	result.Suggestions = r.findSuggestions(result.TransactionUnmatched)
+
+	// Record the matched items, an append drops them from the unmatched items of earlier runs
+	result.matchedBank = matchedBank
+	result.matchedSystem = matchedTrxIDs(system, result.TransactionUnmatched.SystemUnmatched)
+
 	// Return the result
 	return result
 }
 
-// isMatch checks if a system transaction matches a bank transaction
-func isMatch(sysTx types.Transaction, bankTx types.BankStatement) bool {
-	// Match by amount and transaction type
-	bankAmount := bankTx.Amount
+// matchLines matches single system transactions to bank statements, recording the index of the matched bank statement in matches
+// It returns the number of matches by ID, the keys of the bank statements matched by ID and the split matches
+func (r *reconciler) matchLines(system []types.Transaction, bank []types.BankStatement, freshSystem, freshBank int, matches []int) (int, map[string]bool, map[int][]int) {
+	// Match on equal IDs first when enabled
+	matchedByID := 0
+	if r.idMatching {
+		matchedByID = r.matchByID(system, bank, matches)
+	}
+
+	// Bank statements matched by ID or reference are not candidates for the remaining transactions
+	claimedByID := claimedBank(bank, matches)
+
+	// Match the remaining system transactions by amount, date and type
+	r.matchRemaining(system, bank, matches)
+
+	// Match the remaining carried forward items across dates
+	if len(system) > freshSystem || len(bank) > freshBank {
+		r.matchCarryforward(system, bank, freshSystem, freshBank, matches)
+	}
+
+	// Match the remaining system transactions to several bank statements when enabled
+	var splits map[int][]int
+	if r.splitMatching {
+		splits = r.matchSplits(system, bank, matches)
+	}
+
+	return matchedByID, claimedByID, splits
+}
+
+// matchRemaining matches the unmatched system transactions by amount, date and type with the configured matching mode
+// Custom matchers and business day windows may match across dates, so they cannot be sharded by date
+func (r *reconciler) matchRemaining(system []types.Transaction, bank []types.BankStatement, matches []int) {
+	switch {
+	case r.optimalMatching:
+		r.matchOptimal(system, bank, matches)
+	case r.workers > 1 && !r.matchesAcrossDates():
+		r.matchConcurrent(system, bank, matches)
+	default:
+		r.matchSequential(system, bank, matches)
+	}
+}
+
+// inferInvertedBanks returns the banks reporting the types with inverted signs, see WithConsistentBankSign
+// Each pair of a system transaction and a bank statement with equal absolute amount and date key votes for
+// the convention of the bank, a bank is inverted when more pairs disagree than agree with the direction check
+func (r *reconciler) inferInvertedBanks(system []types.Transaction, bank []types.BankStatement) map[string]bool {
+	// Index the system transactions by date key and absolute amount
+	systemByKey := make(map[string][]int, len(system))
+	for i, sysTx := range system {
+		key := r.signMismatchKey(r.systemUnits(sysTx), sysTx.TransactionTime)
+		systemByKey[key] = append(systemByKey[key], i)
+	}
+
+	// Count the agreeing and disagreeing pairs per bank
+	votes := make(map[string]int)
+	for _, bankTx := range bank {
+		for _, i := range systemByKey[r.signMismatchKey(abs(r.toUnits(bankTx.Amount)), bankTx.Date)] {
+			if r.directionMatches(system[i], bankTx) {
+				votes[bankTx.BankName]++
+			} else {
+				votes[bankTx.BankName]--
+			}
+		}
+	}
+
+	inverted := make(map[string]bool)
+	for bankName, vote := range votes {
+		if vote < 0 {
+			inverted[bankName] = true
+		}
+	}
+	return inverted
+}
+
+// findDuplicateBankIDs lists the bank statement IDs that occur more than once within a bank, in order of first occurrence
+func findDuplicateBankIDs(bank []types.BankStatement) []DuplicateBankID {
+	counts := make(map[string]int, len(bank))
+	for _, bankTx := range bank {
+		counts[bankKey(bankTx)]++
+	}
+
+	var duplicates []DuplicateBankID
+	for _, bankTx := range bank {
+		key := bankKey(bankTx)
+		if counts[key] < 2 {
+			continue
+		}
+		duplicates = append(duplicates, DuplicateBankID{BankName: bankTx.BankName, UniqueID: bankTx.UniqueID, Occurrences: counts[key]})
+
+		// Report each ID once
+		counts[key] = 0
+	}
+	return duplicates
+}
+
+// matchedTrxIDs returns the TrxIDs of the system transactions that are not unmatched
+func matchedTrxIDs(system, unmatched []types.Transaction) map[string]bool {
+	unmatchedIDs := make(map[string]bool, len(unmatched))
+	for _, sysTx := range unmatched {
+		unmatchedIDs[sysTx.TrxID] = true
+	}
+
+	matched := make(map[string]bool, len(system)-len(unmatched))
+	for _, sysTx := range system {
+		if !unmatchedIDs[sysTx.TrxID] {
+			matched[sysTx.TrxID] = true
+		}
+	}
+	return matched
+}
+
+// bankKey identifies a bank statement, UniqueIDs are only unique within a bank
+func bankKey(bankTx types.BankStatement) string {
+	return bankTx.BankName + "\x00" + bankTx.UniqueID
+}
+
+// controlTotals sums the system and bank amounts in integer units, CREDIT counted as positive and DEBIT as negative
+// An explicit bank direction sets the sign, otherwise the bank amount sign is used, flipped with an inverted bank sign
+// Signed system amounts are summed as is
+func (r *reconciler) controlTotals(system []types.Transaction, bank []types.BankStatement) (int64, int64) {
+	var systemUnits, bankUnits int64
+	for _, sysTx := range system {
+		units := r.toUnits(sysTx.Amount)
+		if !r.useSignedAmount {
+			units = abs(units)
+			if sysTx.Type == types.TransactionTypeDebit {
+				units = -units
+			}
+		}
+		systemUnits += units
+	}
+
+	for _, bankTx := range bank {
+		units := r.toUnits(bankTx.Amount)
+		switch {
+		case bankTx.Direction == types.TransactionTypeDebit:
+			units = -abs(units)
+		case bankTx.Direction == types.TransactionTypeCredit:
+			units = abs(units)
+		case r.invertBankSign:
+			units = -units
+		}
+		bankUnits += units
+	}
+
+	return systemUnits, bankUnits
+}
+
+// findAmbiguities replays the matching in order and reports the system transactions matched by amount and date
+// while more than one unclaimed bank statement passed isMatch
+func (r *reconciler) findAmbiguities(system []types.Transaction, bank []types.BankStatement, matches []int, claimedByID map[string]bool) []AmbiguityRecord {
+	// Build an index of bank statements by date key, a match requires the same key
+	bankByDate := make(map[string][]int)
+	for j, bankTx := range bank {
+		key := r.candidateKey(bankTx.Date)
+		bankByDate[key] = append(bankByDate[key], j)
+	}
+
+	// Start from the bank statements claimed by ID matching
+	claimed := make(map[string]bool, len(bank))
+	for id := range claimedByID {
+		claimed[id] = true
+	}
+
+	var ambiguities []AmbiguityRecord
+	for i, sysTx := range system {
+		// Skip unmatched transactions and transactions matched by ID
+		if matches[i] < 0 || claimedByID[bankKey(bank[matches[i]])] {
+			continue
+		}
+
+		// Collect the unclaimed candidates at the time of the match
+		var candidates, candidateBanks []string
+		for _, j := range bankByDate[r.candidateKey(sysTx.TransactionTime)] {
+			if !claimed[bankKey(bank[j])] && r.isMatch(sysTx, bank[j]) {
+				candidates = append(candidates, bank[j].UniqueID)
+				candidateBanks = append(candidateBanks, bank[j].BankName)
+			}
+		}
+
+		// Claim the chosen bank statement
+		chosen := bank[matches[i]]
+		claimed[bankKey(chosen)] = true
+
+		if len(candidates) > 1 {
+			ambiguities = append(ambiguities, AmbiguityRecord{
+				TrxID:          sysTx.TrxID,
+				BankName:       chosen.BankName,
+				Chosen:         chosen.UniqueID,
+				Candidates:     candidates,
+				CandidateBanks: candidateBanks,
+			})
+		}
+	}
+
+	return ambiguities
+}
+
+// findSignMismatches pairs unmatched system transactions with unmatched bank statements
+// that have the same absolute amount and date but a sign or direction rejected for the transaction type
+func (r *reconciler) findSignMismatches(unmatched ReconcileUnmatched) []MatchedPair {
+	// Pairs differing by sign are matched when the type is ignored
+	if r.ignoreType || len(unmatched.SystemUnmatched) == 0 || len(unmatched.BankUnmatched) == 0 {
+		return nil
+	}
+
+	// Index unmatched bank statements by absolute amount and date ignoring sign
+	bankByKey := make(map[string][]int, len(unmatched.BankUnmatched))
+	for j, bankTx := range unmatched.BankUnmatched {
+		key := r.signMismatchKey(abs(r.toUnits(bankTx.Amount)), bankTx.Date)
+		bankByKey[key] = append(bankByKey[key], j)
+	}
+
+	// Pair each unmatched system transaction with the first unclaimed bank statement of the same key
+	var mismatches []MatchedPair
+	claimed := make(map[int]bool)
+	for _, sysTx := range unmatched.SystemUnmatched {
+		for _, j := range bankByKey[r.signMismatchKey(r.systemUnits(sysTx), sysTx.TransactionTime)] {
+			bankTx := unmatched.BankUnmatched[j]
+			if claimed[j] || !currencyMatches(sysTx, bankTx) || r.directionMatches(sysTx, bankTx) {
+				continue
+			}
+
+			claimed[j] = true
+			mismatches = append(mismatches, MatchedPair{System: sysTx, Bank: bankTx})
+			break
+		}
+	}
+
+	return mismatches
+}
+
+// findCurrencyMismatches pairs unmatched system transactions with unmatched bank statements that have the same
+// absolute amount and date but a different currency, e.g. a USD transaction and an IDR bank line
+func (r *reconciler) findCurrencyMismatches(unmatched ReconcileUnmatched) []MatchedPair {
+	if len(unmatched.SystemUnmatched) == 0 || len(unmatched.BankUnmatched) == 0 {
+		return nil
+	}
+
+	// Index unmatched bank statements with a currency by absolute amount and date
+	bankByKey := make(map[string][]int, len(unmatched.BankUnmatched))
+	for j, bankTx := range unmatched.BankUnmatched {
+		if bankTx.Currency != "" {
+			key := r.signMismatchKey(abs(r.toUnits(bankTx.Amount)), bankTx.Date)
+			bankByKey[key] = append(bankByKey[key], j)
+		}
+	}
+
+	// Pair each unmatched system transaction with the first unclaimed bank statement of the same key in another currency
+	var mismatches []MatchedPair
+	claimed := make(map[int]bool)
+	for _, sysTx := range unmatched.SystemUnmatched {
+		if sysTx.Currency == "" {
+			continue
+		}
+		for _, j := range bankByKey[r.signMismatchKey(r.systemUnits(sysTx), sysTx.TransactionTime)] {
+			bankTx := unmatched.BankUnmatched[j]
+			if claimed[j] || currencyMatches(sysTx, bankTx) {
+				continue
+			}
+
+			claimed[j] = true
+			mismatches = append(mismatches, MatchedPair{System: sysTx, Bank: bankTx})
+			break
+		}
+	}
+
+	return mismatches
+}
+
+// findSuggestions finds the closest unmatched bank statement for each unmatched system transaction
+// The closest statement has the smallest amount delta, then the smallest date delta, within the suggestion window
+func (r *reconciler) findSuggestions(unmatched ReconcileUnmatched) map[string]Suggestion {
+	if r.suggestionWindow <= 0 || len(unmatched.SystemUnmatched) == 0 || len(unmatched.BankUnmatched) == 0 {
+		return nil
+	}
+
+	// Index unmatched bank statements by date
+	bankByDate := make(map[string][]int, len(unmatched.BankUnmatched))
+	for j, bankTx := range unmatched.BankUnmatched {
+		key := bankTx.Date.Format("2006-01-02")
+		bankByDate[key] = append(bankByDate[key], j)
+	}
+
+	suggestions := make(map[string]Suggestion)
+	for _, sysTx := range unmatched.SystemUnmatched {
+		sysUnits := r.systemUnits(sysTx)
+		sysDate := sysTx.TransactionTime
+
+		// Search the bank statements of each day in the window
+		best, bestUnits, bestDays := -1, int64(0), 0
+		for days := -r.suggestionWindow; days <= r.suggestionWindow; days++ {
+			for _, j := range bankByDate[sysDate.AddDate(0, 0, days).Format("2006-01-02")] {
+				deltaUnits := abs(sysUnits - abs(r.toUnits(unmatched.BankUnmatched[j].Amount)))
+				if best < 0 || deltaUnits < bestUnits || (deltaUnits == bestUnits && abs(days) < abs(bestDays)) {
+					best, bestUnits, bestDays = j, deltaUnits, days
+				}
+			}
+		}
+
+		// Record the closest bank statement
+		if best >= 0 {
+			suggestions[sysTx.TrxID] = Suggestion{
+				Bank:        unmatched.BankUnmatched[best],
+				AmountDelta: r.fromUnits(bestUnits),
+				DateDelta:   bestDays,
+			}
+		}
+	}
+
+	return suggestions
+}
+
+// signMismatchKey returns the index key of an absolute amount in units and date
+func (r *reconciler) signMismatchKey(units int64, date time.Time) string {
+	return r.dateKey(date) + "|" + strconv.FormatInt(units, 10)
+}
+
+// filterByType keeps the system transactions of the filter type and the bank statements
+// whose direction or amount sign is consistent with it
+func (r *reconciler) filterByType(system []types.Transaction, bank []types.BankStatement) ([]types.Transaction, []types.BankStatement) {
+	filteredSystem := make([]types.Transaction, 0, len(system))
+	for _, sysTx := range system {
+		if r.systemType(sysTx) == r.typeFilter {
+			filteredSystem = append(filteredSystem, sysTx)
+		}
+	}
+
+	// With signed system amounts the direction of the filter type is given by the sign
+	filterTx := types.Transaction{Type: r.typeFilter}
+	if r.useSignedAmount && r.typeFilter == types.TransactionTypeDebit {
+		filterTx.Amount = -1
+	}
+
+	filteredBank := make([]types.BankStatement, 0, len(bank))
+	for _, bankTx := range bank {
+		if r.directionMatches(filterTx, bankTx) {
+			filteredBank = append(filteredBank, bankTx)
+		}
+	}
+
+	return filteredSystem, filteredBank
+}
+
+// matchByID matches system transactions to bank statements with a UniqueID equal to the TrxID
+// It records the index of the matched bank statement in matches and returns the number of matches
+func (r *reconciler) matchByID(system []types.Transaction, bank []types.BankStatement, matches []int) int {
+	// Index bank statements by ID
+	bankByID := make(map[string][]int, len(bank))
+	for j, bankTx := range bank {
+		bankByID[bankTx.UniqueID] = append(bankByID[bankTx.UniqueID], j)
+	}
 
-	// For system DEBIT transactions, bank amount should be negative
-	// For system CREDIT transactions, bank amount should be positive
-	if sysTx.Type == "DEBIT" && bankAmount > 0 {
+	// Claim the first unclaimed bank statement with the same ID, banks may reuse the same ID
+	matched := 0
+	matchedBank := make(map[int]bool, len(bank))
+	for _, j := range matches {
+		if j >= 0 {
+			matchedBank[j] = true
+		}
+	}
+	for i, sysTx := range system {
+		// Skip system transactions already matched by reference
+		if matches[i] >= 0 {
+			continue
+		}
+		for _, j := range bankByID[sysTx.TrxID] {
+			if matchedBank[j] {
+				continue
+			}
+			matches[i] = j
+			matchedBank[j] = true
+			matched++
+			break
+		}
+	}
+
+	return matched
+}
+
+// matchSequential records for each unmatched system transaction the index of its matched bank statement in matches
+func (r *reconciler) matchSequential(system []types.Transaction, bank []types.BankStatement, matches []int) {
+	// Bank statements already matched are claimed
+	matchedBank := claimedBank(bank, matches)
+
+	// Compare each system transaction against bank statements
+	for i, sysTx := range system {
+		// Skip already matched system transactions
+		if matches[i] >= 0 {
+			continue
+		}
+
+		// Compare each system transaction against bank statements
+		best, bestScore := -1, candidateScore{}
+		for j, bankTx := range bank {
+			// Skip already matched bank transactions and bank transactions not matching
+			if matchedBank[bankKey(bankTx)] || !r.isMatch(sysTx, bankTx) {
+				continue
+			}
+
+			// Keep the first match, or the highest scoring match in best-match mode
+			score := r.score(sysTx, bankTx)
+			if best < 0 || score.better(bestScore) {
+				best, bestScore = j, score
+			}
+			if !r.bestMatch {
+				break
+			}
+		}
+
+		// Record the match and claim the bank transaction
+		if best >= 0 {
+			matches[i] = best
+			matchedBank[bankKey(bank[best])] = true
+		}
+	}
+}
+
+// matchOptimal matches the unmatched system transactions to maximize the number of matches instead of taking
+// the first candidate, e.g. when a transaction takes the only candidate of a later one
+// A match requires the same date key, so each date is a separate bipartite assignment solved with augmenting paths,
+// taking O(n*m) isMatch calls and O(n*e) steps for n system transactions, m bank statements and e candidate pairs of a date
+// Dates with more than maxOptimalBucketSize transactions or statements fall back to matchSequential to stay tractable
+func (r *reconciler) matchOptimal(system []types.Transaction, bank []types.BankStatement, matches []int) {
+	// Index the unclaimed bank statements by date key
+	claimed := claimedBank(bank, matches)
+	bankByDate := make(map[string][]int)
+	for j, bankTx := range bank {
+		if !claimed[bankKey(bankTx)] {
+			key := r.candidateKey(bankTx.Date)
+			bankByDate[key] = append(bankByDate[key], j)
+		}
+	}
+
+	// Group the unmatched system transactions by date key in order of first appearance
+	var keys []string
+	systemByDate := make(map[string][]int)
+	for i, sysTx := range system {
+		if matches[i] >= 0 {
+			continue
+		}
+		key := r.candidateKey(sysTx.TransactionTime)
+		if _, ok := systemByDate[key]; !ok {
+			keys = append(keys, key)
+		}
+		systemByDate[key] = append(systemByDate[key], i)
+	}
+
+	// Solve the assignment of each date small enough
+	for _, key := range keys {
+		systemIdx, bankIdx := systemByDate[key], bankByDate[key]
+		if len(systemIdx) > maxOptimalBucketSize || len(bankIdx) > maxOptimalBucketSize {
+			continue
+		}
+		r.assign(system, bank, systemIdx, bankIdx, matches, claimed)
+	}
+
+	// Match the transactions of the larger dates, the assigned dates have no matching pairs left
+	r.matchSequential(system, bank, matches)
+}
+
+// assign finds a maximum matching between the system transactions and bank statements of one date with augmenting paths
+// Transactions are assigned in order and prefer their first candidate, or their best candidate in best-match mode
+// Bank keys in claimed are skipped and the assigned keys are added, a key repeated on another date is matched once
+func (r *reconciler) assign(system []types.Transaction, bank []types.BankStatement, systemIdx, bankIdx []int, matches []int, claimed map[string]bool) {
+	// Collect the candidates of each system transaction
+	candidates := make([][]int, len(systemIdx))
+	for p, i := range systemIdx {
+		for _, j := range bankIdx {
+			if !claimed[bankKey(bank[j])] && r.isMatch(system[i], bank[j]) {
+				candidates[p] = append(candidates[p], j)
+			}
+		}
+		if r.bestMatch {
+			sort.SliceStable(candidates[p], func(a, b int) bool {
+				return r.score(system[i], bank[candidates[p][a]]).better(r.score(system[i], bank[candidates[p][b]]))
+			})
+		}
+	}
+
+	// owner maps a bank key to the position of the system transaction holding it
+	owner := make(map[string]int)
+	chosen := make([]int, len(systemIdx))
+
+	// augment assigns a candidate to the transaction, moving current holders to other candidates when possible
+	var augment func(p int, visited map[string]bool) bool
+	augment = func(p int, visited map[string]bool) bool {
+		for _, j := range candidates[p] {
+			key := bankKey(bank[j])
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+
+			if q, taken := owner[key]; !taken || augment(q, visited) {
+				owner[key] = p
+				chosen[p] = j
+				return true
+			}
+		}
 		return false
 	}
-	if sysTx.Type == "CREDIT" && bankAmount < 0 {
+	for p := range systemIdx {
+		augment(p, make(map[string]bool))
+	}
+
+	// Record the matches and claim their bank keys
+	for key, p := range owner {
+		matches[systemIdx[p]] = chosen[p]
+		claimed[key] = true
+	}
+}
+
+// claimedBank returns the keys of the bank statements already matched
+func claimedBank(bank []types.BankStatement, matches []int) map[string]bool {
+	// Pre-allocate map with expected capacity
+	matchedBank := make(map[string]bool, len(bank))
+	for _, j := range matches {
+		if j >= 0 {
+			matchedBank[bankKey(bank[j])] = true
+		}
+	}
+	return matchedBank
+}
+
+// matchConcurrent is the concurrent version of matchSequential
+// System transactions are sharded by date key, since a match requires the same key, transactions competing
+// for the same bank statements are handled in order by one worker and the result is identical to matchSequential
+// Dates sharing a bank key, e.g. a duplicate UniqueID on two dates, compete for the key and go to the same shard
+func (r *reconciler) matchConcurrent(system []types.Transaction, bank []types.BankStatement, matches []int) {
+	// Build a read-only index of bank statements by date key
+	// Join the date keys of the statements sharing a bank key, only one of them can be claimed
+	bankByDate := make(map[string][]int)
+	dates := newDateGroups()
+	firstDate := make(map[string]string, len(bank))
+	for j, bankTx := range bank {
+		key := r.dateKey(bankTx.Date)
+		bankByDate[key] = append(bankByDate[key], j)
+		if first, ok := firstDate[bankKey(bankTx)]; ok {
+			dates.join(first, key)
+		} else {
+			firstDate[bankKey(bankTx)] = key
+		}
+	}
+
+	// Assign each group of date keys to a shard in order of first appearance
+	shardOf := make(map[string]int)
+	shards := make([][]int, r.workers)
+	for i, sysTx := range system {
+		// Skip already matched system transactions
+		if matches[i] >= 0 {
+			continue
+		}
+
+		key := dates.find(r.dateKey(sysTx.TransactionTime))
+		shard, ok := shardOf[key]
+		if !ok {
+			shard = len(shardOf) % r.workers
+			shardOf[key] = shard
+		}
+		shards[shard] = append(shards[shard], i)
+	}
+
+	// Each unmatched index is written by exactly one worker, bank statements already matched are claimed
+	claims := &claimMap{claimed: claimedBank(bank, matches)}
+
+	// Create a wait group to wait for all workers to complete
+	var wg sync.WaitGroup
+
+	// Process each shard concurrently
+	for _, shard := range shards {
+		wg.Add(1)
+		go func(indices []int) {
+			defer wg.Done()
+
+			for _, i := range indices {
+				sysTx := system[i]
+
+				for {
+					// Compare the system transaction against unclaimed bank statements of the same date
+					best, bestScore := -1, candidateScore{}
+					for _, j := range bankByDate[r.dateKey(sysTx.TransactionTime)] {
+						bankTx := bank[j]
+						if claims.isClaimed(bankKey(bankTx)) || !r.isMatch(sysTx, bankTx) {
+							continue
+						}
+
+						// Keep the first match, or the highest scoring match in best-match mode
+						score := r.score(sysTx, bankTx)
+						if best < 0 || score.better(bestScore) {
+							best, bestScore = j, score
+						}
+						if !r.bestMatch {
+							break
+						}
+					}
+					if best < 0 {
+						break
+					}
+
+					// Claim the bank transaction, a key claimed in the meantime sends the transaction back to the scan
+					if claims.claim(bankKey(bank[best])) {
+						matches[i] = best
+						break
+					}
+				}
+			}
+		}(shard)
+	}
+
+	// Wait for all workers to complete
+	wg.Wait()
+}
+
+// dateGroups is a union-find of date keys, joined when they compete for the same bank statement keys
+type dateGroups struct {
+	parent map[string]string
+}
+
+// newDateGroups creates date groups where every date key is its own group
+func newDateGroups() *dateGroups {
+	return &dateGroups{parent: make(map[string]string)}
+}
+
+// find returns the representative date key of the group of the key
+func (d *dateGroups) find(key string) string {
+	for {
+		parent, ok := d.parent[key]
+		if !ok || parent == key {
+			return key
+		}
+
+		// Halve the path to keep lookups short
+		if grandparent, ok := d.parent[parent]; ok {
+			d.parent[key] = grandparent
+		}
+		key = parent
+	}
+}
+
+// join merges the groups of both date keys
+func (d *dateGroups) join(a, b string) {
+	rootA, rootB := d.find(a), d.find(b)
+	if rootA != rootB {
+		d.parent[rootB] = rootA
+	}
+}
+
+// claimMap is a mutex-protected set of claimed bank statement keys
+// It guarantees a bank statement is matched at most once
+type claimMap struct {
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+// claim marks the key as claimed, returns false if it was already claimed
+func (c *claimMap) claim(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.claimed[id] {
+		return false
+	}
+	c.claimed[id] = true
+	return true
+}
+
+// isClaimed checks if the key is already claimed
+func (c *claimMap) isClaimed(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.claimed[id]
+}
+
+// candidateScore rates how well a bank statement passing isMatch fits a system transaction
+type candidateScore struct {
+	// The bank UniqueID equals the system TrxID
+	sameReference bool
+
+	// Absolute amount difference in integer units
+	amountDelta int64
+
+	// Absolute number of days between the dates
+	dateDelta int
+}
+
+// better checks if the score beats the other, an equal reference wins, then the smaller amount and date deltas
+func (s candidateScore) better(other candidateScore) bool {
+	if s.sameReference != other.sameReference {
+		return s.sameReference
+	}
+	if s.amountDelta != other.amountDelta {
+		return s.amountDelta < other.amountDelta
+	}
+	return s.dateDelta < other.dateDelta
+}
+
+// score rates a bank statement passing isMatch for the system transaction
+func (r *reconciler) score(sysTx types.Transaction, bankTx types.BankStatement) candidateScore {
+	sysYear, sysMonth, sysDay := sysTx.TransactionTime.Date()
+	bankYear, bankMonth, bankDay := bankTx.Date.Date()
+	sysDate := time.Date(sysYear, sysMonth, sysDay, 0, 0, 0, 0, time.UTC)
+	bankDate := time.Date(bankYear, bankMonth, bankDay, 0, 0, 0, 0, time.UTC)
+
+	return candidateScore{
+		sameReference: sysTx.TrxID != "" && sysTx.TrxID == bankTx.UniqueID,
+		amountDelta:   abs(r.systemUnits(sysTx) - abs(r.toUnits(bankTx.Amount))),
+		dateDelta:     abs(int(sysDate.Sub(bankDate).Hours() / 24)),
+	}
+}
+
+// isMatch checks if a system transaction matches a bank transaction with the custom or the built-in matcher
+// Items of different currencies never match, whatever the matcher
+func (r *reconciler) isMatch(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	if !currencyMatches(sysTx, bankTx) {
 		return false
 	}
+	if r.matcher != nil {
+		return r.matcher.Match(sysTx, bankTx)
+	}
+	return r.matchDefault(sysTx, bankTx)
+}
 
-	if round(abs(sysTx.Amount-abs(bankAmount))) > amountTolerance {
+// currencyMatches checks if the system transaction and the bank statement have the same currency
+// An item without a currency matches any currency, e.g. files without a currency column
+func currencyMatches(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	return sysTx.Currency == "" || bankTx.Currency == "" || strings.EqualFold(sysTx.Currency, bankTx.Currency)
+}
+
+// matchDefault checks if a system transaction matches a bank transaction by amount, direction and date
+func (r *reconciler) matchDefault(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	// Match by amount and transaction type, then by date at the configured granularity or within the business day window
+	return r.amountMatches(sysTx, bankTx) && r.dateMatches(sysTx.TransactionTime, bankTx.Date)
+}
+
+// amountMatches checks if a bank transaction has the amount and direction of a system transaction, regardless of the date
+func (r *reconciler) amountMatches(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	// Check the bank direction or amount sign against the transaction type unless the type is ignored
+	if !r.ignoreType && !r.directionMatches(sysTx, bankTx) {
 		return false
 	}
 
-	// Match by date
-	return sysTx.TransactionTime.Format("2006-01-02") == bankTx.Date.Format("2006-01-02")
+	// Compare the amounts in integer units
+	sysUnits := r.systemUnits(sysTx)
+	return abs(sysUnits-abs(r.toUnits(bankTx.Amount))) <= r.tolerance(sysUnits, bankTx.BankName)
+}
+
+// tolerance returns the discrepancy allowed for a system amount against a bank in integer units
+// It is the larger of the absolute tolerance of the bank, or the global one for banks without a tolerance,
+// and the percentage of the system amount
+func (r *reconciler) tolerance(sysUnits int64, bankName string) int64 {
+	absolute := r.toleranceUnits
+	if units, ok := r.bankToleranceUnits[strings.ToUpper(bankName)]; ok {
+		absolute = units
+	}
+	pctUnits := int64(math.Round(r.percentageTolerance * float64(abs(sysUnits))))
+	if pctUnits > absolute {
+		return pctUnits
+	}
+	return absolute
+}
+
+// directionMatches checks if the bank statement direction agrees with the system transaction type
+// An explicit bank direction is compared to the type, otherwise the amount sign is checked against
+// the rule for the type, by default DEBIT should be negative, CREDIT should be positive
+// With an inverted bank sign the amount is flipped before checking the rule
+// With signed system amounts the bank amount must have the sign of the system amount
+// Banks inferred to be inverted with WithConsistentBankSign have the opposite direction or sign
+func (r *reconciler) directionMatches(sysTx types.Transaction, bankTx types.BankStatement) bool {
+	inverted := r.invertedBanks[bankTx.BankName]
+	if bankTx.Direction != "" {
+		return (bankTx.Direction == r.systemType(sysTx)) != inverted
+	}
+
+	bankAmount := bankTx.Amount
+	if r.invertBankSign != inverted {
+		bankAmount = -bankAmount
+	}
+	if r.useSignedAmount {
+		return (sysTx.Amount < 0) == (bankAmount < 0)
+	}
+	return r.typeSignRules[sysTx.Type].allows(bankAmount)
+}
+
+// systemType returns the type of the system transaction
+// With signed system amounts it is derived from the sign, negative amounts are DEBIT and others CREDIT
+func (r *reconciler) systemType(sysTx types.Transaction) types.TransactionType {
+	if !r.useSignedAmount {
+		return sysTx.Type
+	}
+	if sysTx.Amount < 0 {
+		return types.TransactionTypeDebit
+	}
+	return types.TransactionTypeCredit
+}
+
+// systemUnits returns the absolute system amount in integer units
+// Negative amounts are accepted when negative system amounts are not rejected, the type or sign gives the direction
+func (r *reconciler) systemUnits(sysTx types.Transaction) int64 {
+	return abs(r.toUnits(sysTx.Amount))
+}
+
+// candidateKey returns the date key bank statements must share with a system transaction to be a candidate
+// Custom matchers and business day windows may match any date, so all bank statements share the empty key
+func (r *reconciler) candidateKey(date time.Time) string {
+	if r.matchesAcrossDates() {
+		return ""
+	}
+	return r.dateKey(date)
+}
+
+// matchesAcrossDates reports whether a match may have different date keys, so matching cannot be sharded by date
+func (r *reconciler) matchesAcrossDates() bool {
+	return r.matcher != nil || r.businessDayWindow > 0
+}
+
+// dateKey formats the date at the configured granularity, dates with equal keys can match
+func (r *reconciler) dateKey(date time.Time) string {
+	switch r.dateGranularity {
+	case DateGranularityMonth:
+		return date.Format("2006-01")
+	case DateGranularityYear:
+		return date.Format("2006")
+	default:
+		return date.Format("2006-01-02")
+	}
+}
+
+// toUnits converts an amount to integer units of the last decimal place, e.g. cents for 2 decimal places
+func (r *reconciler) toUnits(amount float64) int64 {
+	return int64(math.Round(amount * r.pow10))
 }
 
-// Assumes the value is only to 2 decimal places
-func round(value float64) float64 {
-	return math.Round(value*100) / 100
+// fromUnits converts integer units of the last decimal place back to an amount
+func (r *reconciler) fromUnits(units int64) float64 {
+	return float64(units) / r.pow10
 }
 
-// abs returns the absolute value of a float64
-func abs(value float64) float64 {
+// abs returns the absolute value of a number
+func abs[T int | int64 | float64](value T) T {
 	if value < 0 {
 		return -value
 	}
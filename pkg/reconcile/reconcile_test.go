@@ -1,11 +1,16 @@
 package reconcile
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	pkgcsv "reconciliation/pkg/csv"
 	"reconciliation/pkg/types"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -43,6 +48,42 @@ func generateBankStatements(count int) []types.BankStatement {
 	return bankStatements
 }
 
+// generateDatedPairs generates matching transactions and bank statements spread over the given days
+// Every third bank statement is shifted by one day so that some transactions stay unmatched
+func generateDatedPairs(count, days int) ([]types.Transaction, []types.BankStatement) {
+	transactions := make([]types.Transaction, count)
+	bankStatements := make([]types.BankStatement, count)
+
+	for i := 0; i < count; i++ {
+		date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i%days)
+		amount := float64(100 + i%7)
+		txType := types.TransactionTypeCredit
+		bankAmount := amount
+		if i%2 == 0 {
+			txType = types.TransactionTypeDebit
+			bankAmount = -amount
+		}
+		bankDate := date
+		if i%3 == 0 {
+			bankDate = date.AddDate(0, 0, 1)
+		}
+
+		transactions[i] = types.Transaction{
+			TrxID:           fmt.Sprintf("T%06d", i+1),
+			Amount:          amount,
+			Type:            txType,
+			TransactionTime: date.Add(10 * time.Hour),
+		}
+		bankStatements[i] = types.BankStatement{
+			UniqueID: fmt.Sprintf("B%06d", i+1),
+			Amount:   bankAmount,
+			Date:     bankDate,
+		}
+	}
+
+	return transactions, bankStatements
+}
+
 // TestReconcile tests the Reconcile function
 func TestReconcile(t *testing.T) {
 	// Helper functions to create time.Time from string
@@ -104,6 +145,8 @@ func TestReconcile(t *testing.T) {
 				TotalDiscrepancies:   0,
 				TransactionUnmatched: ReconcileUnmatched{
 					TransactionUnmatched: 2,
+					SystemUnmatchedCount: 1,
+					BankUnmatchedCount:   1,
 					SystemUnmatched: []types.Transaction{
 						{
 							TrxID:           "TRX1",
@@ -143,6 +186,47 @@ func TestReconcile(t *testing.T) {
 				TransactionProcessed: 1,
 				TransactionMatched:   1,
 				TotalDiscrepancies:   0.005,
+				NetDiscrepancy:       0.01,
+				TransactionUnmatched: ReconcileUnmatched{
+					TransactionUnmatched: 0,
+					SystemUnmatched:      nil,
+					BankUnmatched:        nil,
+				},
+			},
+		},
+		{
+			name: "Net discrepancy - bank under-reports DEBIT",
+			systemTxs: []types.Transaction{
+				{
+					TrxID:           "TRX1",
+					Amount:          100.00,
+					Type:            "DEBIT",
+					TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+				},
+				{
+					TrxID:           "TRX2",
+					Amount:          50.00,
+					Type:            "CREDIT",
+					TransactionTime: parseDateTime("2024-03-20 11:30:00"),
+				},
+			},
+			bankTxs: []types.BankStatement{
+				{
+					UniqueID: "BANK1",
+					Amount:   -99.99,
+					Date:     parseDate("2024-03-20"),
+				},
+				{
+					UniqueID: "BANK2",
+					Amount:   49.99,
+					Date:     parseDate("2024-03-20"),
+				},
+			},
+			expectedResult: ReconcileResult{
+				TransactionProcessed: 2,
+				TransactionMatched:   2,
+				TotalDiscrepancies:   0.02,
+				NetDiscrepancy:       -0.02,
 				TransactionUnmatched: ReconcileUnmatched{
 					TransactionUnmatched: 0,
 					SystemUnmatched:      nil,
@@ -417,6 +501,22 @@ func TestReconcile(t *testing.T) {
 						},
 					},
 				},
+				SignMismatches: []MatchedPair{
+					{
+						System: types.Transaction{
+							TrxID:           "TRX1",
+							Amount:          100.00,
+							Type:            "DEBIT",
+							TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+						},
+						Bank: types.BankStatement{
+							UniqueID: "BANK1",
+							Amount:   100.00,
+							Date:     parseDate("2024-03-20"),
+							BankName: "BankA",
+						},
+					},
+				},
 			},
 		},
 	}
@@ -431,12 +531,14 @@ func TestReconcile(t *testing.T) {
 			assert.Equal(t, tt.expectedResult.TransactionProcessed, result.TransactionProcessed)
 			assert.Equal(t, tt.expectedResult.TransactionMatched, result.TransactionMatched)
 			assert.InDelta(t, tt.expectedResult.TotalDiscrepancies, result.TotalDiscrepancies, amountTolerance)
+			assert.InDelta(t, tt.expectedResult.NetDiscrepancy, result.NetDiscrepancy, amountTolerance)
 			assert.Equal(t, tt.expectedResult.TransactionUnmatched.TransactionUnmatched,
 				result.TransactionUnmatched.TransactionUnmatched)
 			assert.Equal(t, tt.expectedResult.TransactionUnmatched.SystemUnmatched,
 				result.TransactionUnmatched.SystemUnmatched)
 			assert.Equal(t, tt.expectedResult.TransactionUnmatched.BankUnmatched,
 				result.TransactionUnmatched.BankUnmatched)
+			assert.Equal(t, tt.expectedResult.SignMismatches, result.SignMismatches)
 		})
 	}
 }
@@ -515,7 +617,7 @@ func TestIsMatch(t *testing.T) {
 			expected: true,
 		},
 		{
-			name: "Negative amount transactions",
+			name: "Negative amount transactions match by absolute value",
 			sysTx: types.Transaction{
 				Amount:          -100.00,
 				Type:            "DEBIT",
@@ -525,7 +627,7 @@ func TestIsMatch(t *testing.T) {
 				Amount: -100.00,
 				Date:   parseDate("2024-03-20"),
 			},
-			expected: false,
+			expected: true,
 		},
 		{
 			name: "Amount within tolerance (upper bound)",
@@ -611,7 +713,222 @@ func TestIsMatch(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Call the isMatch function
-			result := isMatch(tt.sysTx, tt.bankTx)
+			result := newReconciler().isMatch(tt.sysTx, tt.bankTx)
+
+			// Check if the result matches the expected result
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// TestIsMatch_WithInvertBankSign tests the isMatch function with an inverted bank sign
+func TestIsMatch_WithInvertBankSign(t *testing.T) {
+	// Define helper function to parse date and time
+	parseDateTime := func(date string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04:05", date)
+		return t
+	}
+
+	// Define helper function to parse date
+	parseDate := func(date string) time.Time {
+		t, _ := time.Parse("2006-01-02", date)
+		return t
+	}
+
+	// Define test cases
+	tests := []struct {
+		name     string
+		sysTx    types.Transaction
+		bankTx   types.BankStatement
+		expected bool
+	}{
+		{
+			name: "DEBIT transaction match",
+			sysTx: types.Transaction{
+				Amount:          100.00,
+				Type:            "DEBIT",
+				TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+			},
+			bankTx: types.BankStatement{
+				Amount: 100.00,
+				Date:   parseDate("2024-03-20"),
+			},
+			expected: true,
+		},
+		{
+			name: "CREDIT transaction match",
+			sysTx: types.Transaction{
+				Amount:          100.00,
+				Type:            "CREDIT",
+				TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+			},
+			bankTx: types.BankStatement{
+				Amount: -100.00,
+				Date:   parseDate("2024-03-20"),
+			},
+			expected: true,
+		},
+		{
+			name: "DEBIT transaction with wrong sign",
+			sysTx: types.Transaction{
+				Amount:          100.00,
+				Type:            "DEBIT",
+				TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+			},
+			bankTx: types.BankStatement{
+				Amount: -100.00, // Should be positive for inverted DEBIT
+				Date:   parseDate("2024-03-20"),
+			},
+			expected: false,
+		},
+		{
+			name: "CREDIT transaction with wrong sign",
+			sysTx: types.Transaction{
+				Amount:          100.00,
+				Type:            "CREDIT",
+				TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+			},
+			bankTx: types.BankStatement{
+				Amount: 100.00, // Should be negative for inverted CREDIT
+				Date:   parseDate("2024-03-20"),
+			},
+			expected: false,
+		},
+		{
+			name: "Direction column is not inverted",
+			sysTx: types.Transaction{
+				Amount:          100.00,
+				Type:            "DEBIT",
+				TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+			},
+			bankTx: types.BankStatement{
+				Amount:    100.00,
+				Date:      parseDate("2024-03-20"),
+				Direction: types.TransactionTypeDebit,
+			},
+			expected: true,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the isMatch function with an inverted bank sign
+			result := newReconciler(WithInvertBankSign(true)).isMatch(tt.sysTx, tt.bankTx)
+
+			// Check if the result matches the expected result
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// TestIsMatch_WithTypeSignRules tests the isMatch function with custom type sign rules
+func TestIsMatch_WithTypeSignRules(t *testing.T) {
+	// Define helper function to parse date and time
+	parseDateTime := func(date string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04:05", date)
+		return t
+	}
+
+	// Define helper function to parse date
+	parseDate := func(date string) time.Time {
+		t, _ := time.Parse("2006-01-02", date)
+		return t
+	}
+
+	// Define the custom rules
+	rules := map[types.TransactionType]Sign{
+		"REFUND": SignPositive,
+		"FEE":    SignNegative,
+		"ADJUST": SignEither,
+	}
+
+	// Define test cases
+	tests := []struct {
+		name     string
+		rules    map[types.TransactionType]Sign
+		txType   types.TransactionType
+		amount   float64
+		expected bool
+	}{
+		{
+			name:     "REFUND with positive bank amount",
+			rules:    rules,
+			txType:   "REFUND",
+			amount:   100.00,
+			expected: true,
+		},
+		{
+			name:     "REFUND with negative bank amount",
+			rules:    rules,
+			txType:   "REFUND",
+			amount:   -100.00,
+			expected: false,
+		},
+		{
+			name:     "FEE with negative bank amount",
+			rules:    rules,
+			txType:   "FEE",
+			amount:   -100.00,
+			expected: true,
+		},
+		{
+			name:     "FEE with positive bank amount",
+			rules:    rules,
+			txType:   "FEE",
+			amount:   100.00,
+			expected: false,
+		},
+		{
+			name:     "ADJUST with either sign",
+			rules:    rules,
+			txType:   "ADJUST",
+			amount:   -100.00,
+			expected: true,
+		},
+		{
+			name:     "Unknown type without rule accepts either sign",
+			rules:    rules,
+			txType:   "UNKNOWN",
+			amount:   -100.00,
+			expected: true,
+		},
+		{
+			name:     "Default DEBIT rule is kept",
+			rules:    rules,
+			txType:   types.TransactionTypeDebit,
+			amount:   100.00,
+			expected: false,
+		},
+		{
+			name:     "Override default DEBIT rule",
+			rules:    map[types.TransactionType]Sign{types.TransactionTypeDebit: SignEither},
+			txType:   types.TransactionTypeDebit,
+			amount:   100.00,
+			expected: true,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create the reconciler with the custom rules
+			r := newReconciler(WithTypeSignRules(tt.rules))
+
+			// Call the isMatch function
+			result := r.isMatch(
+				types.Transaction{
+					TrxID:           "TRX1",
+					Amount:          100.00,
+					Type:            tt.txType,
+					TransactionTime: parseDateTime("2024-03-20 10:30:00"),
+				},
+				types.BankStatement{
+					UniqueID: "BANK1",
+					Amount:   tt.amount,
+					Date:     parseDate("2024-03-20"),
+				},
+			)
 
 			// Check if the result matches the expected result
 			assert.Equal(t, tt.expected, result)
@@ -619,6 +936,29 @@ func TestIsMatch(t *testing.T) {
 	}
 }
 
+// TestReconcile_WithTypeSignRules tests the Reconcile function with a custom REFUND rule
+func TestReconcile_WithTypeSignRules(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: "REFUND", TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: -100.00, Date: date},
+		{UniqueID: "BANK2", Amount: 100.00, Date: date},
+	}
+
+	// Reconcile with the custom REFUND rule
+	result := Reconcile(systemTxs, bankTxs, WithTypeSignRules(map[types.TransactionType]Sign{
+		"REFUND": SignPositive,
+	}))
+
+	// Check the REFUND matched the positive bank statement
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[0]}, result.TransactionUnmatched.BankUnmatched)
+}
+
 // TestReconcileResult_String tests the String method of ReconcileResult
 func TestReconcileResult_String(t *testing.T) {
 	// Define helper function to parse date and time
@@ -654,7 +994,11 @@ func TestReconcileResult_String(t *testing.T) {
 				"Total transactions processed: 0\n" +
 				"Total matched transactions: 0\n" +
 				"Total unmatched transactions: 0\n" +
-				"\nTotal amount discrepancies: 0.00\n",
+				"- Unmatched system transactions: 0\n" +
+				"- Unmatched bank statements: 0\n" +
+				"\nTotal amount discrepancies: 0.00\n" +
+				"Net amount discrepancies: 0.00\n" +
+				"Control totals: System: 0.00, Bank: 0.00, Difference: 0.00\n",
 		},
 		{
 			name: "Result with unmatched transactions",
@@ -663,6 +1007,8 @@ func TestReconcileResult_String(t *testing.T) {
 				TransactionMatched:   1,
 				TransactionUnmatched: ReconcileUnmatched{
 					TransactionUnmatched: 2,
+					SystemUnmatchedCount: 1,
+					BankUnmatchedCount:   1,
 					SystemUnmatched: []types.Transaction{
 						{
 							TrxID:           "TRX1",
@@ -681,18 +1027,23 @@ func TestReconcileResult_String(t *testing.T) {
 					},
 				},
 				TotalDiscrepancies: 0.50,
+				NetDiscrepancy:     -0.50,
 			},
 			expectedOutput: "Reconciliation Summary:\n" +
 				"------------------------\n" +
 				"Total transactions processed: 3\n" +
 				"Total matched transactions: 1\n" +
 				"Total unmatched transactions: 2\n" +
+				"- Unmatched system transactions: 1\n" +
+				"- Unmatched bank statements: 1\n" +
 				"\nSystem transactions missing from bank statements:\n" +
 				"- TrxID: TRX1, Amount: 100.00, Type: CREDIT, Date: 2024-03-20 10:30:00\n" +
 				"\nBank statements missing from system transactions:\n" +
 				"\nBank: BankA\n" +
 				"- ID: BANK1, Amount: 200.00, Date: 2024-03-20\n" +
-				"\nTotal amount discrepancies: 0.50\n",
+				"\nTotal amount discrepancies: 0.50\n" +
+				"Net amount discrepancies: -0.50\n" +
+				"Control totals: System: 0.00, Bank: 0.00, Difference: 0.00\n",
 		},
 	}
 
@@ -708,27 +1059,259 @@ func TestReconcileResult_String(t *testing.T) {
 	}
 }
 
-// TestReconcileResult_GenerateJSON tests the GenerateJSON method of ReconcileResult
-func TestReconcileResult_GenerateJSON(t *testing.T) {
-	// Define helper function to parse date and time
-	parseDateTime := func(date string) time.Time {
-		t, _ := time.Parse("2006-01-02 15:04:05", date)
-		return t
+// TestReconcileResult_EncodeJSON tests encoding the result to a writer
+func TestReconcileResult_EncodeJSON(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the result
+	result := ReconcileResult{
+		TransactionProcessed: 1,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 2,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BANK1", Amount: 200.00, Date: date},
+			},
+		},
 	}
 
-	// Define helper function to parse date
-	parseDate := func(date string) time.Time {
-		t, _ := time.Parse("2006-01-02", date)
-		return t
+	// Encode the result to a buffer
+	var buf bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&buf))
+
+	// Check the output is indented and holds the unmatched details
+	assert.True(t, strings.HasPrefix(buf.String(), "{\n  \"summary\": {\n"))
+	var decoded jsonResult
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, 1, decoded.Summary.TotalTransactionsProcessed)
+	assert.Len(t, decoded.UnmatchedDetails.SystemTransactions, 1)
+	assert.Len(t, decoded.UnmatchedDetails.BankStatements["BRI"], 1)
+
+	// The file written by GenerateJSON has the same content
+	filename := filepath.Join(t.TempDir(), "result.json")
+	assert.NoError(t, result.GenerateJSON(filename))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.String(), string(data))
+}
+
+// TestReconcileResult_EncodeJSON_KeyStyle tests the snake_case and camelCase JSON key styles
+func TestReconcileResult_EncodeJSON_KeyStyle(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the result with data keys that must not be renamed
+	result := ReconcileResult{
+		TransactionProcessed: 1,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 2,
+			SystemUnmatchedCount: 1,
+			BankUnmatchedCount:   1,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX_1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BANK_BRI", UniqueID: "BANK1", Amount: 200.00, Date: date},
+			},
+		},
+		FilteredRows: map[string]int{"bank_bri.csv": 3},
 	}
 
-	// Define test cases
-	tests := []struct {
-		name            string
-		reconcileResult ReconcileResult
-		expectedError   bool
-		validateJSON    func(t *testing.T, filename string)
-	}{
+	// The snake style is the default output
+	var snake, defaults bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&snake, WithKeyStyle(KeyStyleSnake)))
+	assert.NoError(t, result.EncodeJSON(&defaults))
+	assert.Equal(t, defaults.String(), snake.String())
+	assert.Contains(t, snake.String(), `"total_transactions_processed": 1`)
+	assert.Contains(t, snake.String(), `"system_transactions"`)
+
+	// The camel style renames the field names only
+	var camel bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&camel, WithKeyStyle(KeyStyleCamel)))
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(camel.Bytes(), &decoded))
+	assert.Equal(t, map[string]any{
+		"totalTransactionsProcessed": 1.0,
+		"totalTransactionsMatched":   0.0,
+		"matchedById":                0.0,
+		"matchedByHeuristic":         0.0,
+		"exactMatches":               0.0,
+		"toleranceMatches":           0.0,
+		"totalTransactionsUnmatched": 2.0,
+		"systemUnmatchedCount":       1.0,
+		"bankUnmatchedCount":         1.0,
+		"totalDiscrepancies":         0.0,
+		"netDiscrepancy":             0.0,
+		"systemTotal":                0.0,
+		"bankTotal":                  0.0,
+		"totalDifference":            0.0,
+	}, decoded["summary"])
+	assert.Equal(t, map[string]any{
+		"systemTransactions": []any{map[string]any{
+			"trxID": "TRX_1", "amount": 100.0, "type": "CREDIT", "transactionTime": "2024-03-20T00:00:00Z",
+		}},
+		"bankStatements": map[string]any{"BANK_BRI": []any{map[string]any{
+			"bankName": "BANK_BRI", "uniqueID": "BANK1", "amount": 200.0, "date": "2024-03-20T00:00:00Z", "direction": "",
+		}}},
+	}, decoded["unmatchedDetails"])
+	assert.Equal(t, map[string]any{"bank_bri.csv": 3.0}, decoded["filteredRows"])
+	assert.NotContains(t, decoded, "signMismatches")
+
+	// The fields keep their order and indentation
+	assert.True(t, strings.HasPrefix(camel.String(), "{\n  \"summary\": {\n    \"totalTransactionsProcessed\": 1,\n"))
+
+	// The JSON summary line uses the style too
+	var line bytes.Buffer
+	assert.NoError(t, result.WriteSummaryJSON(&line, WithKeyStyle(KeyStyleCamel)))
+	assert.True(t, strings.HasPrefix(line.String(), `{"totalTransactionsProcessed":1,"totalTransactionsMatched":0,`))
+}
+
+// TestReconcileResult_GenerateJSON_SummaryOnly tests writing a JSON file without the unmatched details
+func TestReconcileResult_GenerateJSON_SummaryOnly(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the result with transaction details
+	result := ReconcileResult{
+		TransactionProcessed: 2,
+		TransactionMatched:   1,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 2,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BANK1", Amount: 200.00, Date: date},
+			},
+		},
+		Suggestions: map[string]Suggestion{"TRX1": {Bank: types.BankStatement{UniqueID: "BANK1"}}},
+	}
+
+	// Generate the JSON file with only the summary
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	assert.NoError(t, result.GenerateJSON(filename, WithSummaryOnly(true)))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+
+	// Check the summary is the only key
+	var decoded map[string]map[string]any
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Len(t, decoded, 1)
+	assert.NotContains(t, decoded, "unmatched_details")
+	assert.Equal(t, 2.0, decoded["summary"]["total_transactions_processed"])
+	assert.Equal(t, 2.0, decoded["summary"]["total_transactions_unmatched"])
+	assert.NotContains(t, string(data), "TRX1")
+
+	// The key style applies to the summary
+	var camel bytes.Buffer
+	assert.NoError(t, result.EncodeJSON(&camel, WithSummaryOnly(true), WithKeyStyle(KeyStyleCamel)))
+	assert.Contains(t, camel.String(), `"totalTransactionsProcessed": 2`)
+}
+
+// TestReconcileResult_GenerateNDJSON tests writing one JSON line per unmatched item
+func TestReconcileResult_GenerateNDJSON(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the result
+	result := ReconcileResult{
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 3,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+				{TrxID: "TRX2", Amount: 50.00, Type: "DEBIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BANK1", Amount: 200.00, Date: date},
+			},
+		},
+	}
+
+	// Generate the NDJSON file
+	filename := filepath.Join(t.TempDir(), "unmatched.ndjson")
+	assert.NoError(t, result.GenerateNDJSON(filename))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+
+	// Check each line parses on its own
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Len(t, lines, 3)
+	records := make([]ndjsonRecord, len(lines))
+	for i, line := range lines {
+		assert.NoError(t, json.Unmarshal([]byte(line), &records[i]), "line %d", i+1)
+	}
+
+	// Check the records are tagged with their source
+	assert.Equal(t, "system", records[0].Source)
+	assert.Equal(t, result.TransactionUnmatched.SystemUnmatched[0], *records[0].Transaction)
+	assert.Nil(t, records[0].BankStatement)
+	assert.Equal(t, "system", records[1].Source)
+	assert.Equal(t, "TRX2", records[1].Transaction.TrxID)
+	assert.Equal(t, "bank", records[2].Source)
+	assert.Equal(t, result.TransactionUnmatched.BankUnmatched[0], *records[2].BankStatement)
+	assert.Nil(t, records[2].Transaction)
+
+	// Check the camel key style
+	var buf bytes.Buffer
+	assert.NoError(t, result.EncodeNDJSON(&buf, WithKeyStyle(KeyStyleCamel)))
+	assert.Contains(t, buf.String(), `{"source":"bank","bankStatement":{"bankName":"BRI",`)
+
+	// Nothing is written without unmatched items
+	buf.Reset()
+	assert.NoError(t, (&ReconcileResult{}).EncodeNDJSON(&buf))
+	assert.Empty(t, buf.String())
+}
+
+// TestReconcile_RunID tests propagating the run ID to the result and the JSON summary
+func TestReconcile_RunID(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	system := []types.Transaction{{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date}}
+	bank := []types.BankStatement{{UniqueID: "BS001", Amount: 100.00, Date: date, BankName: "BRI"}}
+
+	// The run ID is set in the result and written in the JSON summary
+	result := Reconcile(system, bank, WithRunID("run-1"))
+	assert.Equal(t, "run-1", result.RunID)
+	filename := filepath.Join(t.TempDir(), "result.json")
+	assert.NoError(t, result.GenerateJSON(filename))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	var decoded map[string]map[string]any
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "run-1", decoded["summary"]["run_id"])
+
+	// The run ID is loaded back and an appended run keeps the latest run ID
+	next := Reconcile(system, bank, WithRunID("run-2"))
+	assert.NoError(t, next.AppendJSON(filename))
+	loaded, err := LoadJSON(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "run-2", loaded.RunID)
+
+	// Without a run ID the key is omitted
+	var buf bytes.Buffer
+	assert.NoError(t, (&ReconcileResult{}).EncodeJSON(&buf))
+	assert.NotContains(t, buf.String(), "run_id")
+}
+
+// TestReconcileResult_GenerateJSON tests the GenerateJSON method of ReconcileResult
+func TestReconcileResult_GenerateJSON(t *testing.T) {
+	// Define helper function to parse date and time
+	parseDateTime := func(date string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04:05", date)
+		return t
+	}
+
+	// Define helper function to parse date
+	parseDate := func(date string) time.Time {
+		t, _ := time.Parse("2006-01-02", date)
+		return t
+	}
+
+	// Define test cases
+	tests := []struct {
+		name            string
+		reconcileResult ReconcileResult
+		expectedError   bool
+		validateJSON    func(t *testing.T, filename string)
+	}{
 		{
 			name: "Generate JSON with complete data",
 			reconcileResult: ReconcileResult{
@@ -754,6 +1337,7 @@ func TestReconcileResult_GenerateJSON(t *testing.T) {
 					},
 				},
 				TotalDiscrepancies: 0.50,
+				NetDiscrepancy:     -0.50,
 			},
 			expectedError: false,
 			validateJSON: func(t *testing.T, filename string) {
@@ -770,6 +1354,7 @@ func TestReconcileResult_GenerateJSON(t *testing.T) {
 				assert.Equal(t, float64(1), summary["total_transactions_matched"])
 				assert.Equal(t, float64(2), summary["total_transactions_unmatched"])
 				assert.Equal(t, 0.50, summary["total_discrepancies"])
+				assert.Equal(t, -0.50, summary["net_discrepancy"])
 
 				unmatchedDetails, ok := result["unmatched_details"].(map[string]interface{})
 				assert.True(t, ok)
@@ -808,6 +1393,7 @@ func TestReconcileResult_GenerateJSON(t *testing.T) {
 				assert.Equal(t, float64(0), summary["total_transactions_matched"])
 				assert.Equal(t, float64(0), summary["total_transactions_unmatched"])
 				assert.Equal(t, float64(0), summary["total_discrepancies"])
+				assert.Equal(t, float64(0), summary["net_discrepancy"])
 			},
 		},
 	}
@@ -834,3 +1420,1608 @@ func TestReconcileResult_GenerateJSON(t *testing.T) {
 		})
 	}
 }
+
+// TestReconcileResult_AppendJSON tests merging two reconciliations into the same JSON file
+func TestReconcileResult_AppendJSON(t *testing.T) {
+	day1 := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 3, 21, 0, 0, 0, 0, time.UTC)
+	filename := filepath.Join(t.TempDir(), "report.json")
+
+	// Reconcile the first day, TRX2 and BANK2 are unmatched
+	first := Reconcile(
+		[]types.Transaction{
+			{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: day1},
+			{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: day1},
+		},
+		[]types.BankStatement{
+			{BankName: "BRI", UniqueID: "BANK1", Amount: 100.00, Date: day1},
+			{BankName: "BRI", UniqueID: "BANK2", Amount: 250.00, Date: day1},
+		},
+		WithSuggestionWindow(0),
+	)
+	assert.NoError(t, first.AppendJSON(filename))
+
+	// Reconcile the second day, TRX2 and BANK2 are reported again and BANK3 is new
+	second := Reconcile(
+		[]types.Transaction{
+			{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: day1},
+			{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: day2},
+		},
+		[]types.BankStatement{
+			{BankName: "BRI", UniqueID: "BANK2", Amount: 250.00, Date: day1},
+			{BankName: "BRI", UniqueID: "BANK3", Amount: 300.00, Date: day2},
+			{BankName: "BCA", UniqueID: "BANK4", Amount: 400.00, Date: day2},
+		},
+		WithSuggestionWindow(0),
+	)
+	assert.NoError(t, second.AppendJSON(filename))
+
+	// Read the merged file
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	var result jsonResult
+	assert.NoError(t, json.Unmarshal(data, &result))
+
+	// Match counts are summed across runs, unmatched counts are those of the merged lists
+	assert.Equal(t, 4, result.Summary.TotalTransactionsProcessed)
+	assert.Equal(t, 2, result.Summary.TotalTransactionsMatched)
+	assert.Equal(t, 3, result.Summary.TotalTransactionsUnmatched)
+	assert.Equal(t, 1, result.Summary.SystemUnmatchedCount)
+	assert.Equal(t, 2, result.Summary.BankUnmatchedCount)
+	assert.InDelta(t, first.TotalDiscrepancies+second.TotalDiscrepancies, result.Summary.TotalDiscrepancies, 0.001)
+
+	// Unmatched details are deduplicated
+	assert.Len(t, result.UnmatchedDetails.SystemTransactions, 1)
+	assert.Equal(t, "TRX2", result.UnmatchedDetails.SystemTransactions[0].TrxID)
+	assert.Len(t, result.UnmatchedDetails.BankStatements["BRI"], 1)
+	assert.Equal(t, "BANK2", result.UnmatchedDetails.BankStatements["BRI"][0].UniqueID)
+	assert.Len(t, result.UnmatchedDetails.BankStatements["BCA"], 1)
+
+	// Reconcile a late statement for TRX2 and BANK4's transaction, both are no longer unmatched
+	third := Reconcile(
+		[]types.Transaction{
+			{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: day1},
+			{TrxID: "TRX4", Amount: 400.00, Type: types.TransactionTypeCredit, TransactionTime: day2},
+		},
+		[]types.BankStatement{
+			{BankName: "BRI", UniqueID: "BANK5", Amount: 200.00, Date: day1},
+			{BankName: "BCA", UniqueID: "BANK4", Amount: 400.00, Date: day2},
+		},
+		WithSuggestionWindow(0),
+	)
+	assert.NoError(t, third.AppendJSON(filename))
+	data, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	result = jsonResult{}
+	assert.NoError(t, json.Unmarshal(data, &result))
+	assert.Empty(t, result.UnmatchedDetails.SystemTransactions)
+	assert.Equal(t, map[string][]types.BankStatement{"BRI": result.UnmatchedDetails.BankStatements["BRI"]},
+		result.UnmatchedDetails.BankStatements)
+	assert.Equal(t, "BANK2", result.UnmatchedDetails.BankStatements["BRI"][0].UniqueID)
+	assert.Equal(t, 4, result.Summary.TotalTransactionsMatched)
+	assert.Equal(t, 1, result.Summary.TotalTransactionsUnmatched)
+	assert.Equal(t, 0, result.Summary.SystemUnmatchedCount)
+	assert.Equal(t, 1, result.Summary.BankUnmatchedCount)
+
+	// Appending to an invalid file fails
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, []byte("not json"), 0o644))
+	assert.Error(t, first.AppendJSON(invalid))
+}
+
+// TestReconcileResult_GenerateJSONPerBank tests the GenerateJSONPerBank method of ReconcileResult
+func TestReconcileResult_GenerateJSONPerBank(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define test cases
+	tests := []struct {
+		name            string
+		reconcileResult ReconcileResult
+		expectedFiles   map[string]int
+	}{
+		{
+			name: "Multiple banks",
+			reconcileResult: ReconcileResult{
+				TransactionProcessed: 2,
+				TransactionUnmatched: ReconcileUnmatched{
+					TransactionUnmatched: 4,
+					SystemUnmatched: []types.Transaction{
+						{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+					},
+					BankUnmatched: []types.BankStatement{
+						{BankName: "BRI", UniqueID: "BANK1", Amount: 200.00, Date: date},
+						{BankName: "BRI", UniqueID: "BANK2", Amount: 300.00, Date: date},
+						{BankName: "BANK MANDIRI", UniqueID: "BANK3", Amount: 400.00, Date: date},
+					},
+				},
+			},
+			expectedFiles: map[string]int{
+				"bri.json":          2,
+				"bank_mandiri.json": 1,
+			},
+		},
+		{
+			name: "Colliding bank names",
+			reconcileResult: ReconcileResult{
+				TransactionUnmatched: ReconcileUnmatched{
+					TransactionUnmatched: 3,
+					BankUnmatched: []types.BankStatement{
+						{BankName: "BANK_A", UniqueID: "BANK1", Amount: 200.00, Date: date},
+						{BankName: "BANK A", UniqueID: "BANK2", Amount: 300.00, Date: date},
+						{BankName: "BANK A", UniqueID: "BANK3", Amount: 400.00, Date: date},
+					},
+				},
+			},
+			expectedFiles: map[string]int{
+				"bank_a.json":   2,
+				"bank_a_2.json": 1,
+			},
+		},
+		{
+			name: "Empty bank name",
+			reconcileResult: ReconcileResult{
+				TransactionUnmatched: ReconcileUnmatched{
+					TransactionUnmatched: 1,
+					BankUnmatched: []types.BankStatement{
+						{UniqueID: "BANK1", Amount: 200.00, Date: date},
+					},
+				},
+			},
+			expectedFiles: map[string]int{
+				"unknown.json": 1,
+			},
+		},
+		{
+			name: "No unmatched bank statements",
+			reconcileResult: ReconcileResult{
+				TransactionUnmatched: ReconcileUnmatched{
+					TransactionUnmatched: 1,
+					SystemUnmatched: []types.Transaction{
+						{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+					},
+				},
+			},
+			expectedFiles: map[string]int{},
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a temporary directory
+			dir := filepath.Join(t.TempDir(), "banks")
+
+			// Call the GenerateJSONPerBank method
+			err := tt.reconcileResult.GenerateJSONPerBank(dir)
+			assert.NoError(t, err)
+
+			// Check the generated files
+			files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+			assert.Len(t, files, len(tt.expectedFiles))
+
+			for filename, count := range tt.expectedFiles {
+				data, err := os.ReadFile(filepath.Join(dir, filename))
+				assert.NoError(t, err)
+
+				var result jsonResult
+				err = json.Unmarshal(data, &result)
+				assert.NoError(t, err)
+
+				// Each file holds only one bank plus the shared system transactions
+				assert.Len(t, result.UnmatchedDetails.BankStatements, 1)
+				for _, statements := range result.UnmatchedDetails.BankStatements {
+					assert.Len(t, statements, count)
+				}
+				assert.Len(t, result.UnmatchedDetails.SystemTransactions,
+					len(tt.reconcileResult.TransactionUnmatched.SystemUnmatched))
+			}
+		})
+	}
+}
+
+// TestReconcileResult_GenerateJSONPerBank_NoOtherBanks tests a bank file holds no statements of another bank
+func TestReconcileResult_GenerateJSONPerBank_NoOtherBanks(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	system := types.Transaction{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date}
+	bankA := types.BankStatement{BankName: "BANK A", UniqueID: "A_STMT", Amount: 100.00, Date: date}
+	bankB := types.BankStatement{BankName: "BANK B", UniqueID: "B_STMT", Amount: 100.00, Date: date}
+
+	// Fill every section with an item of each bank
+	result := ReconcileResult{
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 2,
+			BankUnmatched:        []types.BankStatement{bankA, bankB},
+		},
+		SignMismatches:        []MatchedPair{{System: system, Bank: bankA}, {System: system, Bank: bankB}},
+		CurrencyMismatches:    []MatchedPair{{System: system, Bank: bankA}, {System: system, Bank: bankB}},
+		CarriedForwardMatches: []MatchedPair{{System: system, Bank: bankA}, {System: system, Bank: bankB}},
+		DuplicateBankIDs: []DuplicateBankID{
+			{BankName: "BANK A", UniqueID: "A_DUP", Occurrences: 2},
+			{BankName: "BANK B", UniqueID: "B_DUP", Occurrences: 2},
+		},
+		Ambiguities: []AmbiguityRecord{
+			{TrxID: "TRX1", BankName: "BANK A", Chosen: "A_STMT",
+				Candidates: []string{"A_STMT", "B_STMT"}, CandidateBanks: []string{"BANK A", "BANK B"}},
+			{TrxID: "TRX2", BankName: "BANK B", Chosen: "B_STMT",
+				Candidates: []string{"B_STMT", "A_STMT"}, CandidateBanks: []string{"BANK B", "BANK A"}},
+		},
+		SplitMatches:    []SplitMatch{{System: system, Bank: []types.BankStatement{bankA}}, {System: system, Bank: []types.BankStatement{bankB}}},
+		ReferenceGroups: []ReferenceGroup{{Reference: "A_STMT", Bank: bankA}, {Reference: "B_STMT", Bank: bankB}},
+		Suggestions:     map[string]Suggestion{"TRX1": {Bank: bankA}, "TRX2": {Bank: bankB}},
+	}
+
+	dir := t.TempDir()
+	assert.NoError(t, result.GenerateJSONPerBank(dir))
+
+	// The file of bank A mentions none of the UniqueIDs of bank B
+	data, err := os.ReadFile(filepath.Join(dir, "bank_a.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "A_STMT")
+	assert.Contains(t, string(data), "A_DUP")
+	assert.NotContains(t, string(data), "B_STMT")
+	assert.NotContains(t, string(data), "B_DUP")
+	assert.NotContains(t, string(data), "BANK B")
+
+	// Every section of bank A is kept
+	var decoded jsonResult
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Len(t, decoded.SignMismatches, 1)
+	assert.Len(t, decoded.CurrencyMismatches, 1)
+	assert.Len(t, decoded.CarriedForward, 1)
+	assert.Len(t, decoded.DuplicateBankIDs, 1)
+	assert.Equal(t, []AmbiguityRecord{{TrxID: "TRX1", BankName: "BANK A", Chosen: "A_STMT",
+		Candidates: []string{"A_STMT"}, CandidateBanks: []string{"BANK A"}}}, decoded.Ambiguities)
+	assert.Len(t, decoded.SplitMatches, 1)
+	assert.Len(t, decoded.ReferenceGroups, 1)
+	assert.Len(t, decoded.Suggestions, 1)
+}
+
+// failingWriter is an io.Writer that always fails
+type failingWriter struct{}
+
+// Write always returns an error
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("write failed")
+}
+
+// TestReconcileResult_WriteSummary tests the WriteSummary method of ReconcileResult
+func TestReconcileResult_WriteSummary(t *testing.T) {
+	date := time.Date(2024, 3, 20, 10, 30, 0, 0, time.UTC)
+
+	// Define the result
+	result := ReconcileResult{
+		TransactionProcessed: 1,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 1,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+			},
+		},
+	}
+
+	// Write the summary to a buffer
+	var buf bytes.Buffer
+	err := result.WriteSummary(&buf)
+	assert.NoError(t, err)
+
+	// Check the buffer matches the String output
+	assert.Equal(t, result.String(), buf.String())
+	assert.Contains(t, buf.String(), "- TrxID: TRX1, Amount: 100.00, Type: CREDIT, Date: 2024-03-20 10:30:00\n")
+
+	// Check the write error is returned
+	err = result.WriteSummary(failingWriter{})
+	assert.EqualError(t, err, "failed to write summary: write failed")
+}
+
+// TestReconcile_Concurrent tests the concurrent Reconcile returns the same result as the sequential one
+func TestReconcile_Concurrent(t *testing.T) {
+	// Define test cases
+	tests := []struct {
+		name    string
+		count   int
+		days    int
+		workers int
+	}{
+		{name: "Single day", count: 500, days: 1, workers: 4},
+		{name: "Multiple days", count: 2000, days: 31, workers: 4},
+		{name: "More workers than days", count: 100, days: 3, workers: 8},
+		{name: "Empty input", count: 0, days: 1, workers: 4},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			systemTxs, bankTxs := generateDatedPairs(tt.count, tt.days)
+
+			// Reconcile sequentially and concurrently
+			expected := Reconcile(systemTxs, bankTxs)
+			result := Reconcile(systemTxs, bankTxs, WithWorkers(tt.workers))
+
+			// Check the results are identical
+			assert.Equal(t, expected, result)
+		})
+	}
+}
+
+// TestReconcile_Concurrent_DuplicateBankIDs tests the concurrent Reconcile with bank IDs repeated on two dates
+func TestReconcile_Concurrent_DuplicateBankIDs(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// Each day has two transactions, a statement whose ID the next day repeats, the repeat of the previous day and its own statement
+	var systemTxs []types.Transaction
+	var bankTxs []types.BankStatement
+	for day := 0; day < 20; day++ {
+		date := start.AddDate(0, 0, day)
+		for n := 0; n < 2; n++ {
+			systemTxs = append(systemTxs, types.Transaction{
+				TrxID: fmt.Sprintf("TRX%d_%d", day, n), Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date,
+			})
+		}
+		bankTxs = append(bankTxs,
+			types.BankStatement{BankName: "bca", UniqueID: fmt.Sprintf("DUP%d", day), Amount: 100.00, Date: date},
+			types.BankStatement{BankName: "bca", UniqueID: fmt.Sprintf("DUP%d", day-1), Amount: 100.00, Date: date},
+			types.BankStatement{BankName: "bca", UniqueID: fmt.Sprintf("OWN%d", day), Amount: 100.00, Date: date},
+		)
+	}
+
+	// A claimed ID sends the transaction on to the next candidate, like the sequential matching
+	expected := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 40, expected.TransactionMatched)
+	for _, workers := range []int{2, 4, 8} {
+		for run := 0; run < 10; run++ {
+			assert.Equal(t, expected, Reconcile(systemTxs, bankTxs, WithWorkers(workers)))
+		}
+	}
+}
+
+// BenchmarkReconcile benchmarks the sequential and concurrent Reconcile
+func BenchmarkReconcile(b *testing.B) {
+	systemTxs, bankTxs := generateDatedPairs(5000, 31)
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Reconcile(systemTxs, bankTxs)
+		}
+	})
+
+	b.Run("Concurrent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Reconcile(systemTxs, bankTxs, WithWorkers(4))
+		}
+	})
+}
+
+// TestReconcileResult_String_SignMismatches tests the String method lists sign mismatches
+func TestReconcileResult_String_SignMismatches(t *testing.T) {
+	date := time.Date(2024, 3, 20, 10, 30, 0, 0, time.UTC)
+
+	// Define the result
+	result := ReconcileResult{
+		SignMismatches: []MatchedPair{
+			{
+				System: types.Transaction{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+				Bank:   types.BankStatement{BankName: "BRI", UniqueID: "BANK1", Amount: -100.00, Date: date},
+			},
+		},
+	}
+
+	// Check the sign mismatch is listed
+	assert.Contains(t, result.String(), "\nPossible sign mismatches:\n"+
+		"- TrxID: TRX1, Type: CREDIT, Amount: 100.00 <> Bank: BRI, ID: BANK1, Amount: -100.00, Date: 2024-03-20\n")
+}
+
+// TestReconcile_SignMismatches tests only sign-flipped pairs with the same amount and date are flagged
+func TestReconcile_SignMismatches(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+		{TrxID: "TRX2", Amount: 50.00, Type: "CREDIT", TransactionTime: date},
+		{TrxID: "TRX3", Amount: 70.00, Type: "DEBIT", TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: -100.00, Date: date},
+		{UniqueID: "BANK2", Amount: -60.00, Date: date},
+		{UniqueID: "BANK3", Amount: 70.00, Date: date.AddDate(0, 0, 1)},
+	}
+
+	// Reconcile the transactions
+	result := Reconcile(systemTxs, bankTxs)
+
+	// Check only the same amount and date pair is flagged and it stays unmatched
+	assert.Equal(t, []MatchedPair{{System: systemTxs[0], Bank: bankTxs[0]}}, result.SignMismatches)
+	assert.Equal(t, 6, result.TransactionUnmatched.TransactionUnmatched)
+}
+
+// TestReconcile_CurrencyMismatches tests that items of different currencies do not match and are reported
+func TestReconcile_CurrencyMismatches(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date, Currency: "USD"},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date, Currency: "IDR"},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BANK1", Amount: 100.00, Date: date, Currency: "IDR"},
+		{BankName: "BCA", UniqueID: "BANK2", Amount: 200.00, Date: date, Currency: "idr"},
+		{BankName: "BCA", UniqueID: "BANK3", Amount: 300.00, Date: date, Currency: "USD"},
+	}
+
+	// The same amount in another currency does not match, a missing currency matches any currency
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[0]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[0]}, result.TransactionUnmatched.BankUnmatched)
+
+	// The pair is reported as a currency mismatch, not as a sign mismatch
+	assert.Equal(t, []MatchedPair{{System: systemTxs[0], Bank: bankTxs[0]}}, result.CurrencyMismatches)
+	assert.Empty(t, result.SignMismatches)
+	assert.Contains(t, result.String(), "\nCurrency mismatches:\n- TrxID: TRX1, Amount: 100.00 USD <> Bank: BCA, ID: BANK1, Amount: 100.00 IDR, Date: 2024-03-20\n")
+
+	// A custom matcher cannot match different currencies either
+	result = Reconcile(systemTxs[:1], bankTxs[:1], WithMatcher(MatcherFunc(func(types.Transaction, types.BankStatement) bool { return true })))
+	assert.Equal(t, 0, result.TransactionMatched)
+
+	// The currency mismatches are written to the JSON output and loaded back
+	filename := filepath.Join(t.TempDir(), "result.json")
+	result = Reconcile(systemTxs, bankTxs)
+	assert.NoError(t, result.GenerateJSON(filename))
+	loaded, err := LoadJSON(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, result.CurrencyMismatches, loaded.CurrencyMismatches)
+}
+
+// TestReconcile_WithDecimalPlaces tests the Reconcile function with a configured precision
+func TestReconcile_WithDecimalPlaces(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define test cases
+	tests := []struct {
+		name                string
+		decimalPlaces       int
+		systemAmount        float64
+		bankAmount          float64
+		expectedMatched     int
+		expectedDiscrepancy float64
+	}{
+		{
+			name:                "0 decimals within tolerance",
+			decimalPlaces:       0,
+			systemAmount:        1000,
+			bankAmount:          1001,
+			expectedMatched:     1,
+			expectedDiscrepancy: 1,
+		},
+		{
+			name:                "0 decimals rounds fractions away",
+			decimalPlaces:       0,
+			systemAmount:        1000,
+			bankAmount:          1000.4,
+			expectedMatched:     1,
+			expectedDiscrepancy: 0,
+		},
+		{
+			name:            "0 decimals outside tolerance",
+			decimalPlaces:   0,
+			systemAmount:    1000,
+			bankAmount:      1002,
+			expectedMatched: 0,
+		},
+		{
+			name:                "8 decimals within tolerance",
+			decimalPlaces:       8,
+			systemAmount:        0.12345678,
+			bankAmount:          0.12345679,
+			expectedMatched:     1,
+			expectedDiscrepancy: 0.00000001,
+		},
+		{
+			name:            "8 decimals outside tolerance",
+			decimalPlaces:   8,
+			systemAmount:    0.12345678,
+			bankAmount:      0.12345680,
+			expectedMatched: 0,
+		},
+		{
+			name:                "more than 9 decimals are capped at 9",
+			decimalPlaces:       12,
+			systemAmount:        1000.1234567891,
+			bankAmount:          1000.1234567894,
+			expectedMatched:     1,
+			expectedDiscrepancy: 0,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reconcile with the configured decimal places
+			result := Reconcile(
+				[]types.Transaction{{TrxID: "TRX1", Amount: tt.systemAmount, Type: "CREDIT", TransactionTime: date}},
+				[]types.BankStatement{{UniqueID: "BANK1", Amount: tt.bankAmount, Date: date}},
+				WithDecimalPlaces(tt.decimalPlaces),
+			)
+
+			// Check if the result matches the expected result
+			assert.Equal(t, tt.expectedMatched, result.TransactionMatched)
+			assert.Equal(t, tt.expectedDiscrepancy, result.TotalDiscrepancies)
+		})
+	}
+}
+
+// TestReconcile_FractionalAmounts tests that many fractional amounts reconcile without floating point drift
+func TestReconcile_FractionalAmounts(t *testing.T) {
+	// Build system and bank CSV files with many fractional amounts
+	var systemCSV, bankCSV strings.Builder
+	systemCSV.WriteString("TrxID,Amount,Type,TransactionTime\n")
+	bankCSV.WriteString("UniqueID,Amount,Date\n")
+	for i := 0; i < 1000; i++ {
+		// Bank amounts are written as the float sum of 0.1 and 0.2 steps, e.g. 0.30000000000000004
+		amount := float64(i%10)*0.1 + 0.2
+		fmt.Fprintf(&systemCSV, "T%06d,%.2f,CREDIT,2024-01-01 10:00:00\n", i, amount)
+		fmt.Fprintf(&bankCSV, "B%06d,%s,2024-01-01\n", i, strconv.FormatFloat(amount, 'f', -1, 64))
+	}
+
+	// Parse the CSV files
+	systemTxs, err := pkgcsv.NewCSVReader(csv.NewReader(strings.NewReader(systemCSV.String())),
+		pkgcsv.WithSkipHeader(true)).ReadSystemTransactionsFromCSV()
+	assert.NoError(t, err)
+	bankTxs, err := pkgcsv.NewCSVReader(csv.NewReader(strings.NewReader(bankCSV.String())),
+		pkgcsv.WithSkipHeader(true)).ReadBankStatementsFromCSV()
+	assert.NoError(t, err)
+
+	// Reconcile the transactions
+	result := Reconcile(systemTxs, bankTxs)
+
+	// Check the discrepancies are exactly zero
+	assert.Equal(t, 1000, result.TransactionMatched)
+	assert.Equal(t, 0.0, result.TotalDiscrepancies)
+	assert.Equal(t, 0.0, result.NetDiscrepancy)
+
+	// Shift every bank amount by one cent, the float sum of 1000 cents drifts away from 10
+	for i := range bankTxs {
+		bankTxs[i].Amount += 0.01
+	}
+	result = Reconcile(systemTxs, bankTxs)
+
+	// Check the discrepancies are exact
+	assert.Equal(t, 1000, result.TransactionMatched)
+	assert.Equal(t, 10.0, result.TotalDiscrepancies)
+	assert.Equal(t, 10.0, result.NetDiscrepancy)
+}
+
+// TestReconcileResult_String_SkippedFiles tests the String method lists skipped files
+func TestReconcileResult_String_SkippedFiles(t *testing.T) {
+	// Define the result
+	result := ReconcileResult{
+		SkippedFiles: []SkippedFile{
+			{Filename: "banks/corrupt.csv", Reason: "failed to read CSV file"},
+		},
+	}
+
+	// Check the skipped file is listed
+	assert.Contains(t, result.String(), "\nSkipped files:\n- banks/corrupt.csv: failed to read CSV file\n")
+}
+
+// TestReconcile_DirectionColumn tests matching bank statements with an explicit direction
+func TestReconcile_DirectionColumn(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, bank amounts are always positive
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: date, Direction: types.TransactionTypeDebit},
+		{UniqueID: "BANK2", Amount: 200.00, Date: date, Direction: types.TransactionTypeCredit},
+		{UniqueID: "BANK3", Amount: 300.00, Date: date, Direction: types.TransactionTypeDebit},
+	}
+
+	// Reconcile the transactions
+	result := Reconcile(systemTxs, bankTxs)
+
+	// Check the direction is used instead of the amount sign
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[2]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[2]}, result.TransactionUnmatched.BankUnmatched)
+	assert.Equal(t, []MatchedPair{{System: systemTxs[2], Bank: bankTxs[2]}}, result.SignMismatches)
+}
+
+// TestReconcile_WithIDMatching tests the Reconcile function matching on equal IDs first
+func TestReconcile_WithIDMatching(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, REF1 and REF2 have the same amount and date
+	systemTxs := []types.Transaction{
+		{TrxID: "REF1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+		{TrxID: "REF2", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: "CREDIT", TransactionTime: date},
+		{TrxID: "REF4", Amount: 400.00, Type: "CREDIT", TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "REF2", Amount: 100.00, Date: date},
+		{UniqueID: "REF1", Amount: 100.00, Date: date},
+		{UniqueID: "BANK3", Amount: 300.00, Date: date},
+		{UniqueID: "REF4", Amount: 399.00, Date: date.AddDate(0, 0, 1)},
+	}
+
+	// Define test cases
+	tests := []struct {
+		name                       string
+		opts                       []Option
+		expectedMatched            int
+		expectedMatchedByID        int
+		expectedMatchedByHeuristic int
+		expectedDiscrepancies      float64
+	}{
+		{
+			name:                       "Without ID matching",
+			expectedMatched:            3,
+			expectedMatchedByID:        0,
+			expectedMatchedByHeuristic: 3,
+			expectedDiscrepancies:      0,
+		},
+		{
+			name:                       "With ID matching",
+			opts:                       []Option{WithIDMatching(true)},
+			expectedMatched:            4,
+			expectedMatchedByID:        3,
+			expectedMatchedByHeuristic: 1,
+			expectedDiscrepancies:      1,
+		},
+		{
+			name:                       "With ID matching and workers",
+			opts:                       []Option{WithIDMatching(true), WithWorkers(4)},
+			expectedMatched:            4,
+			expectedMatchedByID:        3,
+			expectedMatchedByHeuristic: 1,
+			expectedDiscrepancies:      1,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reconcile the transactions
+			result := Reconcile(systemTxs, bankTxs, tt.opts...)
+
+			// Check if the result matches the expected result
+			assert.Equal(t, tt.expectedMatched, result.TransactionMatched)
+			assert.Equal(t, tt.expectedMatchedByID, result.MatchedByID)
+			assert.Equal(t, tt.expectedMatchedByHeuristic, result.MatchedByHeuristic)
+			assert.Equal(t, tt.expectedDiscrepancies, result.TotalDiscrepancies)
+		})
+	}
+}
+
+// TestReconcile_Suggestions tests the closest bank statement is suggested for unmatched system transactions
+func TestReconcile_Suggestions(t *testing.T) {
+	date := time.Date(2024, 3, 20, 10, 30, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, none of them match
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT", TransactionTime: date},
+		{TrxID: "TRX2", Amount: 500.00, Type: "CREDIT", TransactionTime: date.AddDate(0, 0, 10)},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 150.00, Date: day},
+		{UniqueID: "BANK2", Amount: 100.50, Date: day.AddDate(0, 0, 2)},
+		{UniqueID: "BANK3", Amount: 100.50, Date: day.AddDate(0, 0, -1)},
+		{UniqueID: "BANK4", Amount: 100.00, Date: day.AddDate(0, 0, 5)},
+	}
+
+	// Define test cases
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected map[string]Suggestion
+	}{
+		{
+			name: "Default window",
+			expected: map[string]Suggestion{
+				"TRX1": {Bank: bankTxs[2], AmountDelta: 0.5, DateDelta: -1},
+			},
+		},
+		{
+			name: "Wider window",
+			opts: []Option{WithSuggestionWindow(5)},
+			expected: map[string]Suggestion{
+				"TRX1": {Bank: bankTxs[3], AmountDelta: 0, DateDelta: 5},
+				"TRX2": {Bank: bankTxs[3], AmountDelta: 400, DateDelta: -5},
+			},
+		},
+		{
+			name: "Disabled",
+			opts: []Option{WithSuggestionWindow(0)},
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reconcile the transactions
+			result := Reconcile(systemTxs, bankTxs, tt.opts...)
+
+			// Check the suggestions
+			assert.Equal(t, 0, result.TransactionMatched)
+			assert.Equal(t, tt.expected, result.Suggestions)
+		})
+	}
+
+	// Check the suggestion is rendered in the summary
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Contains(t, result.String(), "- TrxID: TRX1, Amount: 100.00, Type: CREDIT, Date: 2024-03-20 10:30:00\n"+
+		"  Suggested: Bank: , ID: BANK3, Amount: 100.50, Date: 2024-03-19 (amount delta: 0.50, date delta: -1 days)\n")
+}
+
+// TestReconcile_WithTypeFilter tests only the selected transaction type is processed
+func TestReconcile_WithTypeFilter(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: -100.00, Date: date},
+		{UniqueID: "BANK2", Amount: 200.00, Date: date},
+		{UniqueID: "BANK4", Amount: 400.00, Date: date},
+	}
+
+	// Define test cases
+	tests := []struct {
+		name              string
+		typeFilter        types.TransactionType
+		expectedProcessed int
+		expectedMatched   int
+		expectedSystem    []types.Transaction
+		expectedBank      []types.BankStatement
+	}{
+		{
+			name:              "All types",
+			expectedProcessed: 3,
+			expectedMatched:   2,
+			expectedSystem:    []types.Transaction{systemTxs[2]},
+			expectedBank:      []types.BankStatement{bankTxs[2]},
+		},
+		{
+			name:              "DEBIT only",
+			typeFilter:        types.TransactionTypeDebit,
+			expectedProcessed: 2,
+			expectedMatched:   1,
+			expectedSystem:    []types.Transaction{systemTxs[2]},
+		},
+		{
+			name:              "CREDIT only",
+			typeFilter:        types.TransactionTypeCredit,
+			expectedProcessed: 1,
+			expectedMatched:   1,
+			expectedBank:      []types.BankStatement{bankTxs[2]},
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reconcile the transactions with the type filter
+			result := Reconcile(systemTxs, bankTxs, WithTypeFilter(tt.typeFilter))
+
+			// Check only the selected type is processed
+			assert.Equal(t, tt.expectedProcessed, result.TransactionProcessed)
+			assert.Equal(t, tt.expectedMatched, result.TransactionMatched)
+			assert.Equal(t, tt.expectedSystem, result.TransactionUnmatched.SystemUnmatched)
+			assert.Equal(t, tt.expectedBank, result.TransactionUnmatched.BankUnmatched)
+		})
+	}
+}
+
+// TestReconcile_WithPercentageTolerance tests matching with a percentage tolerance of the system amount
+func TestReconcile_WithPercentageTolerance(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define test cases
+	tests := []struct {
+		name          string
+		systemAmount  float64
+		bankAmount    float64
+		pct           float64
+		expectMatched bool
+	}{
+		{
+			name:          "Large amount within 0.5%",
+			systemAmount:  1000000.00,
+			bankAmount:    995000.00,
+			pct:           0.005,
+			expectMatched: true,
+		},
+		{
+			name:         "Large amount beyond 0.5%",
+			systemAmount: 1000000.00,
+			bankAmount:   994999.99,
+			pct:          0.005,
+		},
+		{
+			name:         "Large amount without percentage tolerance",
+			systemAmount: 1000000.00,
+			bankAmount:   999995.00,
+		},
+		{
+			name:          "Small amount falls back to the absolute tolerance",
+			systemAmount:  1.00,
+			bankAmount:    1.01,
+			pct:           0.005,
+			expectMatched: true,
+		},
+		{
+			name:         "Small amount beyond the absolute tolerance",
+			systemAmount: 1.00,
+			bankAmount:   1.02,
+			pct:          0.005,
+		},
+	}
+
+	// Run each test case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			systemTxs := []types.Transaction{
+				{TrxID: "TRX1", Amount: tt.systemAmount, Type: types.TransactionTypeCredit, TransactionTime: date},
+			}
+			bankTxs := []types.BankStatement{
+				{UniqueID: "BANK1", Amount: tt.bankAmount, Date: date},
+			}
+
+			// Reconcile the transactions with the percentage tolerance
+			result := Reconcile(systemTxs, bankTxs, WithPercentageTolerance(tt.pct))
+
+			// Check the match
+			if tt.expectMatched {
+				assert.Equal(t, 1, result.TransactionMatched)
+			} else {
+				assert.Equal(t, 0, result.TransactionMatched)
+			}
+		})
+	}
+}
+
+// TestReconcile_WithBankTolerance tests matching with a different absolute tolerance per bank
+func TestReconcile_WithBankTolerance(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Both banks take a 0.25 fee, a bank taking 0.01 less matches with the global tolerance
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "BANKA", UniqueID: "A1", Amount: 99.75, Date: date},
+		{BankName: "BANKB", UniqueID: "B1", Amount: 199.75, Date: date},
+		{BankName: "BANKC", UniqueID: "C1", Amount: 299.99, Date: date},
+	}
+
+	// Without bank tolerances only the global tolerance applies
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 1, result.TransactionMatched)
+
+	// BankA allows the fee, BankB is exact, unlisted BankC keeps the global tolerance, bank names are case-insensitive
+	result = Reconcile(systemTxs, bankTxs, WithBankTolerance(map[string]float64{"BankA": 0.25, "BankB": 0}))
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[1]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, 0.26, result.TotalDiscrepancies)
+
+	// An exact bank rejects the global tolerance of one unit
+	exact := []types.BankStatement{{BankName: "BANKB", UniqueID: "B2", Amount: 100.01, Date: date}}
+	assert.Equal(t, 0, Reconcile(systemTxs[:1], exact, WithBankTolerance(map[string]float64{"BankB": 0})).TransactionMatched)
+}
+
+// TestReconcile_Ambiguities tests reporting matches chosen among several candidates
+func TestReconcile_Ambiguities(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, TRX1 and TRX2 compete for BANK1 and BANK2, TRX3 has a single candidate
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "BANK4", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{UniqueID: "BANK2", Amount: 100.00, Date: date},
+		{UniqueID: "BANK3", Amount: 300.00, Date: date},
+		{UniqueID: "BANK4", Amount: 100.00, Date: date},
+	}
+
+	// Reconcile sequentially and concurrently, BANK4 is claimed by ID first
+	for _, workers := range []int{1, 4} {
+		result := Reconcile(systemTxs, bankTxs, WithIDMatching(true), WithWorkers(workers))
+
+		// Only TRX1 had a choice left, TRX2 took the last candidate
+		assert.Equal(t, 4, result.TransactionMatched)
+		assert.Equal(t, []AmbiguityRecord{
+			{TrxID: "TRX1", Chosen: "BANK1", Candidates: []string{"BANK1", "BANK2"}, CandidateBanks: []string{"", ""}},
+		}, result.Ambiguities)
+		assert.Contains(t, result.String(), "- TrxID: TRX1, Matched: BANK1, Candidates: BANK1, BANK2")
+	}
+
+	// Without ambiguous matches nothing is reported
+	result := Reconcile(systemTxs[2:3], bankTxs[2:3])
+	assert.Empty(t, result.Ambiguities)
+	assert.NotContains(t, result.String(), "Ambiguous matches")
+}
+
+// TestReconcile_ControlTotals tests the control totals of a mixed DEBIT/CREDIT set
+func TestReconcile_ControlTotals(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, BANK3 has no system transaction and TRX3 is missing from the bank
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.10, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 40.20, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 10.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.10, Date: date},
+		{UniqueID: "BANK2", Amount: -40.20, Date: date},
+		{UniqueID: "BANK3", Amount: 25.00, Date: date},
+	}
+
+	// Reconcile the transactions
+	result := Reconcile(systemTxs, bankTxs)
+
+	// Check the totals are signed by type and the difference is exact
+	assert.Equal(t, 49.90, result.SystemTotal)
+	assert.Equal(t, 84.90, result.BankTotal)
+	assert.Equal(t, 35.00, result.TotalDifference)
+	assert.Contains(t, result.String(), "Control totals: System: 49.90, Bank: 84.90, Difference: 35.00\n")
+
+	// Explicit directions and an inverted bank sign give the same totals
+	directed := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.10, Date: date, Direction: types.TransactionTypeCredit},
+		{UniqueID: "BANK2", Amount: 40.20, Date: date, Direction: types.TransactionTypeDebit},
+		{UniqueID: "BANK3", Amount: 25.00, Date: date, Direction: types.TransactionTypeCredit},
+	}
+	assert.Equal(t, 84.90, Reconcile(systemTxs, directed).BankTotal)
+	inverted := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: -100.10, Date: date},
+		{UniqueID: "BANK2", Amount: 40.20, Date: date},
+		{UniqueID: "BANK3", Amount: -25.00, Date: date},
+	}
+	assert.Equal(t, 84.90, Reconcile(systemTxs, inverted, WithInvertBankSign(true)).BankTotal)
+}
+
+// TestReconcile_WithDateGranularity tests matching dates at a coarser granularity
+func TestReconcile_WithDateGranularity(t *testing.T) {
+	// Define the transactions, the bank books a few days later in the same month
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: time.Date(2024, 3, 31, 10, 0, 0, 0, time.UTC)},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{UniqueID: "BANK2", Amount: 200.00, Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	// Define test cases
+	tests := []struct {
+		name            string
+		granularity     DateGranularity
+		expectedMatched int
+	}{
+		{
+			name:            "Day",
+			granularity:     DateGranularityDay,
+			expectedMatched: 0,
+		},
+		{
+			name:            "Month",
+			granularity:     DateGranularityMonth,
+			expectedMatched: 1,
+		},
+		{
+			name:            "Year",
+			granularity:     DateGranularityYear,
+			expectedMatched: 2,
+		},
+	}
+
+	// Run each test case sequentially and concurrently
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, workers := range []int{1, 4} {
+				result := Reconcile(systemTxs, bankTxs, WithDateGranularity(tt.granularity), WithWorkers(workers))
+				assert.Equal(t, tt.expectedMatched, result.TransactionMatched)
+			}
+		})
+	}
+}
+
+// TestReconcile_SameIDAcrossBanks tests that banks reusing the same statement ID can all be matched
+func TestReconcile_SameIDAcrossBanks(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, both banks number their statements from 1
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "BRI", UniqueID: "1", Amount: 100.00, Date: date},
+		{BankName: "BCA", UniqueID: "1", Amount: 200.00, Date: date},
+	}
+
+	// Reconcile sequentially and concurrently
+	for _, workers := range []int{1, 4} {
+		result := Reconcile(systemTxs, bankTxs, WithWorkers(workers))
+		assert.Equal(t, 2, result.TransactionMatched)
+		assert.Empty(t, result.TransactionUnmatched.BankUnmatched)
+		assert.Empty(t, result.DuplicateBankIDs)
+	}
+
+	// Both statements can be matched by ID
+	idTxs := []types.Transaction{
+		{TrxID: "1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "1", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	result := Reconcile(idTxs, bankTxs, WithIDMatching(true))
+	assert.Equal(t, 2, result.MatchedByID)
+	assert.Empty(t, result.TransactionUnmatched.BankUnmatched)
+}
+
+// TestReconcileResult_WriteSummaryJSON tests the WriteSummaryJSON method of ReconcileResult
+func TestReconcileResult_WriteSummaryJSON(t *testing.T) {
+	// Define the result
+	result := ReconcileResult{
+		TransactionProcessed: 3,
+		TransactionMatched:   2,
+		MatchedByHeuristic:   2,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 1,
+			SystemUnmatchedCount: 1,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100.00, Type: "CREDIT"},
+			},
+		},
+		TotalDiscrepancies: 0.5,
+		NetDiscrepancy:     -0.5,
+	}
+
+	// Write the summary to a buffer
+	var buf bytes.Buffer
+	err := result.WriteSummaryJSON(&buf)
+	assert.NoError(t, err)
+
+	// Check the summary is a single JSON line without unmatched details
+	assert.Equal(t, `{"total_transactions_processed":3,"total_transactions_matched":2,"matched_by_id":0,`+
+		`"matched_by_heuristic":2,"exact_matches":0,"tolerance_matches":0,"total_transactions_unmatched":1,"system_unmatched_count":1,"bank_unmatched_count":0,`+
+		`"total_discrepancies":0.5,"net_discrepancy":-0.5,`+
+		`"system_total":0,"bank_total":0,"total_difference":0}`+"\n",
+		buf.String())
+}
+
+// TestReconcileResult_GenerateMarkdown tests the Markdown report
+func TestReconcileResult_GenerateMarkdown(t *testing.T) {
+	date := time.Date(2024, 3, 20, 15, 4, 5, 0, time.UTC)
+
+	// Define the result
+	result := ReconcileResult{
+		TransactionProcessed: 5,
+		TransactionMatched:   2,
+		TotalDiscrepancies:   1.5,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: 3,
+			SystemUnmatched: []types.Transaction{
+				{TrxID: "TRX1", Amount: 100, Type: "CREDIT", TransactionTime: date},
+			},
+			BankUnmatched: []types.BankStatement{
+				{BankName: "BRI", UniqueID: "BANK1", Amount: 200.5, Date: date},
+				{BankName: "BCA", UniqueID: "BANK|2", Amount: -50, Date: date},
+			},
+		},
+	}
+
+	// Generate the report
+	var buf bytes.Buffer
+	assert.NoError(t, result.GenerateMarkdown(&buf))
+	report := buf.String()
+
+	// Check the summary table
+	assert.Contains(t, report, "## Reconciliation Summary\n\n| Metric | Value |\n| --- | ---: |\n")
+	assert.Contains(t, report, "| Total transactions processed | 5 |\n")
+	assert.Contains(t, report, "| Total unmatched transactions | 3 |\n")
+	assert.Contains(t, report, "| Total amount discrepancies | 1.50 |\n")
+
+	// Check the collapsible sections of unmatched items
+	assert.Contains(t, report, "<summary>System transactions missing from bank statements (1)</summary>")
+	assert.Contains(t, report, "| TRX1 | 100.00 | CREDIT | 2024-03-20 |\n")
+	assert.Contains(t, report, "<summary>Bank BRI: statements missing from system transactions (1)</summary>")
+	assert.Contains(t, report, "| BANK1 | 200.50 | 2024-03-20 |\n")
+	assert.Contains(t, report, "| BANK\\|2 | -50.00 | 2024-03-20 |\n")
+	assert.Equal(t, 3, strings.Count(report, "<details>"))
+	assert.Equal(t, 3, strings.Count(report, "</details>"))
+
+	// Check the banks are in name order
+	assert.Less(t, strings.Index(report, "Bank BCA"), strings.Index(report, "Bank BRI"))
+}
+
+// TestReconcile_WithUseSignedAmount tests matching on the sign of the system amount instead of the Type
+func TestReconcile_WithUseSignedAmount(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define the transactions, the types are unreliable and the amounts signed
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: -100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: -300.00, Type: "", TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: -100.00, Date: date},
+		{UniqueID: "BANK2", Amount: 200.00, Date: date},
+		{UniqueID: "BANK3", Amount: 300.00, Date: date},
+	}
+
+	// Without the option the types decide the expected sign, only the untyped transaction accepts either sign
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 1, result.TransactionMatched)
+
+	// With the option the amounts must agree in absolute value and sign
+	result = Reconcile(systemTxs, bankTxs, WithUseSignedAmount(true))
+	assert.Equal(t, 3, result.TransactionProcessed)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[2]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[2]}, result.TransactionUnmatched.BankUnmatched)
+	assert.Equal(t, 0.0, result.TotalDiscrepancies)
+
+	// The opposite signs are reported as a sign mismatch
+	assert.Equal(t, []MatchedPair{{System: systemTxs[2], Bank: bankTxs[2]}}, result.SignMismatches)
+
+	// The control totals use the signed system amounts
+	assert.Equal(t, -200.00, result.SystemTotal)
+	assert.Equal(t, 400.00, result.BankTotal)
+
+	// The type filter selects the direction by sign
+	result = Reconcile(systemTxs, bankTxs, WithUseSignedAmount(true), WithTypeFilter(types.TransactionTypeDebit))
+	assert.Equal(t, 2, result.TransactionProcessed)
+	assert.Equal(t, 1, result.TransactionMatched)
+}
+
+// TestReconcile_WithIgnoreType tests matching on the absolute amount and date regardless of the type and sign
+func TestReconcile_WithIgnoreType(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// The DEBIT transactions have positive bank amounts and an opposite bank direction
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{UniqueID: "BANK2", Amount: 200.00, Date: date, Direction: types.TransactionTypeCredit},
+		{UniqueID: "BANK3", Amount: 300.01, Date: date.AddDate(0, 0, 1)},
+	}
+
+	// Without the option the signs do not match and are reported as sign mismatches
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 0, result.TransactionMatched)
+	assert.Len(t, result.SignMismatches, 2)
+
+	// With the option the DEBIT transactions match the positive amounts, the date must still agree
+	result = Reconcile(systemTxs, bankTxs, WithIgnoreType(true))
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[2]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[2]}, result.TransactionUnmatched.BankUnmatched)
+	assert.Empty(t, result.SignMismatches)
+
+	// The amounts still have to agree within the tolerance
+	bankTxs[2].Date = date
+	result = Reconcile(systemTxs, bankTxs, WithIgnoreType(true))
+	assert.Equal(t, 3, result.TransactionMatched)
+	assert.InDelta(t, 0.01, result.TotalDiscrepancies, 1e-9)
+	bankTxs[2].Amount = -300.02
+	result = Reconcile(systemTxs, bankTxs, WithIgnoreType(true))
+	assert.Equal(t, 2, result.TransactionMatched)
+}
+
+// TestReconcile_WithConsistentBankSign tests inferring the sign convention of each bank
+func TestReconcile_WithConsistentBankSign(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// BCA follows the type sign rules, BRI reports CREDIT as negative and DEBIT as positive
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX4", Amount: 400.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TRX5", Amount: 500.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BCA1", Amount: 100.00, Date: date},
+		{BankName: "BCA", UniqueID: "BCA2", Amount: -200.00, Date: date},
+		{BankName: "BRI", UniqueID: "BRI1", Amount: -300.00, Date: date},
+		{BankName: "BRI", UniqueID: "BRI2", Amount: 400.00, Date: date},
+		{BankName: "BRI", UniqueID: "BRI3", Amount: 500.00, Date: date},
+	}
+
+	// With the type sign rules only the statement of the inverted bank following the rules matches
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 3, result.TransactionMatched)
+
+	// Each bank is matched with its own convention, the statement contradicting the convention of BRI does not match
+	result = Reconcile(systemTxs, bankTxs, WithConsistentBankSign(true))
+	assert.Equal(t, 4, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[4]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[4]}, result.TransactionUnmatched.BankUnmatched)
+	assert.Equal(t, []MatchedPair{{System: systemTxs[4], Bank: bankTxs[4]}}, result.SignMismatches)
+	assert.True(t, result.Config.ConsistentBankSign)
+	assert.Equal(t, []string{"BRI"}, result.Config.InvertedBanks)
+
+	// Ignoring the type matches the contradicting statement as well
+	result = Reconcile(systemTxs, bankTxs, WithIgnoreType(true), WithConsistentBankSign(true))
+	assert.Equal(t, 5, result.TransactionMatched)
+	assert.False(t, result.Config.ConsistentBankSign)
+
+	// An explicit bank direction is inverted the same way
+	withDirection := []types.BankStatement{
+		{BankName: "BRI", UniqueID: "BRI1", Amount: 300.00, Direction: types.TransactionTypeDebit, Date: date},
+		{BankName: "BRI", UniqueID: "BRI2", Amount: 400.00, Direction: types.TransactionTypeCredit, Date: date},
+	}
+	result = Reconcile(systemTxs[2:4], withDirection, WithConsistentBankSign(true))
+	assert.Equal(t, 2, result.TransactionMatched)
+}
+
+// TestReconcile_WithCarryforward tests matching unmatched items of the previous period to today's postings
+func TestReconcile_WithCarryforward(t *testing.T) {
+	yesterday := time.Date(2024, 3, 19, 0, 0, 0, 0, time.UTC)
+	today := yesterday.AddDate(0, 0, 1)
+
+	// Yesterday TRX1 was not posted yet and BANK0 had no system transaction
+	previous := Reconcile(
+		[]types.Transaction{{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: yesterday}},
+		[]types.BankStatement{{BankName: "bca", UniqueID: "BANK0", Amount: 50.00, Date: yesterday}},
+	)
+	assert.Equal(t, 2, previous.TransactionUnmatched.TransactionUnmatched)
+
+	// Today the bank posts TRX1, TRX2 is matched fresh and TRX3 was posted yesterday
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: today},
+		{TrxID: "TRX3", Amount: 50.00, Type: types.TransactionTypeCredit, TransactionTime: today},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "bca", UniqueID: "BANK1", Amount: 100.00, Date: today},
+		{BankName: "bca", UniqueID: "BANK2", Amount: 200.00, Date: today},
+	}
+
+	// Without the carryforward TRX3 and BANK1 stay unmatched
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 1, result.TransactionMatched)
+
+	// The carried TRX1 matches the later BANK1, BANK0 is posted before TRX3 and is carried again
+	result = Reconcile(systemTxs, bankTxs, WithCarryforward(previous))
+	assert.Equal(t, 3, result.TransactionProcessed)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, 1, result.MatchedByHeuristic)
+	assert.Equal(t, 1, result.MatchedByCarryforward)
+	assert.Equal(t, []MatchedPair{{System: previous.TransactionUnmatched.SystemUnmatched[0], Bank: bankTxs[0]}}, result.CarriedForwardMatches)
+	assert.Equal(t, []types.Transaction{systemTxs[1]}, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, previous.TransactionUnmatched.BankUnmatched, result.TransactionUnmatched.BankUnmatched)
+
+	// The control totals only cover the fresh items
+	assert.Equal(t, 250.00, result.SystemTotal)
+	assert.Equal(t, 300.00, result.BankTotal)
+
+	// Carried items read again in the fresh input are not duplicated
+	result = Reconcile(systemTxs, append(bankTxs, previous.TransactionUnmatched.BankUnmatched...), WithCarryforward(previous))
+	assert.Empty(t, result.DuplicateBankIDs)
+	assert.Equal(t, previous.TransactionUnmatched.BankUnmatched, result.TransactionUnmatched.BankUnmatched)
+
+	// The carried forward matches survive the JSON output
+	var buf bytes.Buffer
+	result = Reconcile(systemTxs, bankTxs, WithCarryforward(previous))
+	assert.NoError(t, result.EncodeJSON(&buf))
+	var decoded jsonResult
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, 1, decoded.Summary.MatchedByCarryforward)
+	assert.Equal(t, result.CarriedForwardMatches, decoded.toReconcileResult().CarriedForwardMatches)
+}
+
+// TestReconcileResult_MatchRate tests the fraction of matched transactions
+func TestReconcileResult_MatchRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		processed int
+		matched   int
+		expected  float64
+	}{
+		{name: "All matched", processed: 4, matched: 4, expected: 1},
+		{name: "Partially matched", processed: 4, matched: 3, expected: 0.75},
+		{name: "None matched", processed: 4, matched: 0, expected: 0},
+		{name: "Nothing processed", processed: 0, matched: 0, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ReconcileResult{TransactionProcessed: tt.processed, TransactionMatched: tt.matched}
+			assert.Equal(t, tt.expected, result.MatchRate())
+		})
+	}
+}
+
+// TestReconcile_WithMatcher tests replacing the built-in matching with a custom matcher
+func TestReconcile_WithMatcher(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define transactions that never match by amount, date and type
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 150.00, Date: date.AddDate(0, 0, 5)},
+		{UniqueID: "BANK2", Amount: 250.00, Date: date.AddDate(0, 1, 0)},
+		{UniqueID: "BANK3", Amount: 350.00, Date: date},
+	}
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 0, result.TransactionMatched)
+
+	// An always-match matcher pairs the transactions in order, also with several workers
+	alwaysMatch := MatcherFunc(func(types.Transaction, types.BankStatement) bool { return true })
+	for _, workers := range []int{1, 4} {
+		result = Reconcile(systemTxs, bankTxs, WithMatcher(alwaysMatch), WithWorkers(workers))
+		assert.Equal(t, 2, result.TransactionMatched)
+		assert.Empty(t, result.TransactionUnmatched.SystemUnmatched)
+		assert.Equal(t, []types.BankStatement{bankTxs[2]}, result.TransactionUnmatched.BankUnmatched)
+	}
+
+	// A matcher built on the default matcher adds a rule
+	plusFifty := MatcherFunc(func(sysTx types.Transaction, bankTx types.BankStatement) bool {
+		return DefaultMatcher().Match(sysTx, bankTx) || sysTx.Amount+50 == bankTx.Amount
+	})
+	result = Reconcile(systemTxs, bankTxs, WithMatcher(plusFifty))
+	assert.Equal(t, 2, result.TransactionMatched)
+}
+
+// TestDefaultMatcher tests that the default matcher follows the options like the built-in matching
+func TestDefaultMatcher(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	sysTx := types.Transaction{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date}
+
+	assert.True(t, DefaultMatcher().Match(sysTx, types.BankStatement{Amount: 100.00, Date: date}))
+	assert.False(t, DefaultMatcher().Match(sysTx, types.BankStatement{Amount: 100.40, Date: date}))
+	assert.False(t, DefaultMatcher().Match(sysTx, types.BankStatement{Amount: 100.00, Date: date.AddDate(0, 0, 1)}))
+	assert.True(t, DefaultMatcher(WithPercentageTolerance(0.005)).Match(sysTx, types.BankStatement{Amount: 100.40, Date: date}))
+	assert.True(t, DefaultMatcher(WithDateGranularity(DateGranularityMonth)).Match(sysTx, types.BankStatement{Amount: 100.00, Date: date.AddDate(0, 0, 1)}))
+}
+
+// TestReconcile_WithBestMatch tests picking the highest scoring candidate instead of the first one
+func TestReconcile_WithBestMatch(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// Define amounts clustering within a 1% tolerance, the first candidate is the wrong one
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 100.50, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.50, Date: date},
+		{UniqueID: "BANK2", Amount: 100.00, Date: date},
+	}
+
+	// First-match pairs the transactions in order with discrepancies
+	result := Reconcile(systemTxs, bankTxs, WithPercentageTolerance(0.01))
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, 1.00, result.TotalDiscrepancies)
+
+	// Best-match pairs the equal amounts, also with several workers
+	for _, workers := range []int{1, 4} {
+		result = Reconcile(systemTxs, bankTxs, WithPercentageTolerance(0.01), WithBestMatch(true), WithWorkers(workers))
+		assert.Equal(t, 2, result.TransactionMatched)
+		assert.Equal(t, 0.0, result.TotalDiscrepancies)
+	}
+
+	// An equal reference wins over a closer amount, then the closer date
+	bankTxs = []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.00, Date: date.AddDate(0, 0, 3)},
+		{UniqueID: "BANK2", Amount: 100.00, Date: date.AddDate(0, 0, 1)},
+		{UniqueID: "TRX1", Amount: 100.50, Date: date.AddDate(0, 0, 5)},
+	}
+	result = Reconcile(systemTxs[:1], bankTxs, WithPercentageTolerance(0.01), WithDateGranularity(DateGranularityMonth), WithBestMatch(true))
+	assert.Equal(t, []types.BankStatement{bankTxs[0], bankTxs[1]}, result.TransactionUnmatched.BankUnmatched)
+	result = Reconcile(systemTxs[:1], bankTxs[:2], WithDateGranularity(DateGranularityMonth), WithBestMatch(true))
+	assert.Equal(t, []types.BankStatement{bankTxs[0]}, result.TransactionUnmatched.BankUnmatched)
+}
+
+// TestReconcile_WithOptimalMatching tests maximizing the number of matches where first-match leaves a transaction unmatched
+func TestReconcile_WithOptimalMatching(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// TRX1 matches both bank statements within the tolerance, TRX2 only matches the first one
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 100.01, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 500.00, Type: types.TransactionTypeCredit, TransactionTime: date.AddDate(0, 0, 1)},
+	}
+	bankTxs := []types.BankStatement{
+		{UniqueID: "BANK1", Amount: 100.01, Date: date},
+		{UniqueID: "BANK2", Amount: 99.99, Date: date},
+		{UniqueID: "BANK3", Amount: 500.00, Date: date.AddDate(0, 0, 1)},
+	}
+
+	// First-match gives the shared statement to TRX1
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 2, result.TransactionMatched)
+	assert.Equal(t, []types.Transaction{systemTxs[1]}, result.TransactionUnmatched.SystemUnmatched)
+
+	// Optimal matching moves TRX1 to the other statement
+	result = Reconcile(systemTxs, bankTxs, WithOptimalMatching(true), WithWorkers(4))
+	assert.Equal(t, 3, result.TransactionMatched)
+	assert.Empty(t, result.TransactionUnmatched.SystemUnmatched)
+	assert.Empty(t, result.TransactionUnmatched.BankUnmatched)
+	assert.InDelta(t, 0.01, result.TotalDiscrepancies, 1e-9)
+
+	// Statements claimed by ID matching are not reassigned
+	bankTxs[1].UniqueID = "TRX1"
+	result = Reconcile(systemTxs, bankTxs, WithOptimalMatching(true), WithIDMatching(true))
+	assert.Equal(t, 3, result.TransactionMatched)
+	assert.Equal(t, 1, result.MatchedByID)
+
+	// A bank ID repeated on two dates is only matched once, like the sequential matching
+	duplicated := []types.BankStatement{
+		{BankName: "bca", UniqueID: "DUP", Amount: 100.00, Date: date},
+		{BankName: "bca", UniqueID: "DUP", Amount: 500.00, Date: date.AddDate(0, 0, 1)},
+	}
+	system := []types.Transaction{systemTxs[0], systemTxs[2]}
+	sequential := Reconcile(system, duplicated)
+	result = Reconcile(system, duplicated, WithOptimalMatching(true))
+	assert.Equal(t, 1, sequential.TransactionMatched)
+	assert.Equal(t, sequential.TransactionMatched, result.TransactionMatched)
+	assert.Equal(t, sequential.TransactionUnmatched, result.TransactionUnmatched)
+}
+
+// TestReconcile_WithSplitMatching tests matching one system transaction to several bank lines summing to its amount
+func TestReconcile_WithSplitMatching(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// TRX1 is settled in two partial lines, the debit line and the line of the next day are not candidates
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "bca", UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{BankName: "bca", UniqueID: "BANK2", Amount: -200.00, Date: date},
+		{BankName: "bca", UniqueID: "BANK3", Amount: 200.00, Date: date.AddDate(0, 0, 1)},
+		{BankName: "bca", UniqueID: "BANK4", Amount: 200.00, Date: date},
+	}
+
+	// Without split matching no single line matches
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 0, result.TransactionMatched)
+	assert.Empty(t, result.SplitMatches)
+
+	// Split matching consumes the 100.00 and 200.00 lines of the same day
+	result = Reconcile(systemTxs, bankTxs, WithSplitMatching(true))
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Equal(t, 1, result.MatchedByHeuristic)
+	assert.Equal(t, []SplitMatch{{System: systemTxs[0], Bank: []types.BankStatement{bankTxs[0], bankTxs[3]}}}, result.SplitMatches)
+	assert.Empty(t, result.TransactionUnmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bankTxs[1], bankTxs[2]}, result.TransactionUnmatched.BankUnmatched)
+	assert.InDelta(t, 0, result.TotalDiscrepancies, 1e-9)
+
+	// A single line matching the amount is still preferred
+	bankTxs = append(bankTxs, types.BankStatement{BankName: "bca", UniqueID: "BANK5", Amount: 300.00, Date: date})
+	result = Reconcile(systemTxs, bankTxs, WithSplitMatching(true))
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Empty(t, result.SplitMatches)
+
+	// Lines of different banks are not combined
+	bankTxs = []types.BankStatement{
+		{BankName: "bca", UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{BankName: "bni", UniqueID: "BANK2", Amount: 200.00, Date: date},
+	}
+	result = Reconcile(systemTxs, bankTxs, WithSplitMatching(true))
+	assert.Equal(t, 0, result.TransactionMatched)
+}
+
+// TestReconcile_WithReferenceGrouping tests matching two installments sharing a reference to one bank line
+func TestReconcile_WithReferenceGrouping(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	// PAY1 is recorded in two installments on different days and settled in one bank line with the reference
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date, Reference: "PAY1"},
+		{TrxID: "TRX2", Amount: 50.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date.AddDate(0, 0, 1), Reference: "PAY1"},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "bca", UniqueID: "BANK1", Amount: 300.00, Date: date.AddDate(0, 0, 1), Reference: "PAY1"},
+		{BankName: "bca", UniqueID: "BANK2", Amount: 50.00, Date: date},
+	}
+
+	// Without reference grouping no single installment matches the bank line
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Empty(t, result.ReferenceGroups)
+
+	// Reference grouping matches both installments to the bank line, even when ID matching would take it
+	bankTxs[0].UniqueID = "TRX3"
+	result = Reconcile(systemTxs, bankTxs, WithReferenceGrouping(true), WithIDMatching(true))
+	assert.Equal(t, 3, result.TransactionMatched)
+	assert.Equal(t, 0, result.MatchedByID)
+	assert.Equal(t, 1, result.MatchedByHeuristic)
+	assert.Equal(t, []ReferenceGroup{{Reference: "PAY1", System: []types.Transaction{systemTxs[0], systemTxs[2]}, Bank: bankTxs[0]}},
+		result.ReferenceGroups)
+	assert.Empty(t, result.TransactionUnmatched.SystemUnmatched)
+	assert.Empty(t, result.TransactionUnmatched.BankUnmatched)
+	assert.InDelta(t, 0, result.TotalDiscrepancies, 1e-9)
+	assert.Contains(t, result.String(), "Reference groups:\n- Reference: PAY1, Transactions: 2, Amount: 300.00 <> Bank: bca, ID: TRX3, Amount: 300.00\n")
+
+	// The grouping survives the JSON round trip
+	filename := filepath.Join(t.TempDir(), "result.json")
+	assert.NoError(t, result.GenerateJSON(filename))
+	decoded, err := LoadJSON(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, result.ReferenceGroups, decoded.ReferenceGroups)
+
+	// Transactions sharing a TrxID without a reference are not grouped
+	duplicated := []types.Transaction{
+		{TrxID: "PAY2", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "PAY2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	result = Reconcile(duplicated, []types.BankStatement{{BankName: "bca", UniqueID: "PAY2", Amount: 300.00, Date: date}},
+		WithReferenceGrouping(true))
+	assert.Empty(t, result.ReferenceGroups)
+
+	// Installments summing to a different amount are not grouped
+	bankTxs[0].Amount = 250.00
+	result = Reconcile(systemTxs, bankTxs, WithReferenceGrouping(true))
+	assert.Empty(t, result.ReferenceGroups)
+	assert.Len(t, result.TransactionUnmatched.SystemUnmatched, 2)
+}
+
+// TestReconcile_ExactAndToleranceMatches tests counting the exact matches apart from the matches within the tolerance
+func TestReconcile_ExactAndToleranceMatches(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.001, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX3", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX4", Amount: 400.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "bca", UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{BankName: "bca", UniqueID: "BANK2", Amount: 200.00, Date: date},
+		{BankName: "bca", UniqueID: "BANK3", Amount: 300.01, Date: date},
+		{BankName: "bca", UniqueID: "BANK4", Amount: 399.99, Date: date},
+	}
+
+	// A difference rounding to zero is exact, the others only match within the tolerance
+	result := Reconcile(systemTxs, bankTxs)
+	assert.Equal(t, 4, result.TransactionMatched)
+	assert.Equal(t, 2, result.ExactMatches)
+	assert.Equal(t, 2, result.ToleranceMatches)
+	assert.Contains(t, result.String(), "Total matched transactions: 4\n- Exact matches: 2\n- Matched within tolerance: 2\n")
+
+	// The counts are surfaced in the JSON summary
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteSummaryJSON(&buf))
+	assert.Contains(t, buf.String(), `"exact_matches":2,"tolerance_matches":2`)
+
+	// A split match counts by the difference of its summed bank amounts
+	result = Reconcile(systemTxs[:1], []types.BankStatement{
+		{BankName: "bca", UniqueID: "BANK1", Amount: 40.00, Date: date},
+		{BankName: "bca", UniqueID: "BANK2", Amount: 59.99, Date: date},
+	}, WithSplitMatching(true))
+	assert.Equal(t, 0, result.ExactMatches)
+	assert.Equal(t, 1, result.ToleranceMatches)
+
+	// A zero count keeps its key in the JSON summary
+	buf.Reset()
+	assert.NoError(t, result.WriteSummaryJSON(&buf))
+	assert.Contains(t, buf.String(), `"exact_matches":0,"tolerance_matches":1`)
+}
@@ -0,0 +1,93 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+)
+
+// referenceGroup is a group of system transactions sharing a reference matched to one bank statement
+type referenceGroup struct {
+	// Indexes of the system transactions in order
+	system []int
+
+	// Index of the bank statement with the reference
+	bank int
+}
+
+// matchReferenceGroups matches groups of at least two unmatched system transactions sharing a Reference to an unclaimed
+// bank statement with that Reference, in the same direction and with the absolute amounts summing to the
+// bank amount within the tolerance, e.g. a payment recorded in installments and settled in one bank line
+// Transactions and statements without a reference are not grouped
+// The dates are not compared, the installments of a group may span several days
+// It records the index of the bank statement in matches for each transaction of a group and returns the groups in
+// order of the first transaction of each group
+func (r *reconciler) matchReferenceGroups(system []types.Transaction, bank []types.BankStatement, matches []int) []referenceGroup {
+	// Group the unmatched system transactions by reference in order of first occurrence
+	var references []string
+	byReference := make(map[string][]int)
+	for i, sysTx := range system {
+		if matches[i] >= 0 || sysTx.Reference == "" {
+			continue
+		}
+		if _, ok := byReference[sysTx.Reference]; !ok {
+			references = append(references, sysTx.Reference)
+		}
+		byReference[sysTx.Reference] = append(byReference[sysTx.Reference], i)
+	}
+
+	// Index the unclaimed bank statements by reference
+	claimed := claimedBank(bank, matches)
+	bankByReference := make(map[string][]int)
+	for j, bankTx := range bank {
+		if !claimed[bankKey(bankTx)] && bankTx.Reference != "" {
+			bankByReference[bankTx.Reference] = append(bankByReference[bankTx.Reference], j)
+		}
+	}
+
+	var groups []referenceGroup
+	for _, reference := range references {
+		group := byReference[reference]
+		if len(group) < 2 || len(bankByReference[reference]) == 0 {
+			continue
+		}
+
+		// The installments of a payment share the direction, their amounts are summed
+		var sysUnits int64
+		sameType := true
+		for _, i := range group {
+			sysUnits += r.systemUnits(system[i])
+			sameType = sameType && r.systemType(system[i]) == r.systemType(system[group[0]])
+		}
+		if !sameType && !r.ignoreType {
+			continue
+		}
+
+		// Take the first unclaimed bank statement with the reference matching the group
+		for _, j := range bankByReference[reference] {
+			bankTx := bank[j]
+			if claimed[bankKey(bankTx)] || !r.groupMatches(system, group, bankTx, sysUnits) {
+				continue
+			}
+			for _, i := range group {
+				matches[i] = j
+			}
+			claimed[bankKey(bankTx)] = true
+			groups = append(groups, referenceGroup{system: group, bank: j})
+			break
+		}
+	}
+	return groups
+}
+
+// groupMatches checks if the bank statement matches the currency and direction of the grouped system transactions
+// and the summed amount within the tolerance
+func (r *reconciler) groupMatches(system []types.Transaction, group []int, bankTx types.BankStatement, sysUnits int64) bool {
+	for _, i := range group {
+		if !currencyMatches(system[i], bankTx) {
+			return false
+		}
+	}
+	if !r.ignoreType && !r.directionMatches(system[group[0]], bankTx) {
+		return false
+	}
+	return abs(sysUnits-abs(r.toUnits(bankTx.Amount))) <= r.tolerance(sysUnits, bankTx.BankName)
+}
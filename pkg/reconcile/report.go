@@ -0,0 +1,115 @@
+package reconcile
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReportOption is a functional option for the text summary and the Markdown report
+type ReportOption func(*reportOptions)
+
+// reportOptions holds the configuration of the text summary and the Markdown report
+type reportOptions struct {
+	// Highlight the counts and discrepancies with ANSI colors
+	colored bool
+
+	// Collapse identical unmatched items into one line with their count
+	dedupe bool
+
+	// Write the effective configuration of the run
+	explain bool
+
+	// Layout of the dates and transaction times, empty for the default layouts
+	dateFormat string
+}
+
+// WithDedupe collapses identical unmatched system transactions and bank statements, e.g. duplicate rows of a file,
+// into a single line with their count, only the report is affected and the counts of the result are unchanged
+func WithDedupe(dedupe bool) ReportOption {
+	return func(o *reportOptions) {
+		o.dedupe = dedupe
+	}
+}
+
+// WithExplain writes the effective configuration of the run at the end of the text summary, see Config
+func WithExplain(explain bool) ReportOption {
+	return func(o *reportOptions) {
+		o.explain = explain
+	}
+}
+
+// WithDateFormat renders the dates and transaction times of the report in the Go layout, e.g. 02/01/2006,
+// instead of 2006-01-02 for dates and 2006-01-02 15:04:05 for transaction times, see ValidateDateFormat
+func WithDateFormat(layout string) ReportOption {
+	return func(o *reportOptions) {
+		o.dateFormat = layout
+	}
+}
+
+// newReportOptions creates the report configuration with the given options
+func newReportOptions(opts ...ReportOption) reportOptions {
+	o := reportOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// formatDate formats a bank or report date in the configured layout
+func (o reportOptions) formatDate(date time.Time) string {
+	if o.dateFormat != "" {
+		return date.Format(o.dateFormat)
+	}
+	return date.Format("2006-01-02")
+}
+
+// formatTime formats a transaction time in the configured layout
+func (o reportOptions) formatTime(date time.Time) string {
+	if o.dateFormat != "" {
+		return date.Format(o.dateFormat)
+	}
+	return date.Format("2006-01-02 15:04:05")
+}
+
+// ValidateDateFormat checks that the Go layout renders the year, month and day of a date, e.g. 02/01/2006
+func ValidateDateFormat(layout string) error {
+	date := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+	parsed, err := time.Parse(layout, date.Format(layout))
+	if err != nil || !parsed.Equal(date) {
+		return fmt.Errorf("invalid date format %q, use a Go layout with the year, month and day, e.g. 02/01/2006", layout)
+	}
+	return nil
+}
+
+// dedupe returns the distinct items in order of first occurrence with the number of occurrences of each
+func dedupe[T comparable](items []T) ([]T, []int) {
+	index := make(map[T]int, len(items))
+	var distinct []T
+	var counts []int
+	for _, item := range items {
+		if k, ok := index[item]; ok {
+			counts[k]++
+			continue
+		}
+		index[item] = len(distinct)
+		distinct = append(distinct, item)
+		counts = append(counts, 1)
+	}
+	return distinct, counts
+}
+
+// unmatchedItems returns the items to report, deduplicated with their counts when set, otherwise as is with nil counts
+func unmatchedItems[T comparable](items []T, dedupeItems bool) ([]T, []int) {
+	if !dedupeItems {
+		return items, nil
+	}
+	return dedupe(items)
+}
+
+// countSuffix returns the count of the k-th reported item for a summary line, empty when it occurs once
+func countSuffix(counts []int, k int) string {
+	if counts == nil || counts[k] <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(", Count: %d", counts[k])
+}
@@ -0,0 +1,77 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteSummary_Dedupe tests collapsing duplicated unmatched rows in the reports without changing the counts
+func TestWriteSummary_Dedupe(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	duplicate := types.Transaction{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date}
+	statement := types.BankStatement{BankName: "BRI", UniqueID: "BANK1", Amount: -50.00, Date: date}
+	systemTxs := []types.Transaction{
+		duplicate,
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		duplicate,
+		duplicate,
+	}
+	bankTxs := []types.BankStatement{statement, statement}
+	result := Reconcile(systemTxs, bankTxs, WithSuggestionWindow(0))
+	assert.Equal(t, 6, result.TransactionUnmatched.TransactionUnmatched)
+
+	// Without deduplication every row is listed
+	summary := result.String()
+	assert.Equal(t, 3, strings.Count(summary, "- TrxID: TRX1,"))
+	assert.NotContains(t, summary, "Count:")
+
+	// Deduplicated rows are listed once with their count, the totals are unchanged
+	var deduped strings.Builder
+	assert.NoError(t, result.WriteSummary(&deduped, WithDedupe(true)))
+	assert.Contains(t, deduped.String(), "Total unmatched transactions: 6\n")
+	assert.Contains(t, deduped.String(), "- TrxID: TRX1, Amount: 100.00, Type: CREDIT, Date: 2024-03-20 00:00:00, Count: 3\n"+
+		"- TrxID: TRX2, Amount: 200.00, Type: CREDIT, Date: 2024-03-20 00:00:00\n")
+	assert.Contains(t, deduped.String(), "\nBank: BRI\n- ID: BANK1, Amount: -50.00, Date: 2024-03-20, Count: 2\n")
+
+	// The Markdown report has a count column
+	var markdown strings.Builder
+	assert.NoError(t, result.GenerateMarkdown(&markdown, WithDedupe(true)))
+	assert.Contains(t, markdown.String(), "| TrxID | Amount | Type | Date | Count |\n| --- | ---: | --- | --- | ---: |\n"+
+		"| TRX1 | 100.00 | CREDIT | 2024-03-20 | 3 |\n| TRX2 | 200.00 | CREDIT | 2024-03-20 | 1 |\n")
+	assert.Contains(t, markdown.String(), "| BANK1 | -50.00 | 2024-03-20 | 2 |\n")
+}
+
+// TestWriteSummary_DateFormat tests rendering the dates of the reports and the JSON output in a custom layout
+func TestWriteSummary_DateFormat(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	systemTxs := []types.Transaction{{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date}}
+	bankTxs := []types.BankStatement{{BankName: "BRI", UniqueID: "BANK1", Amount: -50.00, Date: date}}
+	result := Reconcile(systemTxs, bankTxs, WithSuggestionWindow(0))
+
+	// The text summary and the Markdown report use the layout for dates and transaction times
+	var summary strings.Builder
+	assert.NoError(t, result.WriteSummary(&summary, WithDateFormat("02/01/2006")))
+	assert.Contains(t, summary.String(), "- TrxID: TRX1, Amount: 100.00, Type: CREDIT, Date: 20/03/2024\n")
+	assert.Contains(t, summary.String(), "- ID: BANK1, Amount: -50.00, Date: 20/03/2024\n")
+	assert.NotContains(t, summary.String(), "2024-03-20")
+	var markdown strings.Builder
+	assert.NoError(t, result.GenerateMarkdown(&markdown, WithDateFormat("02/01/2006")))
+	assert.Contains(t, markdown.String(), "| TRX1 | 100.00 | CREDIT | 20/03/2024 |\n")
+
+	// The JSON output renders the times in the layout
+	var encoded strings.Builder
+	assert.NoError(t, result.EncodeJSON(&encoded, WithJSONDateFormat("02/01/2006")))
+	assert.Contains(t, encoded.String(), `"TransactionTime": "20/03/2024"`)
+	assert.Contains(t, encoded.String(), `"Date": "20/03/2024"`)
+	assert.NotContains(t, encoded.String(), "2024-03-20")
+
+	// Layouts without the year, month and day are rejected
+	assert.NoError(t, ValidateDateFormat("02/01/2006"))
+	assert.NoError(t, ValidateDateFormat("2006-01-02T15:04:05Z07:00"))
+	assert.Error(t, ValidateDateFormat("2006"))
+	assert.Error(t, ValidateDateFormat("foo"))
+}
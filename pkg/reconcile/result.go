@@ -3,24 +3,205 @@ package reconcile
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reconciliation/pkg/types"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // ReconcileResult is the result of the reconciliation process
 type ReconcileResult struct {
+	// RunID identifies the run that produced the result, see WithRunID
+	RunID string
+
 	// TransactionProcessed is the number of transactions that were processed
 	TransactionProcessed int
 
 	// TransactionMatched is the number of transactions that were matched
 	TransactionMatched int
 
+	// MatchedByID is the number of transactions that were matched on equal IDs
+	MatchedByID int
+
+	// MatchedByHeuristic is the number of transactions that were matched by amount, date and type
+	MatchedByHeuristic int
+
+	// MatchedByCarryforward is the number of matches with an item carried forward from a previous result, see WithCarryforward
+	MatchedByCarryforward int
+
+	// ExactMatches is the number of matched transactions without a discrepancy after rounding to the decimal places
+	// ToleranceMatches is the number matched only within the tolerance, e.g. fees or rounding masking small differences
+	// They sum to TransactionMatched, split, grouped and batched transactions count by the difference of their match
+	ExactMatches     int
+	ToleranceMatches int
+
 	// TransactionUnmatched is the details of transactions that were not matched
 	TransactionUnmatched ReconcileUnmatched
 
 	// TotalDiscrepancies is sum of absolute differences in amount between matched transactions
 	TotalDiscrepancies float64
+
+	// NetDiscrepancy is sum of signed differences (bank minus system) in amount between matched transactions
+	// A positive value means the bank over-reports relative to the system, a negative value means it under-reports
+	NetDiscrepancy float64
+
+	// DiscrepancyHistogram is the number of matched pairs per range of absolute amount discrepancy, see WithHistogramEdges
+	// It shows whether most matches are exact or consistently off by a small fee
+	DiscrepancyHistogram []HistogramBucket
+
+	// SignMismatches are unmatched pairs that only differ by the sign of the bank amount
+	// These are likely type or sign data errors rather than genuine missing transactions
+	// Both sides are still reported in TransactionUnmatched
+	SignMismatches []MatchedPair
+
+	// CurrencyMismatches are unmatched pairs with the same absolute amount and date but a different currency
+	// Items of different currencies never match, both sides are still reported in TransactionUnmatched
+	CurrencyMismatches []MatchedPair
+
+	// SkippedFiles are the input files that could not be read and were skipped
+	SkippedFiles []SkippedFile
+
+	// Suggestions are the closest unmatched bank statement for each unmatched system transaction, keyed by TrxID
+	Suggestions map[string]Suggestion
+
+	// FilteredRows is the number of rows filtered out by the date range, keyed by filename
+	FilteredRows map[string]int
+
+	// RowsOutsideRange is the number of rows filtered out by the date range in all files, set by RunFiles
+	RowsOutsideRange int
+
+	// Warnings are the warnings about the rows read by RunFiles, e.g. future dates with WithFlagFutureDates of pkg/csv
+	Warnings []string
+
+	// ZeroAmountRows is the number of rows excluded for a zero amount, keyed by filename
+	// Only files with excluded rows are listed
+	ZeroAmountRows map[string]int
+
+	// SystemTotal is the control total of the system amounts, CREDIT counted as positive and DEBIT as negative
+	SystemTotal float64
+
+	// BankTotal is the control total of the sign-adjusted bank amounts, in the same convention as SystemTotal
+	BankTotal float64
+
+	// TotalDifference is BankTotal minus SystemTotal, independent of line matching
+	// A non-zero value can reveal missing files even when line matching looks fine
+	TotalDifference float64
+
+	// DuplicateBankIDs are the bank statement UniqueIDs that occur more than once within a bank
+	// Matching assumes the IDs are unique per bank, only the first statement with a duplicate ID can be matched
+	DuplicateBankIDs []DuplicateBankID
+
+	// Ambiguities are the system transactions matched by amount and date while several bank statements were candidates
+	// The engine chose the first candidate, operators may want to confirm the choice
+	Ambiguities []AmbiguityRecord
+
+	// Batches are the settlement batches reconciled by their totals, see WithBatchMatching
+	Batches []BatchResult
+
+	// CarriedForwardMatches are the matched pairs with an item carried forward from a previous result
+	CarriedForwardMatches []MatchedPair
+
+	// SplitMatches are the system transactions matched to several bank statements summing to the amount, see WithSplitMatching
+	// They are counted in TransactionMatched and MatchedByHeuristic, their bank statements are not unmatched
+	SplitMatches []SplitMatch
+
+	// ReferenceGroups are the system transactions sharing a reference matched to one bank statement, see WithReferenceGrouping
+	// They are counted in TransactionMatched but not in MatchedByHeuristic, their bank statements are not unmatched
+	ReferenceGroups []ReferenceGroup
+
+	// Performance is the processing throughput, set by RunFiles and nil for results of Reconcile
+	Performance *Performance
+
+	// Config is the effective configuration of the run, nil for results loaded from files written before it was recorded
+	Config *Config
+
+	// matchedSystem and matchedBank are the TrxIDs and bank keys matched by the run, set by Reconcile for AppendJSON
+	matchedSystem map[string]bool
+	matchedBank   map[string]bool
+}
+
+// DuplicateBankID is a bank statement UniqueID that occurs more than once within a bank
+type DuplicateBankID struct {
+	// BankName is the bank of the statements
+	BankName string `json:"bank_name"`
+
+	// UniqueID is the duplicate ID
+	UniqueID string `json:"unique_id"`
+
+	// Occurrences is the number of statements with the ID
+	Occurrences int `json:"occurrences"`
+}
+
+// AmbiguityRecord is a system transaction that could have matched several bank statements
+type AmbiguityRecord struct {
+	// TrxID is the ID of the system transaction
+	TrxID string `json:"trx_id"`
+
+	// BankName is the bank of the chosen bank statement
+	BankName string `json:"bank_name,omitempty"`
+
+	// Chosen is the UniqueID of the bank statement that was matched
+	Chosen string `json:"chosen"`
+
+	// Candidates are the UniqueIDs of all unmatched bank statements that could have been matched, including the chosen one
+	Candidates []string `json:"candidates"`
+
+	// CandidateBanks are the bank names of the candidates, in the same order
+	CandidateBanks []string `json:"candidate_banks,omitempty"`
+}
+
+// Suggestion is the closest unmatched bank statement for an unmatched system transaction
+type Suggestion struct {
+	// Bank is the suggested bank statement
+	Bank types.BankStatement `json:"bank"`
+
+	// AmountDelta is the absolute difference between the system amount and the absolute bank amount
+	AmountDelta float64 `json:"amount_delta"`
+
+	// DateDelta is the number of days from the system transaction to the bank statement, positive when the bank is later
+	DateDelta int `json:"date_delta_days"`
+}
+
+// SkippedFile is an input file that was skipped and the reason why
+type SkippedFile struct {
+	// Filename is the path of the skipped file
+	Filename string `json:"filename"`
+
+	// Reason is why the file was skipped
+	Reason string `json:"reason"`
+}
+
+// MatchedPair is a system transaction paired with a bank statement
+type MatchedPair struct {
+	// System is the system transaction
+	System types.Transaction `json:"system"`
+
+	// Bank is the bank statement
+	Bank types.BankStatement `json:"bank"`
+}
+
+// SplitMatch is a system transaction matched to several bank statements
+type SplitMatch struct {
+	// System is the system transaction
+	System types.Transaction `json:"system"`
+
+	// Bank are the bank statements, their amounts sum to the system amount within the tolerance
+	Bank []types.BankStatement `json:"bank"`
+}
+
+// ReferenceGroup is a group of system transactions sharing a reference matched to the bank statement with that reference
+type ReferenceGroup struct {
+	// Reference is the payment reference shared by the system transactions and the bank statement
+	Reference string `json:"reference"`
+
+	// System are the system transactions, their amounts sum to the bank amount within the tolerance
+	System []types.Transaction `json:"system"`
+
+	// Bank is the bank statement
+	Bank types.BankStatement `json:"bank"`
 }
 
 // ReconcileUnmatched is the details of transactions that were not matched
@@ -28,6 +209,12 @@ type ReconcileUnmatched struct {
 	// TransactionUnmatched is the number of transactions that were not matched to a bank statement
 	TransactionUnmatched int
 
+	// SystemUnmatchedCount is the number of system transactions that were not matched, the length of SystemUnmatched
+	SystemUnmatchedCount int
+
+	// BankUnmatchedCount is the number of bank statements that were not matched, the length of BankUnmatched
+	BankUnmatchedCount int
+
 	// SystemUnmatched is the number of transactions that were not matched to a bank statement
 	SystemUnmatched []types.Transaction
 
@@ -40,94 +227,867 @@ func (r *ReconcileResult) String() string {
 	// Initialize a new strings.Builder
 	var result strings.Builder
 
+	// Write the summary to the builder, writing to a strings.Builder never fails
+	_ = r.WriteSummary(&result)
+
+	// Return the result as a string
+	return result.String()
+}
+
+// IsEmpty reports whether no system transactions or bank statements were reconciled
+func (r *ReconcileResult) IsEmpty() bool {
+	return r.TransactionProcessed == 0 && r.TransactionUnmatched.TransactionUnmatched == 0
+}
+
+// AllRowsOutsideRange reports whether nothing was reconciled because every row read was outside the date range,
+// as opposed to empty input files
+func (r *ReconcileResult) AllRowsOutsideRange() bool {
+	return r.IsEmpty() && r.RowsOutsideRange > 0
+}
+
+// MatchRate returns the fraction of processed transactions that were matched, from 0 to 1
+// Without processed transactions there is nothing left unmatched, so the rate is 1
+func (r *ReconcileResult) MatchRate() float64 {
+	if r.TransactionProcessed == 0 {
+		return 1
+	}
+	return float64(r.TransactionMatched) / float64(r.TransactionProcessed)
+}
+
+// WriteSummary writes a human readable summary of the reconciliation result to the given writer
+func (r *ReconcileResult) WriteSummary(w io.Writer, opts ...ReportOption) error {
+	return r.writeSummary(w, newReportOptions(opts...))
+}
+
+// writeSummary writes the summary with the report options
+func (r *ReconcileResult) writeSummary(w io.Writer, o reportOptions) error {
+	// Wrap the writer to keep the first write error
+	result := &summaryWriter{w: w, colored: o.colored}
+
 	// Write the summary header
-	result.WriteString("Reconciliation Summary:\n------------------------\n")
+	result.printf("Reconciliation Summary:\n------------------------\n")
 
 	// Write the total transactions processed
-	fmt.Fprintf(&result, "Total transactions processed: %d\n", r.TransactionProcessed)
+	result.printf("Total transactions processed: %d\n", r.TransactionProcessed)
 
 	// Write the total matched transactions
-	fmt.Fprintf(&result, "Total matched transactions: %d\n", r.TransactionMatched)
+	result.printf("Total matched transactions: %s\n", result.paint(colorGreen, strconv.Itoa(r.TransactionMatched)))
+
+	// Write the matched transactions breakdown when ID matching or a carryforward was used
+	if r.MatchedByID > 0 || r.MatchedByCarryforward > 0 {
+		result.printf("- Matched by ID: %d\n", r.MatchedByID)
+		result.printf("- Matched by amount and date: %d\n", r.MatchedByHeuristic)
+	}
+
+	// Write the matches with carried forward items
+	if r.MatchedByCarryforward > 0 {
+		result.printf("- Matched from carryforward: %d\n", r.MatchedByCarryforward)
+	}
+
+	// Write the exact matches and the matches within the tolerance, results of older files have neither
+	if r.ExactMatches > 0 || r.ToleranceMatches > 0 {
+		result.printf("- Exact matches: %d\n", r.ExactMatches)
+		result.printf("- Matched within tolerance: %d\n", r.ToleranceMatches)
+	}
 
 	// Write the total unmatched transactions
-	fmt.Fprintf(&result, "Total unmatched transactions: %d\n", r.TransactionUnmatched.TransactionUnmatched)
+	result.printf("Total unmatched transactions: %s\n", result.paintIf(colorRed, r.TransactionUnmatched.TransactionUnmatched != 0,
+		strconv.Itoa(r.TransactionUnmatched.TransactionUnmatched)))
+
+	// Write the unmatched transactions breakdown by side
+	result.printf("- Unmatched system transactions: %d\n", r.TransactionUnmatched.SystemUnmatchedCount)
+	result.printf("- Unmatched bank statements: %d\n", r.TransactionUnmatched.BankUnmatchedCount)
 
 	// Write the system transactions missing from bank statements
 	if len(r.TransactionUnmatched.SystemUnmatched) > 0 {
-		result.WriteString("\nSystem transactions missing from bank statements:\n")
-		for _, tx := range r.TransactionUnmatched.SystemUnmatched {
-			fmt.Fprintf(&result, "- TrxID: %s, Amount: %.2f, Type: %s, Date: %s\n",
+		result.printf("\n%s\n", result.paint(colorRed, "System transactions missing from bank statements:"))
+		transactions, counts := unmatchedItems(r.TransactionUnmatched.SystemUnmatched, o.dedupe)
+		for k, tx := range transactions {
+			result.printf("- TrxID: %s, Amount: %.2f, Type: %s, Date: %s%s\n",
 				tx.TrxID,
 				tx.Amount,
 				tx.Type,
-				tx.TransactionTime.Format("2006-01-02 15:04:05"))
+				o.formatTime(tx.TransactionTime),
+				countSuffix(counts, k))
+
+			// Write the closest bank statement, if any
+			if suggestion, ok := r.Suggestions[tx.TrxID]; ok {
+				result.printf("  Suggested: Bank: %s, ID: %s, Amount: %.2f, Date: %s (amount delta: %.2f, date delta: %d days)\n",
+					suggestion.Bank.BankName,
+					suggestion.Bank.UniqueID,
+					suggestion.Bank.Amount,
+					o.formatDate(suggestion.Bank.Date),
+					suggestion.AmountDelta,
+					suggestion.DateDelta)
+			}
 		}
 	}
 
 	// Write the bank statements missing from system transactions
 	if len(r.TransactionUnmatched.BankUnmatched) > 0 {
-		result.WriteString("\nBank statements missing from system transactions:\n")
-
-		// Pre-allocate map with capacity
-		bankGroups := make(map[string][]types.BankStatement, len(r.TransactionUnmatched.BankUnmatched))
-		for _, stmt := range r.TransactionUnmatched.BankUnmatched {
-			bankGroups[stmt.BankName] = append(bankGroups[stmt.BankName], stmt)
-		}
+		result.printf("\n%s\n", result.paint(colorRed, "Bank statements missing from system transactions:"))
 
 		// Write the bank statements missing from system transactions
-		for bankName, statements := range bankGroups {
-			fmt.Fprintf(&result, "\nBank: %s\n", bankName)
-			for _, stmt := range statements {
-				fmt.Fprintf(&result, "- ID: %s, Amount: %.2f, Date: %s\n",
+		for bankName, statements := range r.groupBankUnmatched() {
+			result.printf("\nBank: %s\n", bankName)
+			statements, counts := unmatchedItems(statements, o.dedupe)
+			for k, stmt := range statements {
+				result.printf("- ID: %s, Amount: %.2f, Date: %s%s\n",
 					stmt.UniqueID,
 					stmt.Amount,
-					stmt.Date.Format("2006-01-02"))
+					o.formatDate(stmt.Date),
+					countSuffix(counts, k))
 			}
 		}
 	}
 
+	// Write the possible sign mismatches
+	if len(r.SignMismatches) > 0 {
+		result.printf("\nPossible sign mismatches:\n")
+		for _, pair := range r.SignMismatches {
+			result.printf("- TrxID: %s, Type: %s, Amount: %.2f <> Bank: %s, ID: %s, Amount: %.2f, Date: %s\n",
+				pair.System.TrxID,
+				pair.System.Type,
+				pair.System.Amount,
+				pair.Bank.BankName,
+				pair.Bank.UniqueID,
+				pair.Bank.Amount,
+				o.formatDate(pair.Bank.Date))
+		}
+	}
+
+	// Write the currency mismatches
+	if len(r.CurrencyMismatches) > 0 {
+		result.printf("\nCurrency mismatches:\n")
+		for _, pair := range r.CurrencyMismatches {
+			result.printf("- TrxID: %s, Amount: %.2f %s <> Bank: %s, ID: %s, Amount: %.2f %s, Date: %s\n",
+				pair.System.TrxID,
+				pair.System.Amount,
+				pair.System.Currency,
+				pair.Bank.BankName,
+				pair.Bank.UniqueID,
+				pair.Bank.Amount,
+				pair.Bank.Currency,
+				o.formatDate(pair.Bank.Date))
+		}
+	}
+
+	// Write the ambiguous matches
+	if len(r.Ambiguities) > 0 {
+		result.printf("\nAmbiguous matches:\n")
+		for _, ambiguity := range r.Ambiguities {
+			result.printf("- TrxID: %s, Matched: %s, Candidates: %s\n",
+				ambiguity.TrxID,
+				ambiguity.Chosen,
+				strings.Join(ambiguity.Candidates, ", "))
+		}
+	}
+
+	// Write the matches with carried forward items
+	if len(r.CarriedForwardMatches) > 0 {
+		result.printf("\nCarried forward matches:\n")
+		for _, pair := range r.CarriedForwardMatches {
+			result.printf("- TrxID: %s, Amount: %.2f, Date: %s <> Bank: %s, ID: %s, Amount: %.2f, Date: %s\n",
+				pair.System.TrxID,
+				pair.System.Amount,
+				o.formatDate(pair.System.TransactionTime),
+				pair.Bank.BankName,
+				pair.Bank.UniqueID,
+				pair.Bank.Amount,
+				o.formatDate(pair.Bank.Date))
+		}
+	}
+
+	// Write the settlement batches
+	if len(r.Batches) > 0 {
+		result.printf("\nSettlement batches:\n")
+		for _, batch := range r.Batches {
+			status := "unmatched"
+			if batch.Matched {
+				status = "matched"
+			}
+			result.printf("- Batch: %s, System: %.2f (%d), Bank: %.2f (%d), Difference: %.2f, %s\n",
+				batch.BatchID,
+				batch.SystemTotal,
+				batch.SystemCount,
+				batch.BankTotal,
+				batch.BankCount,
+				batch.Difference,
+				status)
+		}
+	}
+
+	// Write the split matches
+	if len(r.SplitMatches) > 0 {
+		result.printf("\nSplit matches:\n")
+		for _, split := range r.SplitMatches {
+			ids := make([]string, len(split.Bank))
+			for i, stmt := range split.Bank {
+				ids[i] = stmt.UniqueID
+			}
+			result.printf("- TrxID: %s, Amount: %.2f <> Bank: %s, IDs: %s\n",
+				split.System.TrxID,
+				split.System.Amount,
+				split.Bank[0].BankName,
+				strings.Join(ids, ", "))
+		}
+	}
+
+	// Write the reference groups
+	if len(r.ReferenceGroups) > 0 {
+		result.printf("\nReference groups:\n")
+		for _, group := range r.ReferenceGroups {
+			var total float64
+			for _, tx := range group.System {
+				total += tx.Amount
+			}
+			result.printf("- Reference: %s, Transactions: %d, Amount: %.2f <> Bank: %s, ID: %s, Amount: %.2f\n",
+				group.Reference,
+				len(group.System),
+				total,
+				group.Bank.BankName,
+				group.Bank.UniqueID,
+				group.Bank.Amount)
+		}
+	}
+
+	// Write the duplicate bank statement IDs
+	if len(r.DuplicateBankIDs) > 0 {
+		result.printf("\nDuplicate bank statement IDs:\n")
+		for _, duplicate := range r.DuplicateBankIDs {
+			result.printf("- Bank: %s, ID: %s, Occurrences: %d\n", duplicate.BankName, duplicate.UniqueID, duplicate.Occurrences)
+		}
+	}
+
+	// Write the rows filtered out by the date range
+	if len(r.FilteredRows) > 0 {
+		result.printf("\nRows outside date range skipped:\n")
+		for _, filename := range sortedKeys(r.FilteredRows) {
+			result.printf("- %s: %d\n", filename, r.FilteredRows[filename])
+		}
+	}
+
+	// Write the rows excluded for a zero amount
+	if len(r.ZeroAmountRows) > 0 {
+		result.printf("\nZero amount rows excluded:\n")
+		for _, filename := range sortedKeys(r.ZeroAmountRows) {
+			result.printf("- %s: %d\n", filename, r.ZeroAmountRows[filename])
+		}
+	}
+
+	// Write the warnings about the rows read
+	if len(r.Warnings) > 0 {
+		result.printf("\nWarnings:\n")
+		for _, warning := range r.Warnings {
+			result.printf("- %s\n", warning)
+		}
+	}
+
+	// Write the skipped files
+	if len(r.SkippedFiles) > 0 {
+		result.printf("\nSkipped files:\n")
+		for _, file := range r.SkippedFiles {
+			result.printf("- %s: %s\n", file.Filename, file.Reason)
+		}
+	}
+
 	// Write the total amount discrepancies
-	fmt.Fprintf(&result, "\nTotal amount discrepancies: %.2f\n", r.TotalDiscrepancies)
+	result.printf("\nTotal amount discrepancies: %s\n", result.paintIf(colorYellow, r.TotalDiscrepancies != 0,
+		fmt.Sprintf("%.2f", r.TotalDiscrepancies)))
 
-	// Return the result as a string
-	return result.String()
+	// Write the net amount discrepancies
+	result.printf("Net amount discrepancies: %s\n", result.paintIf(colorYellow, r.NetDiscrepancy != 0,
+		fmt.Sprintf("%.2f", r.NetDiscrepancy)))
+
+	// Write the discrepancy histogram
+	if len(r.DiscrepancyHistogram) > 0 {
+		result.printf("Discrepancy histogram:\n")
+		for _, bucket := range r.DiscrepancyHistogram {
+			result.printf("- %s: %d\n", bucket.Label, bucket.Count)
+		}
+	}
+
+	// Write the control totals
+	result.printf("Control totals: System: %.2f, Bank: %.2f, Difference: %.2f\n", r.SystemTotal, r.BankTotal, r.TotalDifference)
+
+	// Write the effective configuration when requested
+	if o.explain && r.Config != nil {
+		r.Config.writeConfig(result)
+	}
+
+	// Return the first write error, if any
+	if result.err != nil {
+		return fmt.Errorf("failed to write summary: %w", result.err)
+	}
+	return nil
+}
+
+// summaryWriter is an io.Writer wrapper that stops writing after the first error
+type summaryWriter struct {
+	w       io.Writer
+	err     error
+	colored bool
+}
+
+// printf writes the formatted string unless a previous write has failed
+func (sw *summaryWriter) printf(format string, args ...any) {
+	if sw.err != nil {
+		return
+	}
+	_, sw.err = fmt.Fprintf(sw.w, format, args...)
+}
+
+// countMatches counts the matched transactions as exact matches or matches within the tolerance
+func (r *ReconcileResult) countMatches(count int, exact bool) {
+	if exact {
+		r.ExactMatches += count
+		return
+	}
+	r.ToleranceMatches += count
+}
+
+// jsonResult is the JSON representation of the reconciliation result
+type jsonResult struct {
+	Summary          jsonSummary `json:"summary"`
+	UnmatchedDetails struct {
+		SystemTransactions []types.Transaction              `json:"system_transactions,omitempty"`
+		BankStatements     map[string][]types.BankStatement `json:"bank_statements,omitempty"`
+	} `json:"unmatched_details"`
+	SignMismatches     []MatchedPair         `json:"sign_mismatches,omitempty"`
+	CurrencyMismatches []MatchedPair         `json:"currency_mismatches,omitempty"`
+	SkippedFiles       []SkippedFile         `json:"skipped_files,omitempty"`
+	Suggestions        map[string]Suggestion `json:"suggestions,omitempty"`
+	FilteredRows       map[string]int        `json:"filtered_rows,omitempty"`
+	ZeroAmountRows     map[string]int        `json:"zero_amount_rows,omitempty"`
+	Warnings           []string              `json:"warnings,omitempty"`
+	DuplicateBankIDs   []DuplicateBankID     `json:"duplicate_bank_ids,omitempty"`
+	Ambiguities        []AmbiguityRecord     `json:"ambiguities,omitempty"`
+	SplitMatches       []SplitMatch          `json:"split_matches,omitempty"`
+	ReferenceGroups    []ReferenceGroup      `json:"reference_groups,omitempty"`
+	Batches            []BatchResult         `json:"batches,omitempty"`
+	CarriedForward     []MatchedPair         `json:"carried_forward_matches,omitempty"`
+	Config             *Config               `json:"config,omitempty"`
+}
+
+// jsonSummary is the JSON representation of the reconciliation summary
+type jsonSummary struct {
+	RunID                      string  `json:"run_id,omitempty"`
+	TotalTransactionsProcessed int     `json:"total_transactions_processed"`
+	TotalTransactionsMatched   int     `json:"total_transactions_matched"`
+	MatchedByID                int     `json:"matched_by_id"`
+	MatchedByHeuristic         int     `json:"matched_by_heuristic"`
+	MatchedByCarryforward      int     `json:"matched_by_carryforward,omitempty"`
+	ExactMatches               int     `json:"exact_matches"`
+	ToleranceMatches           int     `json:"tolerance_matches"`
+	TotalTransactionsUnmatched int     `json:"total_transactions_unmatched"`
+	SystemUnmatchedCount       int     `json:"system_unmatched_count"`
+	BankUnmatchedCount         int     `json:"bank_unmatched_count"`
+	TotalDiscrepancies         float64 `json:"total_discrepancies"`
+	NetDiscrepancy             float64 `json:"net_discrepancy"`
+	SystemTotal                float64 `json:"system_total"`
+	BankTotal                  float64 `json:"bank_total"`
+	TotalDifference            float64 `json:"total_difference"`
+
+	// DiscrepancyHistogram is omitted by results written before it was added
+	DiscrepancyHistogram []HistogramBucket `json:"discrepancy_histogram,omitempty"`
+
+	// Performance is only set for runs reading files
+	Performance *jsonPerformance `json:"performance,omitempty"`
+
+	// Timings are only set for runs reading files, omitted by results written before they were added
+	Timings *jsonTimings `json:"timings,omitempty"`
+}
+
+// WriteSummaryJSON writes the summary counts as a single JSON line to the given writer
+func (r *ReconcileResult) WriteSummaryJSON(w io.Writer, opts ...JSONOption) error {
+	// Encode the summary without indentation
+	summary := newJSONOptions(opts...).styled(r.toJSONResult(nil).Summary)
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		return fmt.Errorf("failed to encode JSON summary: %w", err)
+	}
+	return nil
+}
+
+// jsonSummaryResult is the JSON representation of the reconciliation result with only the summary
+type jsonSummaryResult struct {
+	Summary jsonSummary `json:"summary"`
+}
+
+// EncodeJSON writes the reconciliation results as indented JSON to the given writer
+func (r *ReconcileResult) EncodeJSON(w io.Writer, opts ...JSONOption) error {
+	o := newJSONOptions(opts...)
+
+	// Write only the summary when requested
+	if o.summaryOnly {
+		return encodeJSON(w, o.styled(jsonSummaryResult{Summary: r.toJSONResult(nil).Summary}))
+	}
+
+	// Build the result with all unmatched bank statements
+	return encodeJSON(w, o.styled(r.toJSONResult(r.groupBankUnmatched())))
 }
 
 // GenerateJSON generates a JSON file containing reconciliation results
-func (r *ReconcileResult) GenerateJSON(filename string) error {
-	// Define the result structure at package level to avoid recreating it
-	type jsonResult struct {
-		Summary struct {
-			TotalTransactionsProcessed int     `json:"total_transactions_processed"`
-			TotalTransactionsMatched   int     `json:"total_transactions_matched"`
-			TotalTransactionsUnmatched int     `json:"total_transactions_unmatched"`
-			TotalDiscrepancies         float64 `json:"total_discrepancies"`
-		} `json:"summary"`
-		UnmatchedDetails struct {
-			SystemTransactions []types.Transaction              `json:"system_transactions,omitempty"`
-			BankStatements     map[string][]types.BankStatement `json:"bank_statements,omitempty"`
-		} `json:"unmatched_details"`
+func (r *ReconcileResult) GenerateJSON(filename string, opts ...JSONOption) error {
+	// Create the JSON file
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON file: %w", err)
+	}
+	defer file.Close()
+
+	// Write the result to the JSON file
+	return r.EncodeJSON(file, opts...)
+}
+
+// ndjsonRecord is a single unmatched item of the NDJSON output, tagged with its source
+type ndjsonRecord struct {
+	Source        string               `json:"source"`
+	Transaction   *types.Transaction   `json:"transaction,omitempty"`
+	BankStatement *types.BankStatement `json:"bank_statement,omitempty"`
+}
+
+// EncodeNDJSON writes one JSON line per unmatched item to the given writer
+// System transactions come first with source "system", followed by bank statements with source "bank"
+func (r *ReconcileResult) EncodeNDJSON(w io.Writer, opts ...JSONOption) error {
+	o := newJSONOptions(opts...)
+	encoder := json.NewEncoder(w)
+
+	// Write the unmatched system transactions
+	for i := range r.TransactionUnmatched.SystemUnmatched {
+		record := ndjsonRecord{Source: "system", Transaction: &r.TransactionUnmatched.SystemUnmatched[i]}
+		if err := encoder.Encode(o.styled(record)); err != nil {
+			return fmt.Errorf("failed to encode NDJSON: %w", err)
+		}
+	}
+
+	// Write the unmatched bank statements
+	for i := range r.TransactionUnmatched.BankUnmatched {
+		record := ndjsonRecord{Source: "bank", BankStatement: &r.TransactionUnmatched.BankUnmatched[i]}
+		if err := encoder.Encode(o.styled(record)); err != nil {
+			return fmt.Errorf("failed to encode NDJSON: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GenerateNDJSON generates a newline-delimited JSON file with one line per unmatched item
+func (r *ReconcileResult) GenerateNDJSON(filename string, opts ...JSONOption) error {
+	// Create the NDJSON file
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create NDJSON file: %w", err)
+	}
+	defer file.Close()
+
+	// Write the unmatched items to the NDJSON file
+	return r.EncodeNDJSON(file, opts...)
+}
+
+// AppendJSON merges the reconciliation result into an existing JSON file, creating it when it does not exist
+// Match counts, discrepancies, control totals and filtered rows are summed across runs
+// The unmatched system transactions are merged by TrxID and the unmatched bank statements by bank name and UniqueID,
+// dropping the items matched by this run, and the unmatched counts are recomputed from the merged lists
+// Sign and currency mismatches and suggestions of items matched by this run are dropped as well,
+// the other sections are deduplicated keeping the first occurrence and suggestions of this run take precedence
+func (r *ReconcileResult) AppendJSON(filename string) error {
+	// Build the result of this run
+	result := r.toJSONResult(r.groupBankUnmatched())
+
+	// Read the existing result, a missing file starts a new report
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read JSON file: %w", err)
+	}
+	if err == nil {
+		var existing jsonResult
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return fmt.Errorf("failed to decode JSON file %s: %w", filename, err)
+		}
+		result = existing.merge(result, r.matchedSystem, r.matchedBank)
+	}
+
+	// Write the merged result back to the JSON file
+	return writeJSONFile(filename, result)
+}
+
+// LoadJSON reads a reconciliation result from a JSON file written by GenerateJSON
+// Unmatched bank statements are ordered by bank name
+func LoadJSON(filename string) (ReconcileResult, error) {
+	// Read the JSON file
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ReconcileResult{}, fmt.Errorf("failed to read JSON file: %w", err)
+	}
+
+	// Decode the result
+	var result jsonResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return ReconcileResult{}, fmt.Errorf("failed to decode JSON file %s: %w", filename, err)
+	}
+
+	return result.toReconcileResult(), nil
+}
+
+// GenerateJSONPerBank generates one JSON file per bank in the given directory
+// Each file contains the bank's unmatched statements plus the shared unmatched system transactions
+// Mismatches, duplicates, ambiguities, split and grouped matches, carried forward matches and suggestions
+// are limited to the ones involving the bank, so a file never holds statements of another bank
+// Bank names mapping to the same filename are suffixed with _2, _3 and so on in order of bank name
+// Nothing is written when there are no unmatched bank statements
+func (r *ReconcileResult) GenerateJSONPerBank(dir string, opts ...JSONOption) error {
+	// Group the unmatched bank statements by bank name
+	bankGroups := r.groupBankUnmatched()
+	if len(bankGroups) == 0 {
+		return nil
+	}
+
+	// Create the output directory
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Write one file per bank
+	for bankName, name := range bankFilenames(bankGroups) {
+		bankResult := r.forBank(bankName)
+		result := bankResult.toJSONResult(map[string][]types.BankStatement{bankName: bankGroups[bankName]})
+		filename := filepath.Join(dir, name+".json")
+		if err := writeJSONFile(filename, newJSONOptions(opts...).styled(result)); err != nil {
+			return fmt.Errorf("failed to generate JSON file for bank %s: %w", bankName, err)
+		}
+	}
+
+	return nil
+}
+
+// forBank returns a copy of the result keeping only the bank statement details of the given bank
+func (r *ReconcileResult) forBank(bankName string) ReconcileResult {
+	result := *r
+	result.SignMismatches = filterBank(r.SignMismatches, bankName, func(pair MatchedPair) string { return pair.Bank.BankName })
+	result.CurrencyMismatches = filterBank(r.CurrencyMismatches, bankName, func(pair MatchedPair) string { return pair.Bank.BankName })
+	result.CarriedForwardMatches = filterBank(r.CarriedForwardMatches, bankName, func(pair MatchedPair) string { return pair.Bank.BankName })
+	result.DuplicateBankIDs = filterBank(r.DuplicateBankIDs, bankName, func(duplicate DuplicateBankID) string { return duplicate.BankName })
+	result.ReferenceGroups = filterBank(r.ReferenceGroups, bankName, func(group ReferenceGroup) string { return group.Bank.BankName })
+
+	// The bank statements of a split match all belong to the same bank
+	result.SplitMatches = filterBank(r.SplitMatches, bankName, func(split SplitMatch) string {
+		if len(split.Bank) == 0 {
+			return ""
+		}
+		return split.Bank[0].BankName
+	})
+
+	// Keep the ambiguities resolved to the bank, with only the candidates of the bank
+	result.Ambiguities = nil
+	for _, ambiguity := range r.Ambiguities {
+		if ambiguity.BankName != bankName {
+			continue
+		}
+		filtered := ambiguity
+		filtered.Candidates, filtered.CandidateBanks = nil, nil
+		for i, candidate := range ambiguity.Candidates {
+			if i < len(ambiguity.CandidateBanks) && ambiguity.CandidateBanks[i] == bankName {
+				filtered.Candidates = append(filtered.Candidates, candidate)
+				filtered.CandidateBanks = append(filtered.CandidateBanks, bankName)
+			}
+		}
+		result.Ambiguities = append(result.Ambiguities, filtered)
+	}
+
+	// Keep the suggestions pointing to a statement of the bank
+	result.Suggestions = nil
+	for trxID, suggestion := range r.Suggestions {
+		if suggestion.Bank.BankName != bankName {
+			continue
+		}
+		if result.Suggestions == nil {
+			result.Suggestions = make(map[string]Suggestion)
+		}
+		result.Suggestions[trxID] = suggestion
+	}
+
+	return result
+}
+
+// filterBank returns the items belonging to the given bank, it returns nil when none do
+func filterBank[T any](items []T, bankName string, bank func(T) string) []T {
+	var filtered []T
+	for _, item := range items {
+		if bank(item) == bankName {
+			filtered = append(filtered, item)
+		}
 	}
+	return filtered
+}
 
+// groupBankUnmatched groups the unmatched bank statements by bank name
+func (r *ReconcileResult) groupBankUnmatched() map[string][]types.BankStatement {
 	// Pre-allocate map with capacity
 	bankGroups := make(map[string][]types.BankStatement, len(r.TransactionUnmatched.BankUnmatched))
 	for _, stmt := range r.TransactionUnmatched.BankUnmatched {
 		bankGroups[stmt.BankName] = append(bankGroups[stmt.BankName], stmt)
 	}
+	return bankGroups
+}
 
+// toJSONResult converts the reconciliation result with the given bank groups to its JSON representation
+func (r *ReconcileResult) toJSONResult(bankGroups map[string][]types.BankStatement) jsonResult {
 	// Initialize the result
 	result := jsonResult{}
 
 	// Set the summary values
+	result.Summary.RunID = r.RunID
 	result.Summary.TotalTransactionsProcessed = r.TransactionProcessed
 	result.Summary.TotalTransactionsMatched = r.TransactionMatched
+	result.Summary.MatchedByID = r.MatchedByID
+	result.Summary.MatchedByHeuristic = r.MatchedByHeuristic
+	result.Summary.ExactMatches = r.ExactMatches
+	result.Summary.ToleranceMatches = r.ToleranceMatches
+	result.Summary.MatchedByCarryforward = r.MatchedByCarryforward
 	result.Summary.TotalTransactionsUnmatched = r.TransactionUnmatched.TransactionUnmatched
+	result.Summary.SystemUnmatchedCount = r.TransactionUnmatched.SystemUnmatchedCount
+	result.Summary.BankUnmatchedCount = r.TransactionUnmatched.BankUnmatchedCount
 	result.Summary.TotalDiscrepancies = r.TotalDiscrepancies
+	result.Summary.NetDiscrepancy = r.NetDiscrepancy
+	result.Summary.DiscrepancyHistogram = r.DiscrepancyHistogram
+	result.Summary.SystemTotal = r.SystemTotal
+	result.Summary.BankTotal = r.BankTotal
+	result.Summary.TotalDifference = r.TotalDifference
+	result.Summary.Performance = r.Performance.toJSON()
+	result.Summary.Timings = r.Performance.toTimings()
 
 	// Set the unmatched details
 	result.UnmatchedDetails.SystemTransactions = r.TransactionUnmatched.SystemUnmatched
 	result.UnmatchedDetails.BankStatements = bankGroups
+	result.SignMismatches = r.SignMismatches
+	result.CurrencyMismatches = r.CurrencyMismatches
+	result.SkippedFiles = r.SkippedFiles
+	result.Suggestions = r.Suggestions
+	result.FilteredRows = r.FilteredRows
+	result.ZeroAmountRows = r.ZeroAmountRows
+	result.Warnings = r.Warnings
+	result.DuplicateBankIDs = r.DuplicateBankIDs
+	result.Ambiguities = r.Ambiguities
+	result.SplitMatches = r.SplitMatches
+	result.ReferenceGroups = r.ReferenceGroups
+	result.Batches = r.Batches
+	result.CarriedForward = r.CarriedForwardMatches
+	result.Config = r.Config
+
+	return result
+}
 
+// toReconcileResult converts the JSON representation back to a reconciliation result
+func (j jsonResult) toReconcileResult() ReconcileResult {
+	result := ReconcileResult{
+		RunID:                 j.Summary.RunID,
+		TransactionProcessed:  j.Summary.TotalTransactionsProcessed,
+		TransactionMatched:    j.Summary.TotalTransactionsMatched,
+		MatchedByID:           j.Summary.MatchedByID,
+		MatchedByHeuristic:    j.Summary.MatchedByHeuristic,
+		ExactMatches:          j.Summary.ExactMatches,
+		ToleranceMatches:      j.Summary.ToleranceMatches,
+		MatchedByCarryforward: j.Summary.MatchedByCarryforward,
+		TransactionUnmatched: ReconcileUnmatched{
+			TransactionUnmatched: j.Summary.TotalTransactionsUnmatched,
+			SystemUnmatchedCount: j.Summary.SystemUnmatchedCount,
+			BankUnmatchedCount:   j.Summary.BankUnmatchedCount,
+			SystemUnmatched:      j.UnmatchedDetails.SystemTransactions,
+		},
+		TotalDiscrepancies:    j.Summary.TotalDiscrepancies,
+		NetDiscrepancy:        j.Summary.NetDiscrepancy,
+		DiscrepancyHistogram:  j.Summary.DiscrepancyHistogram,
+		SignMismatches:        j.SignMismatches,
+		CurrencyMismatches:    j.CurrencyMismatches,
+		SkippedFiles:          j.SkippedFiles,
+		Suggestions:           j.Suggestions,
+		FilteredRows:          j.FilteredRows,
+		ZeroAmountRows:        j.ZeroAmountRows,
+		Warnings:              j.Warnings,
+		DuplicateBankIDs:      j.DuplicateBankIDs,
+		SystemTotal:           j.Summary.SystemTotal,
+		BankTotal:             j.Summary.BankTotal,
+		TotalDifference:       j.Summary.TotalDifference,
+		Ambiguities:           j.Ambiguities,
+		SplitMatches:          j.SplitMatches,
+		ReferenceGroups:       j.ReferenceGroups,
+		Batches:               j.Batches,
+		CarriedForwardMatches: j.CarriedForward,
+		Config:                j.Config,
+		Performance:           j.Summary.Performance.toPerformance(j.Summary.TotalTransactionsMatched, j.Summary.Timings),
+	}
+
+	// Flatten the bank groups in order of bank name
+	bankNames := make([]string, 0, len(j.UnmatchedDetails.BankStatements))
+	for bankName := range j.UnmatchedDetails.BankStatements {
+		bankNames = append(bankNames, bankName)
+	}
+	sort.Strings(bankNames)
+	for _, bankName := range bankNames {
+		result.TransactionUnmatched.BankUnmatched = append(result.TransactionUnmatched.BankUnmatched,
+			j.UnmatchedDetails.BankStatements[bankName]...)
+	}
+
+	return result
+}
+
+// merge combines the result of a previous run with the result of a new run
+// Unmatched items whose TrxID or bank key was matched by the new run are dropped
+func (j jsonResult) merge(next jsonResult, matchedSystem, matchedBank map[string]bool) jsonResult {
+	merged := jsonResult{}
+
+	// Keep the run ID of the latest run
+	merged.Summary.RunID = next.Summary.RunID
+	if merged.Summary.RunID == "" {
+		merged.Summary.RunID = j.Summary.RunID
+	}
+
+	// Sum the summary values
+	merged.Summary.TotalTransactionsProcessed = j.Summary.TotalTransactionsProcessed + next.Summary.TotalTransactionsProcessed
+	merged.Summary.TotalTransactionsMatched = j.Summary.TotalTransactionsMatched + next.Summary.TotalTransactionsMatched
+	merged.Summary.MatchedByID = j.Summary.MatchedByID + next.Summary.MatchedByID
+	merged.Summary.MatchedByHeuristic = j.Summary.MatchedByHeuristic + next.Summary.MatchedByHeuristic
+	merged.Summary.MatchedByCarryforward = j.Summary.MatchedByCarryforward + next.Summary.MatchedByCarryforward
+	merged.Summary.ExactMatches = j.Summary.ExactMatches + next.Summary.ExactMatches
+	merged.Summary.ToleranceMatches = j.Summary.ToleranceMatches + next.Summary.ToleranceMatches
+	merged.Summary.TotalDiscrepancies = j.Summary.TotalDiscrepancies + next.Summary.TotalDiscrepancies
+	merged.Summary.NetDiscrepancy = j.Summary.NetDiscrepancy + next.Summary.NetDiscrepancy
+	merged.Summary.DiscrepancyHistogram = mergeHistograms(j.Summary.DiscrepancyHistogram, next.Summary.DiscrepancyHistogram)
+	merged.Summary.SystemTotal = j.Summary.SystemTotal + next.Summary.SystemTotal
+	merged.Summary.BankTotal = j.Summary.BankTotal + next.Summary.BankTotal
+	merged.Summary.TotalDifference = j.Summary.TotalDifference + next.Summary.TotalDifference
+	merged.Summary.Performance = mergePerformance(j.Summary.Performance, next.Summary.Performance, merged.Summary.TotalTransactionsMatched)
+	merged.Summary.Timings = mergeTimings(j.Summary.Timings, next.Summary.Timings)
+
+	// Merge the unmatched system transactions by TrxID, dropping the ones matched since
+	merged.UnmatchedDetails.SystemTransactions = appendUnique(
+		withoutMatched(j.UnmatchedDetails.SystemTransactions, func(tx types.Transaction) bool { return matchedSystem[tx.TrxID] }),
+		next.UnmatchedDetails.SystemTransactions, func(tx types.Transaction) string { return tx.TrxID })
+
+	// Merge the unmatched bank statements by bank name and UniqueID, dropping the ones matched since
+	bankMatched := func(stmt types.BankStatement) bool { return matchedBank[bankKey(stmt)] }
+	if len(j.UnmatchedDetails.BankStatements) > 0 || len(next.UnmatchedDetails.BankStatements) > 0 {
+		merged.UnmatchedDetails.BankStatements = make(map[string][]types.BankStatement)
+		for bankName, statements := range j.UnmatchedDetails.BankStatements {
+			if statements = withoutMatched(statements, bankMatched); len(statements) > 0 {
+				merged.UnmatchedDetails.BankStatements[bankName] = statements
+			}
+		}
+		for bankName, statements := range next.UnmatchedDetails.BankStatements {
+			merged.UnmatchedDetails.BankStatements[bankName] = appendUnique(merged.UnmatchedDetails.BankStatements[bankName],
+				statements, func(stmt types.BankStatement) string { return stmt.UniqueID })
+		}
+		if len(merged.UnmatchedDetails.BankStatements) == 0 {
+			merged.UnmatchedDetails.BankStatements = nil
+		}
+	}
+
+	// Recompute the unmatched counts from the merged lists
+	merged.Summary.SystemUnmatchedCount = len(merged.UnmatchedDetails.SystemTransactions)
+	for _, statements := range merged.UnmatchedDetails.BankStatements {
+		merged.Summary.BankUnmatchedCount += len(statements)
+	}
+	merged.Summary.TotalTransactionsUnmatched = merged.Summary.SystemUnmatchedCount + merged.Summary.BankUnmatchedCount
+
+	// Merge the sign and currency mismatches, dropping the pairs with an item matched since
+	pairMatched := func(pair MatchedPair) bool { return matchedSystem[pair.System.TrxID] || bankMatched(pair.Bank) }
+	merged.SignMismatches = appendUnique(withoutMatched(j.SignMismatches, pairMatched), next.SignMismatches, func(pair MatchedPair) string {
+		return pair.System.TrxID + "|" + pair.Bank.BankName + "|" + pair.Bank.UniqueID
+	})
+	merged.CurrencyMismatches = appendUnique(withoutMatched(j.CurrencyMismatches, pairMatched), next.CurrencyMismatches, func(pair MatchedPair) string {
+		return pair.System.TrxID + "|" + pair.Bank.BankName + "|" + pair.Bank.UniqueID
+	})
+
+	// Merge the skipped files and the history of the matches
+	merged.SkippedFiles = appendUnique(j.SkippedFiles, next.SkippedFiles, func(file SkippedFile) string { return file.Filename })
+	merged.DuplicateBankIDs = appendUnique(j.DuplicateBankIDs, next.DuplicateBankIDs, func(duplicate DuplicateBankID) string {
+		return duplicate.BankName + "|" + duplicate.UniqueID
+	})
+	merged.Ambiguities = appendUnique(j.Ambiguities, next.Ambiguities, func(ambiguity AmbiguityRecord) string { return ambiguity.TrxID })
+	merged.CarriedForward = appendUnique(j.CarriedForward, next.CarriedForward, func(pair MatchedPair) string {
+		return pair.System.TrxID + "|" + pair.Bank.BankName + "|" + pair.Bank.UniqueID
+	})
+	merged.SplitMatches = appendUnique(j.SplitMatches, next.SplitMatches, func(split SplitMatch) string { return split.System.TrxID })
+	merged.ReferenceGroups = appendUnique(j.ReferenceGroups, next.ReferenceGroups, func(group ReferenceGroup) string {
+		return group.Reference + "|" + group.Bank.BankName + "|" + group.Bank.UniqueID
+	})
+	merged.Batches = appendUnique(j.Batches, next.Batches, func(batch BatchResult) string { return batch.BatchID })
+
+	// Merge the suggestions, the new run takes precedence
+	if len(j.Suggestions) > 0 || len(next.Suggestions) > 0 {
+		merged.Suggestions = make(map[string]Suggestion, len(j.Suggestions)+len(next.Suggestions))
+		for trxID, suggestion := range j.Suggestions {
+			if !matchedSystem[trxID] && !bankMatched(suggestion.Bank) {
+				merged.Suggestions[trxID] = suggestion
+			}
+		}
+		for trxID, suggestion := range next.Suggestions {
+			merged.Suggestions[trxID] = suggestion
+		}
+		if len(merged.Suggestions) == 0 {
+			merged.Suggestions = nil
+		}
+	}
+
+	// Sum the filtered and zero amount rows per file
+	merged.FilteredRows = sumCounts(j.FilteredRows, next.FilteredRows)
+	merged.ZeroAmountRows = sumCounts(j.ZeroAmountRows, next.ZeroAmountRows)
+	merged.Warnings = appendUnique(j.Warnings, next.Warnings, func(warning string) string { return warning })
+
+	// Keep the configuration of the latest run
+	merged.Config = next.Config
+	if merged.Config == nil {
+		merged.Config = j.Config
+	}
+
+	return merged
+}
+
+// sumCounts sums the counts of both maps per key, it returns nil when both are empty
+func sumCounts(counts, next map[string]int) map[string]int {
+	if len(counts) == 0 && len(next) == 0 {
+		return nil
+	}
+	sum := make(map[string]int, len(counts)+len(next))
+	for key, count := range counts {
+		sum[key] += count
+	}
+	for key, count := range next {
+		sum[key] += count
+	}
+	return sum
+}
+
+// withoutMatched returns the items that are not matched, it returns nil when all are
+func withoutMatched[T any](items []T, matched func(T) bool) []T {
+	var result []T
+	for _, item := range items {
+		if !matched(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// appendUnique appends the items whose key is not already present, keeping the first occurrence
+func appendUnique[T any](items, next []T, key func(T) string) []T {
+	seen := make(map[string]bool, len(items)+len(next))
+	result := make([]T, 0, len(items)+len(next))
+	for _, item := range append(items[:len(items):len(items)], next...) {
+		if seen[key(item)] {
+			continue
+		}
+		seen[key(item)] = true
+		result = append(result, item)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// writeJSONFile writes the given value to an indented JSON file
+func writeJSONFile(filename string, v any) error {
 	// Create the JSON file
 	file, err := os.Create(filename)
 	if err != nil {
@@ -135,14 +1095,69 @@ func (r *ReconcileResult) GenerateJSON(filename string) error {
 	}
 	defer file.Close()
 
+	return encodeJSON(file, v)
+}
+
+// encodeJSON writes the given value as indented JSON to the writer
+func encodeJSON(w io.Writer, v any) error {
 	// Set the JSON encoder to use indentation
-	encoder := json.NewEncoder(file)
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 
 	// Encode the result
-	if err := encoder.Encode(result); err != nil {
+	if err := encoder.Encode(v); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
 	return nil
 }
+
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bankFilenames assigns each bank a unique filename, suffixing the ones colliding with a bank earlier in order of name
+func bankFilenames(bankGroups map[string][]types.BankStatement) map[string]string {
+	// Sort the bank names so the same bank always gets the same suffix
+	bankNames := make([]string, 0, len(bankGroups))
+	for bankName := range bankGroups {
+		bankNames = append(bankNames, bankName)
+	}
+	sort.Strings(bankNames)
+
+	filenames := make(map[string]string, len(bankNames))
+	used := make(map[string]bool, len(bankNames))
+	for _, bankName := range bankNames {
+		base := bankFilename(bankName)
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[name] = true
+		filenames[bankName] = name
+	}
+	return filenames
+}
+
+// bankFilename derives a safe lowercase filename from the bank name
+func bankFilename(bankName string) string {
+	if bankName == "" {
+		return "unknown"
+	}
+
+	// Replace any character that is not safe in a filename
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.ToLower(bankName))
+}
@@ -0,0 +1,49 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// defaultRetryBackoff is the wait before the first retry, it doubles with every retry
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// readWithRetries opens the file and reads it with read, the file is closed after reading
+// Transient errors of opening or reading are retried up to readRetries times with exponential backoff
+// The kind describes the file in open errors, e.g. "system file"
+func (r *reconciler) readWithRetries(filename, kind string, read func(file fs.File) error) error {
+	backoff := r.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := r.readOnce(filename, kind, read)
+		if err == nil || attempt >= r.readRetries || !isTransient(err) {
+			return err
+		}
+
+		// Wait before retrying, longer after every failure
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// readOnce opens the file and reads it with read
+func (r *reconciler) readOnce(filename, kind string, read func(file fs.File) error) error {
+	file, err := r.fsys.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", kind, err)
+	}
+	defer file.Close()
+
+	return read(file)
+}
+
+// isTransient checks if the error is an I/O error that can succeed when retried, like EIO on a network mount
+// Missing files and permission errors are not transient
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.ETIMEDOUT) ||
+		errors.Is(err, syscall.ESTALE)
+}
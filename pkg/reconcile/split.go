@@ -0,0 +1,107 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+)
+
+const (
+	// maxSplitLines is the maximum number of bank statements combined into a split match
+	maxSplitLines = 4
+
+	// maxSplitCandidates is the maximum number of bank statements of a bank and date searched for a split match
+	maxSplitCandidates = 20
+)
+
+// matchSplits matches the unmatched system transactions to several unmatched bank statements of one bank on the same date
+// and in the same direction whose absolute amounts sum to the system amount within the tolerance
+// System transactions are handled in order, each taking the first combination found
+// It returns the indexes of the bank statements keyed by the index of the system transaction
+func (r *reconciler) matchSplits(system []types.Transaction, bank []types.BankStatement, matches []int) map[int][]int {
+	// Index the unclaimed bank statements by date key
+	claimed := claimedBank(bank, matches)
+	bankByDate := make(map[string][]int)
+	for j, bankTx := range bank {
+		if !claimed[bankKey(bankTx)] {
+			key := r.dateKey(bankTx.Date)
+			bankByDate[key] = append(bankByDate[key], j)
+		}
+	}
+
+	splits := make(map[int][]int)
+	for i, sysTx := range system {
+		// Skip already matched system transactions
+		if matches[i] >= 0 {
+			continue
+		}
+
+		// Group the candidates by bank in order of first occurrence, a payment is settled by a single bank
+		var bankNames []string
+		candidates := make(map[string][]int)
+		for _, j := range bankByDate[r.dateKey(sysTx.TransactionTime)] {
+			bankTx := bank[j]
+			if claimed[bankKey(bankTx)] || !currencyMatches(sysTx, bankTx) || (!r.ignoreType && !r.directionMatches(sysTx, bankTx)) {
+				continue
+			}
+			if _, ok := candidates[bankTx.BankName]; !ok {
+				bankNames = append(bankNames, bankTx.BankName)
+			}
+			if len(candidates[bankTx.BankName]) < maxSplitCandidates {
+				candidates[bankTx.BankName] = append(candidates[bankTx.BankName], j)
+			}
+		}
+
+		// Search each bank for a combination summing to the system amount
+		sysUnits := r.systemUnits(sysTx)
+		for _, bankName := range bankNames {
+			group := r.findSplit(bank, candidates[bankName], sysUnits)
+			if group == nil {
+				continue
+			}
+
+			// Record the split and claim the bank statements
+			splits[i] = group
+			for _, j := range group {
+				claimed[bankKey(bank[j])] = true
+			}
+			break
+		}
+	}
+	return splits
+}
+
+// findSplit returns the first combination of 2 to maxSplitLines candidates of one bank whose absolute amounts sum to the system
+// amount within the tolerance of the bank, preferring fewer lines, or nil when there is none
+func (r *reconciler) findSplit(bank []types.BankStatement, candidates []int, sysUnits int64) []int {
+	units := make([]int64, len(candidates))
+	for k, j := range candidates {
+		units[k] = abs(r.toUnits(bank[j].Amount))
+	}
+	tolerance := r.tolerance(sysUnits, bank[candidates[0]].BankName)
+
+	// Search the combinations of each size depth first
+	group := make([]int, 0, maxSplitLines)
+	var search func(start, size int, sum int64) bool
+	search = func(start, size int, sum int64) bool {
+		if len(group) == size {
+			return abs(sysUnits-sum) <= tolerance
+		}
+		for k := start; k < len(candidates); k++ {
+			// Amounts are positive, a partial sum beyond the tolerance cannot match
+			if sum+units[k] > sysUnits+tolerance {
+				continue
+			}
+			group = append(group, candidates[k])
+			if search(k+1, size, sum+units[k]) {
+				return true
+			}
+			group = group[:len(group)-1]
+		}
+		return false
+	}
+	for size := 2; size <= maxSplitLines; size++ {
+		if search(0, size, 0) {
+			return group
+		}
+	}
+	return nil
+}
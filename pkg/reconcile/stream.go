@@ -0,0 +1,196 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"sync"
+	"time"
+)
+
+// StreamingReconciler reconciles append-only feeds incrementally, matching each added item against the buffered
+// unmatched items of the other side as it arrives, e.g. for near-real-time reconciliation
+// Items stay buffered until a counterpart arrives or they fall out of the window, it is safe for concurrent use
+type StreamingReconciler struct {
+	r      *reconciler
+	window time.Duration
+
+	mu sync.Mutex
+
+	// Buffered unmatched items in order of arrival
+	system []types.Transaction
+	bank   []types.BankStatement
+
+	// Items that fell out of the window without a counterpart
+	expiredSystem []types.Transaction
+	expiredBank   []types.BankStatement
+
+	// Latest transaction time or bank date added, the window ends there
+	latest time.Time
+}
+
+// NewStreamingReconciler creates a streaming reconciler keeping unmatched items for the window before the latest
+// transaction time or bank date added, 0 keeps them until Flush
+// Bank dates are days, so the window should cover at least a day to match transactions later on the same day
+// The matching options apply, e.g. WithPercentageTolerance, WithMatcher, WithIDMatching or WithBestMatch, while
+// options matching whole sets, e.g. WithOptimalMatching, WithSplitMatching or WithBatchMatching, are ignored
+func NewStreamingReconciler(window time.Duration, opts ...Option) *StreamingReconciler {
+	return &StreamingReconciler{r: newReconciler(opts...), window: window}
+}
+
+// AddSystem adds a system transaction and matches it against the buffered bank statements
+// It returns the matched pair and true on a match, otherwise the transaction is buffered
+func (s *StreamingReconciler) AddSystem(tx types.Transaction) (MatchedPair, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Advance the window and expire the items before it, a late transaction before the window expires right away
+	s.advance(tx.TransactionTime)
+	if s.beforeWindow(tx.TransactionTime) {
+		s.expiredSystem = append(s.expiredSystem, tx)
+		return MatchedPair{}, false
+	}
+
+	// Match the buffered bank statement or buffer the transaction
+	if j := s.matchBank(tx); j >= 0 {
+		pair := MatchedPair{System: tx, Bank: s.bank[j]}
+		s.bank = append(s.bank[:j], s.bank[j+1:]...)
+		return pair, true
+	}
+	s.system = append(s.system, tx)
+	return MatchedPair{}, false
+}
+
+// AddBank adds a bank statement and matches it against the buffered system transactions
+// It returns the matched pair and true on a match, otherwise the statement is buffered
+func (s *StreamingReconciler) AddBank(stmt types.BankStatement) (MatchedPair, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Advance the window and expire the items before it, a late statement before the window expires right away
+	s.advance(stmt.Date)
+	if s.beforeWindow(stmt.Date) {
+		s.expiredBank = append(s.expiredBank, stmt)
+		return MatchedPair{}, false
+	}
+
+	// Match the buffered system transaction or buffer the statement
+	if i := s.matchSystem(stmt); i >= 0 {
+		pair := MatchedPair{System: s.system[i], Bank: stmt}
+		s.system = append(s.system[:i], s.system[i+1:]...)
+		return pair, true
+	}
+	s.bank = append(s.bank, stmt)
+	return MatchedPair{}, false
+}
+
+// Flush returns the items that fell out of the window and the buffered items without a counterpart, in order
+// of arrival, and clears the buffers, e.g. at the end of a period
+func (s *StreamingReconciler) Flush() ReconcileUnmatched {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unmatched := ReconcileUnmatched{
+		SystemUnmatched: append(s.expiredSystem, s.system...),
+		BankUnmatched:   append(s.expiredBank, s.bank...),
+	}
+	unmatched.SystemUnmatchedCount = len(unmatched.SystemUnmatched)
+	unmatched.BankUnmatchedCount = len(unmatched.BankUnmatched)
+	unmatched.TransactionUnmatched = unmatched.SystemUnmatchedCount + unmatched.BankUnmatchedCount
+
+	s.system, s.bank = nil, nil
+	s.expiredSystem, s.expiredBank = nil, nil
+	return unmatched
+}
+
+// Pending returns the number of buffered system transactions and bank statements still within the window
+func (s *StreamingReconciler) Pending() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.system), len(s.bank)
+}
+
+// advance moves the end of the window to the time when it is later and expires the items before the window
+func (s *StreamingReconciler) advance(t time.Time) {
+	if t.After(s.latest) {
+		s.latest = t
+	}
+	if s.window <= 0 {
+		return
+	}
+
+	// Keep the items within the window, items before it can no longer be matched
+	system := s.system[:0]
+	for _, tx := range s.system {
+		if s.beforeWindow(tx.TransactionTime) {
+			s.expiredSystem = append(s.expiredSystem, tx)
+			continue
+		}
+		system = append(system, tx)
+	}
+	s.system = system
+	bank := s.bank[:0]
+	for _, stmt := range s.bank {
+		if s.beforeWindow(stmt.Date) {
+			s.expiredBank = append(s.expiredBank, stmt)
+			continue
+		}
+		bank = append(bank, stmt)
+	}
+	s.bank = bank
+}
+
+// beforeWindow checks if the time is before the window ending at the latest time added
+func (s *StreamingReconciler) beforeWindow(t time.Time) bool {
+	return s.window > 0 && t.Before(s.latest.Add(-s.window))
+}
+
+// matchBank returns the index of the buffered bank statement matching the system transaction, -1 if none
+// With ID matching a statement with the TrxID as UniqueID wins, otherwise the first or the highest scoring match
+func (s *StreamingReconciler) matchBank(tx types.Transaction) int {
+	if s.r.idMatching {
+		for j, stmt := range s.bank {
+			if stmt.UniqueID == tx.TrxID {
+				return j
+			}
+		}
+	}
+	best, bestScore := -1, candidateScore{}
+	for j, stmt := range s.bank {
+		if !s.r.isMatch(tx, stmt) {
+			continue
+		}
+		score := s.r.score(tx, stmt)
+		if best < 0 || score.better(bestScore) {
+			best, bestScore = j, score
+		}
+		if !s.r.bestMatch {
+			break
+		}
+	}
+	return best
+}
+
+// matchSystem returns the index of the buffered system transaction matching the bank statement, -1 if none
+// With ID matching a transaction with the UniqueID as TrxID wins, otherwise the first or the highest scoring match
+func (s *StreamingReconciler) matchSystem(stmt types.BankStatement) int {
+	if s.r.idMatching {
+		for i, tx := range s.system {
+			if tx.TrxID == stmt.UniqueID {
+				return i
+			}
+		}
+	}
+	best, bestScore := -1, candidateScore{}
+	for i, tx := range s.system {
+		if !s.r.isMatch(tx, stmt) {
+			continue
+		}
+		score := s.r.score(tx, stmt)
+		if best < 0 || score.better(bestScore) {
+			best, bestScore = i, score
+		}
+		if !s.r.bestMatch {
+			break
+		}
+	}
+	return best
+}
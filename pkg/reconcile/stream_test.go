@@ -0,0 +1,106 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStreamingReconciler tests matching interleaved system transactions and bank statements as they arrive
+func TestStreamingReconciler(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStreamingReconciler(48 * time.Hour)
+
+	// A system transaction without a bank statement is buffered
+	tx1 := types.Transaction{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: day.Add(10 * time.Hour)}
+	_, ok := s.AddSystem(tx1)
+	assert.False(t, ok)
+
+	// A bank statement arriving first is buffered and matched by the later transaction
+	bs2 := types.BankStatement{BankName: "BCA", UniqueID: "BS002", Amount: -200.00, Date: day}
+	_, ok = s.AddBank(bs2)
+	assert.False(t, ok)
+	tx2 := types.Transaction{TrxID: "TX002", Amount: 200.00, Type: types.TransactionTypeDebit, TransactionTime: day.Add(11 * time.Hour)}
+	pair, ok := s.AddSystem(tx2)
+	assert.True(t, ok)
+	assert.Equal(t, MatchedPair{System: tx2, Bank: bs2}, pair)
+
+	// The buffered transaction is matched when its bank statement arrives
+	bs1 := types.BankStatement{BankName: "BCA", UniqueID: "BS001", Amount: 100.00, Date: day}
+	pair, ok = s.AddBank(bs1)
+	assert.True(t, ok)
+	assert.Equal(t, MatchedPair{System: tx1, Bank: bs1}, pair)
+	systemPending, bankPending := s.Pending()
+	assert.Zero(t, systemPending)
+	assert.Zero(t, bankPending)
+
+	// A bank statement with a different date or sign does not match
+	tx3 := types.Transaction{TrxID: "TX003", Amount: 300.00, Type: types.TransactionTypeCredit, TransactionTime: day.Add(12 * time.Hour)}
+	_, ok = s.AddSystem(tx3)
+	assert.False(t, ok)
+	bs3 := types.BankStatement{BankName: "BCA", UniqueID: "BS003", Amount: -300.00, Date: day}
+	_, ok = s.AddBank(bs3)
+	assert.False(t, ok)
+
+	// Flushing returns the unmatched items and clears the buffers
+	unmatched := s.Flush()
+	assert.Equal(t, []types.Transaction{tx3}, unmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bs3}, unmatched.BankUnmatched)
+	assert.Equal(t, 2, unmatched.TransactionUnmatched)
+	assert.Empty(t, s.Flush().SystemUnmatched)
+}
+
+// TestStreamingReconciler_Window tests expiring items that fall out of the window without a counterpart
+func TestStreamingReconciler_Window(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStreamingReconciler(24 * time.Hour)
+
+	// The transaction falls out of the window once an item two days later arrives
+	tx1 := types.Transaction{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: day.Add(10 * time.Hour)}
+	_, ok := s.AddSystem(tx1)
+	assert.False(t, ok)
+	bs2 := types.BankStatement{BankName: "BCA", UniqueID: "BS002", Amount: 200.00, Date: day.AddDate(0, 0, 2)}
+	_, ok = s.AddBank(bs2)
+	assert.False(t, ok)
+	systemPending, bankPending := s.Pending()
+	assert.Zero(t, systemPending)
+	assert.Equal(t, 1, bankPending)
+
+	// A late counterpart of the expired transaction is no longer matched
+	bs1 := types.BankStatement{BankName: "BCA", UniqueID: "BS001", Amount: 100.00, Date: day}
+	_, ok = s.AddBank(bs1)
+	assert.False(t, ok)
+
+	// The expired items are flushed before the buffered ones
+	unmatched := s.Flush()
+	assert.Equal(t, []types.Transaction{tx1}, unmatched.SystemUnmatched)
+	assert.Equal(t, []types.BankStatement{bs1, bs2}, unmatched.BankUnmatched)
+	assert.Equal(t, 3, unmatched.TransactionUnmatched)
+}
+
+// TestStreamingReconciler_Options tests applying the matching options to the streamed items
+func TestStreamingReconciler_Options(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// With ID matching the statement with the TrxID wins over an earlier statement of the same amount
+	s := NewStreamingReconciler(0, WithIDMatching(true))
+	other := types.BankStatement{BankName: "BCA", UniqueID: "BS001", Amount: 100.00, Date: day}
+	same := types.BankStatement{BankName: "BCA", UniqueID: "TX001", Amount: 100.00, Date: day}
+	_, ok := s.AddBank(other)
+	assert.False(t, ok)
+	_, ok = s.AddBank(same)
+	assert.False(t, ok)
+	tx := types.Transaction{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: day}
+	pair, ok := s.AddSystem(tx)
+	assert.True(t, ok)
+	assert.Equal(t, same, pair.Bank)
+
+	// The tolerance applies to the streamed amounts
+	s = NewStreamingReconciler(0, WithPercentageTolerance(0.01))
+	_, ok = s.AddSystem(tx)
+	assert.False(t, ok)
+	_, ok = s.AddBank(types.BankStatement{BankName: "BCA", UniqueID: "BS002", Amount: 100.90, Date: day})
+	assert.True(t, ok)
+}
@@ -0,0 +1,259 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"reconciliation/pkg/types"
+	"strings"
+)
+
+// ledgerBankName is the bank name of the ledger entries matched as bank statements
+const ledgerBankName = "LEDGER"
+
+// Presence is the combination of sources an item of a three-way reconciliation is present in
+type Presence struct {
+	System bool `json:"system"`
+	Bank   bool `json:"bank"`
+	Ledger bool `json:"ledger"`
+}
+
+// presences are the seven combinations of sources in the order they are reported
+var presences = []Presence{
+	{System: true, Bank: true, Ledger: true},
+	{System: true, Bank: true},
+	{System: true, Ledger: true},
+	{Bank: true, Ledger: true},
+	{System: true},
+	{Bank: true},
+	{Ledger: true},
+}
+
+// String returns the sources joined by a plus sign, e.g. system+bank
+func (p Presence) String() string {
+	var sources []string
+	if p.System {
+		sources = append(sources, "system")
+	}
+	if p.Bank {
+		sources = append(sources, "bank")
+	}
+	if p.Ledger {
+		sources = append(sources, "ledger")
+	}
+	return strings.Join(sources, "+")
+}
+
+// ThreeWayItem is a system transaction, bank statement and ledger entry matched together, nil for the missing sources
+type ThreeWayItem struct {
+	System *types.Transaction   `json:"system,omitempty"`
+	Bank   *types.BankStatement `json:"bank,omitempty"`
+	Ledger *types.LedgerEntry   `json:"ledger,omitempty"`
+}
+
+// Presence returns the sources the item is present in
+func (i ThreeWayItem) Presence() Presence {
+	return Presence{System: i.System != nil, Bank: i.Bank != nil, Ledger: i.Ledger != nil}
+}
+
+// PresenceCount is the number of items present in a combination of sources
+type PresenceCount struct {
+	Presence Presence `json:"presence"`
+	Count    int      `json:"count"`
+}
+
+// ThreeWayResult is the result of reconciling the system transactions, bank statements and ledger entries
+type ThreeWayResult struct {
+	// Items are the matched and unmatched items in order of the system transactions,
+	// then the remaining bank statements, then the remaining ledger entries
+	Items []ThreeWayItem
+}
+
+// Counts returns the number of items of each of the seven combinations of sources, including empty ones
+func (r *ThreeWayResult) Counts() []PresenceCount {
+	counts := make(map[Presence]int, len(presences))
+	for _, item := range r.Items {
+		counts[item.Presence()]++
+	}
+	result := make([]PresenceCount, 0, len(presences))
+	for _, presence := range presences {
+		result = append(result, PresenceCount{Presence: presence, Count: counts[presence]})
+	}
+	return result
+}
+
+// Missing returns the items not present in all three sources
+func (r *ThreeWayResult) Missing() []ThreeWayItem {
+	var missing []ThreeWayItem
+	for _, item := range r.Items {
+		if presence := item.Presence(); !presence.System || !presence.Bank || !presence.Ledger {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+// WriteSummary writes the number of items of each combination of sources to the writer
+func (r *ThreeWayResult) WriteSummary(w io.Writer) error {
+	result := &summaryWriter{w: w}
+	result.printf("Three-way reconciliation:\n")
+	for _, count := range r.Counts() {
+		result.printf("- %s: %d\n", count.Presence, count.Count)
+	}
+	if result.err != nil {
+		return fmt.Errorf("failed to write summary: %w", result.err)
+	}
+	return nil
+}
+
+// String returns the three-way summary
+func (r *ThreeWayResult) String() string {
+	var result strings.Builder
+
+	// Writing to a strings.Builder never fails
+	_ = r.WriteSummary(&result)
+	return result.String()
+}
+
+// ReconcileThreeWay reconciles the system transactions, the bank statements and the internal ledger entries,
+// reporting which sources each item is present in, the input slices are not modified
+// The system transactions are matched to the bank statements and to the ledger entries like Reconcile, then the
+// remaining bank statements to the remaining ledger entries, ledger entries are signed like the bank statements
+// The matching options apply, e.g. WithPercentageTolerance, WithIDMatching or WithOptimalMatching, while batch and
+// split matching, carryforward and file options are ignored
+func ReconcileThreeWay(system []types.Transaction, bank []types.BankStatement, ledger []types.LedgerEntry, opts ...Option) ThreeWayResult {
+	r := newReconciler(opts...)
+
+	// Match the ledger entries as bank statements of the ledger
+	ledgerStatements := make([]types.BankStatement, len(ledger))
+	for k, entry := range ledger {
+		ledgerStatements[k] = types.BankStatement{
+			BankName:  ledgerBankName,
+			UniqueID:  entry.EntryID,
+			Amount:    entry.Amount,
+			Date:      entry.Date,
+			Direction: entry.Direction,
+		}
+	}
+
+	// Match the system transactions to the bank statements and to the ledger entries
+	bankMatches := r.matchPairs(system, bank)
+	ledgerMatches := r.matchPairs(system, ledgerStatements)
+
+	// Match the bank statements without a ledger entry to the ledger entries without a system transaction
+	systemOfBank := make(map[int]int, len(bank))
+	claimedLedger := make(map[int]bool, len(ledger))
+	for i := range system {
+		if bankMatches[i] >= 0 {
+			systemOfBank[bankMatches[i]] = i
+		}
+		if ledgerMatches[i] >= 0 {
+			claimedLedger[ledgerMatches[i]] = true
+		}
+	}
+	var bankIdx, ledgerIdx []int
+	var bankTxs []types.Transaction
+	var remainingLedger []types.BankStatement
+	for j, bankTx := range bank {
+		if i, ok := systemOfBank[j]; !ok || ledgerMatches[i] < 0 {
+			bankIdx = append(bankIdx, j)
+			bankTxs = append(bankTxs, r.statementTransaction(bankTx))
+		}
+	}
+	for k := range ledger {
+		if !claimedLedger[k] {
+			ledgerIdx = append(ledgerIdx, k)
+			remainingLedger = append(remainingLedger, ledgerStatements[k])
+		}
+	}
+	ledgerOfBank := make(map[int]int, len(bankIdx))
+	for n, m := range r.matchPairs(bankTxs, remainingLedger) {
+		if m >= 0 {
+			ledgerOfBank[bankIdx[n]] = ledgerIdx[m]
+		}
+	}
+
+	// Collect the items of the system transactions with their bank statement and ledger entry
+	result := ThreeWayResult{Items: make([]ThreeWayItem, 0, len(system))}
+	usedBank := make(map[int]bool, len(bank))
+	usedLedger := make(map[int]bool, len(ledger))
+	for i := range system {
+		item := ThreeWayItem{System: &system[i]}
+		if j := bankMatches[i]; j >= 0 {
+			item.Bank = &bank[j]
+			usedBank[j] = true
+
+			// A bank statement matched to a ledger entry completes a system transaction without one
+			if k, ok := ledgerOfBank[j]; ok && ledgerMatches[i] < 0 {
+				item.Ledger = &ledger[k]
+				usedLedger[k] = true
+			}
+		}
+		if k := ledgerMatches[i]; k >= 0 {
+			item.Ledger = &ledger[k]
+			usedLedger[k] = true
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	// Collect the remaining bank statements with their ledger entry, then the remaining ledger entries
+	for j := range bank {
+		if usedBank[j] {
+			continue
+		}
+		item := ThreeWayItem{Bank: &bank[j]}
+		if k, ok := ledgerOfBank[j]; ok {
+			item.Ledger = &ledger[k]
+			usedLedger[k] = true
+		}
+		result.Items = append(result.Items, item)
+	}
+	for k := range ledger {
+		if !usedLedger[k] {
+			result.Items = append(result.Items, ThreeWayItem{Ledger: &ledger[k]})
+		}
+	}
+
+	return result
+}
+
+// matchPairs matches the system transactions to the bank statements on their own, without batches, splits or carryforward
+// It returns the index of the matched bank statement of each system transaction, -1 for unmatched ones
+func (r *reconciler) matchPairs(system []types.Transaction, bank []types.BankStatement) []int {
+	matches := make([]int, len(system))
+	for i := range matches {
+		matches[i] = -1
+	}
+	if r.idMatching {
+		r.matchByID(system, bank, matches)
+	}
+	r.matchRemaining(system, bank, matches)
+	return matches
+}
+
+// statementTransaction converts a bank statement to a system transaction to match it against the ledger entries
+// The type is the bank direction or otherwise derived from the sign, negative amounts are DEBIT and others CREDIT
+func (r *reconciler) statementTransaction(bankTx types.BankStatement) types.Transaction {
+	txType := bankTx.Direction
+	if txType == "" {
+		amount := bankTx.Amount
+		if r.invertBankSign {
+			amount = -amount
+		}
+		txType = types.TransactionTypeCredit
+		if amount < 0 {
+			txType = types.TransactionTypeDebit
+		}
+	}
+
+	// Signed system amounts give the direction by the sign
+	amount := abs(bankTx.Amount)
+	if txType == types.TransactionTypeDebit {
+		amount = -amount
+	}
+	return types.Transaction{
+		TrxID:           bankTx.UniqueID,
+		Amount:          amount,
+		Type:            txType,
+		TransactionTime: bankTx.Date,
+	}
+}
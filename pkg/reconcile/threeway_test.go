@@ -0,0 +1,89 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcileThreeWay tests reporting the sources each item is present in
+func TestReconcileThreeWay(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	system := []types.Transaction{
+		{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TX002", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TX003", Amount: 300.00, Type: types.TransactionTypeDebit, TransactionTime: date},
+		{TrxID: "TX004", Amount: 400.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bank := []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: 100.00, Date: day},
+		{BankName: "BCA", UniqueID: "BS002", Amount: 200.00, Date: day},
+		{BankName: "BCA", UniqueID: "BS005", Amount: -500.00, Date: day},
+		{BankName: "BCA", UniqueID: "BS006", Amount: 600.00, Date: day},
+	}
+	ledger := []types.LedgerEntry{
+		{EntryID: "LE001", Amount: 100.00, Date: day},
+		{EntryID: "LE003", Amount: -300.00, Date: day},
+		{EntryID: "LE005", Amount: -500.00, Date: day},
+		{EntryID: "LE007", Amount: 700.00, Date: day},
+	}
+
+	result := ReconcileThreeWay(system, bank, ledger)
+
+	// Each item is reported with the sources it is present in
+	assert.Equal(t, []ThreeWayItem{
+		{System: &system[0], Bank: &bank[0], Ledger: &ledger[0]},
+		{System: &system[1], Bank: &bank[1]},
+		{System: &system[2], Ledger: &ledger[1]},
+		{System: &system[3]},
+		{Bank: &bank[2], Ledger: &ledger[2]},
+		{Bank: &bank[3]},
+		{Ledger: &ledger[3]},
+	}, result.Items)
+
+	// All seven combinations are counted
+	assert.Equal(t, []PresenceCount{
+		{Presence: Presence{System: true, Bank: true, Ledger: true}, Count: 1},
+		{Presence: Presence{System: true, Bank: true}, Count: 1},
+		{Presence: Presence{System: true, Ledger: true}, Count: 1},
+		{Presence: Presence{Bank: true, Ledger: true}, Count: 1},
+		{Presence: Presence{System: true}, Count: 1},
+		{Presence: Presence{Bank: true}, Count: 1},
+		{Presence: Presence{Ledger: true}, Count: 1},
+	}, result.Counts())
+	assert.Len(t, result.Missing(), 6)
+	assert.Equal(t, "Three-way reconciliation:\n- system+bank+ledger: 1\n- system+bank: 1\n- system+ledger: 1\n"+
+		"- bank+ledger: 1\n- system: 1\n- bank: 1\n- ledger: 1\n", result.String())
+}
+
+// TestReconcileThreeWay_BankCompletesLedger tests completing a system and bank match with a ledger entry matching only the bank
+func TestReconcileThreeWay_BankCompletesLedger(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	system := []types.Transaction{{TrxID: "TX001", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date}}
+	bank := []types.BankStatement{{BankName: "BCA", UniqueID: "BS001", Amount: 100.01, Date: day}}
+	ledger := []types.LedgerEntry{{EntryID: "LE001", Amount: 100.02, Date: day}}
+
+	// The ledger is outside the tolerance of the system amount, but within the tolerance of the bank amount
+	result := ReconcileThreeWay(system, bank, ledger)
+	assert.Equal(t, []ThreeWayItem{{System: &system[0], Bank: &bank[0], Ledger: &ledger[0]}}, result.Items)
+	assert.Empty(t, result.Missing())
+
+	// A ledger entry in the other direction is missing from the ledger
+	ledger[0].Direction = types.TransactionTypeDebit
+	result = ReconcileThreeWay(system, bank, ledger)
+	assert.Equal(t, []ThreeWayItem{{System: &system[0], Bank: &bank[0]}, {Ledger: &ledger[0]}}, result.Items)
+}
+
+// TestReconcileThreeWay_Empty tests reconciling empty sources
+func TestReconcileThreeWay_Empty(t *testing.T) {
+	result := ReconcileThreeWay(nil, nil, nil)
+	assert.Empty(t, result.Items)
+	assert.Len(t, result.Counts(), 7)
+	for _, count := range result.Counts() {
+		assert.Zero(t, count.Count)
+	}
+}
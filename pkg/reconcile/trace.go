@@ -0,0 +1,100 @@
+package reconcile
+
+import (
+	"fmt"
+	"reconciliation/pkg/types"
+	"strings"
+)
+
+// trace writes for each system transaction with the traced ID every bank statement it was compared against,
+// why each failed to match and the outcome of the reconciliation, to debug a transaction left unmatched
+func (r *reconciler) trace(system []types.Transaction, bank []types.BankStatement, matches []int, splits map[int][]int, batched batchMatches) {
+	// Index the matched system transaction of each bank statement
+	matchedBy := make(map[int]string, len(bank))
+	for i, j := range matches {
+		if j >= 0 {
+			matchedBy[j] = system[i].TrxID
+		}
+	}
+	for i, group := range splits {
+		for _, j := range group {
+			matchedBy[j] = system[i].TrxID
+		}
+	}
+
+	// A failed trace write does not fail the reconciliation
+	w := &summaryWriter{w: r.traceWriter}
+	found := false
+	for i, sysTx := range system {
+		if sysTx.TrxID != r.traceID {
+			continue
+		}
+		found = true
+		w.printf("Trace TrxID: %s, Amount: %.2f, Type: %s, Date: %s\n",
+			sysTx.TrxID,
+			sysTx.Amount,
+			sysTx.Type,
+			sysTx.TransactionTime.Format("2006-01-02 15:04:05"))
+
+		// Write the reasons each bank statement failed to match
+		for j, bankTx := range bank {
+			reasons := r.mismatchReasons(sysTx, bankTx)
+			if len(reasons) == 0 {
+				reasons = []string{"match"}
+			}
+			if trxID, ok := matchedBy[j]; ok && j != matches[i] {
+				reasons = append(reasons, "matched to TrxID "+trxID)
+			}
+			w.printf("- Bank: %s, ID: %s, Amount: %.2f, Date: %s: %s\n",
+				bankTx.BankName,
+				bankTx.UniqueID,
+				bankTx.Amount,
+				bankTx.Date.Format("2006-01-02"),
+				strings.Join(reasons, ", "))
+		}
+
+		// Write the outcome
+		switch group, split := splits[i]; {
+		case r.batchMatching && batched.system[i]:
+			w.printf("Result: matched in batch %s\n", sysTx.BatchID)
+		case split:
+			ids := make([]string, len(group))
+			for k, j := range group {
+				ids[k] = bank[j].UniqueID
+			}
+			w.printf("Result: split matched to Bank: %s, IDs: %s\n", bank[group[0]].BankName, strings.Join(ids, ", "))
+		case matches[i] >= 0:
+			w.printf("Result: matched to Bank: %s, ID: %s\n", bank[matches[i]].BankName, bank[matches[i]].UniqueID)
+		default:
+			w.printf("Result: unmatched\n")
+		}
+	}
+	if !found {
+		w.printf("Trace TrxID: %s not found in the system transactions\n", r.traceID)
+	}
+}
+
+// mismatchReasons returns why a bank statement does not match a system transaction by the rules of isMatch,
+// e.g. the amount difference beyond the tolerance, the date difference or a sign mismatch, empty when it matches
+func (r *reconciler) mismatchReasons(sysTx types.Transaction, bankTx types.BankStatement) []string {
+	if r.matcher != nil {
+		if r.matcher.Match(sysTx, bankTx) {
+			return nil
+		}
+		return []string{"rejected by the custom matcher"}
+	}
+
+	var reasons []string
+	if !r.ignoreType && !r.directionMatches(sysTx, bankTx) {
+		reasons = append(reasons, "sign mismatch")
+	}
+	sysUnits := r.systemUnits(sysTx)
+	tolerance := r.tolerance(sysUnits, bankTx.BankName)
+	if diff := abs(sysUnits - abs(r.toUnits(bankTx.Amount))); diff > tolerance {
+		reasons = append(reasons, fmt.Sprintf("amount diff %.2f exceeds tolerance %.2f", r.fromUnits(diff), r.fromUnits(tolerance)))
+	}
+	if !r.dateMatches(sysTx.TransactionTime, bankTx.Date) {
+		reasons = append(reasons, fmt.Sprintf("date diff %d days", r.score(sysTx, bankTx).dateDelta))
+	}
+	return reasons
+}
@@ -0,0 +1,45 @@
+package reconcile
+
+import (
+	"reconciliation/pkg/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReconcile_Trace tests tracing why the bank statements failed to match a system transaction
+func TestReconcile_Trace(t *testing.T) {
+	date := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+	systemTxs := []types.Transaction{
+		{TrxID: "TRX1", Amount: 100.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+		{TrxID: "TRX2", Amount: 200.00, Type: types.TransactionTypeCredit, TransactionTime: date},
+	}
+	bankTxs := []types.BankStatement{
+		{BankName: "BRI", UniqueID: "BANK1", Amount: 100.00, Date: date},
+		{BankName: "BRI", UniqueID: "BANK2", Amount: -200.00, Date: date},
+		{BankName: "BRI", UniqueID: "BANK3", Amount: 200.50, Date: date.AddDate(0, 0, 2)},
+	}
+
+	// Only the traced transaction is written, with the reasons of every bank statement and the outcome
+	var trace strings.Builder
+	result := Reconcile(systemTxs, bankTxs, WithTrace("TRX2", &trace))
+	assert.Equal(t, 1, result.TransactionMatched)
+	assert.Equal(t, "Trace TrxID: TRX2, Amount: 200.00, Type: CREDIT, Date: 2024-03-20 00:00:00\n"+
+		"- Bank: BRI, ID: BANK1, Amount: 100.00, Date: 2024-03-20: amount diff 100.00 exceeds tolerance 0.01, matched to TrxID TRX1\n"+
+		"- Bank: BRI, ID: BANK2, Amount: -200.00, Date: 2024-03-20: sign mismatch\n"+
+		"- Bank: BRI, ID: BANK3, Amount: 200.50, Date: 2024-03-22: amount diff 0.50 exceeds tolerance 0.01, date diff 2 days\n"+
+		"Result: unmatched\n", trace.String())
+
+	// A matched transaction reports its match
+	trace.Reset()
+	Reconcile(systemTxs, bankTxs, WithTrace("TRX1", &trace))
+	assert.Contains(t, trace.String(), "- Bank: BRI, ID: BANK1, Amount: 100.00, Date: 2024-03-20: match\n")
+	assert.Contains(t, trace.String(), "Result: matched to Bank: BRI, ID: BANK1\n")
+
+	// An unknown ID is reported
+	trace.Reset()
+	Reconcile(systemTxs, bankTxs, WithTrace("TRX9", &trace))
+	assert.Equal(t, "Trace TrxID: TRX9 not found in the system transactions\n", trace.String())
+}
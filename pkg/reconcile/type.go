@@ -0,0 +1,527 @@
+package reconcile
+
+import (
+	"io"
+	"io/fs"
+	"math"
+	pkgcsv "reconciliation/pkg/csv"
+	"reconciliation/pkg/types"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sign is the expected sign of a bank statement amount for a transaction type
+type Sign int
+
+const (
+	// Enum for bank amount sign
+	SignEither Sign = iota
+	SignPositive
+	SignNegative
+)
+
+// DateGranularity is the precision a system transaction and bank statement date must agree on to match
+type DateGranularity int
+
+const (
+	// Enum for date granularity
+	DateGranularityDay DateGranularity = iota
+	DateGranularityMonth
+	DateGranularityYear
+)
+
+// defaultTypeSignRules are the sign rules used when no custom rule is given for a type
+var defaultTypeSignRules = map[types.TransactionType]Sign{
+	types.TransactionTypeDebit:  SignNegative,
+	types.TransactionTypeCredit: SignPositive,
+}
+
+// reconciler holds the configuration of the reconciliation process
+type reconciler struct {
+	// Expected bank amount sign per system transaction type
+	typeSignRules map[types.TransactionType]Sign
+
+	// Number of workers used to match transactions, 1 or less matches sequentially
+	workers int
+
+	// Number of decimal places amounts are rounded to
+	decimalPlaces int
+
+	// Power of ten of the decimal places, used to convert amounts to integer units
+	pow10 float64
+
+	// Amount of discrepancy allowed in integer units
+	toleranceUnits int64
+
+	// Fraction of the system amount allowed as discrepancy, combined with the absolute tolerance
+	percentageTolerance float64
+
+	// Absolute amount of discrepancy allowed per bank name in upper case, replacing the global tolerance for the bank
+	bankTolerances     map[string]float64
+	bankToleranceUnits map[string]int64
+
+	// Precision the dates must agree on to match, by default the same day
+	dateGranularity DateGranularity
+
+	// Number of business days a bank statement may be posted after the system transaction, 0 requires the same date key
+	businessDayWindow int
+
+	// Holidays skipped when counting business days, keyed by YYYY-MM-DD
+	holidays map[string]bool
+
+	// Skip input files that cannot be read instead of failing
+	skipInvalidFiles bool
+
+	// Maximum number of bank files read at once
+	fileConcurrency int
+
+	// Map input files into memory instead of reading them, see WithMmap
+	mmap bool
+
+	// Additional options applied to every CSV reader
+	csvOptions []pkgcsv.Option
+
+	// Field delimiter of CSV files, a comma when zero
+	delimiter rune
+
+	// Number of times opening and reading a file is retried on transient errors
+	readRetries int
+
+	// Wait before the first retry, doubled with every retry
+	retryBackoff time.Duration
+
+	// File system the input files are read from
+	fsys fs.FS
+
+	// Match system TrxID to bank UniqueID before matching by amount, date and type
+	idMatching bool
+
+	// Number of days around an unmatched system transaction searched for a suggestion, 0 disables suggestions
+	suggestionWindow int
+
+	// Only reconcile transactions of this type, empty reconciles all types
+	typeFilter types.TransactionType
+
+	// Flip the expected bank amount sign, for banks reporting CREDIT as negative and DEBIT as positive
+	invertBankSign bool
+
+	// Report the number of rows filtered out by the date range per file
+	reportFiltered bool
+
+	// Take the direction from the system amount sign instead of the Type, debits are negative amounts
+	useSignedAmount bool
+
+	// Match on the absolute amount and date only, skipping the direction check against the type
+	ignoreType bool
+
+	// Infer the sign convention of each bank instead of applying the type sign rules to all banks,
+	// the banks found to report inverted signs are set by reconcile
+	consistentBankSign bool
+	invertedBanks      map[string]bool
+
+	// Pick the highest scoring unclaimed candidate instead of the first one passing isMatch
+	bestMatch bool
+
+	// Maximize the number of matches per date instead of matching in order
+	optimalMatching bool
+
+	// Reconcile settlement batches by their totals instead of matching single transactions
+	batchMatching bool
+
+	// Match unmatched system transactions to several bank statements of the same date summing to the amount
+	splitMatching bool
+
+	// Match system transactions sharing a reference to the bank statement with that reference by their summed amount
+	referenceGrouping bool
+
+	// Upper edges of the discrepancy histogram buckets in ascending order
+	histogramEdges []float64
+
+	// Unmatched items of the previous period added to the matching pool
+	carriedSystem []types.Transaction
+	carriedBank   []types.BankStatement
+
+	// Additional sources of system transactions and bank statements read with the files, e.g. a database
+	systemSource SystemSource
+	bankSource   BankSource
+
+	// Custom matcher replacing the built-in matching by amount, date and type, nil uses the built-in matching
+	matcher Matcher
+
+	// System transaction ID whose comparisons are traced to the trace writer, empty disables tracing
+	traceID     string
+	traceWriter io.Writer
+
+	// Identifier of the run recorded in the result, empty leaves it unset
+	runID string
+}
+
+// SystemSource reads system transactions within the date range from a source other than files, e.g. a database
+type SystemSource interface {
+	ReadSystemTransactions(start, end time.Time) ([]types.Transaction, error)
+}
+
+// BankSource reads bank statements within the date range from a source other than files, e.g. a database
+type BankSource interface {
+	ReadBankStatements(start, end time.Time) ([]types.BankStatement, error)
+}
+
+// Option is a functional option for the reconciliation process
+type Option func(*reconciler)
+
+// WithTypeSignRules sets how each transaction type should appear on the bank side
+// Rules are merged over the default DEBIT/CREDIT rules, types without a rule accept either sign
+func WithTypeSignRules(rules map[types.TransactionType]Sign) Option {
+	return func(r *reconciler) {
+		for txType, sign := range rules {
+			r.typeSignRules[txType] = sign
+		}
+	}
+}
+
+// WithWorkers sets the number of workers used to match transactions concurrently
+func WithWorkers(workers int) Option {
+	return func(r *reconciler) {
+		r.workers = workers
+	}
+}
+
+// maxDecimalPlaces is the most decimal places amounts are rounded to, more are capped
+// Amounts are compared in units of the last decimal place, 9 places keep amounts up to 9 billion within an int64
+const maxDecimalPlaces = 9
+
+// WithDecimalPlaces sets the number of decimal places amounts are rounded to, from 0 to maxDecimalPlaces
+// The amount tolerance is one unit of the last decimal place, e.g. 0.01 for 2 decimal places
+func WithDecimalPlaces(decimalPlaces int) Option {
+	return func(r *reconciler) {
+		r.decimalPlaces = decimalPlaces
+	}
+}
+
+// WithSkipInvalidFiles skips bank files that cannot be read instead of failing
+// Skipped files are reported in the result
+func WithSkipInvalidFiles(skipInvalidFiles bool) Option {
+	return func(r *reconciler) {
+		r.skipInvalidFiles = skipInvalidFiles
+	}
+}
+
+// WithFileConcurrency sets the maximum number of bank files read at once, it defaults to the number of CPUs
+// Values less than 1 read one file at a time
+func WithFileConcurrency(n int) Option {
+	return func(r *reconciler) {
+		r.fileConcurrency = n
+	}
+}
+
+// WithMmap maps the input files of the operating system read-only into memory instead of reading them,
+// reducing the read syscalls for very large files, files of other file systems, e.g. S3, are read as usual
+// Platforms without mmap and files that cannot be mapped fall back to reading
+func WithMmap(mmap bool) Option {
+	return func(r *reconciler) {
+		r.mmap = mmap
+	}
+}
+
+// WithCSVOptions sets additional options applied to every CSV reader when reading files
+func WithCSVOptions(opts ...pkgcsv.Option) Option {
+	return func(r *reconciler) {
+		r.csvOptions = append(r.csvOptions, opts...)
+	}
+}
+
+// WithDelimiter sets the field delimiter of CSV files, e.g. ';' or '\t', it defaults to a comma
+func WithDelimiter(delimiter rune) Option {
+	return func(r *reconciler) {
+		r.delimiter = delimiter
+	}
+}
+
+// WithReadRetries retries opening and reading an input file up to n times on transient errors like EIO,
+// waiting longer before every retry, missing files are never retried
+func WithReadRetries(n int) Option {
+	return func(r *reconciler) {
+		r.readRetries = n
+	}
+}
+
+// WithFS reads the input files from the given file system instead of the operating system,
+// e.g. an embedded file system, an fstest.MapFS in tests or a cloud storage backed file system
+func WithFS(fsys fs.FS) Option {
+	return func(r *reconciler) {
+		r.fsys = fsys
+	}
+}
+
+// WithIDMatching first matches system transactions to bank statements with a UniqueID equal to the TrxID
+// The remaining transactions fall back to matching by amount, date and type
+func WithIDMatching(idMatching bool) Option {
+	return func(r *reconciler) {
+		r.idMatching = idMatching
+	}
+}
+
+// WithSuggestionWindow sets the number of days around an unmatched system transaction
+// searched for the closest unmatched bank statement, 0 disables suggestions
+func WithSuggestionWindow(days int) Option {
+	return func(r *reconciler) {
+		r.suggestionWindow = days
+	}
+}
+
+// WithTypeFilter only reconciles system transactions of the given type and the bank statements
+// whose direction or amount sign is consistent with it, empty reconciles all types
+// Filtered-out system transactions are excluded from TransactionProcessed
+func WithTypeFilter(txType types.TransactionType) Option {
+	return func(r *reconciler) {
+		r.typeFilter = txType
+	}
+}
+
+// WithInvertBankSign flips the expected bank amount sign for banks reporting CREDIT as negative
+// and DEBIT as positive, a system DEBIT then matches a positive bank amount and vice versa
+func WithInvertBankSign(invertBankSign bool) Option {
+	return func(r *reconciler) {
+		r.invertBankSign = invertBankSign
+	}
+}
+
+// WithPercentageTolerance allows a discrepancy up to a fraction of the system amount, e.g. 0.005 for 0.5%
+// The larger of the absolute tolerance and the percentage of the system amount is used
+func WithPercentageTolerance(pct float64) Option {
+	return func(r *reconciler) {
+		r.percentageTolerance = pct
+	}
+}
+
+// WithBankTolerance sets the absolute amount of discrepancy allowed per bank, e.g. 0.25 for a bank taking a fixed fee,
+// replacing the tolerance of one unit of the last decimal place for the bank, banks are matched case-insensitively
+// Banks without a tolerance keep the global one, a percentage tolerance applies when larger
+func WithBankTolerance(tolerances map[string]float64) Option {
+	return func(r *reconciler) {
+		r.bankTolerances = make(map[string]float64, len(tolerances))
+		for bankName, tolerance := range tolerances {
+			r.bankTolerances[strings.ToUpper(bankName)] = tolerance
+		}
+	}
+}
+
+// WithDateGranularity sets the precision the dates must agree on to match, e.g. the same month for monthly statements
+// The default is DateGranularityDay
+func WithDateGranularity(granularity DateGranularity) Option {
+	return func(r *reconciler) {
+		r.dateGranularity = granularity
+	}
+}
+
+// WithReportFiltered reports the number of rows filtered out by the date range per file when reading files
+// A large number of out-of-range rows can indicate a wrong file was supplied
+func WithReportFiltered(reportFiltered bool) Option {
+	return func(r *reconciler) {
+		r.reportFiltered = reportFiltered
+	}
+}
+
+// WithUseSignedAmount treats the sign of the system amount as authoritative instead of the Type,
+// for systems storing debits as negative amounts, negative system amounts are then accepted when reading files
+// A system transaction matches a bank statement with an equal absolute amount and the same sign
+func WithUseSignedAmount(useSignedAmount bool) Option {
+	return func(r *reconciler) {
+		r.useSignedAmount = useSignedAmount
+	}
+}
+
+// WithIgnoreType matches on the absolute amount within the tolerance and the date only, a system transaction
+// matches a bank statement regardless of its type, the bank direction and the amount signs
+// Sign mismatches are not reported since such pairs are matched
+func WithIgnoreType(ignoreType bool) Option {
+	return func(r *reconciler) {
+		r.ignoreType = ignoreType
+	}
+}
+
+// WithConsistentBankSign matches on the absolute amount and date without requiring the type to agree with a fixed
+// sign rule, only that each bank reports the types with consistent signs, for banks with inconsistent conventions
+// The convention of each bank is inferred from the pairs of equal absolute amount and date: a bank whose pairs
+// mostly disagree with the type sign rules or the bank direction is treated as inverted, e.g. reporting CREDIT as
+// negative, and all its statements must then follow the inverted rule, while the other banks follow the rules
+// Unlike WithIgnoreType, a statement with a sign contradicting the convention of its bank does not match,
+// it has no effect together with WithIgnoreType
+func WithConsistentBankSign(consistentBankSign bool) Option {
+	return func(r *reconciler) {
+		r.consistentBankSign = consistentBankSign
+	}
+}
+
+// WithBestMatch scores all unclaimed bank statements passing the matcher for each system transaction and picks
+// the highest scoring one instead of the first, an equal reference wins, then the closest amount and date
+// This improves accuracy when amounts cluster within the tolerance
+func WithBestMatch(bestMatch bool) Option {
+	return func(r *reconciler) {
+		r.bestMatch = bestMatch
+	}
+}
+
+// WithOptimalMatching maximizes the number of matches instead of giving each system transaction the first candidate,
+// which can leave a later transaction without its only candidate, matching is no longer concurrent
+// Each date is solved as a bipartite assignment in time quadratic in the transactions of the date,
+// dates with more than 500 transactions or statements fall back to first-match
+func WithOptimalMatching(optimalMatching bool) Option {
+	return func(r *reconciler) {
+		r.optimalMatching = optimalMatching
+	}
+}
+
+// WithBatchMatching reconciles settlement batches instead of single transactions, the system amounts of each batch ID
+// are summed and compared to the bank settlement lines of the batch within the tolerance of the system total, see Batches
+// The items of a matched batch are matched, items of other batches and without a batch ID are unmatched
+func WithBatchMatching(batchMatching bool) Option {
+	return func(r *reconciler) {
+		r.batchMatching = batchMatching
+	}
+}
+
+// WithSplitMatching matches a system transaction left unmatched to several unmatched bank statements of the same date
+// and direction whose amounts sum to the system amount within the tolerance, e.g. a payment settled in partial lines
+// Up to 4 bank statements are combined, only the first 20 candidates of a date are searched, see SplitMatches
+func WithSplitMatching(splitMatching bool) Option {
+	return func(r *reconciler) {
+		r.splitMatching = splitMatching
+	}
+}
+
+// WithReferenceGrouping matches system transactions sharing a Reference to the bank statement with that Reference
+// when their amounts sum to the bank amount within the tolerance, e.g. a payment recorded in installments settled
+// in one bank line, before the other matching modes and regardless of the dates, see ReferenceGroups
+func WithReferenceGrouping(referenceGrouping bool) Option {
+	return func(r *reconciler) {
+		r.referenceGrouping = referenceGrouping
+	}
+}
+
+// WithHistogramEdges sets the upper edges of the discrepancy histogram buckets, a discrepancy falls in the first bucket
+// whose edge it does not exceed and a last bucket holds the ones above every edge, the edges are sorted
+// The default edges 0, 0.01 and 0.1 are kept when no edge is given
+func WithHistogramEdges(edges ...float64) Option {
+	return func(r *reconciler) {
+		if len(edges) > 0 {
+			r.histogramEdges = append([]float64(nil), edges...)
+			sort.Float64s(r.histogramEdges)
+		}
+	}
+}
+
+// WithBusinessDayWindow matches a bank statement posted on the same day as the system transaction or up to days
+// business days later, skipping weekends and the holidays, e.g. a Friday transaction settled on Monday with 1 day
+// The window replaces the date granularity, and the transactions are matched sequentially against all bank statements
+func WithBusinessDayWindow(days int, holidays []time.Time) Option {
+	return func(r *reconciler) {
+		r.businessDayWindow = days
+		r.holidays = make(map[string]bool, len(holidays))
+		for _, holiday := range holidays {
+			r.holidays[holiday.Format("2006-01-02")] = true
+		}
+	}
+}
+
+// WithCarryforward adds the unmatched items of a previous result to the matching pool, e.g. loaded with LoadJSON,
+// so transactions of yesterday can match today's bank postings despite the settlement lag
+// A pair with a carried item also matches when the bank statement is posted after the system transaction,
+// such pairs are counted in MatchedByCarryforward and listed in CarriedForwardMatches
+// Carried items count as processed and unmatched ones are reported again, they are left out of the control totals
+func WithCarryforward(previous ReconcileResult) Option {
+	return func(r *reconciler) {
+		r.carriedSystem = previous.TransactionUnmatched.SystemUnmatched
+		r.carriedBank = previous.TransactionUnmatched.BankUnmatched
+	}
+}
+
+// WithSystemSource reads system transactions from the source in addition to the system files, e.g. a database table
+// The transactions of the source follow the ones of the files
+func WithSystemSource(source SystemSource) Option {
+	return func(r *reconciler) {
+		r.systemSource = source
+	}
+}
+
+// WithBankSource reads bank statements from the source in addition to the bank files, e.g. a database table
+func WithBankSource(source BankSource) Option {
+	return func(r *reconciler) {
+		r.bankSource = source
+	}
+}
+
+// WithMatcher replaces the built-in matching by amount, date and type with a custom matcher, e.g. matching
+// on a reference or fuzzy amounts, DefaultMatcher gives the built-in matching to build on
+// Custom matchers may match across dates, so the system transactions are matched sequentially against all bank statements
+func WithMatcher(matcher Matcher) Option {
+	return func(r *reconciler) {
+		r.matcher = matcher
+	}
+}
+
+// WithTrace writes for the system transaction with the given TrxID every bank statement it was compared against,
+// why each failed to match, e.g. the amount or date difference or a sign mismatch, and the outcome to the writer
+// Only the given ID is traced, an empty ID disables tracing
+func WithTrace(trxID string, w io.Writer) Option {
+	return func(r *reconciler) {
+		r.traceID = trxID
+		r.traceWriter = w
+	}
+}
+
+// WithRunID records the identifier of the run in the result and its JSON summary, e.g. to correlate outputs and logs
+func WithRunID(id string) Option {
+	return func(r *reconciler) {
+		r.runID = id
+	}
+}
+
+// newReconciler creates a new reconciler with the given options
+func newReconciler(opts ...Option) *reconciler {
+	// Initialize the reconciler with the default sign rules
+	r := &reconciler{
+		typeSignRules:    make(map[types.TransactionType]Sign, len(defaultTypeSignRules)),
+		workers:          1,
+		fileConcurrency:  runtime.NumCPU(),
+		decimalPlaces:    defaultDecimalPlaces,
+		suggestionWindow: defaultSuggestionWindow,
+		retryBackoff:     defaultRetryBackoff,
+		fsys:             OSFS{},
+		histogramEdges:   defaultHistogramEdges,
+	}
+	for txType, sign := range defaultTypeSignRules {
+		r.typeSignRules[txType] = sign
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// Derive the unit factor from the decimal places, the tolerance is one unit
+	r.decimalPlaces = min(max(r.decimalPlaces, 0), maxDecimalPlaces)
+	r.pow10 = math.Pow10(r.decimalPlaces)
+	r.toleranceUnits = 1
+	r.bankToleranceUnits = make(map[string]int64, len(r.bankTolerances))
+	for bankName, tolerance := range r.bankTolerances {
+		r.bankToleranceUnits[bankName] = r.toUnits(math.Abs(tolerance))
+	}
+
+	// Return the reconciler
+	return r
+}
+
+// allows checks if the given amount satisfies the sign
+func (s Sign) allows(amount float64) bool {
+	switch s {
+	case SignPositive:
+		return amount >= 0
+	case SignNegative:
+		return amount <= 0
+	default:
+		return true
+	}
+}
@@ -0,0 +1,71 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ValidationResult is the result of checking the input files without reconciling them
+type ValidationResult struct {
+	// Number of system transactions read within the date range
+	SystemTransactions int
+
+	// Number of bank statements read within the date range
+	BankStatements int
+
+	// Files that cannot be read and the reason why
+	InvalidFiles []SkippedFile
+}
+
+// Valid checks if all input files could be read
+func (v *ValidationResult) Valid() bool {
+	return len(v.InvalidFiles) == 0
+}
+
+// ValidateFiles reads the system transactions and bank statements from the given files like RunFiles
+// without reconciling them, every file that cannot be read is reported instead of failing on the first one
+func ValidateFiles(systemPaths []string, bankPaths []string, start, end time.Time, opts ...Option) ValidationResult {
+	// Create the reconciler with the given options, invalid files are always collected
+	r := newReconciler(append(opts, WithSkipInvalidFiles(true))...)
+	result := ValidationResult{}
+
+	// Read system transactions of each file
+	for _, systemPath := range systemPaths {
+		systemTransactions, _, err := r.readSystemTransactions(systemPath, start, end)
+		if err != nil {
+			result.InvalidFiles = append(result.InvalidFiles, SkippedFile{Filename: systemPath, Reason: err.Error()})
+		}
+		result.SystemTransactions += len(systemTransactions)
+	}
+
+	// Read bank statements, the error is always nil as invalid files are skipped
+	bankStatements, skippedFiles, _, _ := r.readBankStatements(bankPaths, start, end)
+	result.BankStatements = len(bankStatements)
+	result.InvalidFiles = append(result.InvalidFiles, skippedFiles...)
+
+	return result
+}
+
+// WriteSummary writes a human readable summary of the validation result to the given writer
+func (v *ValidationResult) WriteSummary(w io.Writer) error {
+	// Wrap the writer to keep the first write error
+	result := &summaryWriter{w: w}
+
+	// Write the counts
+	result.printf("Validation Result:\n------------------\n")
+	result.printf("System transactions: %d\n", v.SystemTransactions)
+	result.printf("Bank statements: %d\n", v.BankStatements)
+	result.printf("Invalid files: %d\n", len(v.InvalidFiles))
+
+	// Write the invalid files
+	for _, file := range v.InvalidFiles {
+		result.printf("- File: %s, Reason: %s\n", file.Filename, file.Reason)
+	}
+
+	// Return the first write error, if any
+	if result.err != nil {
+		return fmt.Errorf("failed to write validation result: %w", result.err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// objectAPI is the part of the AWS SDK S3 client used by SDKClient, replaced by a mock in tests
+type objectAPI interface {
+	awss3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *awss3.GetObjectInput, optFns ...func(*awss3.Options)) (*awss3.GetObjectOutput, error)
+}
+
+// SDKClient is a Client calling S3 with the AWS SDK for Go
+type SDKClient struct {
+	api objectAPI
+}
+
+// NewSDKClient creates a client calling S3 with the SDK client
+func NewSDKClient(client *awss3.Client) *SDKClient {
+	return &SDKClient{api: client}
+}
+
+// NewClientFromEnv creates a client configured like the AWS CLI, from the environment, the shared config
+// and credentials files, SSO, web identity or the instance role, defaulting the region to us-east-1
+// An S3 compatible endpoint set by AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL is addressed path-style
+func NewClientFromEnv() (*SDKClient, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	pathStyle := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL") != ""
+	return NewSDKClient(awss3.NewFromConfig(cfg, func(o *awss3.Options) {
+		o.UsePathStyle = pathStyle
+	})), nil
+}
+
+// ListObjects returns all objects of the bucket with keys starting with the prefix, following the pages
+func (c *SDKClient) ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	objects := []Object{}
+	paginator := awss3.NewListObjectsV2Paginator(c.api, &awss3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, requestError(bucket, prefix, err)
+		}
+		for _, content := range page.Contents {
+			objects = append(objects, Object{Key: aws.ToString(content.Key), Size: aws.ToInt64(content.Size)})
+		}
+	}
+	return objects, nil
+}
+
+// GetObject returns the content of the object, the caller closes it
+func (c *SDKClient) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	output, err := c.api.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, requestError(bucket, key, err)
+	}
+	return output.Body, nil
+}
+
+// requestError wraps the error of a request for the key, a missing object or bucket is fs.ErrNotExist
+func requestError(bucket, key string, err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchKey", "NoSuchBucket", "NotFound":
+			return fmt.Errorf("s3://%s/%s: %w", bucket, key, fs.ErrNotExist)
+		}
+	}
+	return fmt.Errorf("S3 request for s3://%s/%s failed: %w", bucket, key, err)
+}
+
+// firstEnv returns the value of the first environment variable that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// uriPrefix is the scheme prefix of S3 URIs
+const uriPrefix = "s3://"
+
+// Client lists and downloads S3 objects
+type Client interface {
+	// ListObjects returns all objects of the bucket with keys starting with the prefix, ordered by key
+	ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error)
+
+	// GetObject returns the content of the object, a missing object returns an error wrapping fs.ErrNotExist
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Object is an S3 object listed under a prefix
+type Object struct {
+	// Key of the object in the bucket
+	Key string
+
+	// Size of the object in bytes
+	Size int64
+}
+
+// IsURI checks if the name is an s3://bucket/key URI
+func IsURI(name string) bool {
+	return strings.HasPrefix(name, uriPrefix)
+}
+
+// ParseURI splits an s3://bucket/key URI into its bucket and key, the key is empty for the bucket root
+func ParseURI(uri string) (bucket, key string, err error) {
+	if !IsURI(uri) {
+		return "", "", fmt.Errorf("invalid S3 URI %q", uri)
+	}
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(uri, uriPrefix), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URI %q: missing bucket", uri)
+	}
+	return bucket, key, nil
+}
+
+// FS is a file system reading s3://bucket/key URIs with the client
+// Other names are opened from the fallback file system, so local and S3 files can be mixed
+type FS struct {
+	client   Client
+	fallback fs.FS
+}
+
+// NewFS creates a file system reading S3 URIs with the client and other names from the fallback
+func NewFS(client Client, fallback fs.FS) *FS {
+	return &FS{client: client, fallback: fallback}
+}
+
+// Open downloads the object of the S3 URI, or opens the name from the fallback file system
+func (f *FS) Open(name string) (fs.File, error) {
+	if !IsURI(name) {
+		if f.fallback == nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return f.fallback.Open(name)
+	}
+
+	bucket, key, err := ParseURI(name)
+	if err != nil || key == "" || strings.HasSuffix(key, "/") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	// Download the object, readers need random access for xlsx files
+	body, err := f.client.GetObject(context.Background(), bucket, key)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return &file{Reader: bytes.NewReader(data), info: fileInfo{name: path.Base(key), size: int64(len(data))}}, nil
+}
+
+// Stat returns the file info of the S3 URI, or of the name in the fallback file system
+// A URI ending with a slash or with objects below it is a directory
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !IsURI(name) {
+		if f.fallback == nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+		return fs.Stat(f.fallback, name)
+	}
+
+	bucket, key, err := ParseURI(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return fileInfo{name: path.Base(bucket + "/" + key), dir: true}, nil
+	}
+
+	// Look up the object, or objects below the key as a directory
+	objects, err := f.client.ListObjects(context.Background(), bucket, key)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	for _, object := range objects {
+		if object.Key == key {
+			return fileInfo{name: path.Base(key), size: object.Size}, nil
+		}
+		if strings.HasPrefix(object.Key, key+"/") {
+			return fileInfo{name: path.Base(key), dir: true}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// List returns the URIs of the objects under the S3 URI prefix, ordered by key
+// Without recursive, only the objects directly under the prefix are returned
+func (f *FS) List(uri string, recursive bool) ([]string, error) {
+	bucket, prefix, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	objects, err := f.client.ListObjects(context.Background(), bucket, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", uri, err)
+	}
+
+	uris := []string{}
+	for _, object := range objects {
+		// Skip the directory markers and, unless recursive, the objects in subdirectories
+		name := strings.TrimPrefix(object.Key, prefix)
+		if name == "" || strings.HasSuffix(name, "/") || (!recursive && strings.Contains(name, "/")) {
+			continue
+		}
+		uris = append(uris, uriPrefix+bucket+"/"+object.Key)
+	}
+	return uris, nil
+}
+
+// file is a downloaded S3 object
+type file struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+// Stat returns the file info of the object
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Close releases the object, its content is held in memory
+func (f *file) Close() error {
+	return nil
+}
+
+// fileInfo describes an S3 object or prefix
+type fileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) ModTime() time.Time { return time.Time{} }
+func (i fileInfo) IsDir() bool        { return i.dir }
+func (i fileInfo) Sys() any           { return nil }
+
+// Mode returns read-only permissions, with the directory bit for prefixes
+func (i fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
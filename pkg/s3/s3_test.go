@@ -0,0 +1,250 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockClient is a Client serving objects from memory
+type mockClient struct {
+	objects map[string]map[string]string
+}
+
+// ListObjects returns the objects of the bucket with the prefix, ordered by key
+func (m *mockClient) ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	objects := []Object{}
+	for key, content := range m.objects[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: int64(len(content))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// GetObject returns the content of the object
+func (m *mockClient) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	content, ok := m.objects[bucket][key]
+	if !ok {
+		return nil, fmt.Errorf("s3://%s/%s: %w", bucket, key, fs.ErrNotExist)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// TestFS tests opening, stating and listing S3 objects through the file system
+func TestFS(t *testing.T) {
+	client := &mockClient{objects: map[string]map[string]string{
+		"bucket": {
+			"system.csv":         "TrxID,Amount,Type,TransactionTime\n",
+			"banks/bca.csv":      "UniqueID,Amount,Date\n",
+			"banks/bri.xlsx":     "xlsx",
+			"banks/notes.txt":    "notes",
+			"banks/2024/bni.csv": "UniqueID,Amount,Date\n",
+		},
+	}}
+	fsys := NewFS(client, nil)
+
+	// Open an object
+	file, err := fsys.Open("s3://bucket/system.csv")
+	assert.NoError(t, err)
+	content, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "TrxID,Amount,Type,TransactionTime\n", string(content))
+	info, err := file.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "system.csv", info.Name())
+	assert.Equal(t, int64(34), info.Size())
+	_, ok := file.(io.ReaderAt)
+	assert.True(t, ok)
+	assert.NoError(t, file.Close())
+
+	// Open a missing object
+	_, err = fsys.Open("s3://bucket/missing.csv")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Open a local file without fallback
+	_, err = fsys.Open("system.csv")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Stat an object and prefixes
+	info, err = fs.Stat(fsys, "s3://bucket/banks/bca.csv")
+	assert.NoError(t, err)
+	assert.False(t, info.IsDir())
+	assert.Equal(t, int64(21), info.Size())
+	info, err = fs.Stat(fsys, "s3://bucket/banks")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	info, err = fs.Stat(fsys, "s3://bucket/banks/")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	_, err = fs.Stat(fsys, "s3://bucket/missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// List the objects under a prefix
+	uris, err := fsys.List("s3://bucket/banks/", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/banks/bca.csv", "s3://bucket/banks/bri.xlsx", "s3://bucket/banks/notes.txt"}, uris)
+	uris, err = fsys.List("s3://bucket/banks", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://bucket/banks/2024/bni.csv", "s3://bucket/banks/bca.csv", "s3://bucket/banks/bri.xlsx", "s3://bucket/banks/notes.txt"}, uris)
+
+	// List with an invalid URI
+	_, err = fsys.List("s3:///banks/", false)
+	assert.Error(t, err)
+}
+
+// TestParseURI tests splitting S3 URIs into bucket and key
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri        string
+		wantBucket string
+		wantKey    string
+		wantErr    bool
+	}{
+		{uri: "s3://bucket/banks/bca.csv", wantBucket: "bucket", wantKey: "banks/bca.csv"},
+		{uri: "s3://bucket/banks/", wantBucket: "bucket", wantKey: "banks/"},
+		{uri: "s3://bucket", wantBucket: "bucket"},
+		{uri: "s3:///key", wantErr: true},
+		{uri: "/tmp/bca.csv", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			bucket, key, err := ParseURI(tt.uri)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBucket, bucket)
+			assert.Equal(t, tt.wantKey, key)
+		})
+	}
+}
+
+// mockAPI is an objectAPI listing the keys two per page and serving the objects from memory
+type mockAPI struct {
+	objects map[string]string
+	keys    []string
+}
+
+func (m *mockAPI) ListObjectsV2(ctx context.Context, params *awss3.ListObjectsV2Input, optFns ...func(*awss3.Options)) (*awss3.ListObjectsV2Output, error) {
+	if aws.ToString(params.Bucket) != "bucket" {
+		return nil, &types.NoSuchBucket{}
+	}
+	start, _ := strconv.Atoi(aws.ToString(params.ContinuationToken))
+	end := min(start+2, len(m.keys))
+	output := &awss3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(m.keys))}
+	for _, key := range m.keys[start:end] {
+		output.Contents = append(output.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(m.objects[key])))})
+	}
+	if end < len(m.keys) {
+		output.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (m *mockAPI) GetObject(ctx context.Context, params *awss3.GetObjectInput, optFns ...func(*awss3.Options)) (*awss3.GetObjectOutput, error) {
+	content, ok := m.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &awss3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(content))}, nil
+}
+
+// TestSDKClient tests listing over several pages and downloading objects with the SDK client
+func TestSDKClient(t *testing.T) {
+	client := &SDKClient{api: &mockAPI{
+		objects: map[string]string{"banks/bca.csv": "UniqueID,Amount,Date\n", "banks/bni.csv": "", "banks/bri.csv": "UniqueID\n"},
+		keys:    []string{"banks/bca.csv", "banks/bni.csv", "banks/bri.csv"},
+	}}
+
+	// List the objects over two pages
+	objects, err := client.ListObjects(context.Background(), "bucket", "banks/")
+	assert.NoError(t, err)
+	assert.Equal(t, []Object{{Key: "banks/bca.csv", Size: 21}, {Key: "banks/bni.csv", Size: 0}, {Key: "banks/bri.csv", Size: 9}}, objects)
+
+	// Download an object
+	body, err := client.GetObject(context.Background(), "bucket", "banks/bca.csv")
+	assert.NoError(t, err)
+	content, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	body.Close()
+	assert.Equal(t, "UniqueID,Amount,Date\n", string(content))
+
+	// A missing object or bucket is not found
+	_, err = client.GetObject(context.Background(), "bucket", "banks/missing.csv")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = client.ListObjects(context.Background(), "missing", "banks/")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// TestNewClientFromEnv tests calling an S3 compatible endpoint with the credentials of a shared credentials profile
+func TestNewClientFromEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request is signed with the profile credentials
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=reports-key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// The bucket is addressed path-style
+		switch r.URL.Path {
+		case "/bucket", "/bucket/":
+			assert.Equal(t, "banks/", r.URL.Query().Get("prefix"))
+			fmt.Fprint(w, `<ListBucketResult><Contents><Key>banks/bca.csv</Key><Size>21</Size></Contents>`+
+				`<IsTruncated>false</IsTruncated></ListBucketResult>`)
+		case "/bucket/banks/bca.csv":
+			fmt.Fprint(w, "UniqueID,Amount,Date\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+		}
+	}))
+	defer server.Close()
+
+	// Configure the endpoint and a profile of the shared credentials file only
+	dir := t.TempDir()
+	content := "[default]\naws_access_key_id = default-key\naws_secret_access_key = default-secret\n\n" +
+		"[reports]\naws_access_key_id = reports-key\naws_secret_access_key = reports-secret\n"
+	assert.NoError(t, os.WriteFile(dir+"/credentials", []byte(content), 0o600))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", dir+"/credentials")
+	t.Setenv("AWS_CONFIG_FILE", dir+"/config")
+	t.Setenv("AWS_PROFILE", "reports")
+	t.Setenv("AWS_REGION", "ap-southeast-3")
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	client, err := NewClientFromEnv()
+	assert.NoError(t, err)
+
+	objects, err := client.ListObjects(context.Background(), "bucket", "banks/")
+	assert.NoError(t, err)
+	assert.Equal(t, []Object{{Key: "banks/bca.csv", Size: 21}}, objects)
+
+	body, err := client.GetObject(context.Background(), "bucket", "banks/bca.csv")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	body.Close()
+	assert.Equal(t, "UniqueID,Amount,Date\n", string(data))
+
+	_, err = client.GetObject(context.Background(), "bucket", "banks/missing.csv")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,267 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reconciliation/pkg/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSystemQuery selects the system transactions from a transactions table
+const DefaultSystemQuery = "SELECT TrxID, Amount, Type, TransactionTime FROM transactions"
+
+// DefaultBankQuery selects the bank statements from a bank_statements table
+const DefaultBankQuery = "SELECT BankName, UniqueID, Amount, Date FROM bank_statements"
+
+// dateLayouts are the layouts tried to parse text dates, like the CSV files
+var dateLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05Z07:00", "2006-01-02"}
+
+// Source reads system transactions and bank statements with SQL queries, e.g. from a SQLite file
+type Source struct {
+	db *sql.DB
+
+	// Query returning the TrxID, Amount, Type and TransactionTime columns
+	systemQuery string
+
+	// Query returning the BankName, UniqueID, Amount and Date columns
+	bankQuery string
+
+	// Location text dates are interpreted in, UTC when nil
+	location *time.Location
+
+	// End has a time of day and bounds system transactions by timestamp, otherwise its whole day is included
+	endTimeOfDay bool
+}
+
+// Option is a functional option for the Source
+type Option func(*Source)
+
+// WithSystemQuery sets the query returning the TrxID, Amount, Type and TransactionTime columns
+func WithSystemQuery(query string) Option {
+	return func(s *Source) {
+		s.systemQuery = query
+	}
+}
+
+// WithBankQuery sets the query returning the BankName, UniqueID, Amount and Date columns
+func WithBankQuery(query string) Option {
+	return func(s *Source) {
+		s.bankQuery = query
+	}
+}
+
+// WithLocation interprets text dates in the given location instead of UTC
+func WithLocation(location *time.Location) Option {
+	return func(s *Source) {
+		s.location = location
+	}
+}
+
+// WithEndTimeOfDay compares system transactions with the full end timestamp instead of including its whole day
+func WithEndTimeOfDay(endTimeOfDay bool) Option {
+	return func(s *Source) {
+		s.endTimeOfDay = endTimeOfDay
+	}
+}
+
+// NewSource creates a source querying the database, the caller keeps ownership of the database
+func NewSource(db *sql.DB, opts ...Option) *Source {
+	// Initialize the Source with the default queries
+	s := &Source{
+		db:          db,
+		systemQuery: DefaultSystemQuery,
+		bankQuery:   DefaultBankQuery,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Return the Source
+	return s
+}
+
+// ReadSystemTransactions runs the system query and returns the transactions within the date range
+func (s *Source) ReadSystemTransactions(start, end time.Time) ([]types.Transaction, error) {
+	transactions := []types.Transaction{}
+	err := s.query(s.systemQuery, 4, func(row int, values []any) error {
+		// Parse the amount
+		amount, err := parseAmount(values[1])
+		if err != nil {
+			return fmt.Errorf("invalid amount [%v] in row %d", values[1], row)
+		}
+
+		// Parse the transaction time
+		date, err := s.parseTime(values[3])
+		if err != nil {
+			return fmt.Errorf("invalid date [%v] in row %d", values[3], row)
+		}
+
+		// Skip if outside the date range
+		if !inRange(date, start, end, s.endTimeOfDay) {
+			return nil
+		}
+
+		transactions = append(transactions, types.Transaction{
+			TrxID:           text(values[0]),
+			Amount:          amount,
+			Type:            types.TransactionType(strings.ToUpper(text(values[2]))),
+			TransactionTime: date,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// ReadBankStatements runs the bank query and returns the statements within the date range
+// Dates are truncated to the day and bank names upper-cased, like bank names taken from filenames
+func (s *Source) ReadBankStatements(start, end time.Time) ([]types.BankStatement, error) {
+	statements := []types.BankStatement{}
+	err := s.query(s.bankQuery, 4, func(row int, values []any) error {
+		// Parse the amount
+		amount, err := parseAmount(values[2])
+		if err != nil {
+			return fmt.Errorf("invalid amount [%v] in row %d", values[2], row)
+		}
+
+		// Parse the date and truncate it to the day
+		date, err := s.parseTime(values[3])
+		if err != nil {
+			return fmt.Errorf("invalid date [%v] in row %d", values[3], row)
+		}
+		date = day(date)
+
+		// Skip if outside the date range
+		if !inRange(date, day(start), day(end), false) {
+			return nil
+		}
+
+		statements = append(statements, types.BankStatement{
+			BankName: strings.ToUpper(text(values[0])),
+			UniqueID: text(values[1]),
+			Amount:   amount,
+			Date:     date,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bank statements: %w", err)
+	}
+	return statements, nil
+}
+
+// query runs the query and calls the function with the values of each row, numbered from 1
+func (s *Source) query(query string, columns int, fn func(row int, values []any) error) error {
+	rows, err := s.db.QueryContext(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Check the number of columns once
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if len(names) != columns {
+		return fmt.Errorf("expected %d columns but got %d (%s)", columns, len(names), strings.Join(names, ","))
+	}
+
+	// Scan the values of each row
+	values := make([]any, columns)
+	pointers := make([]any, columns)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for row := 1; rows.Next(); row++ {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		if err := fn(row, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// parseTime parses a date stored as a time, a text in one of the date layouts or a Unix timestamp
+func (s *Source) parseTime(value any) (time.Time, error) {
+	location := s.location
+	if location == nil {
+		location = time.UTC
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.In(location), nil
+	case int64:
+		return time.Unix(v, 0).In(location), nil
+	case string, []byte:
+		var err error
+		for _, layout := range dateLayouts {
+			var date time.Time
+			date, err = time.ParseInLocation(layout, text(v), location)
+			if err == nil {
+				return date, nil
+			}
+		}
+		return time.Time{}, err
+	default:
+		return time.Time{}, fmt.Errorf("unsupported date type %T", value)
+	}
+}
+
+// parseAmount parses an amount stored as a number or a text
+func parseAmount(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string, []byte:
+		return strconv.ParseFloat(strings.TrimSpace(text(v)), 64)
+	default:
+		return 0, fmt.Errorf("unsupported amount type %T", value)
+	}
+}
+
+// text converts a text or number value to a trimmed string, NULL becomes an empty string
+func text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// inRange checks if the date is within the range like the CSV files, a zero range accepts any date
+// An end with a time of day is compared with the full timestamp, a date-only end includes its whole day
+func inRange(date, start, end time.Time, endTimeOfDay bool) bool {
+	if start.IsZero() || end.IsZero() {
+		return true
+	}
+	if date.Before(start) {
+		return false
+	}
+	if endTimeOfDay {
+		return !date.After(end)
+	}
+	return !day(date).After(day(end))
+}
+
+// day returns midnight of the day of the date in its own location
+func day(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
@@ -0,0 +1,86 @@
+package sqldb
+
+import (
+	"database/sql"
+	"reconciliation/pkg/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// openTestDB opens an in-memory SQLite database and runs the statements
+func openTestDB(t *testing.T, statements ...string) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// Each connection to :memory: opens its own database, keep a single one
+	db.SetMaxOpenConns(1)
+	for _, statement := range statements {
+		_, err := db.Exec(statement)
+		assert.NoError(t, err, statement)
+	}
+	return db
+}
+
+// TestSource tests reading system transactions and bank statements with queries
+func TestSource(t *testing.T) {
+	db := openTestDB(t,
+		`CREATE TABLE transactions (TrxID TEXT, Amount NUMERIC, Type TEXT, TransactionTime DATETIME)`,
+		`INSERT INTO transactions VALUES
+			('TX001', 100.5, 'credit', '2024-01-01 10:00:00'),
+			(CAST('TX002' AS BLOB), 50, 'DEBIT', '2024-01-02T09:00:00Z'),
+			('TX003', '75.25', 'DEBIT', '2024-02-01 10:00:00')`,
+		`CREATE TABLE bank_statements (BankName TEXT, UniqueID TEXT, Amount TEXT, Date TEXT)`,
+		`INSERT INTO bank_statements VALUES ('bca', 'BS001', '100.5', '2024-01-01'), ('bca', 'BS002', '-50', '2024-01-02 23:00:00')`,
+		`CREATE TABLE epochs (TrxID TEXT, Amount REAL, Type TEXT, TransactionTime INTEGER)`,
+		`INSERT INTO epochs VALUES ('TX004', 10, 'DEBIT', 1704103200)`,
+		`CREATE TABLE invalid (TrxID TEXT, Amount TEXT, Type TEXT, TransactionTime TEXT)`,
+		`INSERT INTO invalid VALUES ('TX001', 'abc', 'DEBIT', '2024-01-01 10:00:00')`,
+	)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	source := NewSource(db)
+
+	// Rows outside the date range are skipped, numbers, blobs and texts are converted
+	transactions, err := source.ReadSystemTransactions(start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Transaction{
+		{TrxID: "TX001", Amount: 100.5, Type: types.TransactionTypeCredit, TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{TrxID: "TX002", Amount: 50, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+	}, transactions)
+
+	// An explicit midnight end excludes the rest of its day, a date-only end includes it
+	midnight := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	transactions, err = NewSource(db, WithEndTimeOfDay(true)).ReadSystemTransactions(start, midnight)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	transactions, err = source.ReadSystemTransactions(start, midnight)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+
+	// Integer dates are Unix timestamps
+	transactions, err = NewSource(db, WithSystemQuery("SELECT * FROM epochs")).ReadSystemTransactions(start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Transaction{
+		{TrxID: "TX004", Amount: 10, Type: types.TransactionTypeDebit, TransactionTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+	}, transactions)
+
+	// Bank dates are truncated to the day and bank names upper-cased
+	statements, err := source.ReadBankStatements(start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BankStatement{
+		{BankName: "BCA", UniqueID: "BS001", Amount: 100.5, Date: start},
+		{BankName: "BCA", UniqueID: "BS002", Amount: -50, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}, statements)
+
+	// Queries with the wrong columns or values fail
+	_, err = NewSource(db, WithSystemQuery("SELECT TrxID, Amount FROM transactions")).ReadSystemTransactions(start, end)
+	assert.EqualError(t, err, "failed to query system transactions: expected 4 columns but got 2 (TrxID,Amount)")
+	_, err = NewSource(db, WithSystemQuery("SELECT * FROM invalid")).ReadSystemTransactions(start, end)
+	assert.EqualError(t, err, "failed to query system transactions: invalid amount [abc] in row 1")
+	_, err = NewSource(db, WithBankQuery("SELECT * FROM missing")).ReadBankStatements(start, end)
+	assert.ErrorContains(t, err, "failed to query bank statements: SQL logic error: no such table: missing")
+}
@@ -27,6 +27,18 @@ type Transaction struct {
 	// Date and time of the transaction
 	// Assume the format is YYYY-MM-DD HH:MM:SS
 	TransactionTime time.Time
+
+	// Settlement batch ID, read from an optional batchid column
+	// Empty when the system does not settle in batches
+	BatchID string `json:",omitempty"`
+
+	// ISO 4217 currency code, e.g. IDR or USD, read from an optional currency column
+	// Empty when the system does not report a currency
+	Currency string `json:",omitempty"`
+
+	// Payment reference shared by the installments of a payment, read from an optional reference column
+	// Empty when the system does not record references
+	Reference string `json:",omitempty"`
 }
 
 // BankStatement is a bank statement
@@ -43,6 +55,40 @@ type BankStatement struct {
 	Amount float64
 
 	// Date of the transaction
+	// Assume the format is YYYY-MM-DD, a time component is truncated to the day
+	Date time.Time
+
+	// Direction of the transaction, parsed from an optional D/C column
+	// Empty when the bank uses the amount sign to indicate the direction
+	Direction TransactionType
+
+	// Settlement batch ID of the settlement line, read from an optional batchid column
+	// Empty when the bank does not report batches
+	BatchID string `json:",omitempty"`
+
+	// ISO 4217 currency code, e.g. IDR or USD, read from an optional currency column
+	// Empty when the bank does not report a currency
+	Currency string `json:",omitempty"`
+
+	// Payment reference of the bank line, read from an optional reference column
+	// Empty when the bank does not report references
+	Reference string `json:",omitempty"`
+}
+
+// LedgerEntry is an entry of the internal ledger, reconciled three-way against the system and the bank
+type LedgerEntry struct {
+	// Unique identifier for the ledger entry
+	EntryID string
+
+	// Entry amount, signed like the bank statement amounts
+	// Assume the format is 1234.56
+	Amount float64
+
+	// Date of the entry
 	// Assume the format is YYYY-MM-DD
 	Date time.Time
+
+	// Direction of the entry
+	// Empty when the amount sign indicates the direction
+	Direction TransactionType
 }